@@ -0,0 +1,127 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/loadimpact/k6/api/common"
+	"github.com/loadimpact/k6/stats"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// Baseline is a saved snapshot of the metrics, with the number of metrics in it.
+type Baseline struct {
+	Name    string `json:"name"`
+	Metrics int    `json:"metrics"`
+}
+
+// BaselineValue is a value of a metric in a baseline and now, with the change in percent, which
+// is null if the value didn't exist in the baseline or was 0 there.
+type BaselineValue struct {
+	Baseline null.Float `json:"baseline"`
+	Current  float64    `json:"current"`
+	Change   null.Float `json:"change"`
+}
+
+// BaselineDiff is the comparison of the values of a metric with the ones in a baseline.
+type BaselineDiff struct {
+	Metric string                   `json:"metric"`
+	Values map[string]BaselineValue `json:"values"`
+}
+
+// HandleSaveBaseline saves a snapshot of the current metrics as a named baseline. Like the
+// health endpoint, it doesn't use JSON API.
+func HandleSaveBaseline(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	engine := common.GetEngine(r.Context())
+
+	baseline := engine.SaveMetricsBaseline(p.ByName("name"))
+	data, err := json.Marshal(Baseline{Name: baseline.Name, Metrics: len(baseline.Values)})
+	if err != nil {
+		apiError(rw, "Encoding error", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusCreated)
+	_, _ = rw.Write(data)
+}
+
+// HandleGetBaselineDiff compares the current metrics with a named baseline, sorted by metric name.
+func HandleGetBaselineDiff(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	engine := common.GetEngine(r.Context())
+
+	baseline, ok := engine.GetMetricsBaseline(p.ByName("name"))
+	if !ok {
+		apiError(rw, "Not Found", "No baseline with that name was saved", http.StatusNotFound)
+		return
+	}
+
+	var t time.Duration
+	if engine.Executor != nil {
+		t = engine.Executor.GetTime()
+	}
+
+	data, err := json.Marshal(diffMetrics(baseline.Values, engine.GetMetricsSnapshot(), t))
+	if err != nil {
+		apiError(rw, "Encoding error", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	_, _ = rw.Write(data)
+}
+
+// HandleDeleteBaseline deletes a named baseline.
+func HandleDeleteBaseline(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	engine := common.GetEngine(r.Context())
+
+	if !engine.DeleteMetricsBaseline(p.ByName("name")) {
+		apiError(rw, "Not Found", "No baseline with that name was saved", http.StatusNotFound)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// diffMetrics compares the values of the current metrics with the ones in the baseline.
+func diffMetrics(
+	baseline map[string]map[string]float64, current map[string]*stats.Metric, t time.Duration,
+) []BaselineDiff {
+	diffs := make([]BaselineDiff, 0, len(current))
+	for name, m := range current {
+		baselineValues := baseline[name]
+		values := make(map[string]BaselineValue)
+		for key, value := range m.Sink.Format(t) {
+			v := BaselineValue{Current: value}
+			if bv, ok := baselineValues[key]; ok {
+				v.Baseline = null.FloatFrom(bv)
+				if bv != 0 {
+					v.Change = null.FloatFrom((value - bv) / bv * 100)
+				}
+			}
+			values[key] = v
+		}
+		diffs = append(diffs, BaselineDiff{Metric: name, Values: values})
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Metric < diffs[j].Metric })
+	return diffs
+}
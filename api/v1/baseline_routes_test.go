@@ -0,0 +1,98 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/loadimpact/k6/core"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestBaselines(t *testing.T) {
+	engine, err := core.NewEngine(nil, lib.Options{})
+	require.NoError(t, err)
+	engine.Metrics = map[string]*stats.Metric{
+		"my_trend": stats.New("my_trend", stats.Trend, stats.Time),
+	}
+	engine.Metrics["my_trend"].Sink.Add(stats.Sample{Value: 100})
+
+	t.Run("not found", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, "GET", "/v1/baselines/before/diff", nil))
+		assert.Equal(t, http.StatusNotFound, rw.Result().StatusCode)
+	})
+
+	rw := httptest.NewRecorder()
+	NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, "POST", "/v1/baselines/before", nil))
+	assert.Equal(t, http.StatusCreated, rw.Result().StatusCode)
+	assert.JSONEq(t, `{"name":"before","metrics":1}`, rw.Body.String())
+
+	engine.Metrics["my_trend"].Sink.Add(stats.Sample{Value: 200})
+	engine.Metrics["my_counter"] = stats.New("my_counter", stats.Counter)
+	engine.Metrics["my_counter"].Sink.Add(stats.Sample{Value: 1})
+
+	rw = httptest.NewRecorder()
+	NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, "GET", "/v1/baselines/before/diff", nil))
+	require.Equal(t, http.StatusOK, rw.Result().StatusCode)
+	assert.Equal(t, "application/json", rw.Result().Header.Get("Content-Type"))
+
+	var diffs []BaselineDiff
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &diffs))
+	require.Len(t, diffs, 2)
+	assert.Equal(t, "my_counter", diffs[0].Metric)
+	assert.False(t, diffs[0].Values["count"].Baseline.Valid)
+	assert.False(t, diffs[0].Values["count"].Change.Valid)
+	assert.Equal(t, float64(1), diffs[0].Values["count"].Current)
+
+	assert.Equal(t, "my_trend", diffs[1].Metric)
+	assert.Equal(t, BaselineValue{
+		Baseline: null.FloatFrom(100),
+		Current:  200,
+		Change:   null.FloatFrom(100),
+	}, diffs[1].Values["max"])
+	assert.Equal(t, BaselineValue{
+		Baseline: null.FloatFrom(100),
+		Current:  150,
+		Change:   null.FloatFrom(50),
+	}, diffs[1].Values["avg"])
+
+	// The baseline isn't affected by the later samples.
+	baseline, ok := engine.GetMetricsBaseline("before")
+	require.True(t, ok)
+	assert.Equal(t, 100.0, baseline.Values["my_trend"]["max"])
+
+	rw = httptest.NewRecorder()
+	NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, "DELETE", "/v1/baselines/before", nil))
+	assert.Equal(t, http.StatusNoContent, rw.Result().StatusCode)
+	_, ok = engine.GetMetricsBaseline("before")
+	assert.False(t, ok)
+
+	rw = httptest.NewRecorder()
+	NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, "DELETE", "/v1/baselines/before", nil))
+	assert.Equal(t, http.StatusNotFound, rw.Result().StatusCode)
+}
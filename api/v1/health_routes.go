@@ -0,0 +1,54 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/loadimpact/k6/api/common"
+)
+
+// Health is the minimal status returned by the health endpoint.
+type Health struct {
+	// "running" while the test is running, "ready" before it starts and after it ends.
+	Status string `json:"status"`
+	VUs    int64  `json:"vus"`
+}
+
+// HandleGetHealth is a cheap, side-effect-free endpoint meant for liveness and readiness
+// probes. Unlike the status endpoint, it doesn't use JSON API and doesn't lock anything.
+func HandleGetHealth(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	engine := common.GetEngine(r.Context())
+
+	health := Health{Status: "ready", VUs: engine.Executor.GetVUs()}
+	if engine.Executor.IsRunning() {
+		health.Status = "running"
+	}
+	data, err := json.Marshal(health)
+	if err != nil {
+		apiError(rw, "Encoding error", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	_, _ = rw.Write(data)
+}
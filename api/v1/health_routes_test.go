@@ -0,0 +1,56 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/loadimpact/k6/core"
+	"github.com/loadimpact/k6/lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestGetHealth(t *testing.T) {
+	engine, err := core.NewEngine(nil, lib.Options{})
+	require.NoError(t, err)
+	require.NoError(t, engine.Executor.SetVUsMax(5))
+	require.NoError(t, engine.Executor.SetVUs(3))
+
+	rw := httptest.NewRecorder()
+	NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, "GET", "/v1/health", nil))
+	res := rw.Result()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+	var health Health
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &health))
+	assert.Equal(t, Health{Status: "ready", VUs: 3}, health)
+	assert.JSONEq(t, `{"status":"ready","vus":3}`, rw.Body.String())
+
+	// The health check shouldn't change anything.
+	assert.Equal(t, null.IntFrom(3), NewStatus(engine).VUs)
+	assert.False(t, engine.Executor.IsPaused())
+}
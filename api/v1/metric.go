@@ -78,16 +78,25 @@ type Metric struct {
 	Contains NullValueType  `json:"contains" yaml:"contains"`
 	Tainted  null.Bool      `json:"tainted" yaml:"tainted"`
 
+	// Approximate is true when some of the sample values are estimated, e.g. the
+	// percentiles of trend metrics with reservoir sampling enabled.
+	Approximate bool `json:"approximate" yaml:"approximate"`
+
 	Sample map[string]float64 `json:"sample" yaml:"sample"`
 }
 
 func NewMetric(m *stats.Metric, t time.Duration) Metric {
+	var approximate bool
+	if sink, ok := m.Sink.(*stats.TrendSink); ok {
+		approximate = sink.IsApproximate()
+	}
 	return Metric{
-		Name:     m.Name,
-		Type:     NullMetricType{m.Type, true},
-		Contains: NullValueType{m.Contains, true},
-		Tainted:  m.Tainted,
-		Sample:   m.Sink.Format(t),
+		Name:        m.Name,
+		Type:        NullMetricType{m.Type, true},
+		Contains:    NullValueType{m.Contains, true},
+		Tainted:     m.Tainted,
+		Approximate: approximate,
+		Sample:      m.Sink.Format(t),
 	}
 }
 
@@ -21,7 +21,11 @@
 package v1
 
 import (
+	"bytes"
+	"encoding/json"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/julienschmidt/httprouter"
@@ -38,10 +42,15 @@ func HandleGetMetrics(rw http.ResponseWriter, r *http.Request, p httprouter.Para
 	}
 
 	metrics := make([]Metric, 0)
-	for _, m := range engine.Metrics {
+	for _, m := range engine.GetMetricsSnapshot() {
 		metrics = append(metrics, NewMetric(m, t))
 	}
 
+	if wantsJSONLines(r) {
+		writeMetricsJSONLines(rw, metrics)
+		return
+	}
+
 	data, err := jsonapi.Marshal(metrics)
 	if err != nil {
 		apiError(rw, "Encoding error", err.Error(), http.StatusInternalServerError)
@@ -50,8 +59,9 @@ func HandleGetMetrics(rw http.ResponseWriter, r *http.Request, p httprouter.Para
 	_, _ = rw.Write(data)
 }
 
-func HandleGetMetric(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
-	id := p.ByName("id")
+// HandleGetRegisteredMetrics lists all of the metrics the test may emit, including the built-in
+// ones and the custom ones declared by the script that don't have any samples yet.
+func HandleGetRegisteredMetrics(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	engine := common.GetEngine(r.Context())
 
 	var t time.Duration
@@ -59,25 +69,78 @@ func HandleGetMetric(rw http.ResponseWriter, r *http.Request, p httprouter.Param
 		t = engine.Executor.GetTime()
 	}
 
-	var metric Metric
-	var found bool
-	for _, m := range engine.Metrics {
-		if m.Name == id {
-			metric = NewMetric(m, t)
-			found = true
-			break
-		}
+	registered := engine.GetRegisteredMetrics()
+	metrics := make([]Metric, 0, len(registered))
+	for _, m := range registered {
+		metrics = append(metrics, NewMetric(m, t))
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+
+	if wantsJSONLines(r) {
+		writeMetricsJSONLines(rw, metrics)
+		return
 	}
 
-	if !found {
+	data, err := jsonapi.Marshal(metrics)
+	if err != nil {
+		apiError(rw, "Encoding error", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = rw.Write(data)
+}
+
+func HandleGetMetric(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	id := p.ByName("id")
+	engine := common.GetEngine(r.Context())
+
+	var t time.Duration
+	if engine.Executor != nil {
+		t = engine.Executor.GetTime()
+	}
+
+	m, ok := engine.GetMetricsSnapshot()[id]
+	if !ok {
 		apiError(rw, "Not Found", "No metric with that ID was found", http.StatusNotFound)
 		return
 	}
 
-	data, err := jsonapi.Marshal(metric)
+	data, err := jsonapi.Marshal(NewMetric(m, t))
 	if err != nil {
 		apiError(rw, "Encoding error", err.Error(), http.StatusInternalServerError)
 		return
 	}
 	_, _ = rw.Write(data)
 }
+
+// jsonLinesMetric is a metric in the JSON Lines format, which includes its name, since there's
+// no JSON API document around it.
+type jsonLinesMetric struct {
+	Name string `json:"name"`
+	Metric
+}
+
+// wantsJSONLines returns whether the metrics were requested in the JSON Lines format, either
+// with the format=ndjson query parameter or with an application/x-ndjson Accept header.
+func wantsJSONLines(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "ndjson" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/x-ndjson")
+}
+
+// writeMetricsJSONLines writes one metric object per line, sorted by name, so that the
+// output is easy to grep and diff.
+func writeMetricsJSONLines(rw http.ResponseWriter, metrics []Metric) {
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, m := range metrics {
+		if err := encoder.Encode(jsonLinesMetric{Name: m.Name, Metric: m}); err != nil {
+			apiError(rw, "Encoding error", err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	rw.Header().Set("Content-Type", "application/x-ndjson")
+	_, _ = buf.WriteTo(rw)
+}
@@ -24,6 +24,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/loadimpact/k6/core"
@@ -73,6 +74,53 @@ func TestGetMetrics(t *testing.T) {
 	})
 }
 
+func TestGetMetricsJSONLines(t *testing.T) {
+	engine, err := core.NewEngine(nil, lib.Options{})
+	assert.NoError(t, err)
+
+	engine.Metrics = map[string]*stats.Metric{
+		"b_metric": stats.New("b_metric", stats.Gauge),
+		"a_metric": stats.New("a_metric", stats.Trend, stats.Time),
+	}
+
+	requests := map[string]*http.Request{
+		"query": newRequestWithEngine(engine, "GET", "/v1/metrics?format=ndjson", nil),
+		"accept": func() *http.Request {
+			r := newRequestWithEngine(engine, "GET", "/v1/metrics", nil)
+			r.Header.Set("Accept", "application/x-ndjson")
+			return r
+		}(),
+	}
+	for name, r := range requests {
+		t.Run(name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			NewHandler().ServeHTTP(rw, r)
+			res := rw.Result()
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, "application/x-ndjson", res.Header.Get("Content-Type"))
+
+			lines := strings.Split(strings.TrimSuffix(rw.Body.String(), "\n"), "\n")
+			if !assert.Len(t, lines, 2) {
+				return
+			}
+			names := []string{}
+			for _, line := range lines {
+				var m jsonLinesMetric
+				assert.NoError(t, json.Unmarshal([]byte(line), &m))
+				assert.True(t, m.Type.Valid)
+				names = append(names, m.Name)
+			}
+			assert.Equal(t, []string{"a_metric", "b_metric"}, names)
+
+			var first jsonLinesMetric
+			assert.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+			assert.Equal(t, stats.Trend, first.Type.Type)
+			assert.Equal(t, stats.Time, first.Contains.Type)
+			assert.Contains(t, first.Sample, "avg")
+		})
+	}
+}
+
 func TestGetMetric(t *testing.T) {
 	engine, err := core.NewEngine(nil, lib.Options{})
 	assert.NoError(t, err)
@@ -117,3 +165,33 @@ func TestGetMetric(t *testing.T) {
 		})
 	})
 }
+
+func TestGetRegisteredMetrics(t *testing.T) {
+	engine, err := core.NewEngine(nil, lib.Options{})
+	assert.NoError(t, err)
+
+	engine.Metrics = map[string]*stats.Metric{
+		"my_metric": stats.New("my_metric", stats.Trend, stats.Time),
+	}
+
+	rw := httptest.NewRecorder()
+	NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, "GET", "/v1/registered-metrics", nil))
+	res := rw.Result()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	var metrics []Metric
+	assert.NoError(t, jsonapi.Unmarshal(rw.Body.Bytes(), &metrics))
+	byName := map[string]Metric{}
+	for _, m := range metrics {
+		byName[m.Name] = m
+	}
+	assert.Contains(t, byName, "my_metric")
+	if assert.Contains(t, byName, "http_reqs") {
+		assert.Equal(t, stats.Counter, byName["http_reqs"].Type.Type)
+		assert.Equal(t, stats.Default, byName["http_reqs"].Contains.Type)
+	}
+	if assert.Contains(t, byName, "iteration_duration") {
+		assert.Equal(t, stats.Trend, byName["iteration_duration"].Type.Type)
+		assert.Equal(t, stats.Time, byName["iteration_duration"].Contains.Type)
+	}
+}
@@ -0,0 +1,56 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/loadimpact/k6/api/common"
+)
+
+// OutputFlush is the result of flushing one output, with the error if it couldn't be flushed.
+type OutputFlush struct {
+	Output string `json:"output"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HandleFlushOutputs makes all of the outputs that support it deliver their buffered samples
+// right away, and returns which ones were flushed. Like the health endpoint, it doesn't use JSON API.
+func HandleFlushOutputs(rw http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	engine := common.GetEngine(r.Context())
+
+	flushes := make([]OutputFlush, 0)
+	for _, flush := range engine.FlushOutputs() {
+		result := OutputFlush{Output: flush.Output}
+		if flush.Err != nil {
+			result.Error = flush.Err.Error()
+		}
+		flushes = append(flushes, result)
+	}
+	data, err := json.Marshal(flushes)
+	if err != nil {
+		apiError(rw, "Encoding error", err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	_, _ = rw.Write(data)
+}
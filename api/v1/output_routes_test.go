@@ -0,0 +1,67 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/loadimpact/k6/core"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats/dummy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type flushingCollector struct {
+	dummy.Collector
+	err error
+}
+
+func (c *flushingCollector) Flush() error { return c.err }
+
+func TestFlushOutputs(t *testing.T) {
+	engine, err := core.NewEngine(nil, lib.Options{})
+	require.NoError(t, err)
+
+	t.Run("none", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, "POST", "/v1/outputs/flush", nil))
+		assert.Equal(t, http.StatusOK, rw.Result().StatusCode)
+		assert.JSONEq(t, `[]`, rw.Body.String())
+	})
+
+	t.Run("flushed", func(t *testing.T) {
+		engine.SetFlushCollectors(map[string]lib.FlushingCollector{
+			"influxdb": &flushingCollector{},
+			"kafka":    &flushingCollector{err: errors.New("broker unreachable")},
+		})
+		rw := httptest.NewRecorder()
+		NewHandler().ServeHTTP(rw, newRequestWithEngine(engine, "POST", "/v1/outputs/flush", nil))
+		res := rw.Result()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+		assert.JSONEq(t,
+			`[{"output":"influxdb"},{"output":"kafka","error":"broker unreachable"}]`,
+			rw.Body.String())
+	})
+}
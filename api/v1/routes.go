@@ -29,11 +29,18 @@ import (
 func NewHandler() http.Handler {
 	router := httprouter.New()
 
+	router.GET("/v1/health", HandleGetHealth)
+
 	router.GET("/v1/status", HandleGetStatus)
 	router.PATCH("/v1/status", HandlePatchStatus)
 
 	router.GET("/v1/metrics", HandleGetMetrics)
 	router.GET("/v1/metrics/:id", HandleGetMetric)
+	router.GET("/v1/registered-metrics", HandleGetRegisteredMetrics)
+
+	router.POST("/v1/baselines/:name", HandleSaveBaseline)
+	router.GET("/v1/baselines/:name/diff", HandleGetBaselineDiff)
+	router.DELETE("/v1/baselines/:name", HandleDeleteBaseline)
 
 	router.GET("/v1/groups", HandleGetGroups)
 	router.GET("/v1/groups/:id", HandleGetGroup)
@@ -44,5 +51,7 @@ func NewHandler() http.Handler {
 
 	router.POST("/v1/teardown", HandleRunTeardown)
 
+	router.POST("/v1/outputs/flush", HandleFlushOutputs)
+
 	return router
 }
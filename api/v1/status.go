@@ -31,17 +31,21 @@ type Status struct {
 	VUsMax null.Int  `json:"vus-max" yaml:"vus-max"`
 
 	// Readonly.
-	Running bool `json:"running" yaml:"running"`
-	Tainted bool `json:"tainted" yaml:"tainted"`
+	Running        bool     `json:"running" yaml:"running"`
+	Tainted        bool     `json:"tainted" yaml:"tainted"`
+	OutputsFlushed bool     `json:"outputs-flushed" yaml:"outputs-flushed"`
+	FailedOutputs  []string `json:"failed-outputs,omitempty" yaml:"failed-outputs,omitempty"`
 }
 
 func NewStatus(engine *core.Engine) Status {
 	return Status{
-		Paused:  null.BoolFrom(engine.Executor.IsPaused()),
-		VUs:     null.IntFrom(engine.Executor.GetVUs()),
-		VUsMax:  null.IntFrom(engine.Executor.GetVUsMax()),
-		Running: engine.Executor.IsRunning(),
-		Tainted: engine.IsTainted(),
+		Paused:         null.BoolFrom(engine.Executor.IsPaused()),
+		VUs:            null.IntFrom(engine.Executor.GetVUs()),
+		VUsMax:         null.IntFrom(engine.Executor.GetVUsMax()),
+		Running:        engine.Executor.IsRunning(),
+		Tainted:        engine.IsTainted(),
+		OutputsFlushed: engine.OutputsFlushed(),
+		FailedOutputs:  engine.FailedOutputs(),
 	}
 }
 
@@ -59,6 +59,7 @@ func TestGetStatus(t *testing.T) {
 		assert.True(t, status.VUs.Valid)
 		assert.True(t, status.VUsMax.Valid)
 		assert.False(t, status.Tainted)
+		assert.False(t, status.OutputsFlushed)
 	})
 }
 
@@ -58,12 +58,13 @@ This will execute the test on the Load Impact cloud service. Use "k6 login cloud
 	Args: exactArgsWithMsg(1, "arg should either be \"-\", if reading script from stdin, or a path to a script file"),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		//TODO: disable in quiet mode?
-		_, _ = BannerColor.Fprintf(stdout, "\n%s\n\n", consts.Banner)
+		initOut := textOutput()
+		_, _ = BannerColor.Fprintf(initOut, "\n%s\n\n", consts.Banner)
 		initBar := ui.ProgressBar{
-			Width: 60,
+			Width: progressWidth,
 			Left:  func() string { return "    uploading script" },
 		}
-		fprintf(stdout, "%s \r", initBar.String())
+		fprintf(initOut, "%s \r", initBar.String())
 
 		// Runner
 		pwd, err := os.Getwd()
@@ -72,13 +73,14 @@ This will execute the test on the Load Impact cloud service. Use "k6 login cloud
 		}
 
 		filename := args[0]
-		filesystems := loader.CreateFilesystems()
-		src, err := loader.ReadSource(filename, pwd, filesystems, os.Stdin)
+		runtimeOptions, err := getRuntimeOptions(cmd.Flags())
 		if err != nil {
 			return err
 		}
 
-		runtimeOptions, err := getRuntimeOptions(cmd.Flags())
+		configureLoader(runtimeOptions)
+		filesystems := loader.CreateFilesystems()
+		src, err := loader.ReadSource(filename, pwd, filesystems, os.Stdin)
 		if err != nil {
 			return err
 		}
@@ -92,7 +94,9 @@ This will execute the test on the Load Impact cloud service. Use "k6 login cloud
 		if err != nil {
 			return err
 		}
-		conf, err := getConsolidatedConfig(afero.NewOsFs(), Config{Options: cliOpts}, r)
+		cliConf := Config{Options: cliOpts}
+		cliConf.Collectors.Cloud.ConfigFile = getNullString(cmd.Flags(), "cloud-config-file")
+		conf, err := getConsolidatedConfig(afero.NewOsFs(), cliConf, r)
 		if err != nil {
 			return err
 		}
@@ -112,6 +116,9 @@ This will execute the test on the Load Impact cloud service. Use "k6 login cloud
 		if err := envconfig.Process("k6", &cloudConfig); err != nil {
 			return err
 		}
+		if err := cloud.LoadTokenFile(&cloudConfig); err != nil {
+			return err
+		}
 		if !cloudConfig.Token.Valid {
 			return errors.New("Not logged in, please use `k6 login cloud`.")
 		}
@@ -175,11 +182,19 @@ This will execute the test on the Load Impact cloud service. Use "k6 login cloud
 		}
 
 		testURL := cloud.URLForResults(refID, cloudConfig)
-		fprintf(stdout, "\n\n")
-		fprintf(stdout, "     execution: %s\n", ui.ValueColor.Sprint("cloud"))
-		fprintf(stdout, "     script: %s\n", ui.ValueColor.Sprint(filename))
-		fprintf(stdout, "     output: %s\n", ui.ValueColor.Sprint(testURL))
-		fprintf(stdout, "\n")
+		if isStructuredLogFormat() {
+			log.WithFields(log.Fields{
+				"execution": "cloud",
+				"script":    filename,
+				"output":    testURL,
+			}).Info("Execution description")
+		} else {
+			fprintf(stdout, "\n\n")
+			fprintf(stdout, "     execution: %s\n", ui.ValueColor.Sprint("cloud"))
+			fprintf(stdout, "     script: %s\n", ui.ValueColor.Sprint(filename))
+			fprintf(stdout, "     output: %s\n", ui.ValueColor.Sprint(testURL))
+			fprintf(stdout, "\n")
+		}
 
 		// The quiet option hides the progress bar and disallow aborting the test
 		if quiet {
@@ -194,7 +209,7 @@ This will execute the test on the Load Impact cloud service. Use "k6 login cloud
 		var progressErr error
 		testProgress := &cloud.TestProgressResponse{}
 		progress := ui.ProgressBar{
-			Width: 60,
+			Width: progressWidth,
 			Left: func() string {
 				return "  " + testProgress.RunStatusText
 			},
@@ -213,7 +228,14 @@ This will execute the test on the Load Impact cloud service. Use "k6 login cloud
 						shouldExitLoop = true
 					}
 					progress.Progress = testProgress.Progress
-					fprintf(stdout, "%s\x1b[0K\r", progress.String())
+					if isStructuredLogFormat() {
+						log.WithFields(log.Fields{
+							"status":   testProgress.RunStatusText,
+							"progress": testProgress.Progress,
+						}).Info("Test progress")
+					} else {
+						fprintf(stdout, "%s\x1b[0K\r", progress.String())
+					}
 				} else {
 					log.WithError(progressErr).Error("Test progress error")
 				}
@@ -234,7 +256,11 @@ This will execute the test on the Load Impact cloud service. Use "k6 login cloud
 			return ExitCode{errors.New("Test progress error"), 98}
 		}
 
-		fprintf(stdout, "     test status: %s\n", ui.ValueColor.Sprint(testProgress.RunStatusText))
+		if isStructuredLogFormat() {
+			log.WithField("status", testProgress.RunStatusText).Info("Test finished")
+		} else {
+			fprintf(stdout, "     test status: %s\n", ui.ValueColor.Sprint(testProgress.RunStatusText))
+		}
 
 		if testProgress.ResultStatus == cloud.ResultStatusFailed {
 			return ExitCode{errors.New("The test has failed"), 99}
@@ -249,6 +275,7 @@ func cloudCmdFlagSet() *pflag.FlagSet {
 	flags.SortFlags = false
 	flags.AddFlagSet(optionFlagSet())
 	flags.AddFlagSet(runtimeOptionFlagSet(false))
+	flags.String("cloud-config-file", "", "JSON `file` with the cloud config, overriding the one from the k6 config file")
 
 	//TODO: Figure out a better way to handle the CLI flags:
 	// - the default value is specified in this way so we don't overwrire whatever
@@ -21,23 +21,35 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"path"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"gopkg.in/guregu/null.v3"
 
 	"github.com/kelseyhightower/envconfig"
+	"github.com/loadimpact/k6/core"
 	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/lib/consts"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/lib/netext/httpext"
 	"github.com/loadimpact/k6/loader"
+	"github.com/loadimpact/k6/stats"
 	"github.com/loadimpact/k6/stats/cloud"
 	"github.com/loadimpact/k6/stats/datadog"
+	"github.com/loadimpact/k6/stats/graphite"
 	"github.com/loadimpact/k6/stats/influxdb"
 	jsonc "github.com/loadimpact/k6/stats/json"
 	"github.com/loadimpact/k6/stats/kafka"
 	"github.com/loadimpact/k6/stats/statsd"
 	"github.com/loadimpact/k6/stats/statsd/common"
 	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
 )
 
@@ -48,6 +60,7 @@ const (
 	collectorCloud    = "cloud"
 	collectorStatsD   = "statsd"
 	collectorDatadog  = "datadog"
+	collectorGraphite = "graphite"
 )
 
 func parseCollector(s string) (t, arg string) {
@@ -62,11 +75,847 @@ func parseCollector(s string) (t, arg string) {
 	}
 }
 
+// parseCollectorName extracts the optional `name=<label>` parameter from a collector
+// argument, e.g. `json=out.json,name=primary`, and returns the remaining argument and
+// the label. Labels help tell apart multiple collectors of the same type.
+func parseCollectorName(arg string) (rest, name string) {
+	parts := splitCollectorArg(arg)
+	kept := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if strings.HasPrefix(part, "name=") {
+			name = strings.TrimPrefix(part, "name=")
+			continue
+		}
+		kept = append(kept, part)
+	}
+	return strings.Join(kept, ","), name
+}
+
+// parseCollectorShadow extracts the optional `shadow` flag from a collector argument, e.g.
+// `http://localhost:8086/k6,shadow`, and returns the remaining argument and whether it was set.
+func parseCollectorShadow(arg string) (rest string, shadow bool) {
+	parts := splitCollectorArg(arg)
+	kept := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part == "shadow" {
+			shadow = true
+			continue
+		}
+		kept = append(kept, part)
+	}
+	return strings.Join(kept, ","), shadow
+}
+
+// splitCollectorArg splits a collector argument on the top-level commas only, the way strvals
+// parses the key=value arguments, so the commas in lists like `tagsAsFields={vu,iter}` and the
+// escaped ones like `\,` are left alone.
+func splitCollectorArg(arg string) []string {
+	var parts []string
+	depth, start, escaped := 0, 0, false
+	for i, r := range arg {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '{':
+			depth++
+		case r == '}' && depth > 0:
+			depth--
+		case r == ',' && depth == 0:
+			parts = append(parts, arg[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, arg[start:])
+}
+
+// collectorLabel returns a human-readable identifier for a collector, used in logs and errors.
+func collectorLabel(collectorName, name string) string {
+	if name == "" {
+		return collectorName
+	}
+	return fmt.Sprintf("%s (%s)", collectorName, name)
+}
+
+// isOutput returns whether the output, given by its name label or type, refers to the
+// collector of the given type and name.
+func isOutput(output, collectorType, name string) bool {
+	return output == collectorType || (name != "" && output == name)
+}
+
+// isOnFailureOutput returns whether the collector of the given type and name should only
+// receive the samples if the test fails.
+func isOnFailureOutput(onFailureOutputs []string, collectorType, name string) bool {
+	for _, output := range onFailureOutputs {
+		if isOutput(output, collectorType, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// routedCollector wraps a collector and only passes it the samples of the metrics that are
+// routed to it. Metrics that don't match any of the routes are passed to all collectors.
+type routedCollector struct {
+	lib.Collector
+	collectorType, name string
+	routes              map[string][]string
+
+	// The cache of the routing decisions, by metric name. It's locked because the threshold
+	// results arrive concurrently with the samples.
+	acceptedMu sync.Mutex
+	accepted   map[string]bool
+}
+
+func newRoutedCollector(
+	collector lib.Collector, collectorType, name string, routes map[string][]string,
+) *routedCollector {
+	return &routedCollector{
+		Collector:     collector,
+		collectorType: collectorType,
+		name:          name,
+		routes:        routes,
+		accepted:      map[string]bool{},
+	}
+}
+
+// accepts returns whether the samples of the given metric should be sent to the collector.
+// acceptedMu has to be held.
+func (c *routedCollector) accepts(metric string) bool {
+	if accepted, ok := c.accepted[metric]; ok {
+		return accepted
+	}
+	matched, accepted := false, false
+	for glob, outputs := range c.routes {
+		if ok, _ := path.Match(glob, metric); !ok {
+			continue
+		}
+		matched = true
+		for _, output := range outputs {
+			if isOutput(output, c.collectorType, c.name) {
+				accepted = true
+			}
+		}
+	}
+	c.accepted[metric] = !matched || accepted
+	return !matched || accepted
+}
+
+// Collect filters out the samples of the metrics not routed to the wrapped collector.
+func (c *routedCollector) Collect(sampleContainers []stats.SampleContainer) {
+	c.acceptedMu.Lock()
+	filtered := filterSampleContainers(sampleContainers, func(sample stats.Sample) bool {
+		return c.accepts(sample.Metric.Name)
+	})
+	c.acceptedMu.Unlock()
+	if len(filtered) > 0 {
+		c.Collector.Collect(filtered)
+	}
+}
+
+// CollectThresholdResults filters out the results of the metrics not routed to the wrapped
+// collector, if it records them.
+func (c *routedCollector) CollectThresholdResults(results []stats.ThresholdResult) {
+	c.acceptedMu.Lock()
+	routed := make([]stats.ThresholdResult, 0, len(results))
+	for _, result := range results {
+		if c.accepts(result.Metric) {
+			routed = append(routed, result)
+		}
+	}
+	c.acceptedMu.Unlock()
+	if len(routed) > 0 {
+		collectThresholdResults(c.Collector, routed)
+	}
+}
+
+// sampleFilterRule matches the samples with a specific tag, optionally with a specific value.
+type sampleFilterRule struct {
+	tag, value string
+	anyValue   bool
+}
+
+// sampleFilter matches the samples with any of the filtered tags, or of failed checks.
+type sampleFilter struct {
+	rules        []sampleFilterRule
+	failedChecks bool
+}
+
+// failedChecksFilter is the filter rule that matches the samples of failed checks.
+const failedChecksFilter = "failed_checks"
+
+// parseSampleFilter parses a list of `tag`, `tag:value` and `failed_checks` filter rules.
+func parseSampleFilter(rules []string) (sampleFilter, error) {
+	filter := sampleFilter{}
+	for _, rule := range rules {
+		if rule == failedChecksFilter {
+			filter.failedChecks = true
+			continue
+		}
+		parts := strings.SplitN(rule, ":", 2)
+		if parts[0] == "" {
+			return filter, errors.Errorf("invalid output filter '%s', expected tag or tag:value", rule)
+		}
+		if len(parts) == 1 {
+			filter.rules = append(filter.rules, sampleFilterRule{tag: parts[0], anyValue: true})
+		} else {
+			filter.rules = append(filter.rules, sampleFilterRule{tag: parts[0], value: parts[1]})
+		}
+	}
+	return filter, nil
+}
+
+func (f sampleFilter) matches(sample stats.Sample) bool {
+	if f.failedChecks && sample.Metric.Name == metrics.Checks.Name && sample.Value == 0 {
+		return true
+	}
+	for _, rule := range f.rules {
+		if value, ok := sample.Tags.Get(rule.tag); ok && (rule.anyValue || value == rule.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// filteredCollector wraps a collector and only passes it the samples matched by a filter,
+// e.g. to only output the samples of failed requests and checks while debugging. The engine
+// still processes all samples, so thresholds and the end-of-test summary aren't affected.
+type filteredCollector struct {
+	lib.Collector
+	filter sampleFilter
+}
+
+// Collect filters out the samples not matched by the filter.
+func (c *filteredCollector) Collect(sampleContainers []stats.SampleContainer) {
+	filtered := filterSampleContainers(sampleContainers, c.filter.matches)
+	if len(filtered) > 0 {
+		c.Collector.Collect(filtered)
+	}
+}
+
+// CollectThresholdResults drops the threshold results, which have no tags, so the filter can't
+// match any of them.
+func (c *filteredCollector) CollectThresholdResults(results []stats.ThresholdResult) {}
+
+// The tag key normalization rules.
+const (
+	tagKeysLowercase  = "lowercase"  // lowercase all tag keys
+	tagKeysUnderscore = "underscore" // replace spaces, dots and dashes in tag keys with underscores
+)
+
+//nolint:gochecknoglobals
+var tagKeyReplacer = strings.NewReplacer(" ", "_", ".", "_", "-", "_")
+
+// tagKeyNormalizer normalizes tag keys, e.g. to conform to the naming rules of a backend.
+// The normalization is idempotent, and the normalized keys are cached, so every distinct
+// key is only normalized once and the number of distinct keys can't grow.
+type tagKeyNormalizer struct {
+	lowercase, underscore bool
+	keys                  map[string]string
+}
+
+// newTagKeyNormalizer creates a normalizer with the given rules, lowercase and underscore.
+func newTagKeyNormalizer(rules []string) (*tagKeyNormalizer, error) {
+	n := &tagKeyNormalizer{keys: map[string]string{}}
+	for _, rule := range rules {
+		switch rule {
+		case tagKeysLowercase:
+			n.lowercase = true
+		case tagKeysUnderscore:
+			n.underscore = true
+		default:
+			return nil, errors.Errorf("unknown tag key normalization '%s'", rule)
+		}
+	}
+	return n, nil
+}
+
+func (n *tagKeyNormalizer) normalizeKey(key string) string {
+	if normalized, ok := n.keys[key]; ok {
+		return normalized
+	}
+	normalized := key
+	if n.lowercase {
+		normalized = strings.ToLower(normalized)
+	}
+	if n.underscore {
+		normalized = tagKeyReplacer.Replace(normalized)
+	}
+	n.keys[key] = normalized
+	return normalized
+}
+
+// normalizeTags returns the tags with normalized keys, or the same tags if all of their keys
+// already conform. If several keys are normalized to the same one, the tag with the key that
+// already conformed wins, so existing conforming tags are never overwritten.
+func (n *tagKeyNormalizer) normalizeTags(tags *stats.SampleTags) *stats.SampleTags {
+	if tags.IsEmpty() {
+		return tags
+	}
+	original := tags.CloneTags()
+	conforming := true
+	for key := range original {
+		if n.normalizeKey(key) != key {
+			conforming = false
+			break
+		}
+	}
+	if conforming {
+		return tags
+	}
+
+	normalized := make(map[string]string, len(original))
+	for key, value := range original {
+		if n.normalizeKey(key) == key {
+			normalized[key] = value
+		}
+	}
+	for key, value := range original {
+		normalizedKey := n.normalizeKey(key)
+		if _, exists := normalized[normalizedKey]; !exists {
+			normalized[normalizedKey] = value
+		}
+	}
+	return stats.IntoSampleTags(&normalized)
+}
+
+// normalizedTagsCollector wraps a collector and normalizes the tag keys of the samples
+// passed to it. The engine still sees the original tags, so thresholds aren't affected.
+type normalizedTagsCollector struct {
+	lib.Collector
+	normalizer *tagKeyNormalizer
+}
+
+// Collect normalizes the tag keys of the samples. Containers with conforming tags are passed
+// through untouched, while the rest are copied with core.WithSamples(), keeping their type, and
+// the tags of the connected ones are normalized too.
+func (c *normalizedTagsCollector) Collect(sampleContainers []stats.SampleContainer) {
+	normalized := make([]stats.SampleContainer, 0, len(sampleContainers))
+	cache := map[*stats.SampleTags]*stats.SampleTags{}
+	normalize := func(tags *stats.SampleTags) *stats.SampleTags {
+		if result, ok := cache[tags]; ok {
+			return result
+		}
+		result := c.normalizer.normalizeTags(tags)
+		cache[tags] = result
+		return result
+	}
+
+	for _, sc := range sampleContainers {
+		samples := sc.GetSamples()
+		var changed []stats.Sample
+		for i, sample := range samples {
+			tags := normalize(sample.Tags)
+			if tags == sample.Tags {
+				continue
+			}
+			if changed == nil {
+				changed = append(make([]stats.Sample, 0, len(samples)), samples...)
+			}
+			changed[i].Tags = tags
+		}
+		if changed == nil {
+			normalized = append(normalized, sc)
+			continue
+		}
+
+		switch sc := core.WithSamples(sc, changed).(type) {
+		case *httpext.Trail:
+			sc.Tags = normalize(sc.Tags)
+			normalized = append(normalized, sc)
+		case *netext.NetTrail:
+			sc.Tags = normalize(sc.Tags)
+			normalized = append(normalized, sc)
+		case stats.ConnectedSamples:
+			sc.Tags = normalize(sc.Tags)
+			normalized = append(normalized, sc)
+		default:
+			normalized = append(normalized, sc)
+		}
+	}
+	c.Collector.Collect(normalized)
+}
+
+// CollectThresholdResults passes the threshold results, which have no tags, to the wrapped
+// collector, if it records them.
+func (c *normalizedTagsCollector) CollectThresholdResults(results []stats.ThresholdResult) {
+	collectThresholdResults(c.Collector, results)
+}
+
+// onFailureCollector wraps a collector that should only receive samples if the test fails,
+// e.g. to save cloud quota on passing runs. All samples are buffered in memory until the
+// end of the test, and the wrapped collector is only initialized, run and sent the buffered
+// samples if the test was aborted or some of its thresholds have failed.
+type onFailureCollector struct {
+	lib.Collector
+	label  string
+	failed func() bool // whether any thresholds have failed
+
+	mutex     sync.Mutex
+	buffer    []stats.SampleContainer
+	runStatus lib.RunStatus
+	aborted   bool
+
+	lib.CollectorFailures
+}
+
+// DeliveryFailure also counts the failure to initialize the wrapped collector at the end of
+// the test as a failed delivery, besides the ones of the wrapped collector itself.
+func (c *onFailureCollector) DeliveryFailure() error {
+	if err := c.CollectorFailures.DeliveryFailure(); err != nil {
+		return err
+	}
+	if fc, ok := c.Collector.(lib.FailingCollector); ok {
+		return fc.DeliveryFailure()
+	}
+	return nil
+}
+
+// FailedSamples counts the samples that were lost because the wrapped collector couldn't be
+// initialized, and the ones it failed to deliver itself.
+func (c *onFailureCollector) FailedSamples() int64 {
+	failed := c.CollectorFailures.FailedSamples()
+	if fc, ok := c.Collector.(lib.SampleFailuresCollector); ok {
+		failed += fc.FailedSamples()
+	}
+	return failed
+}
+
+// Init is deferred until the end of the test, since the test may not fail.
+func (c *onFailureCollector) Init() error {
+	return nil
+}
+
+// Link is empty until the end of the test, since the wrapped collector isn't initialized.
+func (c *onFailureCollector) Link() string {
+	return ""
+}
+
+// Collect buffers the samples until the end of the test.
+func (c *onFailureCollector) Collect(sampleContainers []stats.SampleContainer) {
+	c.mutex.Lock()
+	c.buffer = append(c.buffer, sampleContainers...)
+	c.mutex.Unlock()
+}
+
+// SpillSamples takes the samples that are held until the end of the test, and the ones the
+// wrapped collector hasn't delivered yet, if it can give them up. It doesn't make the wrapper a
+// BufferingCollector, since its buffer can't be limited.
+func (c *onFailureCollector) SpillSamples() []stats.SampleContainer {
+	c.mutex.Lock()
+	containers := c.buffer
+	c.buffer = nil
+	c.mutex.Unlock()
+	if sc, ok := c.Collector.(sampleSpiller); ok {
+		containers = append(containers, sc.SpillSamples()...)
+	}
+	return containers
+}
+
+// SetRunStatus records the status of an aborted test, which counts as a failure.
+func (c *onFailureCollector) SetRunStatus(status lib.RunStatus) {
+	c.mutex.Lock()
+	c.runStatus, c.aborted = status, true
+	c.mutex.Unlock()
+}
+
+// Run waits for the end of the test and then, if it failed, passes the buffered samples to
+// the wrapped collector and waits for it to commit them.
+func (c *onFailureCollector) Run(ctx context.Context) {
+	<-ctx.Done()
+
+	// The lock isn't held while the wrapped collector works, so the buffer can still be spilled.
+	c.mutex.Lock()
+	runStatus, aborted := c.runStatus, c.aborted
+	buffered := len(c.buffer)
+	c.mutex.Unlock()
+	logger := log.WithField("output", c.label)
+	if !aborted && !c.failed() {
+		logger.Debug("The test passed, discarding the samples of the output")
+		c.mutex.Lock()
+		c.buffer = nil
+		c.mutex.Unlock()
+		return
+	}
+
+	logger.WithField("samples", buffered).Debug("The test failed, flushing the samples of the output")
+	if err := c.Collector.Init(); err != nil {
+		logger.WithError(err).Error("Couldn't initialize the output")
+		c.mutex.Lock()
+		failed := 0
+		for _, sc := range c.buffer {
+			failed += len(sc.GetSamples())
+		}
+		c.buffer = nil
+		c.mutex.Unlock()
+		c.RecordFailedSamples(err, failed)
+		return
+	}
+	runCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Collector.Run(runCtx)
+		close(done)
+	}()
+	c.mutex.Lock()
+	buffer := c.buffer
+	c.buffer = nil
+	c.mutex.Unlock()
+	if len(buffer) > 0 {
+		c.Collector.Collect(buffer)
+	}
+	if aborted {
+		c.Collector.SetRunStatus(runStatus)
+	}
+	cancel()
+	<-done
+	if link := c.Collector.Link(); link != "" {
+		logger.WithField("link", link).Info("The test failed, its samples were sent to the output")
+	}
+}
+
+const (
+	// How many sample containers a shadow output can fall behind before new ones are dropped.
+	shadowBufferSize = 1000
+	// How long a shadow output is given to finish after the test before k6 stops waiting for it.
+	shadowStopTimeout = 30 * time.Second
+)
+
+// shadowCollector wraps an output that's being trialed alongside the real ones, so that it can
+// never affect the test: it gets its samples through a bounded buffer, which drops them when
+// the output can't keep up instead of blocking, its errors and panics are only logged, and
+// it doesn't take part in the readiness, buffer limits or delivery checks of the other outputs.
+type shadowCollector struct {
+	lib.Collector
+	label string
+	kind  string // what the wrapped collector is called in the logs
+
+	samples     chan []stats.SampleContainer
+	dropped     int64
+	disabled    bool  // the wrapped collector couldn't be initialized
+	failure     error // why the wrapped collector didn't deliver all of its samples, set by Run()
+	failed      int64 // how many samples the wrapped collector didn't deliver, set by Run()
+	diagnostics *deliveryDiagnostics
+}
+
+func newShadowCollector(collector lib.Collector, label string, bufferSize int) *shadowCollector {
+	return &shadowCollector{
+		Collector: collector,
+		label:     label,
+		kind:      "shadow output",
+		samples:   make(chan []stats.SampleContainer, bufferSize),
+	}
+}
+
+// Init initializes the wrapped collector, only logging why it couldn't be initialized. The
+// wrapped collector stays disabled unless it's initialized successfully.
+func (c *shadowCollector) Init() error {
+	c.disabled = true
+	defer c.recover("Init")
+	if err := c.Collector.Init(); err != nil {
+		log.WithError(err).WithField("output", c.label).Warnf("Couldn't initialize the %s, disabling it", c.kind)
+		return nil
+	}
+	c.disabled = false
+	return nil
+}
+
+// Link is only shown if the wrapped collector was initialized.
+func (c *shadowCollector) Link() string {
+	if c.disabled {
+		return ""
+	}
+	defer c.recover("Link")
+	return c.Collector.Link()
+}
+
+// Collect buffers the samples for the wrapped collector, or drops them if the buffer is full.
+func (c *shadowCollector) Collect(sampleContainers []stats.SampleContainer) {
+	if c.disabled {
+		return
+	}
+	select {
+	case c.samples <- sampleContainers:
+	default:
+		var dropped int64
+		for _, sc := range sampleContainers {
+			dropped += int64(len(sc.GetSamples()))
+		}
+		if atomic.AddInt64(&c.dropped, dropped) == dropped {
+			log.WithField("output", c.label).Warnf("The %s can't keep up, dropping samples", c.kind)
+		}
+		c.diagnostics.recordDropped(c.label, dropReasonFellBehind, sampleContainers)
+	}
+}
+
+// SpillSamples takes the samples that are waiting for the wrapped collector, and the ones it
+// hasn't delivered yet, if it can give them up. It doesn't make the wrapper a BufferingCollector,
+// so the buffer of the wrapped collector isn't limited.
+func (c *shadowCollector) SpillSamples() (containers []stats.SampleContainer) {
+	for drained := false; !drained; {
+		select {
+		case sampleContainers := <-c.samples:
+			containers = append(containers, sampleContainers...)
+		default:
+			drained = true
+		}
+	}
+	if sc, ok := c.Collector.(sampleSpiller); ok && !c.disabled {
+		defer c.recover("SpillSamples")
+		containers = append(containers, sc.SpillSamples()...)
+	}
+	return containers
+}
+
+// SetRunStatus passes the status to the wrapped collector.
+func (c *shadowCollector) SetRunStatus(status lib.RunStatus) {
+	if c.disabled {
+		return
+	}
+	defer c.recover("SetRunStatus")
+	c.Collector.SetRunStatus(status)
+}
+
+// Run runs the wrapped collector and passes it the buffered samples, until the test ends. Then
+// it waits a limited time for the wrapped collector to finish and logs its delivery failure.
+func (c *shadowCollector) Run(ctx context.Context) {
+	if c.disabled {
+		<-ctx.Done()
+		return
+	}
+	logger := log.WithField("output", c.label)
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer c.recover("Run")
+		c.Collector.Run(runCtx)
+	}()
+	for running := true; running; {
+		select {
+		case sampleContainers := <-c.samples:
+			c.collect(sampleContainers)
+		case <-ctx.Done():
+			running = false
+		}
+	}
+	for drained := false; !drained; {
+		select {
+		case sampleContainers := <-c.samples:
+			c.collect(sampleContainers)
+		default:
+			drained = true
+		}
+	}
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(shadowStopTimeout):
+		logger.Warnf("The %s didn't finish in time, not waiting for it anymore", c.kind)
+		c.failure = errors.Errorf("the %s didn't finish in %s", c.kind, shadowStopTimeout)
+		return
+	}
+	if dropped := atomic.LoadInt64(&c.dropped); dropped > 0 {
+		logger.WithField("dropped", dropped).Warnf("Some samples weren't sent to the %s, because it couldn't keep up", c.kind)
+	}
+	if fc, ok := c.Collector.(lib.FailingCollector); ok {
+		if err := fc.DeliveryFailure(); err != nil {
+			logger.WithError(err).Warnf("The %s failed to deliver some samples", c.kind)
+			c.failure = err
+		}
+	}
+	if fc, ok := c.Collector.(lib.SampleFailuresCollector); ok {
+		c.failed = fc.FailedSamples()
+	}
+}
+
+// shadowDelivery reports the delivery failures of a shadow output to the engine, which tells the
+// embedders about them once the outputs have flushed. It's kept apart from the shadow collector,
+// so the output stays out of the delivery checks of --strict-outputs.
+type shadowDelivery struct {
+	*shadowCollector
+}
+
+// DeliveryFailure returns why the shadow output didn't deliver all of its samples, if it didn't.
+func (d shadowDelivery) DeliveryFailure() error {
+	return d.failure
+}
+
+// FailedSamples returns how many samples the shadow output didn't deliver.
+func (d shadowDelivery) FailedSamples() int64 {
+	return d.failed
+}
+
+func (c *shadowCollector) collect(sampleContainers []stats.SampleContainer) {
+	collected := false
+	defer func() {
+		if !collected {
+			c.diagnostics.recordDropped(c.label, dropReasonPanicked, sampleContainers)
+		}
+	}()
+	defer c.recover("Collect")
+	c.Collector.Collect(sampleContainers)
+	collected = true
+}
+
+// recover logs the panics of the wrapped collector, instead of letting them crash k6.
+func (c *shadowCollector) recover(method string) {
+	if r := recover(); r != nil {
+		log.WithFields(log.Fields{"output": c.label, "method": method, "panic": r}).Errorf("The %s panicked", c.kind)
+	}
+}
+
+// The policies for the samples that are collected while an output's buffer is full.
+const (
+	sampleBufferPolicyBlock = "block"
+	sampleBufferPolicyDrop  = "drop"
+)
+
+// How often a blocked bufferLimitedCollector checks whether the output has caught up.
+const sampleBufferPollInterval = 10 * time.Millisecond
+
+// bufferLimitedCollector wraps a collector that reports its buffered samples and keeps their
+// number under a limit. When the buffer is full, new samples either wait for the collector to
+// deliver some of the buffered ones, or they are dropped and counted.
+//
+// Blocking is meant to hold back the VUs, which is the only way to keep the memory bounded
+// without losing samples. It happens in Collect(), which the engine calls for every output in
+// turn, so while one output is blocked, none of the others receive new samples either. Queuing
+// the samples for each output on its own instead would just move the unbounded buffer there.
+type bufferLimitedCollector struct {
+	lib.BufferingCollector
+	label string
+	limit int
+	drop  bool
+
+	done        chan struct{} // closed at the end of the test, when blocking is no longer useful
+	dropped     int64
+	diagnostics *deliveryDiagnostics
+}
+
+func newBufferLimitedCollector(
+	collector lib.BufferingCollector, label string, limit int, policy string,
+) *bufferLimitedCollector {
+	return &bufferLimitedCollector{
+		BufferingCollector: collector,
+		label:              label,
+		limit:              limit,
+		drop:               policy == sampleBufferPolicyDrop,
+		done:               make(chan struct{}),
+	}
+}
+
+// Collect passes the samples to the wrapped collector once it has room for them, or drops them.
+func (c *bufferLimitedCollector) Collect(sampleContainers []stats.SampleContainer) {
+	for c.BufferedSamples() >= c.limit {
+		if c.drop {
+			var dropped int64
+			for _, sc := range sampleContainers {
+				dropped += int64(len(sc.GetSamples()))
+			}
+			if atomic.AddInt64(&c.dropped, dropped) == dropped {
+				log.WithFields(log.Fields{"output": c.label, "limit": c.limit}).Warn(
+					"The buffer of the output is full, dropping samples")
+			}
+			c.diagnostics.recordDropped(c.label, dropReasonBufferFull, sampleContainers)
+			return
+		}
+		select {
+		case <-c.done:
+			c.BufferingCollector.Collect(sampleContainers)
+			return
+		case <-time.After(sampleBufferPollInterval):
+		}
+	}
+	c.BufferingCollector.Collect(sampleContainers)
+}
+
+// Run runs the wrapped collector and reports the number of dropped samples after the test.
+func (c *bufferLimitedCollector) Run(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		close(c.done)
+	}()
+	c.BufferingCollector.Run(ctx)
+	if dropped := atomic.LoadInt64(&c.dropped); dropped > 0 {
+		log.WithFields(log.Fields{"output": c.label, "limit": c.limit, "dropped": dropped}).Warn(
+			"Some samples weren't sent to the output, because its buffer was full")
+	}
+}
+
+// CollectThresholdResults passes the threshold results to the wrapped collector, if it records
+// them. They aren't samples, so they don't count against the limit.
+func (c *bufferLimitedCollector) CollectThresholdResults(results []stats.ThresholdResult) {
+	collectThresholdResults(c.BufferingCollector, results)
+}
+
+// collectThresholdResults passes the threshold results to the collector, if it records them.
+// The wrappers above pass them on with it, so they're routed and filtered like the samples.
+func collectThresholdResults(collector lib.Collector, results []stats.ThresholdResult) {
+	if tc, ok := collector.(lib.ThresholdsCollector); ok {
+		tc.CollectThresholdResults(results)
+	}
+}
+
+// filterSampleContainers returns only the samples for which keep returns true. Containers
+// with only kept samples are returned untouched, so collectors can still handle specific
+// container types, e.g. HTTP trails, while the rest are copied, keeping their connection.
+func filterSampleContainers(
+	sampleContainers []stats.SampleContainer, keep func(stats.Sample) bool,
+) []stats.SampleContainer {
+	filtered := make([]stats.SampleContainer, 0, len(sampleContainers))
+	for _, sc := range sampleContainers {
+		samples := sc.GetSamples()
+		kept := make([]stats.Sample, 0, len(samples))
+		for _, sample := range samples {
+			if keep(sample) {
+				kept = append(kept, sample)
+			}
+		}
+		switch {
+		case len(kept) == len(samples):
+			filtered = append(filtered, sc)
+		case len(kept) == 0:
+			continue
+		default:
+			filtered = append(filtered, core.WithSamples(sc, kept))
+		}
+	}
+	return filtered
+}
+
+// checkRequiredTags returns an error if the collector requires tags on all samples that aren't
+// among the tags of the run.
+func checkRequiredTags(collector lib.Collector, runTags *stats.SampleTags) error {
+	trc, ok := collector.(lib.TagRequiringCollector)
+	if !ok {
+		return nil
+	}
+	var missing []string
+	for _, key := range trc.GetRequiredTags() {
+		if _, ok := runTags.Get(key); !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("the output requires the tags %s on all samples, set them with --tag",
+			strings.Join(missing, ", "))
+	}
+	return nil
+}
+
 func newCollector(collectorName, arg string, src *loader.SourceData, conf Config) (lib.Collector, error) {
 	getCollector := func() (lib.Collector, error) {
 		switch collectorName {
 		case collectorJSON:
-			return jsonc.New(afero.NewOsFs(), arg)
+			config := jsonc.NewConfig().Apply(conf.Collectors.JSON)
+			if err := envconfig.Process("k6", &config); err != nil {
+				return nil, err
+			}
+			return jsonc.New(afero.NewOsFs(), arg, config)
 		case collectorInfluxDB:
 			config := influxdb.NewConfig().Apply(conf.Collectors.InfluxDB)
 			if err := envconfig.Process("k6", &config); err != nil {
@@ -112,6 +961,19 @@ func newCollector(collectorName, arg string, src *loader.SourceData, conf Config
 				return nil, err
 			}
 			return datadog.New(config)
+		case collectorGraphite:
+			config := graphite.NewConfig().Apply(conf.Collectors.Graphite)
+			if err := envconfig.Process("k6", &config); err != nil {
+				return nil, err
+			}
+			if arg != "" {
+				cmdConfig, err := graphite.ParseArg(arg)
+				if err != nil {
+					return nil, err
+				}
+				config = config.Apply(cmdConfig)
+			}
+			return graphite.New(config)
 		default:
 			return nil, errors.Errorf("unknown output type: %s", collectorName)
 		}
@@ -0,0 +1,643 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2019 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/netext/httpext"
+	"github.com/loadimpact/k6/stats"
+	"github.com/loadimpact/k6/stats/dummy"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCollectorName(t *testing.T) {
+	testdata := map[string][2]string{
+		"":                                 {"", ""},
+		"out.json":                         {"out.json", ""},
+		"out.json,name=primary":            {"out.json", "primary"},
+		"name=primary":                     {"", "primary"},
+		"brokers=a,name=kafka1,topic=test": {"brokers=a,topic=test", "kafka1"},
+		"tagsAsFields={vu,name=x},db=k6":   {"tagsAsFields={vu,name=x},db=k6", ""},
+		"db=a\\,name=x":                    {"db=a\\,name=x", ""},
+	}
+	for arg, expected := range testdata {
+		arg, expected := arg, expected
+		t.Run(arg, func(t *testing.T) {
+			rest, name := parseCollectorName(arg)
+			assert.Equal(t, expected[0], rest)
+			assert.Equal(t, expected[1], name)
+		})
+	}
+
+	assert.Equal(t, "json", collectorLabel("json", ""))
+	assert.Equal(t, "json (primary)", collectorLabel("json", "primary"))
+}
+
+func TestParseCollectorShadow(t *testing.T) {
+	testdata := map[string]struct {
+		rest   string
+		shadow bool
+	}{
+		"":                          {"", false},
+		"out.json":                  {"out.json", false},
+		"shadow":                    {"", true},
+		"out.json,shadow":           {"out.json", true},
+		"brokers=a,shadow,topic=ab": {"brokers=a,topic=ab", true},
+		"shadow.json":               {"shadow.json", false},
+		"tagsAsFields={vu,shadow}":  {"tagsAsFields={vu,shadow}", false},
+		"db=a\\,shadow":             {"db=a\\,shadow", false},
+		"tagsAsFields={vu},shadow":  {"tagsAsFields={vu}", true},
+	}
+	for arg, expected := range testdata {
+		arg, expected := arg, expected
+		t.Run(arg, func(t *testing.T) {
+			rest, shadow := parseCollectorShadow(arg)
+			assert.Equal(t, expected.rest, rest)
+			assert.Equal(t, expected.shadow, shadow)
+		})
+	}
+}
+
+func TestRoutedCollector(t *testing.T) {
+	routes := map[string][]string{
+		"http_req_*": {"influxdb"},
+		"business_*": {"cloud", "secondary"},
+	}
+	business := stats.New("business_orders", stats.Counter)
+	custom := stats.New("custom", stats.Counter)
+	now := time.Now()
+	trail := &httpext.Trail{EndTime: now, Duration: time.Second, Tags: stats.IntoSampleTags(&map[string]string{})}
+	trail.SaveSamples(stats.IntoSampleTags(&map[string]string{}), nil)
+	samples := []stats.SampleContainer{
+		trail,
+		stats.Sample{Metric: business, Time: now, Value: 1},
+		stats.ConnectedSamples{
+			Samples: []stats.Sample{{Metric: custom, Value: 2}, {Metric: business, Value: 3}},
+			Time:    now,
+		},
+	}
+
+	collect := func(collectorType, name string) []string {
+		inner := &dummy.Collector{}
+		c := newRoutedCollector(inner, collectorType, name, routes)
+		c.Collect(samples)
+		names := []string{}
+		for _, sample := range inner.Samples {
+			names = append(names, sample.Metric.Name)
+		}
+		return names
+	}
+
+	influx := collect("influxdb", "")
+	assert.Contains(t, influx, metrics.HTTPReqDuration.Name)
+	assert.Contains(t, influx, metrics.HTTPReqs.Name)
+	assert.Equal(t, 1, count(influx, custom.Name))
+	assert.Equal(t, 0, count(influx, business.Name))
+
+	cloud := collect("cloud", "")
+	assert.Equal(t, 0, count(cloud, metrics.HTTPReqDuration.Name))
+	assert.Equal(t, 2, count(cloud, business.Name))
+	assert.Equal(t, 1, count(cloud, custom.Name))
+
+	json := collect("json", "secondary")
+	assert.Equal(t, 0, count(json, metrics.HTTPReqDuration.Name))
+	// http_reqs doesn't match the http_req_* glob, so it goes to all collectors
+	assert.Equal(t, []string{metrics.HTTPReqs.Name, business.Name, custom.Name, business.Name}, json)
+
+	t.Run("PassesContainersThrough", func(t *testing.T) {
+		inner := &dummy.Collector{}
+		var received []stats.SampleContainer
+		c := newRoutedCollector(&containerRecorder{inner, &received}, "influxdb", "", routes)
+		c.Collect(samples)
+		require.Len(t, received, 2)
+		assert.Equal(t, trail, received[0])
+		assert.IsType(t, stats.ConnectedSamples{}, received[1])
+		assert.Len(t, received[1].GetSamples(), 1)
+	})
+
+	t.Run("ThresholdResults", func(t *testing.T) {
+		inner := &thresholdsRecorder{Collector: &dummy.Collector{}}
+		c := newRoutedCollector(inner, "cloud", "", routes)
+		c.CollectThresholdResults([]stats.ThresholdResult{
+			{Metric: metrics.HTTPReqDuration.Name, Threshold: "p(95)<500"},
+			{Metric: business.Name, Threshold: "count>10"},
+		})
+		assert.Equal(t, []stats.ThresholdResult{{Metric: business.Name, Threshold: "count>10"}}, inner.results)
+
+		// The results are passed through all the other wrappers.
+		var wrapped lib.Collector = &spillOnPanicCollector{Collector: &normalizedTagsCollector{Collector: c}}
+		wrapped.(lib.ThresholdsCollector).CollectThresholdResults([]stats.ThresholdResult{{Metric: custom.Name}})
+		assert.Len(t, inner.results, 2)
+	})
+}
+
+type thresholdsRecorder struct {
+	lib.Collector
+	results []stats.ThresholdResult
+}
+
+func (c *thresholdsRecorder) CollectThresholdResults(results []stats.ThresholdResult) {
+	c.results = append(c.results, results...)
+}
+
+type containerRecorder struct {
+	*dummy.Collector
+	received *[]stats.SampleContainer
+}
+
+func (c *containerRecorder) Collect(scs []stats.SampleContainer) {
+	*c.received = append(*c.received, scs...)
+}
+
+func count(names []string, name string) (n int) {
+	for _, v := range names {
+		if v == name {
+			n++
+		}
+	}
+	return n
+}
+
+func TestFilteredCollector(t *testing.T) {
+	_, err := parseSampleFilter([]string{"error", ":foo"})
+	assert.Error(t, err)
+
+	filter, err := parseSampleFilter([]string{"error", "status:500", failedChecksFilter})
+	require.NoError(t, err)
+
+	failed := stats.IntoSampleTags(&map[string]string{"error": "dial: i/o timeout", "status": "0"})
+	serverError := stats.IntoSampleTags(&map[string]string{"status": "500"})
+	ok := stats.IntoSampleTags(&map[string]string{"status": "200"})
+	check := stats.IntoSampleTags(&map[string]string{"check": "status is 200"})
+
+	inner := &dummy.Collector{}
+	c := &filteredCollector{Collector: inner, filter: filter}
+	c.Collect([]stats.SampleContainer{
+		stats.Sample{Metric: metrics.HTTPReqs, Tags: failed, Value: 1},
+		stats.Sample{Metric: metrics.HTTPReqs, Tags: serverError, Value: 2},
+		stats.Sample{Metric: metrics.HTTPReqs, Tags: ok, Value: 3},
+		stats.Samples{
+			{Metric: metrics.Checks, Tags: check, Value: 1},
+			{Metric: metrics.Checks, Tags: check, Value: 0},
+		},
+		stats.Sample{Metric: metrics.VUs, Value: 4},
+	})
+
+	values := []float64{}
+	for _, sample := range inner.Samples {
+		values = append(values, sample.Value)
+	}
+	assert.Equal(t, []float64{1, 2, 0}, values)
+	assert.Equal(t, metrics.Checks, inner.Samples[2].Metric)
+
+	inner.Samples = nil
+	c.Collect([]stats.SampleContainer{stats.Sample{Metric: metrics.HTTPReqs, Tags: ok, Value: 3}})
+	assert.Empty(t, inner.Samples)
+
+	recorder := &thresholdsRecorder{Collector: inner}
+	c = &filteredCollector{Collector: recorder, filter: filter}
+	c.CollectThresholdResults([]stats.ThresholdResult{{Metric: metrics.HTTPReqs.Name}})
+	assert.Empty(t, recorder.results)
+}
+
+type initRecorder struct {
+	*dummy.Collector
+	initialized bool
+}
+
+func (c *initRecorder) Init() error {
+	c.initialized = true
+	return nil
+}
+
+func TestOnFailureCollector(t *testing.T) {
+	assert.True(t, isOnFailureOutput([]string{"cloud"}, "cloud", ""))
+	assert.True(t, isOnFailureOutput([]string{"debug"}, "json", "debug"))
+	assert.False(t, isOnFailureOutput([]string{"cloud"}, "json", "debug"))
+	assert.False(t, isOnFailureOutput(nil, "cloud", ""))
+
+	samples := []stats.SampleContainer{
+		stats.Sample{Metric: metrics.HTTPReqs, Value: 1},
+		stats.Sample{Metric: metrics.HTTPReqs, Value: 2},
+	}
+	run := func(failed, aborted bool) *initRecorder {
+		inner := &initRecorder{Collector: &dummy.Collector{}}
+		c := &onFailureCollector{Collector: inner, label: "json", failed: func() bool { return failed }}
+		require.NoError(t, c.Init())
+		assert.False(t, inner.initialized)
+		assert.Empty(t, c.Link())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			c.Run(ctx)
+			close(done)
+		}()
+		c.Collect(samples[:1])
+		c.Collect(samples[1:])
+		if aborted {
+			c.SetRunStatus(lib.RunStatusAbortedUser)
+		}
+		assert.Empty(t, inner.Samples)
+		cancel()
+		<-done
+		return inner
+	}
+
+	t.Run("Passed", func(t *testing.T) {
+		inner := run(false, false)
+		assert.False(t, inner.initialized)
+		assert.Empty(t, inner.Samples)
+	})
+	t.Run("ThresholdsFailed", func(t *testing.T) {
+		inner := run(true, false)
+		assert.True(t, inner.initialized)
+		assert.Len(t, inner.Samples, 2)
+		assert.Equal(t, lib.RunStatus(0), inner.RunStatus)
+	})
+	t.Run("Aborted", func(t *testing.T) {
+		inner := run(false, true)
+		assert.True(t, inner.initialized)
+		assert.Len(t, inner.Samples, 2)
+		assert.Equal(t, lib.RunStatusAbortedUser, inner.RunStatus)
+	})
+}
+
+type readyFlushingCollector struct {
+	dummy.Collector
+}
+
+func (c *readyFlushingCollector) WaitReady(ctx context.Context) error { return nil }
+func (c *readyFlushingCollector) Flush() error                        { return nil }
+
+func TestOnFailureCollectorHidesInterfaces(t *testing.T) {
+	var collector lib.Collector = &readyFlushingCollector{}
+	_, ready := collector.(lib.ReadyCollector)
+	_, flushing := collector.(lib.FlushingCollector)
+	require.True(t, ready && flushing)
+
+	// The wrapped collector is only initialized at the end of a failed test, so it mustn't
+	// be waited for or flushed before that.
+	collector = &onFailureCollector{Collector: collector, label: "json", failed: func() bool { return true }}
+	_, ready = collector.(lib.ReadyCollector)
+	_, flushing = collector.(lib.FlushingCollector)
+	assert.False(t, ready)
+	assert.False(t, flushing)
+}
+
+type panickingCollector struct {
+	dummy.Collector
+	initErr error
+}
+
+func (c *panickingCollector) Init() error { return c.initErr }
+
+func (c *panickingCollector) Collect(sampleContainers []stats.SampleContainer) {
+	panic("shadow output bug")
+}
+
+func TestShadowCollector(t *testing.T) {
+	samples := []stats.SampleContainer{stats.Sample{Metric: metrics.HTTPReqs, Value: 1}}
+	run := func(c *shadowCollector, collect func()) {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			c.Run(ctx)
+			close(done)
+		}()
+		collect()
+		cancel()
+		<-done
+	}
+
+	t.Run("Collect", func(t *testing.T) {
+		inner := &dummy.Collector{}
+		c := newShadowCollector(inner, "json", 10)
+		require.NoError(t, c.Init())
+		assert.Equal(t, inner.Link(), c.Link())
+		run(c, func() {
+			c.Collect(samples)
+			c.Collect(samples)
+		})
+		assert.Len(t, inner.Samples, 2)
+		assert.Equal(t, int64(0), c.dropped)
+	})
+
+	t.Run("Drop", func(t *testing.T) {
+		inner := &dummy.Collector{}
+		c := newShadowCollector(inner, "json", 1)
+		require.NoError(t, c.Init())
+		// Nothing takes the samples out of the buffer before the collector runs.
+		c.Collect(samples)
+		c.Collect(samples)
+		c.Collect(samples)
+		assert.Equal(t, int64(2), c.dropped)
+		run(c, func() {})
+		assert.Len(t, inner.Samples, 1)
+	})
+
+	t.Run("InitFailure", func(t *testing.T) {
+		inner := &panickingCollector{initErr: errors.New("unreachable")}
+		c := newShadowCollector(inner, "influxdb", 10)
+		assert.NoError(t, c.Init())
+		assert.True(t, c.disabled)
+		assert.Empty(t, c.Link())
+		run(c, func() { c.Collect(samples) })
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		inner := &panickingCollector{}
+		c := newShadowCollector(inner, "influxdb", 10)
+		require.NoError(t, c.Init())
+		run(c, func() { c.Collect(samples) })
+	})
+
+	t.Run("DeliveryFailure", func(t *testing.T) {
+		inner := &failingRecorder{Collector: &dummy.Collector{}}
+		inner.SetDeliveryFailure(errors.New("write failed"))
+		c := newShadowCollector(inner, "influxdb", 10)
+		require.NoError(t, c.Init())
+		assert.NoError(t, shadowDelivery{c}.DeliveryFailure())
+		run(c, func() { c.Collect(samples) })
+		assert.EqualError(t, shadowDelivery{c}.DeliveryFailure(), "write failed")
+	})
+
+	t.Run("HidesOptionalInterfaces", func(t *testing.T) {
+		var c lib.Collector = newShadowCollector(&bufferRecorder{Collector: &dummy.Collector{}}, "json", 10)
+		_, buffering := c.(lib.BufferingCollector)
+		_, failing := c.(lib.FailingCollector)
+		assert.False(t, buffering)
+		assert.False(t, failing)
+	})
+}
+
+func TestTagKeyNormalizer(t *testing.T) {
+	_, err := newTagKeyNormalizer([]string{"uppercase"})
+	assert.EqualError(t, err, "unknown tag key normalization 'uppercase'")
+
+	n, err := newTagKeyNormalizer([]string{tagKeysLowercase, tagKeysUnderscore})
+	require.NoError(t, err)
+	assert.Equal(t, "my_custom_tag", n.normalizeKey("My.Custom-Tag"))
+	assert.Equal(t, "my_custom_tag", n.normalizeKey("my_custom_tag"))
+	assert.Equal(t, "a_b", n.normalizeKey("A B"))
+
+	conforming := stats.IntoSampleTags(&map[string]string{"status": "200", "url": "http://example.com"})
+	assert.True(t, conforming == n.normalizeTags(conforming))
+	assert.Nil(t, n.normalizeTags(nil))
+
+	tags := n.normalizeTags(stats.IntoSampleTags(&map[string]string{"Status": "200", "my.tag": "a", "status": "201"}))
+	assert.Equal(t, map[string]string{"status": "201", "my_tag": "a"}, tags.CloneTags())
+	// Normalizing again doesn't change anything.
+	assert.True(t, tags == n.normalizeTags(tags))
+
+	lowercase, err := newTagKeyNormalizer([]string{tagKeysLowercase})
+	require.NoError(t, err)
+	assert.Equal(t, "my.tag", lowercase.normalizeKey("My.Tag"))
+}
+
+func TestNormalizedTagsCollector(t *testing.T) {
+	normalizer, err := newTagKeyNormalizer([]string{tagKeysLowercase})
+	require.NoError(t, err)
+
+	conforming := stats.IntoSampleTags(&map[string]string{"status": "200"})
+	custom := stats.IntoSampleTags(&map[string]string{"Status": "200", "MyTag": "a"})
+	now := time.Now()
+	unchanged := stats.Sample{Metric: metrics.HTTPReqs, Tags: conforming, Value: 1}
+	connected := stats.ConnectedSamples{
+		Samples: []stats.Sample{{Metric: metrics.HTTPReqs, Tags: custom, Value: 2}, {Metric: metrics.VUs, Value: 3}},
+		Tags:    custom,
+		Time:    now,
+	}
+
+	inner := &dummy.Collector{}
+	var received []stats.SampleContainer
+	c := &normalizedTagsCollector{Collector: &containerRecorder{inner, &received}, normalizer: normalizer}
+	c.Collect([]stats.SampleContainer{unchanged, connected})
+
+	require.Len(t, received, 2)
+	assert.Equal(t, unchanged, received[0])
+	normalized, ok := received[1].(stats.ConnectedSamples)
+	require.True(t, ok)
+	assert.Equal(t, now, normalized.Time)
+	assert.Equal(t, map[string]string{"status": "200", "mytag": "a"}, normalized.Tags.CloneTags())
+	assert.Equal(t, map[string]string{"status": "200", "mytag": "a"}, normalized.Samples[0].Tags.CloneTags())
+	assert.Nil(t, normalized.Samples[1].Tags)
+	// The original samples aren't modified.
+	assert.Equal(t, custom, connected.Samples[0].Tags)
+
+	// The HTTP trails stay trails, so the collectors can still aggregate them.
+	trail := &httpext.Trail{EndTime: now, Tags: custom, Samples: []stats.Sample{{Metric: metrics.HTTPReqs, Tags: custom, Value: 1}}}
+	received = nil
+	c.Collect([]stats.SampleContainer{trail})
+	require.Len(t, received, 1)
+	normalizedTrail, ok := received[0].(*httpext.Trail)
+	require.True(t, ok)
+	assert.Equal(t, now, normalizedTrail.EndTime)
+	assert.Equal(t, map[string]string{"status": "200", "mytag": "a"}, normalizedTrail.Tags.CloneTags())
+	assert.Equal(t, map[string]string{"status": "200", "mytag": "a"}, normalizedTrail.Samples[0].Tags.CloneTags())
+	assert.Equal(t, custom, trail.Tags)
+}
+
+type failingRecorder struct {
+	*dummy.Collector
+	lib.CollectorFailures
+	initErr error
+}
+
+func (c *failingRecorder) Init() error {
+	return c.initErr
+}
+
+type tagRequiringCollector struct {
+	dummy.Collector
+	tags []string
+}
+
+func (c *tagRequiringCollector) GetRequiredTags() []string {
+	return c.tags
+}
+
+func TestCheckRequiredTags(t *testing.T) {
+	runTags := stats.IntoSampleTags(&map[string]string{"team": "a", "env": "staging"})
+
+	assert.NoError(t, checkRequiredTags(&dummy.Collector{}, runTags))
+	assert.NoError(t, checkRequiredTags(&tagRequiringCollector{tags: []string{"team"}}, runTags))
+	assert.EqualError(t,
+		checkRequiredTags(&tagRequiringCollector{tags: []string{"team", "cost_center", "owner"}}, runTags),
+		"the output requires the tags cost_center, owner on all samples, set them with --tag")
+	assert.Error(t, checkRequiredTags(&tagRequiringCollector{tags: []string{"team"}}, nil))
+
+	// Shadow outputs can't affect the test, so they don't require anything.
+	shadow := newShadowCollector(&tagRequiringCollector{tags: []string{"team"}}, "shadow", 10)
+	assert.NoError(t, checkRequiredTags(shadow, nil))
+}
+
+func TestGetOutputFailure(t *testing.T) {
+	ok := &failingRecorder{Collector: &dummy.Collector{}}
+	failed := &failingRecorder{Collector: &dummy.Collector{}}
+	collectors := map[string]lib.FailingCollector{"json": ok, "influxdb": failed}
+	assert.NoError(t, getOutputFailure(collectors))
+
+	failed.SetDeliveryFailure(errors.New("write failed"))
+	err := getOutputFailure(collectors)
+	require.Error(t, err)
+	assert.Equal(t, "output influxdb failed to deliver samples: write failed", err.Error())
+
+	t.Run("OnFailureInit", func(t *testing.T) {
+		inner := &failingRecorder{Collector: &dummy.Collector{}, initErr: errors.New("no connection")}
+		c := &onFailureCollector{Collector: inner, label: "json", failed: func() bool { return true }}
+		require.NoError(t, c.Init())
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		c.Run(ctx)
+		assert.EqualError(t, c.DeliveryFailure(), "no connection")
+
+		inner = &failingRecorder{Collector: &dummy.Collector{}}
+		inner.SetDeliveryFailure(errors.New("write failed"))
+		c = &onFailureCollector{Collector: inner, label: "json", failed: func() bool { return false }}
+		assert.EqualError(t, c.DeliveryFailure(), "write failed")
+	})
+}
+
+type bufferRecorder struct {
+	*dummy.Collector
+	mutex    sync.Mutex
+	buffered int
+}
+
+func (c *bufferRecorder) Collect(scs []stats.SampleContainer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, sc := range scs {
+		c.buffered += len(sc.GetSamples())
+	}
+}
+
+func (c *bufferRecorder) BufferedSamples() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.buffered
+}
+
+func (c *bufferRecorder) flush() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.buffered = 0
+}
+
+func TestBufferLimitedCollector(t *testing.T) {
+	sample := stats.Sample{Metric: metrics.HTTPReqs, Value: 1}
+	samples := []stats.SampleContainer{sample, sample}
+
+	t.Run("Drop", func(t *testing.T) {
+		inner := &bufferRecorder{Collector: &dummy.Collector{}}
+		c := newBufferLimitedCollector(inner, "json", 3, sampleBufferPolicyDrop)
+		c.Collect(samples)
+		c.Collect(samples)
+		c.Collect(samples)
+		assert.Equal(t, 4, inner.BufferedSamples())
+		assert.Equal(t, int64(2), c.dropped)
+
+		inner.flush()
+		c.Collect(samples)
+		assert.Equal(t, 2, inner.BufferedSamples())
+		assert.Equal(t, int64(2), c.dropped)
+	})
+
+	t.Run("Block", func(t *testing.T) {
+		inner := &bufferRecorder{Collector: &dummy.Collector{}}
+		c := newBufferLimitedCollector(inner, "json", 2, sampleBufferPolicyBlock)
+		c.Collect(samples)
+
+		collected := make(chan struct{})
+		go func() {
+			c.Collect(samples)
+			close(collected)
+		}()
+		select {
+		case <-collected:
+			t.Fatal("the samples were collected while the buffer was full")
+		case <-time.After(5 * sampleBufferPollInterval):
+		}
+		inner.flush()
+		select {
+		case <-collected:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the samples to be collected")
+		}
+		assert.Equal(t, 2, inner.BufferedSamples())
+		assert.Equal(t, int64(0), c.dropped)
+	})
+
+	t.Run("BlockOtherOutputs", func(t *testing.T) {
+		inner := &bufferRecorder{Collector: &dummy.Collector{}}
+		c := newBufferLimitedCollector(inner, "json", 2, sampleBufferPolicyBlock)
+		c.Collect(samples)
+		other := &dummy.Collector{}
+
+		// Like the engine, which passes the samples to every output in turn
+		collected := make(chan struct{})
+		go func() {
+			for _, collector := range []lib.Collector{c, other} {
+				collector.Collect(samples)
+			}
+			close(collected)
+		}()
+		select {
+		case <-collected:
+			t.Fatal("the samples were collected while the buffer was full")
+		case <-time.After(5 * sampleBufferPollInterval):
+		}
+		assert.Empty(t, other.Samples, "the other output got samples while the first one was blocked")
+		inner.flush()
+		select {
+		case <-collected:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the samples to be collected")
+		}
+		assert.Len(t, other.Samples, 2)
+	})
+
+	t.Run("BlockUntilDone", func(t *testing.T) {
+		inner := &bufferRecorder{Collector: &dummy.Collector{}}
+		c := newBufferLimitedCollector(inner, "json", 2, sampleBufferPolicyBlock)
+		c.Collect(samples)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		runDone := make(chan struct{})
+		go func() {
+			c.Run(ctx)
+			close(runDone)
+		}()
+		cancel()
+		<-runDone
+		c.Collect(samples)
+		assert.Equal(t, 4, inner.BufferedSamples())
+	})
+}
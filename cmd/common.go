@@ -51,6 +51,11 @@ type ExitCode struct {
 	Code int
 }
 
+// Cause returns the wrapped error, so errors.Cause() can be used to inspect it.
+func (e ExitCode) Cause() error {
+	return e.error
+}
+
 // A writer that syncs writes with a mutex and, if the output is a TTY, clears before newlines.
 type consoleWriter struct {
 	Writer io.Writer
@@ -23,21 +23,29 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
-	"errors"
-
+	"github.com/ghodss/yaml"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
 	"github.com/loadimpact/k6/lib/scheduler"
 	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/stats"
 	"github.com/loadimpact/k6/stats/cloud"
 	"github.com/loadimpact/k6/stats/datadog"
+	"github.com/loadimpact/k6/stats/graphite"
 	"github.com/loadimpact/k6/stats/influxdb"
+	jsonc "github.com/loadimpact/k6/stats/json"
 	"github.com/loadimpact/k6/stats/kafka"
 	"github.com/loadimpact/k6/stats/statsd/common"
+	"github.com/loadimpact/k6/ui"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
 	"github.com/spf13/pflag"
@@ -48,11 +56,32 @@ import (
 func configFlagSet() *pflag.FlagSet {
 	flags := pflag.NewFlagSet("", 0)
 	flags.SortFlags = false
-	flags.StringArrayP("out", "o", []string{}, "`uri` for an external metrics database")
+	flags.StringArrayP("out", "o", []string{}, "`uri` for an external metrics database, add \",shadow\" to keep its failures from affecting the test")
 	flags.BoolP("linger", "l", false, "keep the API server alive past test end")
-	flags.Bool("no-usage-report", false, "don't send anonymous stats to the developers")
+	flags.Bool("no-usage-report", false, "don't send anonymous stats to the developers, a no-usage-report file in the k6 config folder or DO_NOT_TRACK turn this off for the whole machine")
 	flags.Bool("no-thresholds", false, "don't run thresholds")
 	flags.Bool("no-summary", false, "don't show the summary at the end of the test")
+	flags.StringArray("metric-name-map", []string{}, "rename the `old=new` metric before it's processed and output")
+	flags.String("metric-prefix", "", "prefix the names of all metrics with this `prefix`, thresholds still use the original names")
+	flags.StringArray("metric-route", []string{}, "send the metrics matching a `glob=output[,output...]` only to the specified outputs")
+	flags.StringSlice("output-filter", nil, "only send the samples with any of these `tag[:value]` filters, or of failed_checks, to the outputs")
+	flags.StringSlice("on-failure-output", nil, "only send samples to these outputs, by `name` or type, if the test fails")
+	flags.StringSlice("normalize-tag-keys", nil, "normalize the tag keys sent to the outputs with the `lowercase` and/or underscore rules")
+	flags.Duration("clamp-sample-times", 0, "keep sample times at most this `tolerance` in the future, to handle clock skew")
+	flags.Bool("tag-script-hash", false, "tag all samples with script_hash, the hash of the script and the files it loaded")
+	flags.Duration("idle-abort-timeout", 0, "abort the test if the idle abort metric gets no samples for this `duration`, 0 disables it")
+	flags.String("idle-abort-metric", "iterations", "the `metric` whose samples have to keep arriving with --idle-abort-timeout")
+	flags.Duration("warmup", 0, "leave the samples of this warm-up `duration` after the start out of the thresholds and the summary, outputs get them tagged with warmup=true")
+	flags.Duration("wait-outputs-ready", 0, "start the VUs only after the outputs are ready to receive samples, waiting at most this `timeout` for them (0 waits indefinitely)")
+	flags.Int64("sample-buffer-limit", 0, "the maximum `number` of samples buffered by each output, 0 for unlimited")
+	flags.String("sample-buffer-policy", sampleBufferPolicyBlock, "what to do with new samples when an output's buffer is full: `block` the test and all outputs, or drop them")
+	flags.Bool("strict-outputs", false, "abort the test if any output fails to deliver its samples, with a distinct exit code")
+	flags.String("cloud-config-file", "", "JSON `file` with the cloud config, overriding the one from the k6 config file")
+	flags.String("thresholds-file", "", "JSON or YAML `file` with thresholds, overriding the ones for the same metrics in the script")
+	flags.String("result-file", "", "write the verdict of the test, with its exit code and breached thresholds, as JSON to the specified `file`")
+	flags.String("thresholds-stream", "", "write the results of the thresholds as NDJSON to the specified `file` every time they're evaluated")
+	flags.String("spill-file", "", "write the samples the outputs haven't delivered to the specified `file` on a panic or a second interrupt, which then stops k6 without waiting for the outputs")
+	flags.String("diagnostics-file", "", "write which samples couldn't be delivered to the outputs and why to the specified `file` at the end of the test")
 	return flags
 }
 
@@ -65,12 +94,94 @@ type Config struct {
 	NoThresholds  null.Bool `json:"noThresholds" envconfig:"no_thresholds"`
 	NoSummary     null.Bool `json:"noSummary" envconfig:"no_summary"`
 
+	MetricNameMapping map[string]string `json:"metricNameMapping" envconfig:"metric_name_mapping"`
+
+	// If set, the names of all metrics in the outputs, the summary and the REST API are prefixed
+	// with it, e.g. to keep them apart in a shared metrics database. Thresholds use the original
+	// names.
+	MetricPrefix null.String `json:"metricPrefix" envconfig:"metric_prefix"`
+
+	// Metric name globs mapped to the outputs their samples should be sent to, identified by
+	// their name label or type. Metrics that don't match any of the globs go to all outputs.
+	MetricRoutes map[string][]string `json:"metricRoutes" ignored:"true"`
+
+	// If set, only the samples with any of these `tag` or `tag:value` filters, or of failed
+	// checks with `failed_checks`, are sent to the outputs.
+	OutputFilter []string `json:"outputFilter" envconfig:"output_filter"`
+
+	// The outputs, identified by their name label or type, that should only receive the
+	// samples if the test fails, i.e. it's aborted or some of its thresholds fail.
+	OnFailureOutputs []string `json:"onFailureOutputs" envconfig:"on_failure_outputs"`
+
+	// The normalization rules applied to the tag keys of the samples sent to the outputs:
+	// `lowercase` and `underscore`, which replaces spaces, dots and dashes with underscores.
+	// Tags whose keys already conform are left untouched, and they take precedence over the
+	// tags whose keys are normalized to the same key.
+	NormalizeTagKeys []string `json:"normalizeTagKeys" envconfig:"normalize_tag_keys"`
+
+	// If set, the sample times never exceed the current time by more than this tolerance,
+	// to handle machines with unreliable clocks.
+	ClampSampleTimes types.NullDuration `json:"clampSampleTimes" envconfig:"clamp_sample_times"`
+
+	// Whether all samples get a script_hash tag with the hash of the script and the files it loaded,
+	// so results can be tied to an exact revision of them. It's always in the summary, but as a tag
+	// it adds a label to all series, so it's opt-in.
+	TagScriptHash null.Bool `json:"tagScriptHash" envconfig:"tag_script_hash"`
+
+	// If set, the samples from this long after the start of the test are left out of the metrics
+	// of the thresholds and the summary. The outputs still get them, tagged with warmup=true.
+	Warmup types.NullDuration `json:"warmup" envconfig:"warmup"`
+
+	// If set, the test is aborted when no samples of IdleAbortMetric, iterations by default, arrive
+	// for this long, e.g. because all requests hang during an outage of the target.
+	IdleAbortTimeout types.NullDuration `json:"idleAbortTimeout" envconfig:"idle_abort_timeout"`
+	IdleAbortMetric  null.String        `json:"idleAbortMetric" envconfig:"idle_abort_metric"`
+
+	// If set, the VUs aren't started until all outputs that can report it, e.g. InfluxDB, are ready
+	// to receive samples, waiting at most this long for them, or indefinitely for 0.
+	WaitOutputsReady types.NullDuration `json:"waitOutputsReady" envconfig:"wait_outputs_ready"`
+
+	// If set, the samples buffered by each output that reports them are kept under this limit,
+	// according to SampleBufferPolicy: with `block`, new samples wait until the output catches up,
+	// which eventually blocks the VUs and holds back the samples of all other outputs as well,
+	// and with `drop`, they are dropped and counted.
+	SampleBufferLimit  null.Int    `json:"sampleBufferLimit" envconfig:"sample_buffer_limit"`
+	SampleBufferPolicy null.String `json:"sampleBufferPolicy" envconfig:"sample_buffer_policy"`
+
+	// If enabled, the test is aborted when any output fails to deliver samples to its backend,
+	// e.g. after a write still failed after its retries, and k6 exits with a distinct exit code.
+	StrictOutputs null.Bool `json:"strictOutputs" envconfig:"strict_outputs"`
+
+	// A JSON or YAML file with the thresholds of the test, mapped by metric name like in the
+	// script options. They override the thresholds for the same metrics from any other source.
+	ThresholdsFile null.String `json:"thresholdsFile" envconfig:"thresholds_file"`
+
+	// If set, a small JSON document with the verdict of the test is written to this file at exit,
+	// even if the test was aborted: its exit code, breached thresholds, iterations and duration.
+	ResultFile null.String `json:"resultFile" envconfig:"result_file"`
+
+	// If set, the results of the thresholds are appended as NDJSON to this file every time the
+	// engine evaluates them, one line per threshold with its metric, value and whether it passed.
+	ThresholdsStream null.String `json:"thresholdsStream" envconfig:"thresholds_stream"`
+
+	// If set, the samples that the outputs buffered but didn't deliver are written to this file
+	// in the format of the json output when k6 has to stop without flushing them, so they can be
+	// resent or replayed with "k6 replay". That's on a panic, or on a second interrupt, which
+	// then stops k6 right away with exit code 106 instead of waiting for the outputs.
+	SpillFile null.String `json:"spillFile" envconfig:"spill_file"`
+
+	// If set, how many samples couldn't be delivered to every output, why, and a few of them as
+	// examples are written to this file as JSON at the end of the test.
+	DiagnosticsFile null.String `json:"diagnosticsFile" envconfig:"diagnostics_file"`
+
 	Collectors struct {
 		InfluxDB influxdb.Config `json:"influxdb"`
 		Kafka    kafka.Config    `json:"kafka"`
 		Cloud    cloud.Config    `json:"cloud"`
 		StatsD   common.Config   `json:"statsd"`
 		Datadog  datadog.Config  `json:"datadog"`
+		JSON     jsonc.Config    `json:"json"`
+		Graphite graphite.Config `json:"graphite"`
 	} `json:"collectors"`
 }
 
@@ -91,11 +202,73 @@ func (c Config) Apply(cfg Config) Config {
 	if cfg.NoSummary.Valid {
 		c.NoSummary = cfg.NoSummary
 	}
+	if len(cfg.MetricNameMapping) > 0 {
+		c.MetricNameMapping = cfg.MetricNameMapping
+	}
+	if cfg.MetricPrefix.Valid {
+		c.MetricPrefix = cfg.MetricPrefix
+	}
+	if len(cfg.MetricRoutes) > 0 {
+		c.MetricRoutes = cfg.MetricRoutes
+	}
+	if len(cfg.OutputFilter) > 0 {
+		c.OutputFilter = cfg.OutputFilter
+	}
+	if len(cfg.OnFailureOutputs) > 0 {
+		c.OnFailureOutputs = cfg.OnFailureOutputs
+	}
+	if len(cfg.NormalizeTagKeys) > 0 {
+		c.NormalizeTagKeys = cfg.NormalizeTagKeys
+	}
+	if cfg.ClampSampleTimes.Valid {
+		c.ClampSampleTimes = cfg.ClampSampleTimes
+	}
+	if cfg.TagScriptHash.Valid {
+		c.TagScriptHash = cfg.TagScriptHash
+	}
+	if cfg.Warmup.Valid {
+		c.Warmup = cfg.Warmup
+	}
+	if cfg.IdleAbortTimeout.Valid {
+		c.IdleAbortTimeout = cfg.IdleAbortTimeout
+	}
+	if cfg.IdleAbortMetric.Valid {
+		c.IdleAbortMetric = cfg.IdleAbortMetric
+	}
+	if cfg.WaitOutputsReady.Valid {
+		c.WaitOutputsReady = cfg.WaitOutputsReady
+	}
+	if cfg.SampleBufferLimit.Valid {
+		c.SampleBufferLimit = cfg.SampleBufferLimit
+	}
+	if cfg.SampleBufferPolicy.Valid {
+		c.SampleBufferPolicy = cfg.SampleBufferPolicy
+	}
+	if cfg.StrictOutputs.Valid {
+		c.StrictOutputs = cfg.StrictOutputs
+	}
+	if cfg.ThresholdsFile.Valid {
+		c.ThresholdsFile = cfg.ThresholdsFile
+	}
+	if cfg.ResultFile.Valid {
+		c.ResultFile = cfg.ResultFile
+	}
+	if cfg.ThresholdsStream.Valid {
+		c.ThresholdsStream = cfg.ThresholdsStream
+	}
+	if cfg.SpillFile.Valid {
+		c.SpillFile = cfg.SpillFile
+	}
+	if cfg.DiagnosticsFile.Valid {
+		c.DiagnosticsFile = cfg.DiagnosticsFile
+	}
 	c.Collectors.InfluxDB = c.Collectors.InfluxDB.Apply(cfg.Collectors.InfluxDB)
 	c.Collectors.Cloud = c.Collectors.Cloud.Apply(cfg.Collectors.Cloud)
 	c.Collectors.Kafka = c.Collectors.Kafka.Apply(cfg.Collectors.Kafka)
 	c.Collectors.StatsD = c.Collectors.StatsD.Apply(cfg.Collectors.StatsD)
 	c.Collectors.Datadog = c.Collectors.Datadog.Apply(cfg.Collectors.Datadog)
+	c.Collectors.JSON = c.Collectors.JSON.Apply(cfg.Collectors.JSON)
+	c.Collectors.Graphite = c.Collectors.Graphite.Apply(cfg.Collectors.Graphite)
 	return c
 }
 
@@ -109,14 +282,96 @@ func getConfig(flags *pflag.FlagSet) (Config, error) {
 	if err != nil {
 		return Config{}, err
 	}
-	return Config{
-		Options:       opts,
-		Out:           out,
-		Linger:        getNullBool(flags, "linger"),
-		NoUsageReport: getNullBool(flags, "no-usage-report"),
-		NoThresholds:  getNullBool(flags, "no-thresholds"),
-		NoSummary:     getNullBool(flags, "no-summary"),
-	}, nil
+	metricNameMapping, err := getMetricNameMapping(flags)
+	if err != nil {
+		return Config{}, err
+	}
+	metricRoutes, err := getMetricRoutes(flags)
+	if err != nil {
+		return Config{}, err
+	}
+	outputFilter, err := flags.GetStringSlice("output-filter")
+	if err != nil {
+		return Config{}, err
+	}
+	onFailureOutputs, err := flags.GetStringSlice("on-failure-output")
+	if err != nil {
+		return Config{}, err
+	}
+	normalizeTagKeys, err := flags.GetStringSlice("normalize-tag-keys")
+	if err != nil {
+		return Config{}, err
+	}
+	conf := Config{
+		Options:            opts,
+		Out:                out,
+		Linger:             getNullBool(flags, "linger"),
+		NoUsageReport:      getNullBool(flags, "no-usage-report"),
+		NoThresholds:       getNullBool(flags, "no-thresholds"),
+		NoSummary:          getNullBool(flags, "no-summary"),
+		MetricNameMapping:  metricNameMapping,
+		MetricPrefix:       getNullString(flags, "metric-prefix"),
+		MetricRoutes:       metricRoutes,
+		OutputFilter:       outputFilter,
+		OnFailureOutputs:   onFailureOutputs,
+		NormalizeTagKeys:   normalizeTagKeys,
+		ClampSampleTimes:   getNullDuration(flags, "clamp-sample-times"),
+		TagScriptHash:      getNullBool(flags, "tag-script-hash"),
+		Warmup:             getNullDuration(flags, "warmup"),
+		IdleAbortTimeout:   getNullDuration(flags, "idle-abort-timeout"),
+		IdleAbortMetric:    getNullString(flags, "idle-abort-metric"),
+		WaitOutputsReady:   getNullDuration(flags, "wait-outputs-ready"),
+		SampleBufferLimit:  getNullInt64(flags, "sample-buffer-limit"),
+		SampleBufferPolicy: getNullString(flags, "sample-buffer-policy"),
+		StrictOutputs:      getNullBool(flags, "strict-outputs"),
+		ThresholdsFile:     getNullString(flags, "thresholds-file"),
+		ResultFile:         getNullString(flags, "result-file"),
+		ThresholdsStream:   getNullString(flags, "thresholds-stream"),
+		SpillFile:          getNullString(flags, "spill-file"),
+		DiagnosticsFile:    getNullString(flags, "diagnostics-file"),
+	}
+	conf.Collectors.Cloud.ConfigFile = getNullString(flags, "cloud-config-file")
+	return conf, nil
+}
+
+// Parses the glob=output[,output...] metric routes from the --metric-route CLI flag.
+func getMetricRoutes(flags *pflag.FlagSet) (map[string][]string, error) {
+	routes, err := flags.GetStringArray("metric-route")
+	if err != nil {
+		return nil, err
+	}
+	if len(routes) == 0 {
+		return nil, nil
+	}
+	result := make(map[string][]string, len(routes))
+	for _, route := range routes {
+		parts := strings.SplitN(route, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.Errorf("invalid metric route '%s', expected glob=output[,output...]", route)
+		}
+		result[parts[0]] = append(result[parts[0]], strings.Split(parts[1], ",")...)
+	}
+	return result, nil
+}
+
+// Parses the old=new metric name pairs from the --metric-name-map CLI flag.
+func getMetricNameMapping(flags *pflag.FlagSet) (map[string]string, error) {
+	pairs, err := flags.GetStringArray("metric-name-map")
+	if err != nil {
+		return nil, err
+	}
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	mapping := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.Errorf("invalid metric name mapping '%s', expected old=new", pair)
+		}
+		mapping[parts[0]] = parts[1]
+	}
+	return mapping, nil
 }
 
 // Reads the configuration file from the supplied filesystem and returns it and its path.
@@ -166,6 +421,45 @@ func writeDiskConfig(fs afero.Fs, configPath string, conf Config) error {
 	return afero.WriteFile(fs, configPath, data, 0644)
 }
 
+const redactedConfigValue = "[redacted]"
+
+// redactConfig returns a copy of the supplied config with all secrets (tokens, passwords
+// and private keys) replaced, so it can be safely shown to users or written in logs.
+func redactConfig(conf Config) Config {
+	redact := func(s null.String) null.String {
+		if s.Valid && s.String != "" {
+			return null.StringFrom(redactedConfigValue)
+		}
+		return s
+	}
+
+	conf.Collectors.Cloud.Token = redact(conf.Collectors.Cloud.Token)
+	conf.Collectors.Cloud.DeprecatedToken = redact(conf.Collectors.Cloud.DeprecatedToken)
+	conf.Collectors.InfluxDB.Password = redact(conf.Collectors.InfluxDB.Password)
+	conf.Collectors.Kafka.InfluxDBConfig.Password = redact(conf.Collectors.Kafka.InfluxDBConfig.Password)
+
+	if conf.TLSAuth != nil {
+		tlsAuth := make([]*lib.TLSAuth, len(conf.TLSAuth))
+		for i, auth := range conf.TLSAuth {
+			authCopy := *auth
+			authCopy.Key = redactedConfigValue
+			tlsAuth[i] = &authCopy
+		}
+		conf.TLSAuth = tlsAuth
+	}
+	return conf
+}
+
+// dumpConfig writes the supplied config, with its secrets redacted, as indented JSON.
+func dumpConfig(w io.Writer, conf Config) error {
+	data, err := json.MarshalIndent(redactConfig(conf), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
 // Reads configuration variables from the environment.
 func readEnvConfig() (conf Config, err error) {
 	// TODO: replace envconfig and refactor the whole configuration from the groun up :/
@@ -288,6 +582,9 @@ func getConsolidatedConfig(fs afero.Fs, cliConf Config, runner lib.Runner) (conf
 	if err != nil {
 		return conf, err
 	}
+	if fileConf, err = applyCloudConfigFile(fs, cliConf, fileConf); err != nil {
+		return conf, err
+	}
 	envConf, err := readEnvConfig()
 	if err != nil {
 		return conf, err
@@ -300,9 +597,131 @@ func getConsolidatedConfig(fs afero.Fs, cliConf Config, runner lib.Runner) (conf
 	conf = conf.Apply(envConf).Apply(cliConf)
 	conf = applyDefault(conf)
 
+	if conf.ThresholdsFile.String != "" {
+		thresholds, err := readThresholdsFile(fs, conf.ThresholdsFile.String)
+		if err != nil {
+			return conf, err
+		}
+		conf.Thresholds = mergeThresholds(conf.Thresholds, thresholds)
+	}
+
 	return conf, nil
 }
 
+// applyCloudConfigFile merges the cloud config file, specified with --cloud-config-file or with
+// K6_CLOUD_CONFIG_FILE, over the cloud config from the k6 config file.
+func applyCloudConfigFile(fs afero.Fs, cliConf, fileConf Config) (Config, error) {
+	filename := cliConf.Collectors.Cloud.ConfigFile
+	if !filename.Valid {
+		var envCloudConf cloud.Config
+		if err := envconfig.Process("k6", &envCloudConf); err != nil {
+			return fileConf, err
+		}
+		filename = envCloudConf.ConfigFile
+	}
+	if filename.String == "" {
+		return fileConf, nil
+	}
+	cloudConf, err := cloud.ReadConfigFile(fs, filename.String)
+	if err != nil {
+		return fileConf, err
+	}
+	fileConf.Collectors.Cloud = fileConf.Collectors.Cloud.Apply(cloudConf)
+	return fileConf, nil
+}
+
+// readThresholdsFile reads the thresholds, mapped by metric name, from a JSON or YAML file.
+func readThresholdsFile(fs afero.Fs, filename string) (map[string]stats.Thresholds, error) {
+	data, err := afero.ReadFile(fs, filename)
+	if err != nil {
+		return nil, err
+	}
+	// Since JSON is valid YAML, both formats are handled by converting the file to JSON.
+	data, err = yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't parse the thresholds file %s", filename)
+	}
+	var thresholds map[string]stats.Thresholds
+	if err := json.Unmarshal(data, &thresholds); err != nil {
+		return nil, errors.Wrapf(err, "invalid thresholds in %s", filename)
+	}
+	return thresholds, nil
+}
+
+// mergeThresholds adds the thresholds from a thresholds file to the ones from all the other
+// sources. The thresholds file takes precedence, so the thresholds from other sources for the same
+// metrics are dropped, with a warning if they differ.
+func mergeThresholds(thresholds, fileThresholds map[string]stats.Thresholds) map[string]stats.Thresholds {
+	merged := make(map[string]stats.Thresholds, len(thresholds)+len(fileThresholds))
+	for name, ts := range thresholds {
+		merged[name] = ts
+	}
+	for name, ts := range fileThresholds {
+		if old, ok := merged[name]; ok && !sameThresholds(old, ts) {
+			log.WithField("metric", name).Warn(
+				"The thresholds from the thresholds file override the ones for the same metric from the script or config")
+		}
+		merged[name] = ts
+	}
+	return merged
+}
+
+func sameThresholds(a, b stats.Thresholds) bool {
+	if len(a.Thresholds) != len(b.Thresholds) {
+		return false
+	}
+	for i, t := range a.Thresholds {
+		o := b.Thresholds[i]
+		if t.Source != o.Source || t.AbortOnFail != o.AbortOnFail || t.AbortGracePeriod != o.AbortGracePeriod ||
+			t.Message != o.Message {
+			return false
+		}
+	}
+	return true
+}
+
+// warnThresholdUnits logs a warning for the thresholds whose values look like they're in the
+// wrong unit for their metrics, e.g. plain numbers for time metrics, given the known metrics.
+func warnThresholdUnits(metrics map[string]*stats.Metric, thresholds map[string]stats.Thresholds) {
+	names := make([]string, 0, len(thresholds))
+	for name := range thresholds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		metricName := name
+		if i := strings.IndexByte(name, '{'); i >= 0 {
+			metricName = name[:i]
+		}
+		m, ok := metrics[metricName]
+		if !ok {
+			continue
+		}
+		for _, warning := range thresholds[name].UnitWarnings(m.Contains) {
+			log.WithField("metric", name).Warn(warning)
+		}
+	}
+}
+
+// warnDisabledMetrics logs a warning for the thresholds of disabled metrics, which never get any
+// samples, so they can't be evaluated.
+func warnDisabledMetrics(disabled lib.TagSet, thresholds map[string]stats.Thresholds) {
+	names := make([]string, 0, len(thresholds))
+	for name := range thresholds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		metricName := name
+		if i := strings.IndexByte(name, '{'); i >= 0 {
+			metricName = name[:i]
+		}
+		if disabled[metricName] {
+			log.WithField("metric", name).Warn("The metric of the threshold is disabled, so it never gets any samples")
+		}
+	}
+}
+
 // applyDefault applys default options value if it is not specified by any mechenisms. This happens with types
 // which does not support by "gopkg.in/guregu/null.v3".
 //
@@ -319,24 +738,270 @@ func deriveAndValidateConfig(conf Config) (Config, error) {
 	if err != nil {
 		return result, err
 	}
-	return result, validateConfig(conf)
+	//TODO: actually return the error here instead of warning, so k6 aborts on config validation errors
+	if verr, ok := validateConfig(conf).(*ConfigValidationError); ok {
+		warnConfigProblems(verr)
+	}
+	return result, nil
+}
+
+// warnConfigProblems logs the problems with the configuration, with the invalid option and,
+// where they're known, the expected and actual values as fields, for tools that parse the logs.
+func warnConfigProblems(verr *ConfigValidationError) {
+	log.Warn("There were problems with the specified script configuration:")
+	for _, p := range verr.Problems {
+		fields := log.Fields{"option": p.Option}
+		if p.Expected != "" {
+			fields["expected"] = p.Expected
+			fields["got"] = p.Got
+		}
+		log.WithFields(fields).Warn(p.Message)
+	}
+}
+
+// ConfigProblem describes a single invalid option in the consolidated configuration.
+type ConfigProblem struct {
+	Option   string `json:"option"`
+	Expected string `json:"expected,omitempty"`
+	Got      string `json:"got,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (p ConfigProblem) String() string {
+	msg := p.Option + ": " + p.Message
+	if p.Expected != "" {
+		msg += fmt.Sprintf(" (expected %s, got '%s')", p.Expected, p.Got)
+	}
+	return msg
+}
+
+// ConfigValidationError is returned by validateConfig when the consolidated configuration has
+// invalid options. Its Error() method produces the human-readable message, while Problems allows
+// tools and embedders to inspect every issue separately.
+type ConfigValidationError struct {
+	Problems []ConfigProblem `json:"problems"`
+}
+
+func (e *ConfigValidationError) Error() string {
+	errMsgParts := []string{"There were problems with the specified script configuration:"}
+	for _, p := range e.Problems {
+		errMsgParts = append(errMsgParts, fmt.Sprintf("\t- %s", p))
+	}
+	return strings.Join(errMsgParts, "\n")
 }
 
-//TODO: remove ↓
-//nolint:unparam
+var _ error = &ConfigValidationError{}
+
+// validateConfig returns all of the problems with the configuration as a
+// *ConfigValidationError, or nil if there are none.
 func validateConfig(conf Config) error {
-	errList := conf.Validate()
-	if len(errList) == 0 {
+	problems := []ConfigProblem{}
+
+	// The errors of the schedulers come in the random order of their map.
+	optionErrors := []string{}
+	for _, err := range conf.Validate() {
+		optionErrors = append(optionErrors, err.Error())
+	}
+	sort.Strings(optionErrors)
+	for _, msg := range optionErrors {
+		problems = append(problems, ConfigProblem{Option: "execution", Message: msg})
+	}
+
+	if unit := conf.SummaryTimeUnit; unit.Valid && unit.String != "" {
+		if unit.String != "s" && unit.String != "ms" && unit.String != "us" {
+			problems = append(problems, ConfigProblem{
+				Option:   "summaryTimeUnit",
+				Expected: "one of 's', 'ms' or 'us'",
+				Got:      unit.String,
+				Message:  "invalid summary time unit",
+			})
+		}
+	}
+
+	if conf.SummaryPrecision.Valid && (conf.SummaryPrecision.Int64 < 0 || conf.SummaryPrecision.Int64 > 10) {
+		problems = append(problems, ConfigProblem{
+			Option:   "summaryPrecision",
+			Expected: "a number of decimal places between 0 and 10",
+			Got:      fmt.Sprint(conf.SummaryPrecision.Int64),
+			Message:  "invalid summary precision",
+		})
+	}
+
+	if conf.SummaryMaxEntries.Valid && conf.SummaryMaxEntries.Int64 < 0 {
+		problems = append(problems, ConfigProblem{
+			Option:   "summaryMaxEntries",
+			Expected: "a number of entries, or 0 for all of them",
+			Got:      fmt.Sprint(conf.SummaryMaxEntries.Int64),
+			Message:  "invalid summary max entries",
+		})
+	}
+
+	for _, stat := range conf.SummaryTrendStats {
+		if err := ui.VerifyTrendColumnStat(stat); err != nil {
+			problems = append(problems, ConfigProblem{
+				Option:   "summaryTrendStats",
+				Expected: "'avg', 'min', 'med', 'max' or a percentile like 'p(95)'",
+				Got:      stat,
+				Message:  err.Error(),
+			})
+		}
+	}
+
+	builtinMetrics := map[string]bool{}
+	for _, m := range metrics.Builtin() {
+		builtinMetrics[m.Name] = true
+	}
+	disabledMetrics := make([]string, 0, len(conf.DisabledMetrics))
+	for name := range conf.DisabledMetrics {
+		disabledMetrics = append(disabledMetrics, name)
+	}
+	sort.Strings(disabledMetrics)
+	for _, name := range disabledMetrics {
+		if !builtinMetrics[name] {
+			problems = append(problems, ConfigProblem{
+				Option:   "disabledMetrics",
+				Expected: "the name of a built-in metric",
+				Got:      name,
+				Message:  "only built-in metrics can be disabled",
+			})
+		}
+	}
+
+	problems = append(problems, validateMetricRoutes(conf)...)
+
+	outputs := configuredOutputs(conf)
+	for _, output := range conf.OnFailureOutputs {
+		if !outputs[output] {
+			problems = append(problems, ConfigProblem{
+				Option:   "onFailureOutputs",
+				Expected: "the name or type of a configured output",
+				Got:      output,
+				Message:  "unknown output",
+			})
+		}
+	}
+
+	if _, err := parseSampleFilter(conf.OutputFilter); err != nil {
+		problems = append(problems, ConfigProblem{
+			Option:   "outputFilter",
+			Expected: "tag, tag:value or " + failedChecksFilter,
+			Message:  err.Error(),
+		})
+	}
+
+	if conf.ClampSampleTimes.Valid && conf.ClampSampleTimes.Duration < 0 {
+		problems = append(problems, ConfigProblem{
+			Option:   "clampSampleTimes",
+			Expected: "a non-negative duration",
+			Got:      conf.ClampSampleTimes.Duration.String(),
+			Message:  "invalid sample time tolerance",
+		})
+	}
+
+	if conf.Warmup.Valid && conf.Warmup.Duration < 0 {
+		problems = append(problems, ConfigProblem{
+			Option:   "warmup",
+			Expected: "a non-negative duration",
+			Got:      conf.Warmup.Duration.String(),
+			Message:  "invalid warm-up period",
+		})
+	}
+
+	if conf.IdleAbortTimeout.Valid && conf.IdleAbortTimeout.Duration < 0 {
+		problems = append(problems, ConfigProblem{
+			Option:   "idleAbortTimeout",
+			Expected: "a non-negative duration",
+			Got:      conf.IdleAbortTimeout.Duration.String(),
+			Message:  "invalid idle abort timeout",
+		})
+	}
+
+	if conf.WaitOutputsReady.Valid && conf.WaitOutputsReady.Duration < 0 {
+		problems = append(problems, ConfigProblem{
+			Option:   "waitOutputsReady",
+			Expected: "a non-negative duration",
+			Got:      conf.WaitOutputsReady.Duration.String(),
+			Message:  "invalid timeout for the outputs to be ready",
+		})
+	}
+
+	if conf.SampleBufferLimit.Valid && conf.SampleBufferLimit.Int64 < 0 {
+		problems = append(problems, ConfigProblem{
+			Option:   "sampleBufferLimit",
+			Expected: "a non-negative number",
+			Got:      fmt.Sprint(conf.SampleBufferLimit.Int64),
+			Message:  "invalid sample buffer limit",
+		})
+	}
+	switch conf.SampleBufferPolicy.String {
+	case "", sampleBufferPolicyBlock, sampleBufferPolicyDrop:
+	default:
+		problems = append(problems, ConfigProblem{
+			Option:   "sampleBufferPolicy",
+			Expected: sampleBufferPolicyBlock + " or " + sampleBufferPolicyDrop,
+			Got:      conf.SampleBufferPolicy.String,
+			Message:  "unknown sample buffer policy",
+		})
+	}
+
+	if _, err := newTagKeyNormalizer(conf.NormalizeTagKeys); err != nil {
+		problems = append(problems, ConfigProblem{
+			Option:   "normalizeTagKeys",
+			Expected: tagKeysLowercase + " or " + tagKeysUnderscore,
+			Message:  err.Error(),
+		})
+	}
+
+	if len(problems) == 0 {
 		return nil
 	}
+	return &ConfigValidationError{Problems: problems}
+}
 
-	errMsgParts := []string{"There were problems with the specified script configuration:"}
-	for _, err := range errList {
-		errMsgParts = append(errMsgParts, fmt.Sprintf("\t- %s", err.Error()))
+// configuredOutputs returns the set of the types and name labels of the configured outputs.
+func configuredOutputs(conf Config) map[string]bool {
+	outputs := map[string]bool{}
+	for _, out := range conf.Out {
+		collectorType, arg := parseCollector(out)
+		_, name := parseCollectorName(arg)
+		outputs[collectorType] = true
+		if name != "" {
+			outputs[name] = true
+		}
 	}
-	errMsg := errors.New(strings.Join(errMsgParts, "\n"))
+	return outputs
+}
 
-	//TODO: actually return the error here instead of warning, so k6 aborts on config validation errors
-	log.Warn(errMsg)
-	return nil
+// validateMetricRoutes checks that the metric routes have valid globs and that they only
+// refer to the configured outputs, either by their name label or by their type.
+func validateMetricRoutes(conf Config) []ConfigProblem {
+	outputs := configuredOutputs(conf)
+
+	globs := make([]string, 0, len(conf.MetricRoutes))
+	for glob := range conf.MetricRoutes {
+		globs = append(globs, glob)
+	}
+	sort.Strings(globs)
+
+	problems := []ConfigProblem{}
+	for _, glob := range globs {
+		if _, err := path.Match(glob, ""); err != nil {
+			problems = append(problems, ConfigProblem{
+				Option:  "metricRoutes",
+				Got:     glob,
+				Message: "invalid metric name glob: " + err.Error(),
+			})
+		}
+		for _, output := range conf.MetricRoutes[glob] {
+			if !outputs[output] {
+				problems = append(problems, ConfigProblem{
+					Option:   "metricRoutes." + glob,
+					Expected: "the name or type of a configured output",
+					Got:      output,
+					Message:  "unknown output",
+				})
+			}
+		}
+	}
+	return problems
 }
@@ -207,6 +207,10 @@ func resetStickyGlobalVars() {
 	runType = ""
 	runNoSetup = false
 	runNoTeardown = false
+	runSetupOnly = false
+	runTeardownOnly = false
+	runSetupData = ""
+	runConfigDump = false
 }
 
 // Something that makes the test also be a valid io.Writer, useful for passing it
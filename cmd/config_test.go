@@ -21,11 +21,22 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/guregu/null.v3"
 )
 
@@ -132,3 +143,401 @@ func TestConfigApply(t *testing.T) {
 		assert.Equal(t, []string{"influxdb", "json"}, conf.Out)
 	})
 }
+
+func TestConfigMetricNameMapping(t *testing.T) {
+	t.Run("Flags", func(t *testing.T) {
+		fs := configFlagSet()
+		fs.AddFlagSet(optionFlagSet())
+		assert.NoError(t, fs.Parse([]string{
+			"--metric-name-map", "http_req_duration=http.latency",
+			"--metric-name-map", "checks=assertions",
+		}))
+		config, err := getConfig(fs)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"http_req_duration": "http.latency",
+			"checks":            "assertions",
+		}, config.MetricNameMapping)
+	})
+	t.Run("NoFlags", func(t *testing.T) {
+		fs := configFlagSet()
+		fs.AddFlagSet(optionFlagSet())
+		assert.NoError(t, fs.Parse([]string{}))
+		config, err := getConfig(fs)
+		assert.NoError(t, err)
+		assert.Nil(t, config.MetricNameMapping)
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		for _, arg := range []string{"http_req_duration", "=foo", "foo="} {
+			t.Run(arg, func(t *testing.T) {
+				fs := configFlagSet()
+				fs.AddFlagSet(optionFlagSet())
+				assert.NoError(t, fs.Parse([]string{"--metric-name-map", arg}))
+				_, err := getConfig(fs)
+				assert.Error(t, err)
+			})
+		}
+	})
+	t.Run("Apply", func(t *testing.T) {
+		conf := Config{MetricNameMapping: map[string]string{"a": "b"}}.Apply(Config{})
+		assert.Equal(t, map[string]string{"a": "b"}, conf.MetricNameMapping)
+
+		conf = conf.Apply(Config{MetricNameMapping: map[string]string{"c": "d"}})
+		assert.Equal(t, map[string]string{"c": "d"}, conf.MetricNameMapping)
+	})
+}
+
+func TestConfigMetricPrefix(t *testing.T) {
+	fs := configFlagSet()
+	fs.AddFlagSet(optionFlagSet())
+	assert.NoError(t, fs.Parse([]string{"--metric-prefix", "teamA_"}))
+	config, err := getConfig(fs)
+	assert.NoError(t, err)
+	assert.Equal(t, null.StringFrom("teamA_"), config.MetricPrefix)
+
+	conf := Config{}.Apply(config)
+	assert.Equal(t, "teamA_", conf.MetricPrefix.String)
+	conf = conf.Apply(Config{})
+	assert.Equal(t, "teamA_", conf.MetricPrefix.String)
+}
+
+func TestValidateConfig(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		assert.NoError(t, validateConfig(Config{}))
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		conf := Config{}
+		conf.SummaryTimeUnit = null.StringFrom("h")
+		conf.SummaryTrendStats = []string{"avg", "p(foo)"}
+		err := validateConfig(conf)
+		require.Error(t, err)
+
+		verr, ok := errors.Cause(ExitCode{err, invalidConfigErrorCode}).(*ConfigValidationError)
+		require.True(t, ok)
+		require.Len(t, verr.Problems, 2)
+		assert.Equal(t, "summaryTimeUnit", verr.Problems[0].Option)
+		assert.Equal(t, "h", verr.Problems[0].Got)
+		assert.Equal(t, "summaryTrendStats", verr.Problems[1].Option)
+		assert.Equal(t, "p(foo)", verr.Problems[1].Got)
+		assert.Contains(t, err.Error(), "There were problems with the specified script configuration:")
+		assert.Contains(t, err.Error(), "summaryTimeUnit: invalid summary time unit (expected one of 's', 'ms' or 'us', got 'h')")
+	})
+	t.Run("SummaryPrecision", func(t *testing.T) {
+		conf := Config{}
+		conf.SummaryPrecision = null.IntFrom(3)
+		assert.NoError(t, validateConfig(conf))
+		conf.SummaryPrecision = null.IntFrom(-1)
+		err := validateConfig(conf)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "summaryPrecision: invalid summary precision")
+	})
+	t.Run("IdleAbortTimeout", func(t *testing.T) {
+		conf := Config{IdleAbortTimeout: types.NullDurationFrom(30 * time.Second)}
+		assert.NoError(t, validateConfig(conf))
+		conf.IdleAbortTimeout = types.NullDurationFrom(-time.Second)
+		err := validateConfig(conf)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "idleAbortTimeout: invalid idle abort timeout")
+	})
+	t.Run("SummaryMaxEntries", func(t *testing.T) {
+		conf := Config{}
+		conf.SummaryMaxEntries = null.IntFrom(0)
+		assert.NoError(t, validateConfig(conf))
+		conf.SummaryMaxEntries = null.IntFrom(-5)
+		err := validateConfig(conf)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "summaryMaxEntries: invalid summary max entries")
+	})
+	t.Run("DisabledMetrics", func(t *testing.T) {
+		conf := Config{}
+		conf.DisabledMetrics = lib.GetTagSet("http_req_blocked", "http_req_connecting")
+		assert.NoError(t, validateConfig(conf))
+		conf.DisabledMetrics = lib.GetTagSet("http_req_blocked", "my_metric")
+		err := validateConfig(conf)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(),
+			"disabledMetrics: only built-in metrics can be disabled (expected the name of a built-in metric, got 'my_metric')")
+	})
+	t.Run("MetricRoutes", func(t *testing.T) {
+		conf := Config{
+			Out: []string{"influxdb=http://localhost:8086/k6", "json=out.json,name=primary"},
+			MetricRoutes: map[string][]string{
+				"http_req_*": {"influxdb", "primary"},
+				"business_*": {"cloud"},
+				"[":          {"json"},
+			},
+		}
+		err := validateConfig(conf)
+		require.Error(t, err)
+
+		verr, ok := err.(*ConfigValidationError)
+		require.True(t, ok)
+		require.Len(t, verr.Problems, 2)
+		assert.Equal(t, "metricRoutes", verr.Problems[0].Option)
+		assert.Equal(t, "[", verr.Problems[0].Got)
+		assert.Equal(t, "metricRoutes.business_*", verr.Problems[1].Option)
+		assert.Equal(t, "cloud", verr.Problems[1].Got)
+
+		delete(conf.MetricRoutes, "[")
+		delete(conf.MetricRoutes, "business_*")
+		assert.NoError(t, validateConfig(conf))
+	})
+	t.Run("OutputFilter", func(t *testing.T) {
+		assert.NoError(t, validateConfig(Config{OutputFilter: []string{"error", "status:500", "failed_checks"}}))
+
+		err := validateConfig(Config{OutputFilter: []string{":500"}})
+		require.Error(t, err)
+		verr, ok := err.(*ConfigValidationError)
+		require.True(t, ok)
+		require.Len(t, verr.Problems, 1)
+		assert.Equal(t, "outputFilter", verr.Problems[0].Option)
+	})
+	t.Run("OnFailureOutputs", func(t *testing.T) {
+		conf := Config{Out: []string{"cloud", "json=out.json,name=debug"}, OnFailureOutputs: []string{"cloud", "debug"}}
+		assert.NoError(t, validateConfig(conf))
+
+		conf.OnFailureOutputs = []string{"influxdb"}
+		err := validateConfig(conf)
+		require.Error(t, err)
+		verr, ok := err.(*ConfigValidationError)
+		require.True(t, ok)
+		require.Len(t, verr.Problems, 1)
+		assert.Equal(t, "onFailureOutputs", verr.Problems[0].Option)
+		assert.Equal(t, "influxdb", verr.Problems[0].Got)
+	})
+	t.Run("ClampSampleTimes", func(t *testing.T) {
+		assert.NoError(t, validateConfig(Config{ClampSampleTimes: types.NullDurationFrom(time.Second)}))
+
+		err := validateConfig(Config{ClampSampleTimes: types.NullDurationFrom(-time.Second)})
+		require.Error(t, err)
+		verr, ok := err.(*ConfigValidationError)
+		require.True(t, ok)
+		require.Len(t, verr.Problems, 1)
+		assert.Equal(t, "clampSampleTimes", verr.Problems[0].Option)
+	})
+	t.Run("WaitOutputsReady", func(t *testing.T) {
+		assert.NoError(t, validateConfig(Config{WaitOutputsReady: types.NullDurationFrom(0)}))
+
+		err := validateConfig(Config{WaitOutputsReady: types.NullDurationFrom(-time.Second)})
+		require.Error(t, err)
+		verr, ok := err.(*ConfigValidationError)
+		require.True(t, ok)
+		require.Len(t, verr.Problems, 1)
+		assert.Equal(t, "waitOutputsReady", verr.Problems[0].Option)
+	})
+	t.Run("SampleBuffer", func(t *testing.T) {
+		assert.NoError(t, validateConfig(Config{
+			SampleBufferLimit:  null.IntFrom(1000),
+			SampleBufferPolicy: null.StringFrom(sampleBufferPolicyDrop),
+		}))
+
+		err := validateConfig(Config{
+			SampleBufferLimit:  null.IntFrom(-1),
+			SampleBufferPolicy: null.StringFrom("wait"),
+		})
+		require.Error(t, err)
+		verr, ok := err.(*ConfigValidationError)
+		require.True(t, ok)
+		require.Len(t, verr.Problems, 2)
+		assert.Equal(t, "sampleBufferLimit", verr.Problems[0].Option)
+		assert.Equal(t, "sampleBufferPolicy", verr.Problems[1].Option)
+		assert.Equal(t, "wait", verr.Problems[1].Got)
+	})
+	t.Run("NormalizeTagKeys", func(t *testing.T) {
+		assert.NoError(t, validateConfig(Config{NormalizeTagKeys: []string{"lowercase", "underscore"}}))
+
+		err := validateConfig(Config{NormalizeTagKeys: []string{"camelcase"}})
+		require.Error(t, err)
+		verr, ok := err.(*ConfigValidationError)
+		require.True(t, ok)
+		require.Len(t, verr.Problems, 1)
+		assert.Equal(t, "normalizeTagKeys", verr.Problems[0].Option)
+	})
+}
+
+func TestConfigMetricRoutes(t *testing.T) {
+	fs := configFlagSet()
+	fs.AddFlagSet(optionFlagSet())
+	assert.NoError(t, fs.Parse([]string{
+		"--metric-route", "http_req_*=influxdb,primary",
+		"--metric-route", "checks=cloud",
+		"--metric-route", "http_req_*=statsd",
+	}))
+	config, err := getConfig(fs)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"http_req_*": {"influxdb", "primary", "statsd"},
+		"checks":     {"cloud"},
+	}, config.MetricRoutes)
+
+	fs = configFlagSet()
+	fs.AddFlagSet(optionFlagSet())
+	assert.NoError(t, fs.Parse([]string{"--metric-route", "http_req_*"}))
+	_, err = getConfig(fs)
+	assert.Error(t, err)
+}
+
+func TestDumpConfig(t *testing.T) {
+	conf := Config{}
+	conf.VUs = null.IntFrom(10)
+	conf.Collectors.Cloud.Token = null.StringFrom("secret-token")
+	conf.Collectors.InfluxDB.Password = null.StringFrom("secret-password")
+	conf.TLSAuth = []*lib.TLSAuth{{TLSAuthFields: lib.TLSAuthFields{Cert: "cert", Key: "secret-key"}}}
+
+	var buf bytes.Buffer
+	require.NoError(t, dumpConfig(&buf, conf))
+	assert.NotContains(t, buf.String(), "secret")
+	assert.Contains(t, buf.String(), `"vus": 10`)
+	assert.Contains(t, buf.String(), `"cert": "cert"`)
+
+	// The original config shouldn't be modified
+	assert.Equal(t, "secret-token", conf.Collectors.Cloud.Token.String)
+	assert.Equal(t, "secret-key", conf.TLSAuth[0].Key)
+}
+
+func TestConfigThresholdsFile(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/thresholds.yaml", []byte(`
+http_req_duration:
+  - "p(95)<500"
+checks:
+  - threshold: "rate>0.99"
+    abortOnFail: true
+`), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/thresholds.json", []byte(`{"checks": ["rate>0.9"]}`), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/invalid.yaml", []byte(`checks: [[`), 0644))
+
+	thresholds, err := readThresholdsFile(fs, "/thresholds.yaml")
+	require.NoError(t, err)
+	require.Len(t, thresholds, 2)
+	assert.Equal(t, "p(95)<500", thresholds["http_req_duration"].Thresholds[0].Source)
+	assert.True(t, thresholds["checks"].Thresholds[0].AbortOnFail)
+
+	thresholds, err = readThresholdsFile(fs, "/thresholds.json")
+	require.NoError(t, err)
+	assert.Equal(t, "rate>0.9", thresholds["checks"].Thresholds[0].Source)
+
+	_, err = readThresholdsFile(fs, "/invalid.yaml")
+	assert.Error(t, err)
+	_, err = readThresholdsFile(fs, "/nonexistent.yaml")
+	assert.Error(t, err)
+
+	t.Run("Consolidation", func(t *testing.T) {
+		var scriptThresholds map[string]stats.Thresholds
+		require.NoError(t, json.Unmarshal(
+			[]byte(`{"checks": ["rate>0.5"], "iterations": ["count>10"]}`), &scriptThresholds))
+		runner := &lib.MiniRunner{Options: lib.Options{Thresholds: scriptThresholds}}
+
+		cliConf := Config{ThresholdsFile: null.StringFrom("/thresholds.json")}
+		conf, err := getConsolidatedConfig(fs, cliConf, runner)
+		require.NoError(t, err)
+		require.Len(t, conf.Thresholds, 2)
+		assert.Equal(t, "rate>0.9", conf.Thresholds["checks"].Thresholds[0].Source)
+		assert.Equal(t, "count>10", conf.Thresholds["iterations"].Thresholds[0].Source)
+
+		cliConf = Config{ThresholdsFile: null.StringFrom("/nonexistent.json")}
+		_, err = getConsolidatedConfig(fs, cliConf, runner)
+		assert.Error(t, err)
+	})
+}
+
+func TestWarnThresholdUnits(t *testing.T) {
+	var thresholds map[string]stats.Thresholds
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"http_req_duration": ["p(95)<500ms", "avg<200"],
+		"http_req_duration{status:200}": ["max<2000"],
+		"checks": ["rate>0.9"],
+		"unknown": ["avg<100"]
+	}`), &thresholds))
+	metrics := map[string]*stats.Metric{
+		"http_req_duration": stats.New("http_req_duration", stats.Trend, stats.Time),
+		"checks":            stats.New("checks", stats.Rate),
+	}
+
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+	warnThresholdUnits(metrics, thresholds)
+
+	entries := hook.AllEntries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "http_req_duration", entries[0].Data["metric"])
+	assert.Contains(t, entries[0].Message, `"avg<200"`)
+	assert.Equal(t, "http_req_duration{status:200}", entries[1].Data["metric"])
+	assert.Contains(t, entries[1].Message, `"max<2000"`)
+}
+
+func TestDeriveAndValidateConfigWarns(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	conf := Config{}
+	conf.SummaryTimeUnit = null.StringFrom("h")
+	_, err := deriveAndValidateConfig(conf)
+	require.NoError(t, err)
+
+	entries := hook.AllEntries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "There were problems with the specified script configuration:", entries[0].Message)
+	assert.Equal(t, logrus.WarnLevel, entries[1].Level)
+	assert.Equal(t, "invalid summary time unit", entries[1].Message)
+	assert.Equal(t, "summaryTimeUnit", entries[1].Data["option"])
+	assert.Equal(t, "h", entries[1].Data["got"])
+}
+
+func TestWarnDisabledMetrics(t *testing.T) {
+	var thresholds map[string]stats.Thresholds
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"http_req_duration": ["p(95)<500"],
+		"http_req_blocked{status:200}": ["max<100"],
+		"http_req_connecting_custom": ["max<100"]
+	}`), &thresholds))
+
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+	warnDisabledMetrics(lib.GetTagSet("http_req_blocked", "http_req_connecting"), thresholds)
+
+	entries := hook.AllEntries()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "http_req_blocked{status:200}", entries[0].Data["metric"])
+	assert.Contains(t, entries[0].Message, "disabled")
+}
+
+func TestConfigCloudConfigFile(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, defaultConfigFilePath,
+		[]byte(`{"collectors": {"cloud": {"token": "disk-token", "name": "disk"}}}`), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/cloud.json", []byte(`{"token": "ci-token"}`), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/other.json", []byte(`{"token": "other-token"}`), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/invalid.json", []byte(`{"tokn": "ci-token"}`), 0644))
+
+	getCloudConf := func(cliConf Config) (Config, error) {
+		return getConsolidatedConfig(fs, cliConf, nil)
+	}
+
+	conf, err := getCloudConf(Config{})
+	require.NoError(t, err)
+	assert.Equal(t, "disk-token", conf.Collectors.Cloud.Token.String)
+
+	cliConf := Config{}
+	cliConf.Collectors.Cloud.ConfigFile = null.StringFrom("/cloud.json")
+	conf, err = getCloudConf(cliConf)
+	require.NoError(t, err)
+	assert.Equal(t, "ci-token", conf.Collectors.Cloud.Token.String)
+	assert.Equal(t, "disk", conf.Collectors.Cloud.Name.String)
+
+	require.NoError(t, os.Setenv("K6_CLOUD_CONFIG_FILE", "/other.json"))
+	defer func() { _ = os.Unsetenv("K6_CLOUD_CONFIG_FILE") }()
+	conf, err = getCloudConf(Config{})
+	require.NoError(t, err)
+	assert.Equal(t, "other-token", conf.Collectors.Cloud.Token.String)
+
+	// The CLI flag takes precedence over the environment variable
+	conf, err = getCloudConf(cliConf)
+	require.NoError(t, err)
+	assert.Equal(t, "ci-token", conf.Collectors.Cloud.Token.String)
+
+	cliConf.Collectors.Cloud.ConfigFile = null.StringFrom("/invalid.json")
+	_, err = getCloudConf(cliConf)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "tokn")
+}
@@ -0,0 +1,140 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package cmd
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/loadimpact/k6/stats"
+	"github.com/spf13/afero"
+)
+
+// How many of the undelivered samples of every output are included in the diagnostics, as
+// examples of what was lost.
+const diagnosticsSampleLimit = 10
+
+// The reasons why samples weren't delivered to an output.
+const (
+	dropReasonBufferFull = "buffer full"   // --sample-buffer-limit with the drop policy
+	dropReasonFellBehind = "fell behind"   // a shadow output or a tap couldn't keep up
+	dropReasonPanicked   = "panicked"      // a shadow output or a tap panicked while collecting them
+	failReasonBackend    = "backend error" // the output gave up on delivering samples
+)
+
+// diagnosticsSample is an undelivered sample, with the reason why it wasn't delivered.
+type diagnosticsSample struct {
+	Reason string            `json:"reason"`
+	Metric string            `json:"metric"`
+	Time   time.Time         `json:"time"`
+	Value  float64           `json:"value"`
+	Tags   map[string]string `json:"tags,omitempty"`
+}
+
+// outputDiagnostics is what k6 couldn't deliver to one output.
+type outputDiagnostics struct {
+	Output   string              `json:"output"`
+	Dropped  map[string]int64    `json:"dropped,omitempty"`
+	Failed   map[string]int64    `json:"failed,omitempty"`
+	Failures map[string]string   `json:"failures,omitempty"`
+	Samples  []diagnosticsSample `json:"samples,omitempty"`
+}
+
+// deliveryDiagnostics collects, for every output, how many samples weren't delivered to it and
+// why, with a few of them as examples, so they can be written to the --diagnostics-file at the
+// end of the test. Its methods can be called on a nil one, which records nothing.
+type deliveryDiagnostics struct {
+	mutex   sync.Mutex
+	outputs map[string]*outputDiagnostics
+}
+
+func newDeliveryDiagnostics() *deliveryDiagnostics {
+	return &deliveryDiagnostics{outputs: map[string]*outputDiagnostics{}}
+}
+
+func (d *deliveryDiagnostics) getOutput(label string) *outputDiagnostics {
+	o, ok := d.outputs[label]
+	if !ok {
+		o = &outputDiagnostics{
+			Output: label, Dropped: map[string]int64{}, Failed: map[string]int64{}, Failures: map[string]string{},
+		}
+		d.outputs[label] = o
+	}
+	return o
+}
+
+// recordDropped records the samples that weren't delivered to the output for the reason.
+func (d *deliveryDiagnostics) recordDropped(label, reason string, sampleContainers []stats.SampleContainer) {
+	if d == nil {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	o := d.getOutput(label)
+	for _, sc := range sampleContainers {
+		for _, sample := range sc.GetSamples() {
+			o.Dropped[reason]++
+			if len(o.Samples) < diagnosticsSampleLimit {
+				o.Samples = append(o.Samples, diagnosticsSample{
+					Reason: reason,
+					Metric: sample.Metric.Name,
+					Time:   sample.Time,
+					Value:  sample.Value,
+					Tags:   sample.Tags.CloneTags(),
+				})
+			}
+		}
+	}
+}
+
+// recordFailure records why the output failed to deliver samples, with the last error, and how
+// many samples it failed to deliver, if it counts them.
+func (d *deliveryDiagnostics) recordFailure(label, reason string, err error, failed int64) {
+	if d == nil || err == nil {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	o := d.getOutput(label)
+	o.Failures[reason] = err.Error()
+	if failed > 0 {
+		o.Failed[reason] += failed
+	}
+}
+
+// write writes the diagnostics of the outputs, ordered by their labels, as JSON to the file.
+func (d *deliveryDiagnostics) write(fs afero.Fs, filename string) error {
+	d.mutex.Lock()
+	outputs := make([]*outputDiagnostics, 0, len(d.outputs))
+	for _, o := range d.outputs {
+		outputs = append(outputs, o)
+	}
+	sort.Slice(outputs, func(i, j int) bool { return outputs[i].Output < outputs[j].Output })
+	data, err := json.MarshalIndent(struct {
+		Outputs []*outputDiagnostics `json:"outputs"`
+	}{outputs}, "", "  ")
+	d.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	return afero.WriteFile(fs, filename, append(data, '\n'), 0644)
+}
@@ -0,0 +1,83 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/loadimpact/k6/stats/dummy"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliveryDiagnostics(t *testing.T) {
+	var nilDiagnostics *deliveryDiagnostics
+	nilDiagnostics.recordDropped("json", dropReasonBufferFull, []stats.SampleContainer{stats.Sample{Metric: metrics.VUs}})
+	nilDiagnostics.recordFailure("json", failReasonBackend, errors.New("oops"), 1)
+
+	now := time.Unix(1000, 0).UTC()
+	tags := stats.IntoSampleTags(&map[string]string{"status": "200"})
+	sample := stats.Sample{Metric: metrics.HTTPReqs, Time: now, Tags: tags, Value: 1}
+	samples := []stats.SampleContainer{sample, stats.Samples{sample, sample}}
+
+	d := newDeliveryDiagnostics()
+	inner := &bufferRecorder{Collector: &dummy.Collector{}}
+	c := newBufferLimitedCollector(inner, "json", 3, sampleBufferPolicyDrop)
+	c.diagnostics = d
+	for i := 0; i < 6; i++ {
+		c.Collect(samples)
+	}
+	d.recordFailure("influxdb", failReasonBackend, errors.New("connection refused"), 42)
+	d.recordFailure("kafka", failReasonBackend, nil, 0)
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, d.write(fs, "/diagnostics.json"))
+	data, err := afero.ReadFile(fs, "/diagnostics.json")
+	require.NoError(t, err)
+
+	var report struct {
+		Outputs []outputDiagnostics `json:"outputs"`
+	}
+	require.NoError(t, json.Unmarshal(data, &report))
+	require.Len(t, report.Outputs, 2)
+
+	assert.Equal(t, "influxdb", report.Outputs[0].Output)
+	assert.Empty(t, report.Outputs[0].Dropped)
+	assert.Equal(t, map[string]string{"backend error": "connection refused"}, report.Outputs[0].Failures)
+	assert.Equal(t, map[string]int64{"backend error": 42}, report.Outputs[0].Failed)
+
+	assert.Equal(t, "json", report.Outputs[1].Output)
+	assert.Equal(t, map[string]int64{"buffer full": 15}, report.Outputs[1].Dropped)
+	assert.Empty(t, report.Outputs[1].Failures)
+	require.Len(t, report.Outputs[1].Samples, diagnosticsSampleLimit)
+	assert.Equal(t, diagnosticsSample{
+		Reason: "buffer full",
+		Metric: "http_reqs",
+		Time:   now,
+		Value:  1,
+		Tags:   map[string]string{"status": "200"},
+	}, report.Outputs[1].Samples[0])
+}
@@ -0,0 +1,109 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var extractOut = ""
+
+// extractCmd represents the extract command
+var extractCmd = &cobra.Command{
+	Use:   "extract",
+	Short: "Extract an archive",
+	Long: `Extract an archive.
+
+Writes the scripts and data files embedded in an archive back to a directory, so they can be
+edited and run again. Local files are written under the "file" subdirectory and remote
+modules under the "https" one, preserving their (anonymized) paths. The archived options are
+written to an "options.json" file, which can be used with --config.`,
+	Example: `
+  # Extract an archive to the myarchive directory.
+  k6 extract myarchive.tar
+
+  # Extract an archive to a specific directory.
+  k6 extract -O mytest myarchive.tar`[1:],
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filename := args[0]
+		dir := extractOut
+		if dir == "" {
+			dir = strings.TrimSuffix(filename, filepath.Ext(filename))
+		}
+
+		mainScript, err := extractArchive(defaultFs, filename, dir)
+		if err != nil {
+			return err
+		}
+		fprintf(stdout, "Extracted %s to %s, main script: %s\n", filename, dir, mainScript)
+		return nil
+	},
+}
+
+// extractArchive reads the archive at filename and writes its files and options to dir,
+// returning the path of the extracted main script.
+func extractArchive(fs afero.Fs, filename, dir string) (string, error) {
+	f, err := fs.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	arc, err := lib.ReadArchive(f)
+	if err != nil {
+		return "", err
+	}
+	mainScript, err := arc.Extract(fs, dir)
+	if err != nil {
+		return "", err
+	}
+
+	options, err := json.MarshalIndent(Config{Options: arc.Options}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := afero.WriteFile(fs, filepath.Join(dir, "options.json"), options, 0644); err != nil {
+		return "", err
+	}
+	return mainScript, nil
+}
+
+func extractCmdFlagSet() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("", pflag.ContinueOnError)
+	flags.SortFlags = false
+	flags.StringVarP(&extractOut, "extract-out", "O", extractOut,
+		"output directory, defaults to the archive filename without its extension")
+	return flags
+}
+
+func init() {
+	RootCmd.AddCommand(extractCmd)
+	extractCmd.Flags().SortFlags = false
+	extractCmd.Flags().AddFlagSet(extractCmdFlagSet())
+}
@@ -0,0 +1,76 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/consts"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestExtractArchive(t *testing.T) {
+	scriptFs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(scriptFs, "/test/script.js", []byte(`export default function() {}`), 0644))
+	require.NoError(t, afero.WriteFile(scriptFs, "/test/data.csv", []byte(`a,b`), 0644))
+	arc := &lib.Archive{
+		Type:        "js",
+		K6Version:   consts.Version,
+		Options:     lib.Options{VUs: null.IntFrom(5)},
+		FilenameURL: &url.URL{Scheme: "file", Path: "/test/script.js"},
+		Data:        []byte(`export default function() {}`),
+		PwdURL:      &url.URL{Scheme: "file", Path: "/test"},
+		Filesystems: map[string]afero.Fs{"file": scriptFs, "https": afero.NewMemMapFs()},
+	}
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, arc.Write(buf))
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/archive.tar", buf.Bytes(), 0644))
+
+	mainScript, err := extractArchive(fs, "/archive.tar", "/out")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.FromSlash("/out/file/test/script.js"), mainScript)
+
+	data, err := afero.ReadFile(fs, mainScript)
+	require.NoError(t, err)
+	assert.Equal(t, `export default function() {}`, string(data))
+	data, err = afero.ReadFile(fs, filepath.FromSlash("/out/file/test/data.csv"))
+	require.NoError(t, err)
+	assert.Equal(t, `a,b`, string(data))
+
+	data, err = afero.ReadFile(fs, filepath.FromSlash("/out/options.json"))
+	require.NoError(t, err)
+	var conf Config
+	require.NoError(t, json.Unmarshal(data, &conf))
+	assert.Equal(t, null.IntFrom(5), conf.VUs)
+
+	_, err = extractArchive(fs, "/missing.tar", "/out")
+	assert.Error(t, err)
+}
@@ -29,20 +29,34 @@ import (
 	"github.com/loadimpact/k6/js"
 	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/loader"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
+var inspectStatic = false
+
 // inspectCmd represents the resume command
 var inspectCmd = &cobra.Command{
 	Use:   "inspect [file]",
 	Short: "Inspect a script or archive",
-	Long:  `Inspect a script or archive.`,
+	Long: `Inspect a script or archive.
+
+Prints the options of a script or archive. With --static, the options of a script are read
+without running its init code, so no files are opened and no modules are imported. That's
+much faster, but only works if the options are a literal that isn't modified anywhere in the
+script. Otherwise, the init code is run after all, with a warning.`,
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		pwd, err := os.Getwd()
 		if err != nil {
 			return err
 		}
+		runtimeOptions, err := getRuntimeOptions(cmd.Flags())
+		if err != nil {
+			return err
+		}
+
+		configureLoader(runtimeOptions)
 		filesystems := loader.CreateFilesystems()
 		src, err := loader.ReadSource(args[0], pwd, filesystems, os.Stdin)
 		if err != nil {
@@ -54,11 +68,6 @@ var inspectCmd = &cobra.Command{
 			typ = detectType(src.Data)
 		}
 
-		runtimeOptions, err := getRuntimeOptions(cmd.Flags())
-		if err != nil {
-			return err
-		}
-
 		var (
 			opts lib.Options
 			b    *js.Bundle
@@ -70,12 +79,25 @@ var inspectCmd = &cobra.Command{
 			if err != nil {
 				return err
 			}
+			if inspectStatic {
+				// The options of the script were already saved in the archive.
+				opts = arc.Options
+				break
+			}
 			b, err = js.NewBundleFromArchive(arc, runtimeOptions)
 			if err != nil {
 				return err
 			}
 			opts = b.Options
 		case typeJS:
+			if inspectStatic {
+				if opts, err = js.ReadStaticOptions(src); err == nil {
+					break
+				}
+				// The options that can't be read statically are read the usual way instead, so
+				// the result is never partial.
+				log.WithError(err).Warn("Couldn't read the options statically, running the init code instead")
+			}
 			b, err = js.NewBundle(src, filesystems, runtimeOptions)
 			if err != nil {
 				return err
@@ -97,4 +119,5 @@ func init() {
 	inspectCmd.Flags().SortFlags = false
 	inspectCmd.Flags().AddFlagSet(runtimeOptionFlagSet(false))
 	inspectCmd.Flags().StringVarP(&runType, "type", "t", runType, "override file `type`, \"js\" or \"archive\"")
+	inspectCmd.Flags().BoolVar(&inspectStatic, "static", inspectStatic, "read the options without running the script's init code")
 }
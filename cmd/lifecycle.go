@@ -0,0 +1,102 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package cmd
+
+import (
+	"context"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// runOnlySetup runs only the setup() of the script, e.g. to provision the data for a test, and
+// writes the data it returned to setupDataFile, if it's set, for a later --teardown-only run.
+func runOnlySetup(ctx context.Context, r lib.Runner, fs afero.Fs, setupDataFile string) error {
+	if err := runLifecycleFunc(ctx, "setup", r.Setup); err != nil {
+		return err
+	}
+	if setupDataFile == "" {
+		return nil
+	}
+	// If setup() returned nothing, the file is left empty, so teardown() gets undefined again.
+	if err := afero.WriteFile(fs, setupDataFile, r.GetSetupData(), 0644); err != nil {
+		return errors.Wrap(err, "couldn't write the setup data")
+	}
+	log.WithField("filename", setupDataFile).Debug("Wrote the setup data")
+	return nil
+}
+
+// runOnlyTeardown runs only the teardown() of the script, e.g. to clean up after a test, with
+// the data from setupDataFile, if it's set, as written by an earlier --setup-only run.
+func runOnlyTeardown(ctx context.Context, r lib.Runner, fs afero.Fs, setupDataFile string) error {
+	if setupDataFile != "" {
+		data, err := afero.ReadFile(fs, setupDataFile)
+		if err != nil {
+			return errors.Wrap(err, "couldn't read the setup data")
+		}
+		if len(data) == 0 {
+			data = nil
+		}
+		r.SetSetupData(data)
+	}
+	return runLifecycleFunc(ctx, "teardown", r.Teardown)
+}
+
+// runLifecycleFunc runs setup() or teardown() without an executor. There are no outputs either,
+// so the samples they emit are dropped.
+func runLifecycleFunc(
+	ctx context.Context, name string, fn func(context.Context, chan<- stats.SampleContainer) error,
+) error {
+	samples := make(chan stats.SampleContainer)
+	done := make(chan struct{})
+	dropped := 0
+	go func() {
+		defer close(done)
+		for range samples {
+			dropped++
+		}
+	}()
+
+	log.WithField("function", name).Debug("Running only the lifecycle function")
+	err := fn(ctx, samples)
+	close(samples)
+	<-done
+	log.WithFields(log.Fields{"function": name, "samples": dropped}).Debug("Dropped the samples of the lifecycle function")
+	return err
+}
+
+// validateLifecycleFlags checks that --setup-only and --teardown-only aren't combined with each
+// other, or with the flags that skip the function they'd run.
+func validateLifecycleFlags() error {
+	switch {
+	case runSetupOnly && runTeardownOnly:
+		return errors.New("--setup-only and --teardown-only can't be used together")
+	case runSetupOnly && runNoSetup:
+		return errors.New("--setup-only can't be used with --no-setup")
+	case runTeardownOnly && runNoTeardown:
+		return errors.New("--teardown-only can't be used with --no-teardown")
+	case runSetupData != "" && !runSetupOnly && !runTeardownOnly:
+		return errors.New("--setup-data can only be used with --setup-only or --teardown-only")
+	}
+	return nil
+}
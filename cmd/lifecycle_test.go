@@ -0,0 +1,85 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunLifecycleOnly(t *testing.T) {
+	var teardownData []byte
+	newRunner := func() *lib.MiniRunner {
+		return &lib.MiniRunner{
+			SetupFn: func(ctx context.Context, out chan<- stats.SampleContainer) ([]byte, error) {
+				out <- stats.Sample{Metric: metrics.HTTPReqs, Value: 1}
+				return []byte(`{"users":[1,2,3]}`), nil
+			},
+			TeardownFn: func(ctx context.Context, out chan<- stats.SampleContainer) error {
+				out <- stats.Sample{Metric: metrics.HTTPReqs, Value: 1}
+				return nil
+			},
+		}
+	}
+	fs := afero.NewMemMapFs()
+
+	r := newRunner()
+	require.NoError(t, runOnlySetup(context.Background(), r, fs, "/setup.json"))
+	data, err := afero.ReadFile(fs, "/setup.json")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"users":[1,2,3]}`, string(data))
+
+	r = newRunner()
+	r.TeardownFn = func(ctx context.Context, out chan<- stats.SampleContainer) error {
+		teardownData = r.GetSetupData()
+		return nil
+	}
+	require.NoError(t, runOnlyTeardown(context.Background(), r, fs, "/setup.json"))
+	assert.JSONEq(t, `{"users":[1,2,3]}`, string(teardownData))
+
+	r = newRunner()
+	r.TeardownFn = func(ctx context.Context, out chan<- stats.SampleContainer) error {
+		return errors.New("cleanup failed")
+	}
+	assert.EqualError(t, runOnlyTeardown(context.Background(), r, fs, ""), "cleanup failed")
+	assert.Error(t, runOnlyTeardown(context.Background(), newRunner(), fs, "/nonexistent.json"))
+}
+
+func TestValidateLifecycleFlags(t *testing.T) {
+	defer resetStickyGlobalVars()
+
+	assert.NoError(t, validateLifecycleFlags())
+	runSetupOnly, runTeardownOnly = true, true
+	assert.Error(t, validateLifecycleFlags())
+	runTeardownOnly, runNoSetup = false, true
+	assert.Error(t, validateLifecycleFlags())
+	runSetupOnly, runNoSetup, runSetupData = false, false, "setup.json"
+	assert.Error(t, validateLifecycleFlags())
+	runTeardownOnly = true
+	assert.NoError(t, validateLifecycleFlags())
+}
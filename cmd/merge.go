@@ -0,0 +1,112 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/loadimpact/k6/ui"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var mergeOut = ""
+
+// mergeCmd represents the merge command
+var mergeCmd = &cobra.Command{
+	Use:   "merge",
+	Short: "Merge the summaries of a distributed test",
+	Long: `Merge the summaries of a distributed test.
+
+Combines the summaries exported with --summary-export and --summary-merge-data by the
+machines of a distributed test into the summary of the whole test. Counters are summed, and
+so are the gauges every machine adds to, like vus, while the other gauges keep the largest
+value. Rates are recalculated from their passes and fails, and the trends are recalculated
+from their merged histograms, with a precision of 3 significant digits. A threshold fails if
+it failed on any machine, and the passes and fails of the checks are summed.`,
+	Example: `
+  # Export the summary of a machine with the data needed to merge it.
+  k6 run --summary-export machine1.json --summary-merge-data script.js
+
+  # Merge the summaries of two machines and print the result.
+  k6 merge machine1.json machine2.json
+
+  # Merge the summaries of all machines into a file.
+  k6 merge -O summary.json machine*.json`[1:],
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := mergeSummaryFiles(defaultFs, args)
+		if err != nil {
+			return err
+		}
+		if mergeOut == "" {
+			_, err = stdout.Write(data)
+			return err
+		}
+		return afero.WriteFile(defaultFs, mergeOut, data, 0644)
+	},
+}
+
+// mergeSummaryFiles reads the summaries in the files and returns their merged summary as JSON.
+func mergeSummaryFiles(fs afero.Fs, filenames []string) ([]byte, error) {
+	summaries := make([]ui.ExportedSummary, 0, len(filenames))
+	for _, filename := range filenames {
+		data, err := afero.ReadFile(fs, filename)
+		if err != nil {
+			return nil, err
+		}
+		var summary ui.ExportedSummary
+		if err := json.Unmarshal(data, &summary); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+
+	merged, warnings, err := ui.MergeSummaries(summaries)
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range warnings {
+		log.Warn(warning)
+	}
+
+	var buf bytes.Buffer
+	if err := ui.WriteSummaryJSON(&buf, merged); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func mergeCmdFlagSet() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("", pflag.ContinueOnError)
+	flags.SortFlags = false
+	flags.StringVarP(&mergeOut, "merge-out", "O", mergeOut, "output file, defaults to stdout")
+	return flags
+}
+
+func init() {
+	RootCmd.AddCommand(mergeCmd)
+	mergeCmd.Flags().SortFlags = false
+	mergeCmd.Flags().AddFlagSet(mergeCmdFlagSet())
+}
@@ -0,0 +1,60 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/loadimpact/k6/ui"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeSummaryFiles(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/a.json", []byte(`{"metrics": {
+		"iterations": {"type": "counter", "contains": "default", "values": {"count": 10, "rate": 1}},
+		"checks": {"type": "rate", "contains": "default", "values": {"rate": 1}, "merge": {"passes": 4},
+			"thresholds": {"rate>0.9": {"ok": true}}}
+	}}`), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/b.json", []byte(`{"metrics": {
+		"iterations": {"type": "counter", "contains": "default", "values": {"count": 20, "rate": 2}},
+		"checks": {"type": "rate", "contains": "default", "values": {"rate": 0.5}, "merge": {"passes": 2, "fails": 2},
+			"thresholds": {"rate>0.9": {"ok": false}}}
+	}}`), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/broken.json", []byte(`{`), 0644))
+
+	data, err := mergeSummaryFiles(fs, []string{"/a.json", "/b.json"})
+	require.NoError(t, err)
+	var merged ui.ExportedSummary
+	require.NoError(t, json.Unmarshal(data, &merged))
+	assert.Equal(t, map[string]float64{"count": 30, "rate": 3}, merged.Metrics["iterations"].Values)
+	assert.Equal(t, map[string]float64{"rate": 0.75}, merged.Metrics["checks"].Values)
+	assert.Equal(t, &ui.ExportedMergeData{Passes: 6, Fails: 2}, merged.Metrics["checks"].Merge)
+	assert.Equal(t, map[string]ui.ExportedThreshold{"rate>0.9": {OK: false}}, merged.Metrics["checks"].Thresholds)
+
+	_, err = mergeSummaryFiles(fs, []string{"/a.json", "/missing.json"})
+	assert.Error(t, err)
+	_, err = mergeSummaryFiles(fs, []string{"/a.json", "/broken.json"})
+	assert.Error(t, err)
+}
@@ -65,6 +65,16 @@ func optionFlagSet() *pflag.FlagSet {
 	flags.StringSlice("blacklist-ip", nil, "blacklist an `ip range` from being called")
 	flags.StringSlice("summary-trend-stats", nil, "define `stats` for trend metrics (response times), one or more as 'avg,p(95),...'")
 	flags.String("summary-time-unit", "", "define the time unit used to display the trend stats. Possible units are: 's', 'ms' and 'us'")
+	flags.Int64("trend-reservoir-size", 0, "keep at most `n` randomly sampled values per trend metric, approximating percentiles")
+	flags.Int64("trend-reservoir-seed", 0, "sample the values of the trend metrics with this `seed`, so the sampling can be reproduced; random by default")
+	flags.String("summary-export", "", "also export the end-of-test summary as JSON to the specified `file`")
+	flags.String("summary-junit", "", "also write the end-of-test summary as JUnit XML, with a test case per threshold, to the specified `file`")
+	flags.String("summary-hdr", "", "also write the distributions of the trend metrics as an HdrHistogram log to the specified `file`")
+	flags.String("summary-svg", "", "also write a chart of the key trend metrics over the test as SVG to the specified `file`")
+	flags.Int64("summary-precision", 0, "show the values in the summary with this many decimal `places`")
+	flags.Int64("summary-max-entries", 0, "show at most `n` sub-groups per group and submetrics per metric in the summary, 0 for all")
+	flags.Int64("summary-trend-values", 0, "include up to `n` raw values per trend metric in the exported summary")
+	flags.Bool("summary-merge-data", false, "include the data needed to merge the summaries of a distributed test, like the histograms of the trends, in the exported summary")
 	// system-tags must have a default value, but we can't specify it here, otherwiese, it will always override others.
 	// set it to nil here, and add the default in applyDefault() instead.
 	systemTagsCliHelpText := fmt.Sprintf(
@@ -72,6 +82,7 @@ func optionFlagSet() *pflag.FlagSet {
 		lib.DefaultSystemTagList,
 	)
 	flags.StringSlice("system-tags", nil, systemTagsCliHelpText)
+	flags.StringSlice("disable-metrics", nil, "don't sample or process these built-in `metrics`, e.g. http_req_blocked")
 	flags.StringSlice("tag", nil, "add a `tag` to be applied to all samples, as `[name]=[value]`")
 	flags.String("console-output", "", "redirects the console logging to the provided output file")
 	flags.Bool("discard-response-bodies", false, "Read but don't process or save HTTP response bodies")
@@ -96,6 +107,16 @@ func getOptions(flags *pflag.FlagSet) (lib.Options, error) {
 		MinIterationDuration:  getNullDuration(flags, "min-iteration-duration"),
 		Throw:                 getNullBool(flags, "throw"),
 		DiscardResponseBodies: getNullBool(flags, "discard-response-bodies"),
+		TrendReservoirSize:    getNullInt64(flags, "trend-reservoir-size"),
+		TrendReservoirSeed:    getNullInt64(flags, "trend-reservoir-seed"),
+		SummaryExport:         getNullString(flags, "summary-export"),
+		SummaryJUnit:          getNullString(flags, "summary-junit"),
+		SummaryHDR:            getNullString(flags, "summary-hdr"),
+		SummarySVG:            getNullString(flags, "summary-svg"),
+		SummaryPrecision:      getNullInt64(flags, "summary-precision"),
+		SummaryMaxEntries:     getNullInt64(flags, "summary-max-entries"),
+		SummaryTrendValues:    getNullInt64(flags, "summary-trend-values"),
+		SummaryMergeData:      getNullBool(flags, "summary-merge-data"),
 		// Default values for options without CLI flags:
 		// TODO: find a saner and more dev-friendly and error-proof way to handle options
 		SetupTimeout:    types.NullDuration{Duration: types.Duration(10 * time.Second), Valid: false},
@@ -131,6 +152,14 @@ func getOptions(flags *pflag.FlagSet) (lib.Options, error) {
 		opts.SystemTags = lib.GetTagSet(systemTagList...)
 	}
 
+	if flags.Changed("disable-metrics") {
+		disabledMetrics, err := flags.GetStringSlice("disable-metrics")
+		if err != nil {
+			return opts, err
+		}
+		opts.DisabledMetrics = lib.GetTagSet(disabledMetrics...)
+	}
+
 	blacklistIPStrings, err := flags.GetStringSlice("blacklist-ip")
 	if err != nil {
 		return opts, err
@@ -0,0 +1,128 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2019 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// The kinds of profiles of the k6 process itself that can be captured with --profile.
+const (
+	profileCPU  = "cpu"
+	profileHeap = "heap"
+)
+
+// k6Profiler captures pprof profiles of the k6 process itself, not of the system under test,
+// to help find out whether k6 or the script is the bottleneck on a saturated machine. The
+// CPU profile covers the whole test run, while the heap profile is written at its end.
+type k6Profiler struct {
+	fs     afero.Fs
+	dir    string
+	heap   bool
+	cpu    afero.File
+	logger *log.Logger
+
+	stopOnce sync.Once
+	stopErr  error
+}
+
+// parseProfileKinds returns which of the CPU and heap profiles were requested.
+func parseProfileKinds(kinds []string) (cpu, heap bool, err error) {
+	for _, kind := range kinds {
+		switch kind {
+		case profileCPU:
+			cpu = true
+		case profileHeap:
+			heap = true
+		default:
+			return false, false, errors.Errorf("unknown profile '%s', it should be %s or %s", kind, profileCPU, profileHeap)
+		}
+	}
+	return cpu, heap, nil
+}
+
+// startProfiling validates the requested profile kinds and starts the CPU profiling, if it
+// was requested. The profiles are written as k6-<kind>.pprof files in dir.
+func startProfiling(fs afero.Fs, kinds []string, dir string, logger *log.Logger) (*k6Profiler, error) {
+	cpu, heap, err := parseProfileKinds(kinds)
+	if err != nil {
+		return nil, err
+	}
+	p := &k6Profiler{fs: fs, dir: dir, heap: heap, logger: logger}
+	if cpu {
+		f, err := fs.Create(p.path(profileCPU))
+		if err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		p.cpu = f
+	}
+	return p, nil
+}
+
+func (p *k6Profiler) path(kind string) string {
+	return filepath.Join(p.dir, "k6-"+kind+".pprof")
+}
+
+// stop stops the CPU profiling and writes the heap profile. It's safe to call it repeatedly.
+func (p *k6Profiler) stop() error {
+	p.stopOnce.Do(func() {
+		if p.cpu != nil {
+			pprof.StopCPUProfile()
+			if err := p.cpu.Close(); err != nil {
+				p.stopErr = err
+				return
+			}
+			p.logger.WithField("file", p.path(profileCPU)).Info("Wrote the CPU profile of k6")
+		}
+		if p.heap {
+			p.stopErr = p.writeHeapProfile()
+		}
+	})
+	return p.stopErr
+}
+
+func (p *k6Profiler) writeHeapProfile() error {
+	f, err := p.fs.Create(p.path(profileHeap))
+	if err != nil {
+		return err
+	}
+	runtime.GC() // get up-to-date statistics
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	p.logger.WithField("file", p.path(profileHeap)).Info("Wrote the heap profile of k6")
+	return nil
+}
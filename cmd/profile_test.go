@@ -0,0 +1,67 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2019 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestK6Profiler(t *testing.T) {
+	logger, hook := logtest.NewNullLogger()
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := startProfiling(afero.NewMemMapFs(), []string{"cpu", "disk"}, ".", logger)
+		assert.EqualError(t, err, "unknown profile 'disk', it should be cpu or heap")
+	})
+	t.Run("None", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		p, err := startProfiling(fs, nil, "/profiles", logger)
+		require.NoError(t, err)
+		require.NoError(t, p.stop())
+		exists, err := afero.DirExists(fs, "/profiles")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+	t.Run("CPUAndHeap", func(t *testing.T) {
+		hook.Reset()
+		fs := afero.NewMemMapFs()
+		require.NoError(t, fs.MkdirAll("/profiles", 0755))
+		p, err := startProfiling(fs, []string{"cpu", "heap"}, "/profiles", logger)
+		require.NoError(t, err)
+		require.NoError(t, p.stop())
+		require.NoError(t, p.stop()) // stopping again does nothing
+
+		for _, kind := range []string{"cpu", "heap"} {
+			info, err := fs.Stat(filepath.Join("/profiles", "k6-"+kind+".pprof"))
+			require.NoError(t, err, kind)
+			assert.NotZero(t, info.Size(), kind)
+		}
+		require.Len(t, hook.AllEntries(), 2)
+		assert.Equal(t, logrus.InfoLevel, hook.LastEntry().Level)
+	})
+}
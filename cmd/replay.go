@@ -0,0 +1,236 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/loadimpact/k6/core"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	jsonc "github.com/loadimpact/k6/stats/json"
+	"github.com/loadimpact/k6/ui"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	replayThresholdsFile = ""
+	replaySummaryExport  = ""
+	replaySummaryJUnit   = ""
+	replaySummaryHDR     = ""
+	replayCheckpoint     = ""
+)
+
+// replayCmd represents the replay command
+var replayCmd = &cobra.Command{
+	Use:   "replay [file]",
+	Short: "Replay the results of a test run",
+	Long: `Replay the results of a test run.
+
+Reads the samples written by the json output (-o json=file.json) of an earlier test run, or
+spilled with --spill-file, and feeds them through the metrics engine again, printing the
+end-of-test summary. Thresholds can be supplied with --thresholds-file, so they can be
+evaluated, or tuned, without running the test again; the exit code is the same as the one of
+"k6 run" when they have failed.
+
+With --checkpoint, the summary is produced from a metrics checkpoint written by "k6 run
+--checkpoint-interval" instead, e.g. after k6 crashed during a long test.`,
+	Example: `
+  # Print the summary of a test run.
+  k6 replay results.json
+
+  # Evaluate thresholds over the results of a test run.
+  k6 replay --thresholds-file thresholds.yaml results.json
+
+  # Print the summary of the last checkpoint of a crashed test run.
+  k6 replay --checkpoint k6-checkpoint.json`[1:],
+	Args: func(cmd *cobra.Command, args []string) error {
+		if replayCheckpoint != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := lib.Options{}
+		if replayThresholdsFile != "" {
+			thresholds, err := readThresholdsFile(defaultFs, replayThresholdsFile)
+			if err != nil {
+				return err
+			}
+			opts.Thresholds = thresholds
+		}
+
+		filename, replay := "", replayJSONOutput
+		if replayCheckpoint != "" {
+			filename, replay = replayCheckpoint, replayMetricsCheckpoint
+		} else {
+			filename = args[0]
+		}
+		f, err := defaultFs.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+
+		engine, duration, err := replay(f, opts)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't replay %s", filename)
+		}
+
+		summaryData := ui.SummaryData{
+			Opts:    opts,
+			Metrics: engine.Metrics,
+			Time:    duration,
+		}
+		fprintf(stdout, "\n")
+		ui.Summarize(stdout, "", summaryData)
+		fprintf(stdout, "\n")
+		if replaySummaryExport != "" {
+			if err := exportSummary(afero.NewOsFs(), replaySummaryExport, summaryData); err != nil {
+				log.WithError(err).Error("Couldn't export the summary")
+			}
+		}
+		if replaySummaryJUnit != "" {
+			if err := exportSummaryJUnit(afero.NewOsFs(), replaySummaryJUnit, summaryData); err != nil {
+				log.WithError(err).Error("Couldn't write the JUnit summary")
+			}
+		}
+		if replaySummaryHDR != "" {
+			if err := exportSummaryHDR(afero.NewOsFs(), replaySummaryHDR, summaryData); err != nil {
+				log.WithError(err).Error("Couldn't write the HDR histograms of the summary")
+			}
+		}
+
+		if engine.IsTainted() {
+			return ExitCode{thresholdsFailedError(engine), thresholdHaveFailedErroCode}
+		}
+		return nil
+	},
+}
+
+// replayEnvelope is an entry of a json output file, with its data left to be decoded by type.
+type replayEnvelope struct {
+	Type   string          `json:"type"`
+	Metric string          `json:"metric"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// replayJSONOutput reads the metrics and samples from a json output file and processes them with
+// a new engine, which evaluates the given thresholds as if the test had run for the time between
+// the first and the last sample. That time is returned along with the engine.
+func replayJSONOutput(r io.Reader, opts lib.Options) (*core.Engine, time.Duration, error) {
+	metrics := make(map[string]*stats.Metric)
+	var samples stats.Samples
+	var start, end time.Time
+
+	dec := json.NewDecoder(r)
+	for {
+		var env replayEnvelope
+		if err := dec.Decode(&env); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, 0, err
+		}
+
+		switch env.Type {
+		case "Metric":
+			var m stats.Metric
+			if err := json.Unmarshal(env.Data, &m); err != nil {
+				return nil, 0, errors.Wrapf(err, "invalid metric %s", env.Metric)
+			}
+			metrics[env.Metric] = stats.New(env.Metric, m.Type, m.Contains)
+		case "Point":
+			m, ok := metrics[env.Metric]
+			if !ok {
+				return nil, 0, errors.Errorf("sample of the undefined metric %s", env.Metric)
+			}
+			var s jsonc.JSONSample
+			if err := json.Unmarshal(env.Data, &s); err != nil {
+				return nil, 0, errors.Wrapf(err, "invalid sample of the metric %s", env.Metric)
+			}
+			samples = append(samples, stats.Sample{Metric: m, Time: s.Time, Value: s.Value, Tags: s.Tags})
+			if start.IsZero() || s.Time.Before(start) {
+				start = s.Time
+			}
+			if s.Time.After(end) {
+				end = s.Time
+			}
+		case "Threshold":
+			// The recorded threshold results are skipped, since the thresholds are evaluated again.
+		default:
+			return nil, 0, errors.Errorf("unknown entry type %q", env.Type)
+		}
+	}
+
+	engine, err := core.NewEngine(nil, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	duration := end.Sub(start)
+	engine.ReplaySamples([]stats.SampleContainer{samples}, duration)
+	return engine, duration, nil
+}
+
+// replayMetricsCheckpoint restores the metrics from a checkpoint in a new engine, which evaluates
+// the given thresholds as if the test had ended when the checkpoint was taken. The test's run time
+// at that point is returned along with the engine.
+func replayMetricsCheckpoint(r io.Reader, opts lib.Options) (*core.Engine, time.Duration, error) {
+	checkpoint, err := core.ReadMetricsCheckpoint(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	engine, err := core.NewEngine(nil, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := engine.RestoreMetricsCheckpoint(checkpoint); err != nil {
+		return nil, 0, err
+	}
+	return engine, checkpoint.Duration, nil
+}
+
+func replayCmdFlagSet() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("", pflag.ContinueOnError)
+	flags.SortFlags = false
+	flags.StringVar(&replayThresholdsFile, "thresholds-file", replayThresholdsFile,
+		"JSON or YAML `file` with the thresholds to evaluate, mapped by metric name")
+	flags.StringVar(&replayCheckpoint, "checkpoint", replayCheckpoint,
+		"produce the summary from the metrics checkpoint in `file` instead of a json output file")
+	flags.StringVar(&replaySummaryExport, "summary-export", replaySummaryExport,
+		"output the end-of-test summary report to JSON `file`")
+	flags.StringVar(&replaySummaryJUnit, "summary-junit", replaySummaryJUnit,
+		"output the end-of-test summary as JUnit XML, with a test case per threshold, to `file`")
+	flags.StringVar(&replaySummaryHDR, "summary-hdr", replaySummaryHDR,
+		"output the distributions of the trend metrics as an HdrHistogram log to `file`")
+	return flags
+}
+
+func init() {
+	RootCmd.AddCommand(replayCmd)
+	replayCmd.Flags().SortFlags = false
+	replayCmd.Flags().AddFlagSet(replayCmdFlagSet())
+}
@@ -0,0 +1,112 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	jsonc "github.com/loadimpact/k6/stats/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayJSONOutput(t *testing.T) {
+	metric := stats.New("my_counter", stats.Counter)
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	buf := bytes.NewBuffer(nil)
+	enc := json.NewEncoder(buf)
+	require.NoError(t, enc.Encode(jsonc.WrapMetric(metric)))
+	for i, tag := range []string{"a", "b", "a"} {
+		require.NoError(t, enc.Encode(jsonc.WrapSample(&stats.Sample{
+			Metric: metric,
+			Time:   start.Add(time.Duration(i) * 5 * time.Second),
+			Tags:   stats.IntoSampleTags(&map[string]string{"tag": tag}),
+			Value:  5,
+		})))
+	}
+	require.NoError(t, enc.Encode(jsonc.WrapThresholdResult(stats.ThresholdResult{
+		Time: start, Metric: "my_counter", Threshold: "rate>=2", Passed: true,
+	})))
+
+	testdata := map[string]struct {
+		thresholds map[string][]string
+		pass       bool
+	}{
+		"no thresholds": {nil, true},
+		"passing":       {map[string][]string{"my_counter": {"rate>=1.5"}}, true},
+		"failing":       {map[string][]string{"my_counter": {"rate>=2"}}, false},
+		"submetric":     {map[string][]string{"my_counter{tag:a}": {"count==10"}}, true},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			opts := lib.Options{Thresholds: make(map[string]stats.Thresholds)}
+			for m, srcs := range data.thresholds {
+				ths, err := stats.NewThresholds(srcs)
+				require.NoError(t, err)
+				opts.Thresholds[m] = ths
+			}
+
+			engine, duration, err := replayJSONOutput(bytes.NewReader(buf.Bytes()), opts)
+			require.NoError(t, err)
+			assert.Equal(t, 10*time.Second, duration)
+			require.Contains(t, engine.Metrics, "my_counter")
+			assert.Equal(t, float64(15), engine.Metrics["my_counter"].Sink.(*stats.CounterSink).Value)
+			assert.Equal(t, data.pass, !engine.IsTainted())
+		})
+	}
+
+	t.Run("undefined metric", func(t *testing.T) {
+		_, _, err := replayJSONOutput(strings.NewReader(
+			`{"type":"Point","metric":"other","data":{"time":"2019-01-01T00:00:00Z","value":1}}`,
+		), lib.Options{})
+		assert.EqualError(t, err, "sample of the undefined metric other")
+	})
+}
+
+func TestReplayMetricsCheckpoint(t *testing.T) {
+	checkpoint := `{"time":"2019-01-01T00:00:00Z","duration":10000000000,"metrics":[` +
+		`{"name":"my_counter","type":"counter","contains":"default","tainted":null,"value":15},` +
+		`{"name":"my_trend","type":"trend","contains":"time","tainted":null,` +
+		`"min":1,"max":3,"count":3,"sum":6,` +
+		`"histogram":"HISTFAAAAD54nAAxAM7/HISTEwAAAAkAAAAAAAAAAwAAAAAAAAABAAAAAAAAC7g/8AAAAAAAAM8PAs0PApUIAgMAbl0Fow=="}]}`
+
+	ths, err := stats.NewThresholds([]string{"rate>=2"})
+	require.NoError(t, err)
+	opts := lib.Options{Thresholds: map[string]stats.Thresholds{"my_counter": ths}}
+
+	engine, duration, err := replayMetricsCheckpoint(strings.NewReader(checkpoint), opts)
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, duration)
+	assert.Equal(t, float64(15), engine.Metrics["my_counter"].Sink.(*stats.CounterSink).Value)
+	assert.Equal(t, 2.0, engine.Metrics["my_trend"].Sink.Format(duration)["avg"])
+	assert.Equal(t, 2.0, engine.Metrics["my_trend"].Sink.Format(duration)["med"])
+	assert.True(t, engine.IsTainted())
+
+	_, _, err = replayMetricsCheckpoint(strings.NewReader(`{`), lib.Options{})
+	assert.Error(t, err)
+}
@@ -23,6 +23,7 @@ package cmd
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	golog "log"
 	"os"
 	"path/filepath"
@@ -63,6 +64,15 @@ var (
 	noColor bool
 	logFmt  string
 	address string
+
+	progressWidth = defaultProgressWidth
+)
+
+const (
+	// The width of the progress bars in columns, and the bounds --progress-width is clamped to.
+	defaultProgressWidth = 60
+	minProgressWidth     = 10
+	maxProgressWidth     = 300
 )
 
 // RootCmd represents the base command when called without any subcommands.
@@ -79,9 +89,27 @@ var RootCmd = &cobra.Command{
 			stderr.Writer = colorable.NewNonColorable(os.Stderr)
 		}
 		golog.SetOutput(log.StandardLogger().Writer())
+		if width := clampProgressWidth(progressWidth); width != progressWidth {
+			log.WithFields(log.Fields{"width": progressWidth, "clamped": width}).Warn(
+				"The progress width is out of bounds, clamping it")
+			progressWidth = width
+		}
 	},
 }
 
+// clampProgressWidth keeps the width of the progress bars between minProgressWidth and
+// maxProgressWidth, so they're neither unreadable nor wrapped in any reasonable terminal.
+func clampProgressWidth(width int) int {
+	switch {
+	case width < minProgressWidth:
+		return minProgressWidth
+	case width > maxProgressWidth:
+		return maxProgressWidth
+	default:
+		return width
+	}
+}
+
 // Execute adds all child commands to the root command sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -99,6 +127,7 @@ func rootCmdPersistentFlagSet() *pflag.FlagSet {
 	//TODO: figure out a better way to handle the CLI flags - global variables are not very testable... :/
 	flags.BoolVarP(&verbose, "verbose", "v", false, "enable debug logging")
 	flags.BoolVarP(&quiet, "quiet", "q", false, "disable progress updates")
+	flags.IntVar(&progressWidth, "progress-width", defaultProgressWidth, "fixed `width` of the progress bars in columns, for reproducible output")
 	flags.BoolVar(&noColor, "no-color", false, "disable colored output")
 	flags.StringVar(&logFmt, "logformat", "", "log output format")
 	flags.StringVarP(&address, "address", "a", "localhost:6565", "address for the api server")
@@ -133,6 +162,21 @@ func fprintf(w io.Writer, format string, a ...interface{}) (n int) {
 	return n
 }
 
+// isStructuredLogFormat returns true if the logs are emitted as JSON. Human-readable text,
+// like the banner or the init progress, shouldn't be mixed with them in that case.
+func isStructuredLogFormat() bool {
+	return logFmt == "json"
+}
+
+// textOutput returns the writer for human-readable output that isn't essential, which is
+// discarded when the logs are structured, so the output streams stay parseable.
+func textOutput() io.Writer {
+	if isStructuredLogFormat() {
+		return ioutil.Discard
+	}
+	return stdout
+}
+
 // RawFormatter it does nothing with the message just prints it
 type RawFormater struct{}
 
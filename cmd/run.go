@@ -26,11 +26,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
+	"io"
+	"io/ioutil"
 	"os"
 	"os/signal"
-	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -42,6 +44,7 @@ import (
 	"github.com/loadimpact/k6/lib/consts"
 	"github.com/loadimpact/k6/lib/types"
 	"github.com/loadimpact/k6/loader"
+	"github.com/loadimpact/k6/stats"
 	"github.com/loadimpact/k6/ui"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -55,19 +58,47 @@ const (
 	typeJS      = "js"
 	typeArchive = "archive"
 
+	// TypeScript is only recognized to reject it clearly, since the compiler can't transpile it.
+	typeTS = "ts"
+
 	thresholdHaveFailedErroCode = 99
 	setupTimeoutErrorCode       = 100
 	teardownTimeoutErrorCode    = 101
 	genericTimeoutErrorCode     = 102
 	genericEngineErrorCode      = 103
 	invalidConfigErrorCode      = 104
+	outputFailedErrorCode       = 105
+	hardStopErrorCode           = 106
+	idleAbortErrorCode          = 107
 )
 
 var (
 	//TODO: fix this, global variables are not very testable...
-	runType       = os.Getenv("K6_TYPE")
-	runNoSetup    = os.Getenv("K6_NO_SETUP") != ""
-	runNoTeardown = os.Getenv("K6_NO_TEARDOWN") != ""
+	runType         = os.Getenv("K6_TYPE")
+	runNoSetup      = os.Getenv("K6_NO_SETUP") != ""
+	runNoTeardown   = os.Getenv("K6_NO_TEARDOWN") != ""
+	runConfigDump   = false
+	runStallTimeout time.Duration
+	runProfile      []string
+	runProfileDir   = "."
+	runLiveMetrics  []string
+	runSetupOnly    = false
+	runTeardownOnly = false
+	runSetupData    = ""
+
+	runExecutionDescription = ""
+	runRuntimeStatsInterval time.Duration
+	runCheckpointFile       = "k6-checkpoint.json"
+	runCheckpointInterval   time.Duration
+)
+
+const (
+	// How often the table of --live-metrics is redrawn in a terminal.
+	liveMetricsRefreshInterval = 1 * time.Second
+	// How often a snapshot of the --live-metrics is printed when the output isn't a terminal.
+	liveMetricsSnapshotInterval = 10 * time.Second
+	// How often the outputs are checked for delivery failures with --strict-outputs.
+	strictOutputsCheckInterval = 1 * time.Second
 )
 
 // runCmd represents the run command.
@@ -85,6 +116,9 @@ a commandline interface for interacting with it.`,
   # Run a single VU, 10 times.
   k6 run -i 10 script.js
 
+  # Run the index.js or main.js of a directory, or the script declared in its k6.config.json.
+  k6 run ./mytest/
+
   # Run 5 VUs, splitting 10 iterations between them.
   k6 run -u 5 -i 10 script.js
 
@@ -96,30 +130,37 @@ a commandline interface for interacting with it.`,
 
   # Send metrics to an influxdb server
   k6 run -o influxdb=http://1.2.3.4:8086/k6`[1:],
-	Args: exactArgsWithMsg(1, "arg should either be \"-\", if reading script from stdin, or a path to a script file"),
-	RunE: func(cmd *cobra.Command, args []string) error {
+	Args: exactArgsWithMsg(1, "arg should either be \"-\", if reading script from stdin, or a path to a script file or directory"),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		// When dumping the config, nothing but it should be written to stdout
+		initOut := textOutput()
+		if runConfigDump {
+			initOut = ioutil.Discard
+		}
+
 		//TODO: disable in quiet mode?
-		_, _ = BannerColor.Fprintf(stdout, "\n%s\n\n", consts.Banner)
+		_, _ = BannerColor.Fprintf(initOut, "\n%s\n\n", consts.Banner)
 
 		initBar := ui.ProgressBar{
-			Width: 60,
+			Width: progressWidth,
 			Left:  func() string { return "    init" },
 		}
 
 		// Create the Runner.
-		fprintf(stdout, "%s runner\r", initBar.String())
+		fprintf(initOut, "%s runner\r", initBar.String())
 		pwd, err := os.Getwd()
 		if err != nil {
 			return err
 		}
 		filename := args[0]
-		filesystems := loader.CreateFilesystems()
-		src, err := loader.ReadSource(filename, pwd, filesystems, os.Stdin)
+		runtimeOptions, err := getRuntimeOptions(cmd.Flags())
 		if err != nil {
 			return err
 		}
 
-		runtimeOptions, err := getRuntimeOptions(cmd.Flags())
+		configureLoader(runtimeOptions)
+		filesystems := loader.CreateFilesystems()
+		src, err := loader.ReadSource(filename, pwd, filesystems, os.Stdin)
 		if err != nil {
 			return err
 		}
@@ -129,7 +170,7 @@ a commandline interface for interacting with it.`,
 			return err
 		}
 
-		fprintf(stdout, "%s options\r", initBar.String())
+		fprintf(initOut, "%s options\r", initBar.String())
 
 		cliConf, err := getConfig(cmd.Flags())
 		if err != nil {
@@ -174,19 +215,78 @@ a commandline interface for interacting with it.`,
 		if cerr != nil {
 			return ExitCode{cerr, invalidConfigErrorCode}
 		}
+		if _, _, err := parseProfileKinds(runProfile); err != nil {
+			return ExitCode{err, invalidConfigErrorCode}
+		}
+		if err := validateLifecycleFlags(); err != nil {
+			return ExitCode{err, invalidConfigErrorCode}
+		}
+		if err := validateRuntimeStatsInterval(runRuntimeStatsInterval); err != nil {
+			return ExitCode{err, invalidConfigErrorCode}
+		}
+		if runCheckpointInterval < 0 {
+			return ExitCode{errors.New("the checkpoint interval can't be negative"), invalidConfigErrorCode}
+		}
+
+		if runConfigDump {
+			return dumpConfig(stdout, conf)
+		}
+
+		// The result file is written however the test ends, so it's always there to be parsed.
+		var engine *core.Engine
+		resultRecorder := &runResultRecorder{}
+		if conf.ResultFile.String != "" {
+			defer func() {
+				result := resultRecorder.result(engine, err)
+				if werr := writeRunResult(afero.NewOsFs(), conf.ResultFile.String, result); werr != nil {
+					log.WithError(werr).Error("Couldn't write the result file")
+				}
+			}()
+		}
 
 		// If summary trend stats are defined, update the UI to reflect them
 		if len(conf.SummaryTrendStats) > 0 {
 			ui.UpdateTrendColumns(conf.SummaryTrendStats)
 		}
 
+		// The hash of the sources ties the results to an exact revision of the script and its files.
+		scriptHash, err := getScriptHash(r)
+		if err != nil {
+			return err
+		}
+		if conf.TagScriptHash.Bool {
+			tags := conf.RunTags.CloneTags()
+			tags["script_hash"] = scriptHash
+			conf.RunTags = stats.IntoSampleTags(&tags)
+		}
+
 		// Write options back to the runner too.
 		if err = r.SetOptions(conf.Options); err != nil {
 			return err
 		}
 
+		// With --setup-only or --teardown-only, just that function runs, without the executor.
+		if runSetupOnly || runTeardownOnly {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			sigC := make(chan os.Signal, 1)
+			signal.Notify(sigC, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+			defer signal.Stop(sigC)
+			go func() {
+				select {
+				case <-sigC:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+			if runSetupOnly {
+				return runOnlySetup(ctx, r, afero.NewOsFs(), runSetupData)
+			}
+			return runOnlyTeardown(ctx, r, afero.NewOsFs(), runSetupData)
+		}
+
 		// Create a local executor wrapping the runner.
-		fprintf(stdout, "%s executor\r", initBar.String())
+		fprintf(initOut, "%s executor\r", initBar.String())
 		ex := local.New(r)
 		if runNoSetup {
 			ex.SetRunSetup(false)
@@ -196,8 +296,8 @@ a commandline interface for interacting with it.`,
 		}
 
 		// Create an engine.
-		fprintf(stdout, "%s   engine\r", initBar.String())
-		engine, err := core.NewEngine(ex, conf.Options)
+		fprintf(initOut, "%s   engine\r", initBar.String())
+		engine, err = core.NewEngine(ex, conf.Options)
 		if err != nil {
 			return err
 		}
@@ -209,28 +309,232 @@ a commandline interface for interacting with it.`,
 		if conf.NoSummary.Valid {
 			engine.NoSummary = conf.NoSummary.Bool
 		}
+		if err := engine.SetMetricNameMapping(conf.MetricNameMapping); err != nil {
+			return err
+		}
+		warnThresholdUnits(engine.GetRegisteredMetrics(), conf.Thresholds)
+		warnDisabledMetrics(conf.DisabledMetrics, conf.Thresholds)
+		if err := engine.SetMetricPrefix(conf.MetricPrefix.String); err != nil {
+			return err
+		}
+		if conf.ClampSampleTimes.Valid {
+			engine.SetSampleTimeClamping(time.Duration(conf.ClampSampleTimes.Duration))
+		}
+		if conf.Warmup.Duration > 0 {
+			engine.SetWarmup(time.Duration(conf.Warmup.Duration))
+		}
+		if runCheckpointInterval > 0 {
+			engine.SetCheckpoints(afero.NewOsFs(), runCheckpointFile, runCheckpointInterval)
+		}
+		if conf.IdleAbortTimeout.Duration > 0 {
+			idleMetric := conf.IdleAbortMetric.String
+			if idleMetric == "" {
+				idleMetric = "iterations"
+			}
+			engine.SetIdleAbort(idleMetric, time.Duration(conf.IdleAbortTimeout.Duration))
+		}
+		engine.OutputsReadyTimeout = time.Duration(conf.WaitOutputsReady.Duration)
+		engine.AddRunEventHandler(func(event core.RunEvent) {
+			fields := log.Fields{"event": event.Type}
+			if event.Type == core.RunEventAborting {
+				fields["reason"] = event.AbortReason
+			}
+			if event.Metric != "" {
+				fields["metric"] = event.Metric
+			}
+			log.WithFields(fields).Debug("Run event")
+			// Supervisors can wait for this before tearing down the backends of the outputs.
+			if event.Type == core.RunEventOutputsFlushed && len(engine.Collectors) > 0 {
+				var failed []string
+				for _, output := range event.Outputs {
+					if output.Err != nil {
+						failed = append(failed, output.Output)
+					}
+				}
+				if len(failed) > 0 {
+					log.WithField("failed", strings.Join(failed, ", ")).Warn("The outputs have stopped, but some failed to deliver their samples")
+				} else {
+					log.WithField("outputs", len(engine.Collectors)).Info("All outputs have flushed their samples")
+				}
+			}
+		})
+		engine.AddRunEventHandler(resultRecorder.handleRunEvent)
+		if conf.ThresholdsStream.String != "" {
+			stream, err := newThresholdsStream(afero.NewOsFs(), conf.ThresholdsStream.String)
+			if err != nil {
+				return errors.Wrap(err, "thresholds stream")
+			}
+			defer func() {
+				if cerr := stream.Close(); cerr != nil {
+					log.WithError(cerr).Error("Couldn't close the thresholds stream")
+				}
+			}()
+			engine.AddThresholdResultsHandler(stream.handle)
+		}
+
+		// Create an API server. It's started before the outputs are initialized, so that its
+		// health endpoint can be used as a liveness probe while that's happening.
+		fprintf(initOut, "%s   server\r", initBar.String())
+		go func() {
+			if err := api.ListenAndServe(address, engine); err != nil {
+				log.WithError(err).Warn("Error from API server")
+			}
+		}()
 
 		// Create a collector and assign it to the engine if requested.
-		fprintf(stdout, "%s   collector\r", initBar.String())
+		fprintf(initOut, "%s   collector\r", initBar.String())
+		testRunCollectors := map[string]lib.TestRunCollector{}
+		failingCollectors := map[string]lib.FailingCollector{}
+		statusCollectors := map[string]lib.StatusCollector{}
+		spillingCollectors := map[string]sampleSpiller{}
+		summaryCollectors := map[string]lib.SummaryCollector{}
+		flushCollectors := map[string]lib.FlushingCollector{}
+		shadowDeliveries := map[string]lib.FailingCollector{}
+
+		// With --spill-file, the samples that the outputs haven't delivered are saved when k6 has to
+		// stop without flushing them, i.e. on a panic, also in the goroutine of an output, or when
+		// it's interrupted a second time.
+		var spillOnce sync.Once
+		spill := func(reason string) {
+			if conf.SpillFile.String == "" {
+				return
+			}
+			spillOnce.Do(func() {
+				n, err := spillSamples(afero.NewOsFs(), conf.SpillFile.String, spillingCollectors)
+				l := log.WithFields(log.Fields{"filename": conf.SpillFile.String, "samples": n, "reason": reason})
+				if err != nil {
+					l.WithError(err).Error("Couldn't spill the undelivered samples")
+					return
+				}
+				l.Warn("Spilled the undelivered samples")
+			})
+		}
+		spillOnPanic := func() {
+			if r := recover(); r != nil {
+				spill("panic")
+				panic(r)
+			}
+		}
+
+		var diagnostics *deliveryDiagnostics
+		if conf.DiagnosticsFile.String != "" {
+			diagnostics = newDeliveryDiagnostics()
+		}
 		for _, out := range conf.Out {
 			t, arg := parseCollector(out)
+			arg, name := parseCollectorName(arg)
+			arg, shadow := parseCollectorShadow(arg)
+			label := collectorLabel(t, name)
 			collector, err := newCollector(t, arg, src, conf)
 			if err != nil {
-				return err
+				return errors.Wrapf(err, "output %s", label)
+			}
+			if lc, ok := collector.(lib.LabeledCollector); ok {
+				lc.SetLabel(label)
+			}
+			if sc, ok := collector.(lib.StartTimeCollector); ok {
+				// Registered before the collector is wrapped, so an output that's only initialized
+				// when the test fails still gets the start of the run.
+				engine.AddRunEventHandler(func(event core.RunEvent) {
+					if event.Type == core.RunEventRunStarted {
+						sc.SetStartTime(event.Time)
+					}
+				})
+			}
+			if shadow {
+				// Hides the optional interfaces of the collector, so it's left out of everything
+				// that could affect the test, like waiting for it to be ready or limiting its buffer.
+				sc := newShadowCollector(collector, label, shadowBufferSize)
+				sc.diagnostics = diagnostics
+				shadowDeliveries[label] = shadowDelivery{sc}
+				collector = sc
+			}
+			// Checked before the collector is wrapped to be only initialized when the test fails,
+			// which hides the tags it requires. Shadow outputs don't require anything.
+			if err := checkRequiredTags(collector, conf.RunTags); err != nil {
+				return ExitCode{errors.Wrapf(err, "output %s", label), invalidConfigErrorCode}
+			}
+			if isOnFailureOutput(conf.OnFailureOutputs, t, name) {
+				// Wrapped first, so the collector isn't flushed, waited for or asked for its status
+				// while it's not initialized, which only happens at the end of a failed test.
+				collector = &onFailureCollector{Collector: collector, label: label, failed: engine.IsTainted}
+			}
+			if trc, ok := collector.(lib.TestRunCollector); ok {
+				testRunCollectors[label] = trc
+			}
+			if sc, ok := collector.(lib.StatusCollector); ok {
+				statusCollectors[label] = sc
+			}
+			if sc, ok := collector.(sampleSpiller); ok {
+				spillingCollectors[label] = sc
+			}
+			if sc, ok := collector.(lib.SummaryCollector); ok {
+				summaryCollectors[label] = sc
+			}
+			if fc, ok := collector.(lib.FlushingCollector); ok {
+				flushCollectors[label] = fc
+			}
+			_, collectsThresholds := collector.(lib.ThresholdsCollector)
+			if conf.WaitOutputsReady.Valid {
+				if rc, ok := collector.(lib.ReadyCollector); ok {
+					engine.ReadyCollectors = append(engine.ReadyCollectors, rc)
+				}
+			}
+			if fc, ok := collector.(lib.FailingCollector); ok {
+				failingCollectors[label] = fc
+			} else if conf.StrictOutputs.Bool && !shadow {
+				log.WithField("output", label).Warn("The output doesn't report delivery failures, so --strict-outputs can't check it")
 			}
 			if err := collector.Init(); err != nil {
-				return err
+				return errors.Wrapf(err, "output %s", label)
 			}
+			if conf.SampleBufferLimit.Int64 > 0 {
+				if bc, ok := collector.(lib.BufferingCollector); ok {
+					blc := newBufferLimitedCollector(
+						bc, label, int(conf.SampleBufferLimit.Int64), conf.SampleBufferPolicy.String)
+					blc.diagnostics = diagnostics
+					collector = blc
+				} else if !shadow {
+					log.WithField("output", label).Warn("The output doesn't report its buffered samples, so they can't be limited")
+				}
+			}
+			if len(conf.NormalizeTagKeys) > 0 {
+				normalizer, err := newTagKeyNormalizer(conf.NormalizeTagKeys)
+				if err != nil {
+					return err
+				}
+				collector = &normalizedTagsCollector{Collector: collector, normalizer: normalizer}
+			}
+			if len(conf.MetricRoutes) > 0 {
+				collector = newRoutedCollector(collector, t, name, conf.MetricRoutes)
+			}
+			if len(conf.OutputFilter) > 0 {
+				filter, err := parseSampleFilter(conf.OutputFilter)
+				if err != nil {
+					return err
+				}
+				collector = &filteredCollector{Collector: collector, filter: filter}
+			}
+			if conf.SpillFile.String != "" {
+				collector = &spillOnPanicCollector{Collector: collector, onPanic: spillOnPanic}
+			}
+			if collectsThresholds {
+				// Registered on the wrapped collector, so the results are routed and filtered too.
+				engine.AddThresholdResultsHandler(collector.(lib.ThresholdsCollector).CollectThresholdResults)
+			}
+			log.WithField("output", label).Debug("Initialized output")
 			engine.Collectors = append(engine.Collectors, collector)
 		}
+		engine.SetFlushCollectors(flushCollectors)
+		engine.FailingCollectors = make(map[string]lib.FailingCollector, len(failingCollectors)+len(shadowDeliveries))
+		for label, fc := range failingCollectors {
+			engine.FailingCollectors[label] = fc
+		}
+		for label, fc := range shadowDeliveries {
+			engine.FailingCollectors[label] = fc
+		}
 
-		// Create an API server.
-		fprintf(stdout, "%s   server\r", initBar.String())
-		go func() {
-			if err := api.ListenAndServe(address, engine); err != nil {
-				log.WithError(err).Warn("Error from API server")
-			}
-		}()
+		defer spillOnPanic()
 
 		// Write the big banner.
 		{
@@ -250,77 +554,93 @@ a commandline interface for interacting with it.`,
 				}
 			}
 
-			fprintf(stdout, "  execution: %s\n", ui.ValueColor.Sprint("local"))
-			fprintf(stdout, "     output: %s%s\n", ui.ValueColor.Sprint(out), ui.ExtraColor.Sprint(link))
-			fprintf(stdout, "     script: %s\n", ui.ValueColor.Sprint(filename))
-			fprintf(stdout, "\n")
-
-			duration := ui.GrayColor.Sprint("-")
-			iterations := ui.GrayColor.Sprint("-")
-			if conf.Duration.Valid {
-				duration = ui.ValueColor.Sprint(conf.Duration.Duration)
-			}
-			if conf.Iterations.Valid {
-				iterations = ui.ValueColor.Sprint(conf.Iterations.Int64)
+			desc := newExecutionDescription(filename, out, link, conf)
+			printExecutionDescription(initOut, desc)
+			if runExecutionDescription != "" {
+				err := writeExecutionDescription(afero.NewOsFs(), runExecutionDescription, desc)
+				if err != nil {
+					return errors.Wrap(err, "couldn't write the execution description")
+				}
 			}
-			vus := ui.ValueColor.Sprint(conf.VUs.Int64)
-			max := ui.ValueColor.Sprint(conf.VUsMax.Int64)
+		}
 
-			leftWidth := ui.StrWidth(duration)
-			if l := ui.StrWidth(vus); l > leftWidth {
-				leftWidth = l
-			}
-			durationPad := strings.Repeat(" ", leftWidth-ui.StrWidth(duration))
-			vusPad := strings.Repeat(" ", leftWidth-ui.StrWidth(vus))
+		// The taps of the programs embedding k6 get the samples like the outputs, but aren't
+		// shown as ones.
+		engine.Collectors = append(engine.Collectors, getSampleTapCollectors(shadowBufferSize, diagnostics)...)
 
-			fprintf(stdout, "    duration: %s,%s iterations: %s\n", duration, durationPad, iterations)
-			fprintf(stdout, "         vus: %s,%s max: %s\n", vus, vusPad, max)
-			fprintf(stdout, "\n")
+		// The summary only has the totals of the metrics, so the series for the SVG chart are
+		// recorded from the samples, like by a tap.
+		var trendSeries *ui.TrendSeriesRecorder
+		if conf.SummarySVG.String != "" {
+			trendSeries = ui.NewTrendSeriesRecorder(ui.DefaultSummarySVGMetrics, conf.MetricPrefix.String)
+			c := newShadowCollector(sampleTapCollector{trendSeries.Collect}, "summary svg", shadowBufferSize)
+			c.kind = "sample tap"
+			c.diagnostics = diagnostics
+			engine.Collectors = append(engine.Collectors, c)
 		}
 
+		// If requested, profile the k6 process itself while the test is running.
+		profiler, err := startProfiling(afero.NewOsFs(), runProfile, runProfileDir, log.StandardLogger())
+		if err != nil {
+			return err
+		}
+		defer func() { _ = profiler.stop() }()
+
 		// Run the engine with a cancellable context.
-		fprintf(stdout, "%s starting\r", initBar.String())
+		fprintf(initOut, "%s starting\r", initBar.String())
 		ctx, cancel := context.WithCancel(context.Background())
 		errC := make(chan error)
-		go func() { errC <- engine.Run(ctx) }()
+		go func() {
+			defer spillOnPanic()
+			errC <- engine.Run(ctx)
+		}()
+
+		// If requested, watch for stalled tests and dump the goroutine stacks.
+		if runStallTimeout > 0 {
+			watchdogCtx, watchdogCancel := context.WithCancel(context.Background())
+			defer watchdogCancel()
+			checkInterval := time.Second
+			if runStallTimeout < checkInterval {
+				checkInterval = runStallTimeout
+			}
+			go stallWatchdog{
+				timeout:       runStallTimeout,
+				checkInterval: checkInterval,
+				iterations:    engine.Executor.GetIterations,
+				paused:        engine.Executor.IsPaused,
+				out:           stderr,
+				logger:        log.StandardLogger(),
+			}.run(watchdogCtx)
+		}
+
+		// If requested, log the resource usage of the k6 process while the test is running.
+		if runRuntimeStatsInterval > 0 {
+			statsCtx, statsCancel := context.WithCancel(context.Background())
+			defer statsCancel()
+			go runtimeStatsLogger{
+				interval: runRuntimeStatsInterval,
+				logger:   log.StandardLogger(),
+			}.run(statsCtx)
+		}
 
 		// Trap Interrupts, SIGINTs and SIGTERMs.
 		sigC := make(chan os.Signal, 1)
 		signal.Notify(sigC, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 		defer signal.Stop(sigC)
 
-		// If the user hasn't opted out: report usage.
+		// If the user hasn't opted out, for this run or for the whole machine: report usage.
 		if !conf.NoUsageReport.Bool {
-			go func() {
-				u := "http://k6reports.loadimpact.com/"
-				mime := "application/json"
-				var endTSeconds float64
-				if endT := engine.Executor.GetEndTime(); endT.Valid {
-					endTSeconds = time.Duration(endT.Duration).Seconds()
-				}
-				var stagesEndTSeconds float64
-				if stagesEndT := lib.SumStages(engine.Executor.GetStages()); stagesEndT.Valid {
-					stagesEndTSeconds = time.Duration(stagesEndT.Duration).Seconds()
-				}
-				body, err := json.Marshal(map[string]interface{}{
-					"k6_version":  consts.Version,
-					"vus_max":     engine.Executor.GetVUsMax(),
-					"iterations":  engine.Executor.GetEndIterations(),
-					"duration":    endTSeconds,
-					"st_duration": stagesEndTSeconds,
-					"goos":        runtime.GOOS,
-					"goarch":      runtime.GOARCH,
-				})
-				if err != nil {
-					panic(err) // This should never happen!!
-				}
-				_, _ = http.Post(u, mime, bytes.NewBuffer(body))
-			}()
+			optOut := getUsageReportOptOut(afero.NewOsFs(), getUsageReportOptOutFolders(), collectEnv())
+			if optOut == "" {
+				reportUsage(engine.Executor)
+			} else {
+				log.Debugf("Not reporting usage, %s", optOut)
+			}
 		}
 
 		// Prepare a progress bar.
 		progress := ui.ProgressBar{
-			Width: 60,
+			Width: progressWidth,
 			Left: func() string {
 				if engine.Executor.IsPaused() {
 					return "  paused"
@@ -351,20 +671,51 @@ a commandline interface for interacting with it.`,
 			},
 		}
 
-		// Ticker for progress bar updates. Less frequent updates for non-TTYs, none if quiet.
+		// Ticker for progress bar updates. Less frequent updates for non-TTYs and structured
+		// logs, where the progress is logged instead of drawn, none if quiet.
+		logProgress := quiet || !stdoutTTY || isStructuredLogFormat()
 		updateFreq := 50 * time.Millisecond
-		if !stdoutTTY {
+		if logProgress {
 			updateFreq = 1 * time.Second
 		}
 		ticker := time.NewTicker(updateFreq)
 		if quiet || conf.HttpDebug.Valid && conf.HttpDebug.String != "" {
 			ticker.Stop()
 		}
+		var liveMetricsLines int
+		var liveMetricsTime time.Time
+
+		// With --strict-outputs, the test is aborted as soon as any output fails to deliver samples.
+		var outputsCheckC <-chan time.Time
+		var outputErr error
+		var interrupted bool
+		if conf.StrictOutputs.Bool {
+			outputsTicker := time.NewTicker(strictOutputsCheckInterval)
+			defer outputsTicker.Stop()
+			outputsCheckC = outputsTicker.C
+		}
 	mainLoop:
 		for {
 			select {
+			case <-outputsCheckC:
+				if outputErr = getOutputFailure(failingCollectors); outputErr != nil {
+					log.WithError(outputErr).Error("Aborting the test, because an output failed")
+					outputsCheckC = nil
+					cancel()
+				}
 			case <-ticker.C:
-				if quiet || !stdoutTTY {
+				if len(runLiveMetrics) > 0 {
+					refresh := liveMetricsRefreshInterval
+					if logProgress {
+						refresh = liveMetricsSnapshotInterval
+					}
+					if now := time.Now(); now.Sub(liveMetricsTime) >= refresh {
+						liveMetricsTime = now
+						liveMetricsLines = printLiveMetrics(stdout, engine, runLiveMetrics,
+							conf.SummaryTimeUnit.String, ui.SummaryPrecision(conf.Options), liveMetricsLines, logProgress)
+					}
+				}
+				if logProgress {
 					l := log.WithFields(log.Fields{
 						"t": engine.Executor.GetTime(),
 						"i": engine.Executor.GetIterations(),
@@ -394,7 +745,7 @@ a commandline interface for interacting with it.`,
 						prog = float64(engine.Executor.GetTime()) / float64(endT.Duration)
 					}
 				}
-				progress.Progress = prog
+				progress.Modify(ui.WithProgress(prog), ui.WithStatus(getTransientStatus(engine.Executor, statusCollectors)))
 				fprintf(stdout, "%s\x1b[0K\r", progress.String())
 			case err := <-errC:
 				cancel()
@@ -404,6 +755,9 @@ a commandline interface for interacting with it.`,
 				}
 
 				switch e := errors.Cause(err).(type) {
+				case core.IdleAbortError:
+					log.WithError(err).Error("Idle test aborted")
+					return ExitCode{e, idleAbortErrorCode}
 				case lib.TimeoutError:
 					switch string(e) {
 					case "setup":
@@ -421,11 +775,23 @@ a commandline interface for interacting with it.`,
 					return ExitCode{errors.New("Engine Error"), genericEngineErrorCode}
 				}
 			case sig := <-sigC:
+				// Only with --spill-file, a second signal stops k6 without waiting for the outputs,
+				// since the samples they haven't delivered can be saved.
+				if interrupted && conf.SpillFile.String != "" {
+					log.WithField("sig", sig).Error("Hard stop in response to a second signal")
+					cancel()
+					spill("hard stop")
+					return ExitCode{errors.New("Hard stop"), hardStopErrorCode}
+				}
+				interrupted = true
 				log.WithField("sig", sig).Debug("Exiting in response to signal")
 				cancel()
 			}
 		}
-		if quiet || !stdoutTTY {
+		if err := profiler.stop(); err != nil {
+			log.WithError(err).Error("Couldn't write the profiles of k6")
+		}
+		if logProgress {
 			e := log.WithFields(log.Fields{
 				"t": engine.Executor.GetTime(),
 				"i": engine.Executor.GetIterations(),
@@ -436,7 +802,7 @@ a commandline interface for interacting with it.`,
 			}
 			fn("Test finished")
 		} else {
-			progress.Progress = 1
+			progress.Modify(ui.WithProgress(1), ui.WithStatus(""))
 			fprintf(stdout, "%s\x1b[0K\n", progress.String())
 		}
 
@@ -445,30 +811,163 @@ a commandline interface for interacting with it.`,
 			log.Warn("No data generated, because no script iterations finished, consider making the test duration longer")
 		}
 
-		// Print the end-of-test summary.
+		// Print the end-of-test summary. All of its formats are generated from the same snapshot.
+		summaryData := ui.SummaryData{
+			Opts:       conf.Options,
+			Root:       engine.Executor.GetRunner().GetDefaultGroup(),
+			Metrics:    engine.GetMetricsSnapshot(),
+			Time:       engine.Executor.GetTime(),
+			TestRuns:   getTestRuns(testRunCollectors),
+			Outputs:    getSummaryOutputs(summaryCollectors),
+			ScriptHash: scriptHash,
+		}
+		for _, run := range summaryData.TestRuns {
+			log.WithFields(log.Fields{"output": run.Output, "testRunId": run.ID, "url": run.URL}).Info("Test run")
+		}
 		if !conf.NoSummary.Bool {
 			fprintf(stdout, "\n")
-			ui.Summarize(stdout, "", ui.SummaryData{
-				Opts:    conf.Options,
-				Root:    engine.Executor.GetRunner().GetDefaultGroup(),
-				Metrics: engine.Metrics,
-				Time:    engine.Executor.GetTime(),
-			})
+			ui.Summarize(stdout, "", summaryData)
 			fprintf(stdout, "\n")
 		}
+		if conf.SummaryExport.String != "" {
+			if err := exportSummary(afero.NewOsFs(), conf.SummaryExport.String, summaryData); err != nil {
+				log.WithError(err).Error("Couldn't export the summary")
+			}
+		}
+		if conf.SummaryJUnit.String != "" {
+			if err := exportSummaryJUnit(afero.NewOsFs(), conf.SummaryJUnit.String, summaryData); err != nil {
+				log.WithError(err).Error("Couldn't write the JUnit summary")
+			}
+		}
+		if conf.SummaryHDR.String != "" {
+			if err := exportSummaryHDR(afero.NewOsFs(), conf.SummaryHDR.String, summaryData); err != nil {
+				log.WithError(err).Error("Couldn't write the HDR histograms of the summary")
+			}
+		}
+		if trendSeries != nil {
+			err := exportSummarySVG(afero.NewOsFs(), conf.SummarySVG.String, summaryData, trendSeries.Series())
+			if err != nil {
+				log.WithError(err).Error("Couldn't write the SVG chart of the summary")
+			}
+		}
+		if diagnostics != nil {
+			// Including the shadow outputs, which aren't checked with --strict-outputs.
+			for label, fc := range engine.FailingCollectors {
+				var failed int64
+				if sfc, ok := fc.(lib.SampleFailuresCollector); ok {
+					failed = sfc.FailedSamples()
+				}
+				diagnostics.recordFailure(label, failReasonBackend, fc.DeliveryFailure(), failed)
+			}
+			if err := diagnostics.write(afero.NewOsFs(), conf.DiagnosticsFile.String); err != nil {
+				log.WithError(err).Error("Couldn't write the delivery diagnostics")
+			}
+		}
 
 		if conf.Linger.Bool {
 			log.Info("Linger set; waiting for Ctrl+C...")
 			<-sigC
 		}
 
+		// The outputs have flushed their last samples when the engine terminated.
+		if conf.StrictOutputs.Bool {
+			if outputErr == nil {
+				outputErr = getOutputFailure(failingCollectors)
+			}
+			if outputErr != nil {
+				return ExitCode{outputErr, outputFailedErrorCode}
+			}
+		}
 		if engine.IsTainted() {
-			return ExitCode{errors.New("some thresholds have failed"), thresholdHaveFailedErroCode}
+			return ExitCode{thresholdsFailedError(engine), thresholdHaveFailedErroCode}
 		}
 		return nil
 	},
 }
 
+// getScriptHash returns the hash of the sources of the test, or an empty string if the runner
+// can't be archived.
+func getScriptHash(r lib.Runner) (string, error) {
+	arc := r.MakeArchive()
+	if arc == nil {
+		return "", nil
+	}
+	return arc.SourceHash()
+}
+
+// thresholdsFailedError returns the error for a test whose thresholds have failed, with the
+// messages of the thresholds that aborted it, if any.
+func thresholdsFailedError(engine *core.Engine) error {
+	if messages := engine.ThresholdAbortMessages(); len(messages) > 0 {
+		return errors.Errorf("some thresholds have failed and aborted the test: %s", strings.Join(messages, "; "))
+	}
+	return errors.New("some thresholds have failed")
+}
+
+// getOutputFailure returns an error for the first output, by label, that failed to deliver samples.
+func getOutputFailure(collectors map[string]lib.FailingCollector) error {
+	labels := make([]string, 0, len(collectors))
+	for label := range collectors {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		if err := collectors[label].DeliveryFailure(); err != nil {
+			return errors.Wrapf(err, "output %s failed to deliver samples", label)
+		}
+	}
+	return nil
+}
+
+// getTransientStatus returns the status that the executor, or else the first output by label, wants
+// to show in the progress bar, or an empty string if there's none.
+func getTransientStatus(ex lib.Executor, collectors map[string]lib.StatusCollector) string {
+	if se, ok := ex.(lib.StatusExecutor); ok {
+		if status := se.Status(); status != "" {
+			return status
+		}
+	}
+	labels := make([]string, 0, len(collectors))
+	for label := range collectors {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		if status := collectors[label].Status(); status != "" {
+			return status
+		}
+	}
+	return ""
+}
+
+// printLiveMetrics writes the table of the --live-metrics. In a terminal, the previous table, with
+// the given number of lines, is drawn over, while otherwise every table is printed as a new snapshot.
+// It returns the number of lines of the written table.
+func printLiveMetrics(
+	w io.Writer, engine *core.Engine, names []string, timeUnit string, precision int, prevLines int, snapshot bool,
+) int {
+	var buf bytes.Buffer
+	t := engine.Executor.GetTime()
+	lines := ui.SummarizeLiveMetrics(&buf, "  ", t, timeUnit, precision, names, engine.GetMetricsSnapshot(names...))
+	if snapshot {
+		fprintf(w, "metrics at %s:\n%s", (t/time.Second)*time.Second, buf.String())
+		return lines
+	}
+
+	if prevLines > 0 {
+		fprintf(w, "\r\x1b[%dA", prevLines)
+	}
+	fprintf(w, "%s", strings.Replace(buf.String(), "\n", "\x1b[0K\n", -1))
+	// Clear what's left of a previous, longer table.
+	for i := lines; i < prevLines; i++ {
+		fprintf(w, "\x1b[0K\n")
+	}
+	if lines < prevLines {
+		return prevLines
+	}
+	return lines
+}
+
 func runCmdFlagSet() *pflag.FlagSet {
 	flags := pflag.NewFlagSet("", pflag.ContinueOnError)
 	flags.SortFlags = false
@@ -483,13 +982,25 @@ func runCmdFlagSet() *pflag.FlagSet {
 	//   that will be used in the help/usage message - if we don't set it, the environment
 	//   variables will affect the usage message
 	// - and finally, global variables are not very testable... :/
-	flags.StringVarP(&runType, "type", "t", runType, "override file `type`, \"js\" or \"archive\"")
+	flags.StringVarP(&runType, "type", "t", runType, "override file `type`, \"js\" or \"archive\", e.g. for a script piped to stdin with -")
 	flags.Lookup("type").DefValue = ""
 	flags.BoolVar(&runNoSetup, "no-setup", runNoSetup, "don't run setup()")
 	falseStr := "false" // avoiding goconst warnings...
 	flags.Lookup("no-setup").DefValue = falseStr
 	flags.BoolVar(&runNoTeardown, "no-teardown", runNoTeardown, "don't run teardown()")
 	flags.Lookup("no-teardown").DefValue = falseStr
+	flags.BoolVar(&runSetupOnly, "setup-only", runSetupOnly, "only run setup(), e.g. to provision data, without the load")
+	flags.BoolVar(&runTeardownOnly, "teardown-only", runTeardownOnly, "only run teardown(), e.g. to clean up, without the load")
+	flags.StringVar(&runSetupData, "setup-data", runSetupData, "JSON `file` the setup data is written to with --setup-only, and read from with --teardown-only")
+	flags.BoolVar(&runConfigDump, "config-dump", runConfigDump, "print the consolidated configuration as JSON and exit without running")
+	flags.DurationVar(&runStallTimeout, "stall-timeout", runStallTimeout, "log a warning and a goroutine dump if no iterations complete for this `duration`, 0 disables it")
+	flags.DurationVar(&runRuntimeStatsInterval, "runtime-stats-interval", runRuntimeStatsInterval, "log the goroutines, heap and garbage collections of the k6 process every `duration`, at least 1s, 0 disables it")
+	flags.StringSliceVar(&runProfile, "profile", runProfile, "write pprof profiles of the k6 process itself, not of the target, during the run; one or more of `cpu,heap`")
+	flags.StringVar(&runProfileDir, "profile-dir", runProfileDir, "the `directory` in which the k6-<profile>.pprof files are written")
+	flags.StringVar(&runExecutionDescription, "execution-description", runExecutionDescription, "also write the description of the execution as JSON to the specified `file`, or to stderr for \"-\"")
+	flags.DurationVar(&runCheckpointInterval, "checkpoint-interval", runCheckpointInterval, "write the state of the metrics to the --checkpoint-file every `duration`, for \"k6 replay --checkpoint\" after a crash, 0 disables it")
+	flags.StringVar(&runCheckpointFile, "checkpoint-file", runCheckpointFile, "the `file` the metrics checkpoints are written to")
+	flags.StringSliceVar(&runLiveMetrics, "live-metrics", runLiveMetrics, "show a periodically refreshed table of the given `metrics` while the test is running")
 	return flags
 }
 
@@ -506,6 +1017,11 @@ func newRunner(
 ) (lib.Runner, error) {
 	switch typ {
 	case "":
+		// Scripts piped to stdin have no filename, so their type can only be detected from
+		// their contents, which needs some.
+		if src.URL != nil && src.URL.Path == "/-" && len(bytes.TrimSpace(src.Data)) == 0 {
+			return nil, errors.New("no script or archive was piped to stdin, so its type couldn't be detected")
+		}
 		return newRunner(src, detectType(src.Data), filesystems, rtOpts)
 	case typeJS:
 		return js.New(src, filesystems, rtOpts)
@@ -520,8 +1036,10 @@ func newRunner(
 		default:
 			return nil, errors.Errorf("archive requests unsupported runner: %s", arc.Type)
 		}
+	case typeTS:
+		return nil, errors.New("TypeScript isn't supported, transpile the script to JavaScript and use -t/--type js")
 	default:
-		return nil, errors.Errorf("unknown -t/--type: %s", typ)
+		return nil, errors.Errorf("unknown -t/--type: %s, it has to be %q or %q", typ, typeJS, typeArchive)
 	}
 }
 
@@ -531,3 +1049,192 @@ func detectType(data []byte) string {
 	}
 	return typeJS
 }
+
+// executionDescription describes the local test execution, as it's shown before the test.
+type executionDescription struct {
+	Execution  string      `json:"execution"`
+	Output     string      `json:"output"`
+	Link       string      `json:"link,omitempty"`
+	Script     string      `json:"script"`
+	VUs        int64       `json:"vus"`
+	VUsMax     int64       `json:"vusMax"`
+	Duration   string      `json:"duration,omitempty"`
+	Iterations *int64      `json:"iterations,omitempty"`
+	Stages     []lib.Stage `json:"stages,omitempty"`
+}
+
+func newExecutionDescription(filename, out, link string, conf Config) executionDescription {
+	desc := executionDescription{
+		Execution: "local",
+		Output:    out,
+		Link:      strings.TrimSpace(link),
+		Script:    filename,
+		VUs:       conf.VUs.Int64,
+		VUsMax:    conf.VUsMax.Int64,
+		Stages:    conf.Stages,
+	}
+	if conf.Duration.Valid {
+		desc.Duration = conf.Duration.Duration.String()
+	}
+	if conf.Iterations.Valid {
+		iterations := conf.Iterations.Int64
+		desc.Iterations = &iterations
+	}
+	return desc
+}
+
+// printExecutionDescription writes the human-readable description of the local test execution
+// to w or, if the logs are structured, emits it as a single log record instead.
+func printExecutionDescription(w io.Writer, desc executionDescription) {
+	if isStructuredLogFormat() {
+		fields := log.Fields{
+			"execution": desc.Execution,
+			"output":    desc.Output,
+			"script":    desc.Script,
+			"vus":       desc.VUs,
+			"vusMax":    desc.VUsMax,
+		}
+		if desc.Link != "" {
+			fields["link"] = desc.Link
+		}
+		if desc.Duration != "" {
+			fields["duration"] = desc.Duration
+		}
+		if desc.Iterations != nil {
+			fields["iterations"] = *desc.Iterations
+		}
+		log.WithFields(fields).Info("Execution description")
+		return
+	}
+
+	link := ""
+	if desc.Link != "" {
+		link = " " + desc.Link
+	}
+	fprintf(w, "  execution: %s\n", ui.ValueColor.Sprint(desc.Execution))
+	fprintf(w, "     output: %s%s\n", ui.ValueColor.Sprint(desc.Output), ui.ExtraColor.Sprint(link))
+	fprintf(w, "     script: %s\n", ui.ValueColor.Sprint(desc.Script))
+	fprintf(w, "\n")
+
+	duration := ui.GrayColor.Sprint("-")
+	iterations := ui.GrayColor.Sprint("-")
+	if desc.Duration != "" {
+		duration = ui.ValueColor.Sprint(desc.Duration)
+	}
+	if desc.Iterations != nil {
+		iterations = ui.ValueColor.Sprint(*desc.Iterations)
+	}
+	vus := ui.ValueColor.Sprint(desc.VUs)
+	max := ui.ValueColor.Sprint(desc.VUsMax)
+
+	leftWidth := ui.StrWidth(duration)
+	if l := ui.StrWidth(vus); l > leftWidth {
+		leftWidth = l
+	}
+	durationPad := strings.Repeat(" ", leftWidth-ui.StrWidth(duration))
+	vusPad := strings.Repeat(" ", leftWidth-ui.StrWidth(vus))
+
+	fprintf(w, "    duration: %s,%s iterations: %s\n", duration, durationPad, iterations)
+	fprintf(w, "         vus: %s,%s max: %s\n", vus, vusPad, max)
+	fprintf(w, "\n")
+}
+
+// writeExecutionDescription writes the description of the local test execution as JSON to the
+// file, or to stderr if the filename is "-".
+func writeExecutionDescription(fs afero.Fs, filename string, desc executionDescription) error {
+	data, err := json.MarshalIndent(desc, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if filename == "-" {
+		_, err = stderr.Write(data)
+		return err
+	}
+	return afero.WriteFile(fs, filename, data, 0644)
+}
+
+// getTestRuns returns the test runs created by the outputs, sorted by the output labels.
+func getTestRuns(collectors map[string]lib.TestRunCollector) []ui.SummaryTestRun {
+	testRuns := []ui.SummaryTestRun{}
+	for label, collector := range collectors {
+		if id := collector.TestRunID(); id != "" {
+			testRuns = append(testRuns, ui.SummaryTestRun{Output: label, ID: id, URL: collector.Link()})
+		}
+	}
+	sort.Slice(testRuns, func(i, j int) bool { return testRuns[i].Output < testRuns[j].Output })
+	return testRuns
+}
+
+// getSummaryOutputs returns what the outputs contribute to the summary, sorted by their labels.
+func getSummaryOutputs(collectors map[string]lib.SummaryCollector) []ui.SummaryOutput {
+	outputs := []ui.SummaryOutput{}
+	for label, collector := range collectors {
+		if lines := collector.SummaryLines(); len(lines) > 0 {
+			outputs = append(outputs, ui.SummaryOutput{Output: label, Lines: lines})
+		}
+	}
+	sort.Slice(outputs, func(i, j int) bool { return outputs[i].Output < outputs[j].Output })
+	return outputs
+}
+
+// exportSummary writes the machine-readable end-of-test summary to the specified file.
+func exportSummary(fs afero.Fs, filename string, data ui.SummaryData) error {
+	summary, truncated, err := ui.ExportSummary(data)
+	if err != nil {
+		return err
+	}
+	for _, name := range truncated {
+		log.WithFields(log.Fields{"metric": name, "max": data.Opts.SummaryTrendValues.Int64}).Warn(
+			"The exported summary contains only a subset of the raw values of the metric")
+	}
+	f, err := fs.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := ui.WriteSummaryJSON(f, summary); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// exportSummaryJUnit writes the thresholds of the end-of-test summary as JUnit XML to the file.
+func exportSummaryJUnit(fs afero.Fs, filename string, data ui.SummaryData) error {
+	f, err := fs.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := ui.WriteSummaryJUnit(f, data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// exportSummaryHDR writes the distributions of the trend metrics of the end-of-test summary as
+// an HdrHistogram log to the file.
+func exportSummaryHDR(fs afero.Fs, filename string, data ui.SummaryData) error {
+	f, err := fs.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := ui.WriteSummaryHDR(f, data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// exportSummarySVG writes a chart of the trend series over the test as SVG to the file.
+func exportSummarySVG(fs afero.Fs, filename string, data ui.SummaryData, series []ui.TrendSeries) error {
+	f, err := fs.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := ui.WriteSummarySVG(f, data, series); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,108 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/loadimpact/k6/core"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/spf13/afero"
+)
+
+// runResult is the machine-readable verdict of a test run, written to the --result-file. Unlike
+// the exported summary, it's small and written however the test ends, so CI can always parse it.
+type runResult struct {
+	ExitCode           int            `json:"exitCode"`
+	Passed             bool           `json:"passed"`
+	Error              string         `json:"error,omitempty"`
+	Aborted            bool           `json:"aborted"`
+	AbortReason        string         `json:"abortReason,omitempty"`
+	BreachedThresholds []string       `json:"breachedThresholds"`
+	Iterations         int64          `json:"iterations"`
+	Duration           types.Duration `json:"duration"`
+}
+
+// runResultRecorder keeps track of the run events needed for the result of a test run.
+type runResultRecorder struct {
+	mutex       sync.Mutex
+	aborted     bool
+	abortReason lib.RunStatus
+}
+
+// handleRunEvent is a core.RunEventHandler recording whether and why the test was aborted.
+func (r *runResultRecorder) handleRunEvent(event core.RunEvent) {
+	if event.Type != core.RunEventAborting {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if !r.aborted {
+		r.aborted = true
+		r.abortReason = event.AbortReason
+	}
+}
+
+// result returns the result of a test run that ended with the given error, which is nil if k6
+// exits successfully. The engine is nil if the test ended before it was created.
+func (r *runResultRecorder) result(engine *core.Engine, err error) runResult {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	result := runResult{Aborted: r.aborted, BreachedThresholds: []string{}}
+	if r.aborted {
+		result.AbortReason = r.abortReason.String()
+	}
+	if err != nil {
+		result.Error = err.Error()
+		// The same exit codes as in Execute().
+		result.ExitCode = -1
+		if e, ok := err.(ExitCode); ok {
+			result.ExitCode = e.Code
+		}
+	}
+	result.Passed = result.ExitCode == 0
+
+	if engine != nil {
+		for name, m := range engine.GetMetricsSnapshot() {
+			if m.Tainted.Bool {
+				result.BreachedThresholds = append(result.BreachedThresholds, name)
+			}
+		}
+		sort.Strings(result.BreachedThresholds)
+		result.Iterations = engine.Executor.GetIterations()
+		result.Duration = types.Duration(engine.Executor.GetTime().Truncate(time.Millisecond))
+	}
+	return result
+}
+
+// writeRunResult writes the result of a test run as JSON to the specified file.
+func writeRunResult(fs afero.Fs, filename string, result runResult) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	return afero.WriteFile(fs, filename, append(data, '\n'), 0644)
+}
@@ -0,0 +1,94 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/loadimpact/k6/core"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestRunResult(t *testing.T) {
+	t.Run("NoEngine", func(t *testing.T) {
+		r := &runResultRecorder{}
+		result := r.result(nil, ExitCode{errors.New("invalid config"), invalidConfigErrorCode})
+		assert.Equal(t, runResult{
+			ExitCode:           invalidConfigErrorCode,
+			Error:              "invalid config",
+			BreachedThresholds: []string{},
+		}, result)
+
+		result = r.result(nil, errors.New("no such file"))
+		assert.Equal(t, -1, result.ExitCode)
+		assert.False(t, result.Passed)
+	})
+
+	t.Run("Passed", func(t *testing.T) {
+		engine, err := core.NewEngine(nil, lib.Options{})
+		require.NoError(t, err)
+		r := &runResultRecorder{}
+		result := r.result(engine, nil)
+		assert.True(t, result.Passed)
+		assert.False(t, result.Aborted)
+		assert.Equal(t, "", result.AbortReason)
+	})
+
+	t.Run("Aborted", func(t *testing.T) {
+		engine, err := core.NewEngine(nil, lib.Options{})
+		require.NoError(t, err)
+		engine.Metrics["http_req_duration"] = &stats.Metric{Name: "http_req_duration", Tainted: null.BoolFrom(true)}
+		engine.Metrics["checks"] = &stats.Metric{Name: "checks", Tainted: null.BoolFrom(true)}
+		engine.Metrics["iterations"] = &stats.Metric{Name: "iterations", Tainted: null.BoolFrom(false)}
+
+		r := &runResultRecorder{}
+		r.handleRunEvent(core.RunEvent{Type: core.RunEventRunStarted})
+		r.handleRunEvent(core.RunEvent{Type: core.RunEventAborting, AbortReason: lib.RunStatusAbortedThreshold})
+		r.handleRunEvent(core.RunEvent{Type: core.RunEventAborting, AbortReason: lib.RunStatusAbortedUser})
+		result := r.result(engine, ExitCode{errors.New("some thresholds have failed"), thresholdHaveFailedErroCode})
+		assert.Equal(t, runResult{
+			ExitCode:           thresholdHaveFailedErroCode,
+			Error:              "some thresholds have failed",
+			Aborted:            true,
+			AbortReason:        "aborted_threshold",
+			BreachedThresholds: []string{"checks", "http_req_duration"},
+		}, result)
+	})
+
+	t.Run("Write", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		require.NoError(t, writeRunResult(fs, "/result.json", runResult{
+			Passed: true, BreachedThresholds: []string{}, Iterations: 10,
+		}))
+		data, err := afero.ReadFile(fs, "/result.json")
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"exitCode": 0, "passed": true, "aborted": false, "breachedThresholds": [],
+			"iterations": 10, "duration": "0s"
+		}`, string(data))
+	})
+}
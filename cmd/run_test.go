@@ -0,0 +1,206 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2019 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/core/local"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/loader"
+	"github.com/loadimpact/k6/stats/dummy"
+	"github.com/loadimpact/k6/ui"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestPrintExecutionDescription(t *testing.T) {
+	conf := Config{Options: lib.Options{
+		VUs:      null.IntFrom(5),
+		VUsMax:   null.IntFrom(10),
+		Duration: types.NullDurationFrom(10 * time.Second),
+	}}
+	defer func(oldLogFmt string) { logFmt = oldLogFmt }(logFmt)
+
+	t.Run("Text", func(t *testing.T) {
+		logFmt = ""
+		assert.Equal(t, stdout, textOutput())
+
+		var buf bytes.Buffer
+		printExecutionDescription(&buf, newExecutionDescription("script.js", "json=out.json", "", conf))
+		assert.Contains(t, buf.String(), "execution:")
+		assert.Contains(t, buf.String(), "script.js")
+		assert.Contains(t, buf.String(), "json=out.json")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		logFmt = "json"
+		assert.Equal(t, ioutil.Discard, textOutput())
+
+		hook := logtest.NewGlobal()
+		defer hook.Reset()
+
+		var buf bytes.Buffer
+		printExecutionDescription(&buf, newExecutionDescription("script.js", "json=out.json", " (https://example.com)", conf))
+		assert.Empty(t, buf.String())
+
+		entries := hook.AllEntries()
+		require.Len(t, entries, 1)
+		assert.Equal(t, log.InfoLevel, entries[0].Level)
+		assert.Equal(t, log.Fields{
+			"execution": "local",
+			"output":    "json=out.json",
+			"link":      "(https://example.com)",
+			"script":    "script.js",
+			"vus":       int64(5),
+			"vusMax":    int64(10),
+			"duration":  "10s",
+		}, entries[0].Data)
+	})
+
+	t.Run("File", func(t *testing.T) {
+		conf := conf
+		conf.Iterations = null.IntFrom(100)
+		conf.Stages = []lib.Stage{{Duration: types.NullDurationFrom(10 * time.Second), Target: null.IntFrom(5)}}
+		desc := newExecutionDescription("script.js", "json=out.json", "", conf)
+
+		fs := afero.NewMemMapFs()
+		require.NoError(t, writeExecutionDescription(fs, "execution.json", desc))
+		data, err := afero.ReadFile(fs, "execution.json")
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"execution": "local",
+			"output": "json=out.json",
+			"script": "script.js",
+			"vus": 5,
+			"vusMax": 10,
+			"duration": "10s",
+			"iterations": 100,
+			"stages": [{"duration": "10s", "target": 5}]
+		}`, string(data))
+	})
+}
+
+type testRunCollector struct {
+	*dummy.Collector
+	id, link string
+}
+
+func (c *testRunCollector) TestRunID() string { return c.id }
+func (c *testRunCollector) Link() string      { return c.link }
+
+func TestGetTestRuns(t *testing.T) {
+	testRuns := getTestRuns(map[string]lib.TestRunCollector{
+		"cloud":           &testRunCollector{&dummy.Collector{}, "1234", "https://app.loadimpact.com/k6/runs/1234"},
+		"cloud (failing)": &testRunCollector{&dummy.Collector{}, "", ""},
+		"another":         &testRunCollector{&dummy.Collector{}, "abcd", ""},
+	})
+	assert.Equal(t, []ui.SummaryTestRun{
+		{Output: "another", ID: "abcd"},
+		{Output: "cloud", ID: "1234", URL: "https://app.loadimpact.com/k6/runs/1234"},
+	}, testRuns)
+}
+
+type summaryCollector struct {
+	*dummy.Collector
+	lines []string
+}
+
+func (c *summaryCollector) SummaryLines() []string { return c.lines }
+
+func TestGetSummaryOutputs(t *testing.T) {
+	outputs := getSummaryOutputs(map[string]lib.SummaryCollector{
+		"json":  &summaryCollector{&dummy.Collector{}, []string{"written to out.json (1.2 kB)"}},
+		"kafka": &summaryCollector{&dummy.Collector{}, nil},
+		"cloud": &summaryCollector{&dummy.Collector{}, []string{"uploaded 10 samples", "in 1 request"}},
+	})
+	assert.Equal(t, []ui.SummaryOutput{
+		{Output: "cloud", Lines: []string{"uploaded 10 samples", "in 1 request"}},
+		{Output: "json", Lines: []string{"written to out.json (1.2 kB)"}},
+	}, outputs)
+}
+
+type statusExecutor struct {
+	*local.Executor
+	lib.TransientStatus
+}
+
+type statusCollector struct {
+	*dummy.Collector
+	lib.TransientStatus
+}
+
+func TestGetTransientStatus(t *testing.T) {
+	ex := &statusExecutor{Executor: local.New(nil)}
+	draining := &statusCollector{Collector: &dummy.Collector{}}
+	flushing := &statusCollector{Collector: &dummy.Collector{}}
+	collectors := map[string]lib.StatusCollector{"json": draining, "influxdb": flushing}
+	assert.Equal(t, "", getTransientStatus(ex, collectors))
+
+	draining.SetStatus("draining")
+	flushing.SetStatus("flushing")
+	assert.Equal(t, "flushing", getTransientStatus(ex, collectors))
+
+	ex.SetStatus("warming up")
+	assert.Equal(t, "warming up", getTransientStatus(ex, collectors))
+	assert.Equal(t, "", getTransientStatus(local.New(nil), nil))
+}
+
+func TestNewRunnerType(t *testing.T) {
+	stdinSource := func(data string) *loader.SourceData {
+		return &loader.SourceData{Data: []byte(data), URL: &url.URL{Path: "/-", Scheme: "file"}}
+	}
+	newStdinRunner := func(data, typ string) (lib.Runner, error) {
+		fs := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fs, "/-", []byte(data), 0644))
+		return newRunner(stdinSource(data), typ, map[string]afero.Fs{"file": fs}, lib.RuntimeOptions{})
+	}
+
+	t.Run("Detected", func(t *testing.T) {
+		_, err := newStdinRunner("export default function() {}", "")
+		assert.NoError(t, err)
+	})
+	t.Run("Explicit", func(t *testing.T) {
+		_, err := newStdinRunner("export default function() {}", typeJS)
+		assert.NoError(t, err)
+	})
+	t.Run("EmptyStdin", func(t *testing.T) {
+		_, err := newStdinRunner(" \n", "")
+		assert.EqualError(t, err, "no script or archive was piped to stdin, so its type couldn't be detected")
+	})
+	t.Run("TypeScript", func(t *testing.T) {
+		_, err := newStdinRunner("export default function(): void {}", typeTS)
+		assert.EqualError(t, err, "TypeScript isn't supported, transpile the script to JavaScript and use -t/--type js")
+	})
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := newStdinRunner("export default function() {}", "coffee")
+		assert.EqualError(t, err, `unknown -t/--type: coffee, it has to be "js" or "archive"`)
+	})
+}
@@ -24,8 +24,10 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/loader"
 	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
 )
@@ -53,6 +55,9 @@ func runtimeOptionFlagSet(includeSysEnv bool) *pflag.FlagSet {
 	flags.SortFlags = false
 	flags.Bool("include-system-env-vars", includeSysEnv, "pass the real system environment variables to the runtime")
 	flags.StringArrayP("env", "e", nil, "add/override environment variable with `VAR=value`")
+	flags.Int64("module-fetch-attempts", 1, "how many times to try fetching remote modules and files")
+	flags.Duration("module-fetch-backoff", 1*time.Second, "how long to wait before retrying a failed fetch, doubled for every retry")
+	flags.Bool("check-modules", false, "fail before running the init code if the script or the modules it imports use built-in modules that this build of k6 doesn't have")
 	return flags
 }
 
@@ -60,6 +65,9 @@ func getRuntimeOptions(flags *pflag.FlagSet) (lib.RuntimeOptions, error) {
 	opts := lib.RuntimeOptions{
 		IncludeSystemEnvVars: getNullBool(flags, "include-system-env-vars"),
 		Env:                  make(map[string]string),
+		ModuleFetchAttempts:  getNullInt64(flags, "module-fetch-attempts"),
+		ModuleFetchBackoff:   getNullDuration(flags, "module-fetch-backoff"),
+		CheckModules:         getNullBool(flags, "check-modules"),
 	}
 
 	// If enabled, gather the actual system environment variables
@@ -85,3 +93,16 @@ func getRuntimeOptions(flags *pflag.FlagSet) (lib.RuntimeOptions, error) {
 
 	return opts, nil
 }
+
+// configureLoader sets the retry policy for the remote fetches of the loader from the runtime
+// options. It has to be called before any script or module is loaded.
+func configureLoader(rtOpts lib.RuntimeOptions) {
+	policy := loader.DefaultFetchRetryPolicy()
+	if rtOpts.ModuleFetchAttempts.Valid {
+		policy.Attempts = int(rtOpts.ModuleFetchAttempts.Int64)
+	}
+	if rtOpts.ModuleFetchBackoff.Valid {
+		policy.Backoff = time.Duration(rtOpts.ModuleFetchBackoff.Duration)
+	}
+	loader.SetFetchRetryPolicy(policy)
+}
@@ -28,12 +28,15 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
 	"github.com/loadimpact/k6/loader"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
 )
 
 var envVars []string
@@ -267,3 +270,23 @@ func TestEnvVars(t *testing.T) {
 		})
 	}
 }
+
+func TestModuleFetchRuntimeOptions(t *testing.T) {
+	flags := runtimeOptionFlagSet(false)
+	require.NoError(t, flags.Parse([]string{}))
+	rtOpts, err := getRuntimeOptions(flags)
+	require.NoError(t, err)
+	assert.False(t, rtOpts.ModuleFetchAttempts.Valid)
+	assert.False(t, rtOpts.ModuleFetchBackoff.Valid)
+
+	flags = runtimeOptionFlagSet(false)
+	require.NoError(t, flags.Parse([]string{"--module-fetch-attempts", "5", "--module-fetch-backoff", "250ms"}))
+	rtOpts, err = getRuntimeOptions(flags)
+	require.NoError(t, err)
+	assert.Equal(t, null.IntFrom(5), rtOpts.ModuleFetchAttempts)
+	assert.Equal(t, types.NullDurationFrom(250*time.Millisecond), rtOpts.ModuleFetchBackoff)
+
+	merged := lib.RuntimeOptions{ModuleFetchAttempts: null.IntFrom(2)}.Apply(rtOpts)
+	assert.Equal(t, null.IntFrom(5), merged.ModuleFetchAttempts)
+	assert.Equal(t, types.NullDurationFrom(250*time.Millisecond), merged.ModuleFetchBackoff)
+}
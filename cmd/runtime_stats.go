@@ -0,0 +1,78 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2019 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// minRuntimeStatsInterval is the shortest interval of --runtime-stats-interval. Reading the
+// memory stats briefly stops the world, so it's not done more often than that.
+const minRuntimeStatsInterval = 1 * time.Second
+
+// validateRuntimeStatsInterval checks the interval of --runtime-stats-interval, 0 disables it.
+func validateRuntimeStatsInterval(interval time.Duration) error {
+	if interval != 0 && interval < minRuntimeStatsInterval {
+		return errors.Errorf("the runtime stats interval must be 0 or at least %s, not %s",
+			minRuntimeStatsInterval, interval)
+	}
+	return nil
+}
+
+// runtimeStatsLogger periodically logs the resource usage of the k6 process itself: the number
+// of goroutines, the heap and the garbage collections since the previous log line, so it can be
+// correlated with the phases of long tests without attaching a profiler.
+type runtimeStatsLogger struct {
+	interval time.Duration
+	logger   *log.Logger
+}
+
+// run blocks until the context is done.
+func (l runtimeStatsLogger) run(ctx context.Context) {
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	var last runtime.MemStats
+	runtime.ReadMemStats(&last)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+			l.logger.WithFields(log.Fields{
+				"goroutines":  runtime.NumGoroutine(),
+				"heapAlloc":   humanize.Bytes(stats.HeapAlloc),
+				"heapObjects": stats.HeapObjects,
+				"numGC":       stats.NumGC - last.NumGC,
+				"gcPause":     time.Duration(stats.PauseTotalNs - last.PauseTotalNs).String(),
+			}).Info("Runtime stats")
+			last = stats
+		}
+	}
+}
@@ -0,0 +1,60 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2019 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRuntimeStatsInterval(t *testing.T) {
+	assert.NoError(t, validateRuntimeStatsInterval(0))
+	assert.NoError(t, validateRuntimeStatsInterval(time.Second))
+	assert.NoError(t, validateRuntimeStatsInterval(time.Minute))
+	assert.EqualError(t, validateRuntimeStatsInterval(100*time.Millisecond),
+		"the runtime stats interval must be 0 or at least 1s, not 100ms")
+	assert.Error(t, validateRuntimeStatsInterval(-time.Second))
+}
+
+func TestRuntimeStatsLogger(t *testing.T) {
+	logger := log.New()
+	logger.Out = ioutil.Discard
+	hook := logtest.NewLocal(logger)
+	ctx, cancel := context.WithTimeout(context.Background(), 55*time.Millisecond)
+	defer cancel()
+	runtimeStatsLogger{interval: 10 * time.Millisecond, logger: logger}.run(ctx)
+
+	entries := hook.AllEntries()
+	require.NotEmpty(t, entries)
+	assert.Equal(t, log.InfoLevel, entries[0].Level)
+	assert.Equal(t, "Runtime stats", entries[0].Message)
+	for _, field := range []string{"goroutines", "heapAlloc", "heapObjects", "numGC", "gcPause"} {
+		assert.Contains(t, entries[0].Data, field)
+	}
+	assert.True(t, entries[0].Data["goroutines"].(int) > 0)
+}
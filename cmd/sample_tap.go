@@ -0,0 +1,88 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"sync"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+)
+
+// A SampleTap receives the samples of a test run, after the engine has processed them and at
+// the same time as the outputs. The samples are shared with the outputs, so a tap must not
+// modify them or keep them past the call.
+type SampleTap func(sampleContainers []stats.SampleContainer)
+
+type namedSampleTap struct {
+	name string
+	tap  SampleTap
+}
+
+//nolint:gochecknoglobals
+var (
+	sampleTapsMutex sync.Mutex
+	sampleTaps      []namedSampleTap
+)
+
+// AddSampleTap registers a tap that receives the samples of every test run by `k6 run`, so that
+// programs embedding k6 can process them without implementing a whole output. It has to be
+// called before Execute. Every tap gets the samples through its own bounded buffer, so a slow
+// tap can't hold up the test, but once its buffer is full the new samples are dropped for it,
+// which is logged. Taps that panic are only logged too.
+func AddSampleTap(name string, tap SampleTap) {
+	sampleTapsMutex.Lock()
+	defer sampleTapsMutex.Unlock()
+	sampleTaps = append(sampleTaps, namedSampleTap{name, tap})
+}
+
+// getSampleTapCollectors returns the registered taps as collectors, each wrapped with a bounded
+// buffer of the given size, which records the samples dropped for it in the diagnostics.
+func getSampleTapCollectors(bufferSize int, diagnostics *deliveryDiagnostics) []lib.Collector {
+	sampleTapsMutex.Lock()
+	defer sampleTapsMutex.Unlock()
+	collectors := make([]lib.Collector, 0, len(sampleTaps))
+	for _, t := range sampleTaps {
+		c := newShadowCollector(sampleTapCollector{t.tap}, t.name, bufferSize)
+		c.kind = "sample tap"
+		c.diagnostics = diagnostics
+		collectors = append(collectors, c)
+	}
+	return collectors
+}
+
+// sampleTapCollector passes the samples to a tap.
+type sampleTapCollector struct {
+	tap SampleTap
+}
+
+var _ lib.Collector = sampleTapCollector{}
+
+func (c sampleTapCollector) Init() error                       { return nil }
+func (c sampleTapCollector) Link() string                      { return "" }
+func (c sampleTapCollector) GetRequiredSystemTags() lib.TagSet { return lib.TagSet{} }
+func (c sampleTapCollector) Run(ctx context.Context)           { <-ctx.Done() }
+func (c sampleTapCollector) SetRunStatus(status lib.RunStatus) {}
+
+func (c sampleTapCollector) Collect(sampleContainers []stats.SampleContainer) {
+	c.tap(sampleContainers)
+}
@@ -0,0 +1,67 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2019 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleTaps(t *testing.T) {
+	defer func() { sampleTaps = nil }()
+	assert.Empty(t, getSampleTapCollectors(10, nil))
+
+	var received []stats.SampleContainer
+	AddSampleTap("recorder", func(sampleContainers []stats.SampleContainer) {
+		received = append(received, sampleContainers...)
+	})
+	AddSampleTap("panicking", func(sampleContainers []stats.SampleContainer) {
+		panic("tap bug")
+	})
+	diagnostics := newDeliveryDiagnostics()
+	collectors := getSampleTapCollectors(10, diagnostics)
+	require.Len(t, collectors, 2)
+
+	samples := []stats.SampleContainer{stats.Sample{Metric: metrics.HTTPReqs, Value: 1}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	for _, c := range collectors {
+		require.NoError(t, c.Init())
+		assert.Empty(t, c.Link())
+		go func(c interface{ Run(context.Context) }) {
+			c.Run(ctx)
+			done <- struct{}{}
+		}(c)
+		c.Collect(samples)
+		c.Collect(samples)
+	}
+	cancel()
+	<-done
+	<-done
+	assert.Len(t, received, 2)
+	require.Len(t, diagnostics.outputs, 1)
+	assert.Equal(t, map[string]int64{dropReasonPanicked: 2}, diagnostics.outputs["panicking"].Dropped)
+}
@@ -0,0 +1,142 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/lib/netext/httpext"
+	"github.com/loadimpact/k6/stats"
+	jsonc "github.com/loadimpact/k6/stats/json"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+)
+
+// sampleSpiller is anything that can give up the samples that weren't delivered yet, i.e. a
+// lib.SpillingCollector, or a wrapper that buffers samples for the collector it wraps.
+type sampleSpiller interface {
+	SpillSamples() []stats.SampleContainer
+}
+
+// spillSamples takes the samples that the outputs haven't delivered out of their buffers and
+// writes them to the --spill-file in the format of the json output, so they can be resent, or
+// replayed with "k6 replay", after a hard stop. Samples buffered by more than one output are
+// written once. It returns the number of written samples.
+func spillSamples(fs afero.Fs, filename string, collectors map[string]sampleSpiller) (int, error) {
+	labels := make([]string, 0, len(collectors))
+	for label := range collectors {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	f, err := fs.Create(filename)
+	if err != nil {
+		return 0, err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+
+	written := 0
+	seenContainers := make(map[interface{}]bool)
+	// Single samples have no identity, so equal ones are only written as many times as the
+	// output that buffered the most of them has them.
+	writtenSamples := make(map[stats.Sample]int)
+	seenMetrics := make(map[string]bool)
+	for _, label := range labels {
+		outputSamples := make(map[stats.Sample]int)
+		for _, sc := range collectors[label].SpillSamples() {
+			if key := containerIdentity(sc); key != nil {
+				if seenContainers[key] {
+					continue
+				}
+				seenContainers[key] = true
+			} else if sample, ok := sc.(stats.Sample); ok {
+				outputSamples[sample]++
+				if outputSamples[sample] <= writtenSamples[sample] {
+					continue
+				}
+				writtenSamples[sample] = outputSamples[sample]
+			}
+			for _, sample := range sc.GetSamples() {
+				if !seenMetrics[sample.Metric.Name] {
+					seenMetrics[sample.Metric.Name] = true
+					if err := enc.Encode(jsonc.WrapMetric(sample.Metric)); err != nil {
+						_ = f.Close()
+						return written, errors.Wrapf(err, "couldn't write the metric %s", sample.Metric.Name)
+					}
+				}
+				if err := enc.Encode(jsonc.WrapSample(&sample)); err != nil {
+					_ = f.Close()
+					return written, errors.Wrapf(err, "couldn't write a sample of the metric %s", sample.Metric.Name)
+				}
+				written++
+			}
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return written, err
+	}
+	return written, f.Close()
+}
+
+// containerIdentity returns what identifies a sample container that the engine passed to all of
+// the outputs, i.e. the container itself if it's a pointer, or the array backing its samples. It
+// returns nil for containers without an identity, like single samples.
+func containerIdentity(sc stats.SampleContainer) interface{} {
+	switch c := sc.(type) {
+	case *httpext.Trail, *netext.NetTrail:
+		return c
+	case stats.Samples:
+		if len(c) > 0 {
+			return &c[0]
+		}
+	case stats.ConnectedSamples:
+		if len(c.Samples) > 0 {
+			return &c.Samples[0]
+		}
+	}
+	return nil
+}
+
+// spillOnPanicCollector runs a collector with a handler for its panics, which spills the
+// undelivered samples before k6 crashes. The engine runs every collector in a goroutine of its
+// own, which the handlers of the command don't cover.
+type spillOnPanicCollector struct {
+	lib.Collector
+	onPanic func()
+}
+
+// Run runs the wrapped collector, handling its panic.
+func (c *spillOnPanicCollector) Run(ctx context.Context) {
+	defer c.onPanic()
+	c.Collector.Run(ctx)
+}
+
+// CollectThresholdResults passes the threshold results to the wrapped collector, if it records them.
+func (c *spillOnPanicCollector) CollectThresholdResults(results []stats.ThresholdResult) {
+	collectThresholdResults(c.Collector, results)
+}
@@ -0,0 +1,143 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+	"github.com/loadimpact/k6/stats/dummy"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type spillRecorder struct {
+	*dummy.Collector
+	containers []stats.SampleContainer
+}
+
+func (c *spillRecorder) BufferedSamples() int {
+	return len(stats.FlattenSampleContainers(c.containers))
+}
+
+func (c *spillRecorder) SpillSamples() []stats.SampleContainer {
+	containers := c.containers
+	c.containers = nil
+	return containers
+}
+
+func TestSpillSamples(t *testing.T) {
+	now := time.Unix(1500000000, 0).UTC()
+	tags := stats.IntoSampleTags(&map[string]string{"status": "200"})
+	shared := stats.Samples{{Metric: metrics.HTTPReqDuration, Time: now, Tags: tags, Value: 120}}
+	// Equal, but separate samples, e.g. of two requests in the same instant.
+	equal := stats.Sample{Metric: metrics.HTTPReqs, Time: now, Tags: tags, Value: 1}
+	influxdb := &spillRecorder{Collector: &dummy.Collector{}, containers: []stats.SampleContainer{
+		shared,
+		stats.Samples{{Metric: metrics.HTTPReqDuration, Time: now.Add(2 * time.Second), Tags: tags, Value: 80}},
+		equal,
+		equal,
+	}}
+	kafka := &spillRecorder{Collector: &dummy.Collector{}, containers: []stats.SampleContainer{
+		shared,
+		stats.Samples{{Metric: metrics.HTTPReqDuration, Time: now, Tags: tags, Value: 120}},
+		equal,
+		stats.Sample{Metric: metrics.HTTPReqs, Time: now.Add(time.Second), Tags: tags, Value: 1},
+	}}
+
+	fs := afero.NewMemMapFs()
+	n, err := spillSamples(fs, "/spill.json", map[string]sampleSpiller{
+		"influxdb": influxdb,
+		"kafka":    kafka,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 6, n)
+	assert.Equal(t, 0, influxdb.BufferedSamples())
+	assert.Equal(t, 0, kafka.BufferedSamples())
+
+	f, err := fs.Open("/spill.json")
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+	engine, duration, err := replayJSONOutput(f, lib.Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, duration)
+	require.Contains(t, engine.Metrics, "http_req_duration")
+	assert.Equal(t, uint64(3), engine.Metrics["http_req_duration"].Sink.(*stats.TrendSink).Count)
+	require.Contains(t, engine.Metrics, "http_reqs")
+	assert.Equal(t, 3.0, engine.Metrics["http_reqs"].Sink.(*stats.CounterSink).Value)
+}
+
+func TestSpillSamplesWrapped(t *testing.T) {
+	sample := stats.Sample{Metric: metrics.HTTPReqs, Time: time.Unix(1500000000, 0).UTC(), Value: 1}
+	buffered := stats.Samples{sample}
+	held := stats.Samples{sample}
+
+	t.Run("OnFailure", func(t *testing.T) {
+		inner := &spillRecorder{Collector: &dummy.Collector{}, containers: []stats.SampleContainer{buffered}}
+		collector := &onFailureCollector{Collector: inner, failed: func() bool { return false }}
+		collector.Collect([]stats.SampleContainer{held})
+
+		var spiller lib.Collector = collector
+		_, buffering := spiller.(lib.BufferingCollector)
+		assert.False(t, buffering)
+		assert.Equal(t, []stats.SampleContainer{held, buffered}, collector.SpillSamples())
+		assert.Empty(t, collector.SpillSamples())
+	})
+
+	t.Run("Shadow", func(t *testing.T) {
+		inner := &spillRecorder{Collector: &dummy.Collector{}, containers: []stats.SampleContainer{buffered}}
+		collector := newShadowCollector(inner, "shadow", 10)
+		require.NoError(t, collector.Init())
+		collector.Collect([]stats.SampleContainer{held})
+
+		var spiller lib.Collector = collector
+		_, buffering := spiller.(lib.BufferingCollector)
+		assert.False(t, buffering)
+		assert.Equal(t, []stats.SampleContainer{held, buffered}, collector.SpillSamples())
+		assert.Empty(t, collector.SpillSamples())
+	})
+}
+
+type runPanickingCollector struct {
+	dummy.Collector
+}
+
+func (c *runPanickingCollector) Run(ctx context.Context) {
+	panic("output bug")
+}
+
+func TestSpillOnPanicCollector(t *testing.T) {
+	spilled := false
+	collector := &spillOnPanicCollector{
+		Collector: &runPanickingCollector{},
+		onPanic: func() {
+			if r := recover(); r != nil {
+				spilled = true
+			}
+		},
+	}
+	assert.NotPanics(t, func() { collector.Run(context.Background()) })
+	assert.True(t, spilled)
+}
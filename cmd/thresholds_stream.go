@@ -0,0 +1,71 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/loadimpact/k6/stats"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// thresholdsStream writes the results of every evaluation of the thresholds as NDJSON to the
+// --thresholds-stream file, so their health can be followed over the course of the test.
+type thresholdsStream struct {
+	mutex    sync.Mutex
+	file     afero.File
+	filename string
+}
+
+func newThresholdsStream(fs afero.Fs, filename string) (*thresholdsStream, error) {
+	file, err := fs.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &thresholdsStream{file: file, filename: filename}, nil
+}
+
+// handle is a core.ThresholdResultsHandler writing one line per threshold result.
+func (s *thresholdsStream) handle(results []stats.ThresholdResult) {
+	var buf []byte
+	for _, result := range results {
+		line, err := json.Marshal(result)
+		if err != nil {
+			log.WithError(err).WithField("metric", result.Metric).Warn("Couldn't encode a threshold result")
+			continue
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, err := s.file.Write(buf); err != nil {
+		log.WithError(err).WithField("filename", s.filename).Error("Couldn't write the threshold results")
+	}
+}
+
+func (s *thresholdsStream) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.file.Close()
+}
@@ -0,0 +1,66 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/stats"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestThresholdsStream(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	stream, err := newThresholdsStream(fs, "/thresholds.ndjson")
+	require.NoError(t, err)
+
+	now := time.Unix(1500000000, 0).UTC()
+	stream.handle([]stats.ThresholdResult{
+		{Time: now, Metric: "http_req_duration", Threshold: "p(95)<500", Value: null.FloatFrom(612.5)},
+		{Time: now, Metric: "checks", Threshold: "rate>0.9", Value: null.FloatFrom(1), Passed: true},
+	})
+	stream.handle([]stats.ThresholdResult{
+		{Time: now.Add(2 * time.Second), Metric: "checks", Threshold: "true", Passed: true},
+	})
+	require.NoError(t, stream.Close())
+
+	data, err := afero.ReadFile(fs, "/thresholds.ndjson")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	require.Len(t, lines, 3)
+
+	var first map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	assert.Equal(t, map[string]interface{}{
+		"time":      "2017-07-14T02:40:00Z",
+		"metric":    "http_req_duration",
+		"threshold": "p(95)<500",
+		"value":     612.5,
+		"passed":    false,
+	}, first)
+	assert.Contains(t, lines[2], `"value":null`)
+}
@@ -0,0 +1,107 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/consts"
+	"github.com/shibukawa/configdir"
+	"github.com/spf13/afero"
+)
+
+// The usage report can be turned off for all runs on a machine, regardless of the config of
+// the single runs, by creating this file in the system-wide or the user's k6 config folder
+// (e.g. /etc/xdg/loadimpact/k6/ or ~/.config/loadimpact/k6/ on Linux) or by setting the
+// DO_NOT_TRACK environment variable to anything but an empty string, "0" or "false". Otherwise
+// the usual precedence of the --no-usage-report flag, K6_NO_USAGE_REPORT and the config file
+// applies.
+const (
+	usageReportOptOutFile = "no-usage-report"
+	usageReportOptOutEnv  = "DO_NOT_TRACK"
+)
+
+// getUsageReportOptOutFolders returns the config folders where the opt-out file is looked for.
+func getUsageReportOptOutFolders() []string {
+	configDirs := configdir.New("loadimpact", "k6")
+	folders := []string{}
+	for _, configType := range []configdir.ConfigType{configdir.System, configdir.Global} {
+		for _, folder := range configDirs.QueryFolders(configType) {
+			folders = append(folders, folder.Path)
+		}
+	}
+	return folders
+}
+
+// getUsageReportOptOut returns the reason why the usage report is turned off for the whole
+// machine, or an empty string if it isn't.
+func getUsageReportOptOut(fs afero.Fs, folders []string, env map[string]string) string {
+	if v, ok := env[usageReportOptOutEnv]; ok {
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "", "0", "false":
+		default:
+			return usageReportOptOutEnv + " is set"
+		}
+	}
+	for _, folder := range folders {
+		filename := filepath.Join(folder, usageReportOptOutFile)
+		if _, err := fs.Stat(filename); err == nil {
+			return filename + " exists"
+		}
+	}
+	return ""
+}
+
+// reportUsage sends the anonymous stats of the test run to the developers in the background.
+func reportUsage(executor lib.Executor) {
+	go func() {
+		u := "http://k6reports.loadimpact.com/"
+		mime := "application/json"
+		var endTSeconds float64
+		if endT := executor.GetEndTime(); endT.Valid {
+			endTSeconds = time.Duration(endT.Duration).Seconds()
+		}
+		var stagesEndTSeconds float64
+		if stagesEndT := lib.SumStages(executor.GetStages()); stagesEndT.Valid {
+			stagesEndTSeconds = time.Duration(stagesEndT.Duration).Seconds()
+		}
+		body, err := json.Marshal(map[string]interface{}{
+			"k6_version":  consts.Version,
+			"vus_max":     executor.GetVUsMax(),
+			"iterations":  executor.GetEndIterations(),
+			"duration":    endTSeconds,
+			"st_duration": stagesEndTSeconds,
+			"goos":        runtime.GOOS,
+			"goarch":      runtime.GOARCH,
+		})
+		if err != nil {
+			panic(err) // This should never happen!!
+		}
+		_, _ = http.Post(u, mime, bytes.NewBuffer(body))
+	}()
+}
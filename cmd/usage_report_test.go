@@ -0,0 +1,46 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2019 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetUsageReportOptOut(t *testing.T) {
+	folders := []string{"/etc/xdg/loadimpact/k6", "/home/k6/.config/loadimpact/k6"}
+
+	fs := afero.NewMemMapFs()
+	assert.Equal(t, "", getUsageReportOptOut(fs, folders, nil))
+	for _, v := range []string{"", "0", "false", " FALSE "} {
+		assert.Equal(t, "", getUsageReportOptOut(fs, folders, map[string]string{"DO_NOT_TRACK": v}), v)
+	}
+	for _, v := range []string{"1", "true", "yes"} {
+		assert.Equal(t, "DO_NOT_TRACK is set", getUsageReportOptOut(fs, folders, map[string]string{"DO_NOT_TRACK": v}), v)
+	}
+
+	require.NoError(t, afero.WriteFile(fs, "/home/k6/.config/loadimpact/k6/no-usage-report", nil, 0644))
+	assert.Equal(t, "/home/k6/.config/loadimpact/k6/no-usage-report exists", getUsageReportOptOut(fs, folders, nil))
+	assert.Equal(t, "", getUsageReportOptOut(fs, folders[:1], nil))
+}
@@ -21,22 +21,66 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"runtime"
 
 	"github.com/loadimpact/k6/lib/consts"
 	"github.com/spf13/cobra"
 )
 
+var versionJSON = false
+
+// versionInfo is the version and build information of k6, for tools that record which k6 build
+// ran, printed by `k6 version --json`.
+type versionInfo struct {
+	Version   string `json:"version"`
+	GoVersion string `json:"goVersion"`
+	GOOS      string `json:"goos"`
+	GOARCH    string `json:"goarch"`
+	BuildDate string `json:"buildDate,omitempty"`
+}
+
+func getVersionInfo() versionInfo {
+	return versionInfo{
+		Version:   consts.Version,
+		GoVersion: runtime.Version(),
+		GOOS:      runtime.GOOS,
+		GOARCH:    runtime.GOARCH,
+		BuildDate: consts.BuildDate,
+	}
+}
+
+// printVersion writes the version of k6, either as text or as a single line of JSON.
+func printVersion(w io.Writer, asJSON bool) error {
+	if !asJSON {
+		_, err := fmt.Fprintln(w, "k6 v"+consts.Version)
+		return err
+	}
+	data, err := json.Marshal(getVersionInfo())
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
 // versionCmd represents the version command.
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Show application version",
-	Long:  `Show the application version and exit.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("k6 v" + consts.Version)
+	Long: `Show the application version and exit.
+
+With --json, the version and build information is printed as JSON instead, for tools
+that need to record exactly which k6 build ran.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printVersion(os.Stdout, versionJSON)
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(versionCmd)
+	versionCmd.Flags().BoolVar(&versionJSON, "json", versionJSON, "print the version and build information as JSON")
 }
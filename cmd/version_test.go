@@ -0,0 +1,58 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2019 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"testing"
+
+	"github.com/loadimpact/k6/lib/consts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrintVersion(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, printVersion(&buf, false))
+	assert.Equal(t, "k6 v"+consts.Version+"\n", buf.String())
+
+	defer func(buildDate string) { consts.BuildDate = buildDate }(consts.BuildDate)
+	for _, buildDate := range []string{"", "2019-10-01T12:00:00Z"} {
+		consts.BuildDate = buildDate
+		buf.Reset()
+		require.NoError(t, printVersion(&buf, true))
+
+		var info map[string]interface{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &info))
+		expected := map[string]interface{}{
+			"version":   consts.Version,
+			"goVersion": runtime.Version(),
+			"goos":      runtime.GOOS,
+			"goarch":    runtime.GOARCH,
+		}
+		if buildDate != "" {
+			expected["buildDate"] = buildDate
+		}
+		assert.Equal(t, expected, info)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2019 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// stallWatchdog periodically checks if the test is still making progress, i.e. if any
+// iterations were completed recently. If no iterations were completed in the last
+// timeout period, a warning is logged and a dump of all goroutine stacks is written to
+// out, to help with the diagnosis of deadlocked scripts or blocked network calls.
+type stallWatchdog struct {
+	timeout       time.Duration
+	checkInterval time.Duration
+	iterations    func() int64
+	paused        func() bool
+	out           io.Writer
+	logger        *log.Logger
+}
+
+// run blocks until the context is done. Only a single warning is emitted per stall, the
+// watchdog is re-armed once the test starts making progress again.
+func (w stallWatchdog) run(ctx context.Context) {
+	ticker := time.NewTicker(w.checkInterval)
+	defer ticker.Stop()
+
+	lastIterations := w.iterations()
+	lastProgress := time.Now()
+	fired := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if iterations := w.iterations(); iterations != lastIterations || w.paused() {
+				lastIterations = iterations
+				lastProgress = now
+				fired = false
+				continue
+			}
+			if fired || now.Sub(lastProgress) < w.timeout {
+				continue
+			}
+			fired = true
+			w.logger.WithFields(log.Fields{
+				"iterations": lastIterations,
+				"stalled":    now.Sub(lastProgress).Round(time.Second).String(),
+			}).Warn("No iterations have completed recently, the test may be stalled; dumping goroutine stacks")
+			_, _ = w.out.Write(goroutineDump())
+		}
+	}
+}
+
+// goroutineDump returns the stack traces of all goroutines.
+func goroutineDump() []byte {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
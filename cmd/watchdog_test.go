@@ -0,0 +1,93 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2019 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+type syncBuffer struct {
+	sync.Mutex
+	bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.Buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.Buffer.String()
+}
+
+func TestStallWatchdog(t *testing.T) {
+	run := func(iterations func() int64, paused bool) (*logtest.Hook, *syncBuffer) {
+		logger := log.New()
+		logger.Out = ioutil.Discard
+		hook := logtest.NewLocal(logger)
+		out := &syncBuffer{}
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		stallWatchdog{
+			timeout:       50 * time.Millisecond,
+			checkInterval: 5 * time.Millisecond,
+			iterations:    iterations,
+			paused:        func() bool { return paused },
+			out:           out,
+			logger:        logger,
+		}.run(ctx)
+		return hook, out
+	}
+
+	t.Run("Stalled", func(t *testing.T) {
+		hook, out := run(func() int64 { return 3 }, false)
+		if assert.Len(t, hook.AllEntries(), 1) {
+			assert.Equal(t, log.WarnLevel, hook.LastEntry().Level)
+			assert.Equal(t, int64(3), hook.LastEntry().Data["iterations"])
+		}
+		assert.Contains(t, out.String(), "goroutine ")
+		assert.Contains(t, out.String(), "TestStallWatchdog")
+	})
+	t.Run("Progressing", func(t *testing.T) {
+		var iterations int64
+		hook, out := run(func() int64 { return atomic.AddInt64(&iterations, 1) }, false)
+		assert.Empty(t, hook.AllEntries())
+		assert.Empty(t, out.String())
+	})
+	t.Run("Paused", func(t *testing.T) {
+		hook, out := run(func() int64 { return 0 }, true)
+		assert.Empty(t, hook.AllEntries())
+		assert.Empty(t, out.String())
+	})
+}
@@ -0,0 +1,74 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package core
+
+import (
+	"time"
+)
+
+// MetricsBaseline is a named snapshot of the values of the metrics, which the current ones can be
+// compared with later, e.g. to see whether a change made while the test is running improved the
+// latency. Only the formatted values are kept, not the sinks, which hold all the values of trends.
+type MetricsBaseline struct {
+	Name   string
+	Time   time.Duration
+	Values map[string]map[string]float64
+}
+
+// SaveMetricsBaseline takes a snapshot of the values of the metrics, like Sink.Format() of the
+// metrics of GetMetricsSnapshot(), and saves it with the given name, replacing any earlier
+// baseline with the same name.
+func (e *Engine) SaveMetricsBaseline(name string) MetricsBaseline {
+	baseline := MetricsBaseline{Name: name}
+	if e.Executor != nil {
+		baseline.Time = e.Executor.GetTime()
+	}
+	snapshot := e.GetMetricsSnapshot()
+	baseline.Values = make(map[string]map[string]float64, len(snapshot))
+	for name, m := range snapshot {
+		baseline.Values[name] = m.Sink.Format(baseline.Time)
+	}
+
+	e.baselinesLock.Lock()
+	defer e.baselinesLock.Unlock()
+	if e.baselines == nil {
+		e.baselines = make(map[string]MetricsBaseline)
+	}
+	e.baselines[name] = baseline
+	return baseline
+}
+
+// GetMetricsBaseline returns the baseline saved with the given name, if there is one.
+func (e *Engine) GetMetricsBaseline(name string) (MetricsBaseline, bool) {
+	e.baselinesLock.Lock()
+	defer e.baselinesLock.Unlock()
+	baseline, ok := e.baselines[name]
+	return baseline, ok
+}
+
+// DeleteMetricsBaseline deletes the baseline saved with the given name, and returns whether there
+// was one.
+func (e *Engine) DeleteMetricsBaseline(name string) bool {
+	e.baselinesLock.Lock()
+	defer e.baselinesLock.Unlock()
+	_, ok := e.baselines[name]
+	delete(e.baselines, name)
+	return ok
+}
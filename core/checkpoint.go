@@ -0,0 +1,285 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// MetricsCheckpoint is the state of the metrics of a test run at some point, which can be written
+// to disk periodically, so that the summary of a long test can still be produced if k6 crashes.
+type MetricsCheckpoint struct {
+	// When the checkpoint was taken, and how long the test had been running at that point.
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+
+	// The prefix of the names of the metrics, which the thresholds don't have.
+	MetricPrefix string `json:"metricPrefix,omitempty"`
+
+	Metrics []CheckpointMetric `json:"metrics"`
+}
+
+// checkpointTrendValues is the most values the restored sink of a trend metric without a
+// reservoir gets, spread as they were recorded in the histogram of the checkpoint.
+const checkpointTrendValues = 100000
+
+// CheckpointMetric is the state of the sink of a metric in a MetricsCheckpoint. Only the fields
+// of the sink of the metric's type are set.
+type CheckpointMetric struct {
+	Name     string           `json:"name"`
+	Type     stats.MetricType `json:"type"`
+	Contains stats.ValueType  `json:"contains"`
+	Tainted  null.Bool        `json:"tainted"`
+
+	// Counters and gauges.
+	Value float64 `json:"value,omitempty"`
+
+	// Gauges and trends.
+	Min float64 `json:"min,omitempty"`
+	Max float64 `json:"max,omitempty"`
+
+	// Rates.
+	Trues int64 `json:"trues,omitempty"`
+	Total int64 `json:"total,omitempty"`
+
+	// Trends, with the distribution of their values as an HDR histogram, in the compressed
+	// encoding of HdrHistogram, so the checkpoint doesn't grow with the number of values.
+	Count     uint64  `json:"count,omitempty"`
+	Sum       float64 `json:"sum,omitempty"`
+	Histogram []byte  `json:"histogram,omitempty"`
+	MaxValues int     `json:"maxValues,omitempty"`
+}
+
+// newCheckpointMetric returns the state of a metric. Sinks of other types, like custom ones set
+// with SetSinkFactory(), can't be saved.
+func newCheckpointMetric(m *stats.Metric) (CheckpointMetric, bool, error) {
+	cm := CheckpointMetric{Name: m.Name, Type: m.Type, Contains: m.Contains, Tainted: m.Tainted}
+	switch sink := m.Sink.(type) {
+	case *stats.CounterSink:
+		cm.Value = sink.Value
+	case *stats.GaugeSink:
+		cm.Value, cm.Min, cm.Max = sink.Value, sink.Min, sink.Max
+	case *stats.RateSink:
+		cm.Trues, cm.Total = sink.Trues, sink.Total
+	case *stats.TrendSink:
+		histogram, err := stats.NewTrendHistogram(sink).Encode()
+		if err != nil {
+			return cm, false, err
+		}
+		cm.Count, cm.Min, cm.Max, cm.Sum = sink.Count, sink.Min, sink.Max, sink.Sum
+		cm.Histogram, cm.MaxValues = histogram, sink.MaxValues
+	default:
+		return cm, false, nil
+	}
+	return cm, true, nil
+}
+
+// Metric returns a metric with a sink in the saved state. The reservoirs of trend sinks continue
+// sampling with the given seed.
+func (cm CheckpointMetric) Metric(reservoirSeed int64) (*stats.Metric, error) {
+	m := stats.New(cm.Name, cm.Type, cm.Contains)
+	m.Tainted = cm.Tainted
+	if strings.Contains(cm.Name, "{") {
+		_, sub := stats.NewSubmetric(cm.Name)
+		m.Sub = *sub
+	}
+	switch cm.Type {
+	case stats.Counter:
+		m.Sink = &stats.CounterSink{Value: cm.Value}
+	case stats.Gauge:
+		m.Sink = stats.RestoreGaugeSink(cm.Value, cm.Min, cm.Max)
+	case stats.Rate:
+		m.Sink = &stats.RateSink{Trues: cm.Trues, Total: cm.Total}
+	case stats.Trend:
+		sink, err := cm.trendSink(reservoirSeed)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid histogram of the metric %s", cm.Name)
+		}
+		m.Sink = sink
+	default:
+		return nil, errors.Errorf("the metric %s has the unknown type %s", cm.Name, cm.Type)
+	}
+	return m, nil
+}
+
+// trendSink returns a trend sink in the saved state, with values spread like the ones recorded in
+// the histogram, up to the size of the reservoir of the sink, or checkpointTrendValues.
+func (cm CheckpointMetric) trendSink(reservoirSeed int64) (*stats.TrendSink, error) {
+	sink := &stats.TrendSink{}
+	limit := int64(checkpointTrendValues)
+	if cm.MaxValues > 0 {
+		sink = stats.NewReservoirTrendSink(cm.MaxValues, reservoirSeed)
+		limit = int64(cm.MaxValues)
+	}
+	sink.Count, sink.Min, sink.Max, sink.Sum = cm.Count, cm.Min, cm.Max, cm.Sum
+	if cm.Count > 0 {
+		sink.Avg = cm.Sum / float64(cm.Count)
+	}
+	if len(cm.Histogram) == 0 {
+		return sink, nil
+	}
+
+	h, err := stats.DecodeHDRHistogram(cm.Histogram)
+	if err != nil {
+		return nil, err
+	}
+	total := h.TotalCount()
+	h.EachValue(func(value, count int64) {
+		if total > limit {
+			count = int64(math.Round(float64(count) * float64(limit) / float64(total)))
+		}
+		// The histogram only has the values to its precision, and none below 0.
+		v := math.Min(math.Max(float64(value)/stats.HDRValueScale, cm.Min), cm.Max)
+		for ; count > 0; count-- {
+			sink.Values = append(sink.Values, v)
+		}
+	})
+	// The values are already sorted, the median of an even number of them is interpolated.
+	sink.Med = sink.P(0.5)
+	return sink, nil
+}
+
+// ReadMetricsCheckpoint reads a checkpoint written by the engine.
+func ReadMetricsCheckpoint(r io.Reader) (*MetricsCheckpoint, error) {
+	var checkpoint MetricsCheckpoint
+	if err := json.NewDecoder(r).Decode(&checkpoint); err != nil {
+		return nil, errors.Wrap(err, "invalid metrics checkpoint")
+	}
+	return &checkpoint, nil
+}
+
+// SetCheckpoints makes the engine write the state of its metrics to the file at the given interval
+// while the test runs. The file is replaced atomically, so it always holds a complete checkpoint.
+func (e *Engine) SetCheckpoints(fs afero.Fs, filename string, interval time.Duration) {
+	e.checkpointFs = fs
+	e.checkpointFile = filename
+	e.checkpointInterval = interval
+}
+
+// GetMetricsCheckpoint returns the current state of the metrics, sorted by name.
+func (e *Engine) GetMetricsCheckpoint() (*MetricsCheckpoint, error) {
+	checkpoint := &MetricsCheckpoint{Time: time.Now()}
+	if e.Executor != nil {
+		checkpoint.Duration = e.Executor.GetTime()
+	}
+
+	// The state of the sinks is taken from the metrics themselves, instead of copies of them.
+	e.MetricsLock.Lock()
+	checkpoint.MetricPrefix = e.metricPrefix
+	for _, m := range e.Metrics {
+		cm, ok, err := newCheckpointMetric(m)
+		if err != nil {
+			e.MetricsLock.Unlock()
+			return nil, errors.Wrapf(err, "couldn't save the metric %s", m.Name)
+		}
+		if !ok {
+			e.logger.WithField("metric", m.Name).Debug("Engine: The sink of the metric can't be checkpointed")
+			continue
+		}
+		checkpoint.Metrics = append(checkpoint.Metrics, cm)
+	}
+	e.MetricsLock.Unlock()
+	sort.Slice(checkpoint.Metrics, func(i, j int) bool {
+		return checkpoint.Metrics[i].Name < checkpoint.Metrics[j].Name
+	})
+	return checkpoint, nil
+}
+
+// writeCheckpoint writes a checkpoint to a temporary file and renames it to the checkpoint file.
+func (e *Engine) writeCheckpoint() error {
+	checkpoint, err := e.GetMetricsCheckpoint()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(e.checkpointFile), "."+filepath.Base(e.checkpointFile)+".tmp")
+	if err := afero.WriteFile(e.checkpointFs, tmp, data, 0644); err != nil {
+		return err
+	}
+	return e.checkpointFs.Rename(tmp, e.checkpointFile)
+}
+
+func (e *Engine) runCheckpoints(ctx context.Context) {
+	ticker := time.NewTicker(e.checkpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := e.writeCheckpoint(); err != nil {
+				e.logger.WithError(err).WithField("filename", e.checkpointFile).Warn("Couldn't write the metrics checkpoint")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RestoreMetricsCheckpoint replaces the metrics of the engine with the ones from a checkpoint and
+// evaluates the thresholds over them, as if the test had ended when the checkpoint was taken. Like
+// ReplaySamples(), it's meant for engines that don't run a test.
+func (e *Engine) RestoreMetricsCheckpoint(checkpoint *MetricsCheckpoint) error {
+	e.MetricsLock.Lock()
+	e.Metrics = make(map[string]*stats.Metric, len(checkpoint.Metrics))
+	for _, cm := range checkpoint.Metrics {
+		m, err := cm.Metric(e.trendReservoirSeed)
+		if err != nil {
+			e.MetricsLock.Unlock()
+			return err
+		}
+		// The metrics keep their prefixed names, but the thresholds use the original ones.
+		name := strings.TrimPrefix(m.Name, checkpoint.MetricPrefix)
+		m.Thresholds = e.thresholds[name]
+		m.Submetrics = e.submetrics[name]
+		e.Metrics[m.Name] = m
+	}
+	// The submetrics of the thresholds get their metrics from the checkpoint, if it has them.
+	for parent, subs := range e.submetrics {
+		for _, sm := range subs {
+			if m, ok := e.Metrics[checkpoint.MetricPrefix+parent+"{"+sm.Suffix+"}"]; ok {
+				m.Sub = *sm
+				m.Sub.Name = m.Name
+				m.Sub.Parent = checkpoint.MetricPrefix + parent
+				sm.Metric = m
+			}
+		}
+	}
+	e.MetricsLock.Unlock()
+
+	if !e.NoThresholds {
+		_, _, results := e.runThresholdChecks(checkpoint.Duration)
+		e.emitThresholdResults(results)
+	}
+	return nil
+}
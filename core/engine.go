@@ -22,6 +22,7 @@ package core
 
 import (
 	"context"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -29,8 +30,12 @@ import (
 	"github.com/loadimpact/k6/core/local"
 	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/lib/netext/httpext"
 	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
 	"gopkg.in/guregu/null.v3"
 )
 
@@ -55,19 +60,82 @@ type Engine struct {
 	NoThresholds bool
 	NoSummary    bool
 
+	// If set, the executor isn't started until all of these collectors report that they're
+	// ready, waiting at most OutputsReadyTimeout for them, if it's greater than 0.
+	ReadyCollectors     []lib.ReadyCollector
+	OutputsReadyTimeout time.Duration
+
+	// The collectors that can be flushed on demand with FlushOutputs(), by their labels. They're
+	// set with SetFlushCollectors() while the REST API may already be serving, hence the mutex.
+	flushCollectors map[string]lib.FlushingCollector
+	flushMutex      sync.Mutex
+
+	// The collectors whose delivery failures are reported once the outputs have flushed at the
+	// end of the test, by their labels.
+	FailingCollectors map[string]lib.FailingCollector
+
 	logger *log.Logger
 
 	Metrics     map[string]*stats.Metric
 	MetricsLock sync.Mutex
 
+	// Serializes the calls to Collect() of the collectors, which are made without the MetricsLock.
+	collectLock sync.Mutex
+
 	Samples chan stats.SampleContainer
 
+	// Creates the sinks of the metrics, with the factories set for their types.
+	sinks *stats.Registry
+
 	// Assigned to metrics upon first received sample.
 	thresholds map[string]stats.Thresholds
 	submetrics map[string][]*stats.Submetric
 
 	// Are thresholds tainted?
 	thresholdsTainted bool
+
+	// Metric names that should be replaced before the samples reach the engine and the collectors.
+	// The thresholds are still resolved by the original names, from originalNames.
+	metricNames    map[string]string
+	originalNames  map[string]string
+	renamedMetrics map[string]*stats.Metric
+
+	// If set, all metric names are prefixed with it after the thresholds are resolved.
+	metricPrefix    string
+	prefixedMetrics map[string]*stats.Metric
+
+	// The seed of the reservoir sampling of the trend metrics, from the options or the time.
+	trendReservoirSeed int64
+
+	// If set, the sample times are clamped before the samples are processed.
+	timeClamper *sampleTimeClamper
+
+	// If set, the run is aborted when the idle metric gets no samples for the idle timeout. The
+	// last sample is timed with the clock of the executor, see idleClock().
+	idleMetric     string
+	idleTimeout    time.Duration
+	idleLastSample time.Duration
+
+	// If set, the metrics are periodically written to the checkpoint file.
+	checkpointFs       afero.Fs
+	checkpointFile     string
+	checkpointInterval time.Duration
+
+	// The samples from the warm-up period after the start of the run aren't added to the metrics.
+	warmup    time.Duration
+	startTime time.Time
+
+	runEventHandlers         []RunEventHandler
+	thresholdResultsHandlers []ThresholdResultsHandler
+	breachedThresholds       map[string]bool
+
+	// The metrics snapshots saved with SaveMetricsBaseline(), by name.
+	baselines     map[string]MetricsBaseline
+	baselinesLock sync.Mutex
+
+	// Closed when the collectors have stopped, after flushing all of their samples.
+	outputsFlushed chan struct{}
+	outputFlushes  []OutputFlush // set before outputsFlushed is closed
 }
 
 func NewEngine(ex lib.Executor, o lib.Options) (*Engine, error) {
@@ -76,10 +144,13 @@ func NewEngine(ex lib.Executor, o lib.Options) (*Engine, error) {
 	}
 
 	e := &Engine{
-		Executor: ex,
-		Options:  o,
-		Metrics:  make(map[string]*stats.Metric),
-		Samples:  make(chan stats.SampleContainer, o.MetricSamplesBufferSize.Int64),
+		Executor:           ex,
+		Options:            o,
+		Metrics:            make(map[string]*stats.Metric),
+		Samples:            make(chan stats.SampleContainer, o.MetricSamplesBufferSize.Int64),
+		sinks:              stats.NewRegistry(),
+		breachedThresholds: make(map[string]bool),
+		outputsFlushed:     make(chan struct{}),
 	}
 	e.SetLogger(log.StandardLogger())
 
@@ -94,6 +165,16 @@ func NewEngine(ex lib.Executor, o lib.Options) (*Engine, error) {
 	ex.SetEndTime(o.Duration)
 	ex.SetEndIterations(o.Iterations)
 
+	e.trendReservoirSeed = time.Now().UnixNano()
+	if o.TrendReservoirSeed.Valid {
+		e.trendReservoirSeed = o.TrendReservoirSeed.Int64
+	}
+	if size := int(o.TrendReservoirSize.Int64); size > 0 {
+		e.sinks.SetSinkFactory(stats.Trend, func() stats.Sink {
+			return stats.NewReservoirTrendSink(size, e.trendReservoirSeed)
+		})
+	}
+
 	e.thresholds = o.Thresholds
 	e.submetrics = make(map[string][]*stats.Submetric)
 	for name := range e.thresholds {
@@ -109,6 +190,7 @@ func NewEngine(ex lib.Executor, o lib.Options) (*Engine, error) {
 }
 
 func (e *Engine) setRunStatus(status lib.RunStatus) {
+	e.emitRunEvent(RunEvent{Type: RunEventAborting, AbortReason: status})
 	if len(e.Collectors) == 0 {
 		return
 	}
@@ -142,6 +224,7 @@ func (e *Engine) Run(ctx context.Context) error {
 		fields["iter"] = endIter.Int64
 	}
 	e.logger.WithFields(fields).Debug(" - end conditions (if any)")
+	e.emitRunEvent(RunEvent{Type: RunEventVUsInitialized})
 
 	collectorwg := sync.WaitGroup{}
 	collectorctx, collectorcancel := context.WithCancel(context.Background())
@@ -154,6 +237,21 @@ func (e *Engine) Run(ctx context.Context) error {
 			}(collector)
 		}
 	}
+	e.emitRunEvent(RunEvent{Type: RunEventOutputsStarted})
+
+	if err := e.waitOutputsReady(ctx); err != nil {
+		if ctx.Err() != nil {
+			e.setRunStatus(lib.RunStatusAbortedUser)
+			err = nil
+		} else {
+			e.setRunStatus(lib.RunStatusAbortedSystem)
+		}
+		collectorcancel()
+		collectorwg.Wait()
+		e.outputsStopped()
+		e.emitRunEvent(RunEvent{Type: RunEventRunFinished})
+		return err
+	}
 
 	subctx, subcancel := context.WithCancel(context.Background())
 	subwg := sync.WaitGroup{}
@@ -166,6 +264,16 @@ func (e *Engine) Run(ctx context.Context) error {
 		subwg.Done()
 	}()
 
+	// Run checkpoints.
+	if e.checkpointInterval > 0 {
+		subwg.Add(1)
+		go func() {
+			e.runCheckpoints(subctx)
+			e.logger.Debug("Engine: Checkpoints terminated")
+			subwg.Done()
+		}()
+	}
+
 	// Run thresholds.
 	if !e.NoThresholds {
 		subwg.Add(1)
@@ -178,6 +286,10 @@ func (e *Engine) Run(ctx context.Context) error {
 
 	// Run the executor.
 	errC := make(chan error)
+	e.MetricsLock.Lock()
+	e.startTime = time.Now()
+	e.MetricsLock.Unlock()
+	e.emitRunEvent(RunEvent{Type: RunEventRunStarted, Time: e.startTime})
 	subwg.Add(1)
 	go func() {
 		errC <- e.Executor.Run(subctx, e.Samples)
@@ -219,6 +331,11 @@ func (e *Engine) Run(ctx context.Context) error {
 		// Finally, shut down collector.
 		collectorcancel()
 		collectorwg.Wait()
+		e.outputsStopped()
+
+		e.logUnusedMetricNames()
+		e.logClampedSampleTimes()
+		e.emitRunEvent(RunEvent{Type: RunEventRunFinished})
 	}()
 
 	ticker := time.NewTicker(CollectRate)
@@ -229,6 +346,11 @@ func (e *Engine) Run(ctx context.Context) error {
 				e.processSamples(sampleContainers)
 				sampleContainers = []stats.SampleContainer{}
 			}
+			if err := e.checkIdle(e.idleClock()); err != nil {
+				e.logger.WithError(err).Debug("run: aborting an idle test")
+				e.setRunStatus(lib.RunStatusAbortedSystem)
+				return err
+			}
 		case sc := <-e.Samples:
 			sampleContainers = append(sampleContainers, sc)
 		case err := <-errC:
@@ -248,10 +370,351 @@ func (e *Engine) Run(ctx context.Context) error {
 	}
 }
 
+// waitOutputsReady blocks until all of the ReadyCollectors are ready, or until the timeout
+// expires or the context is done.
+func (e *Engine) waitOutputsReady(ctx context.Context) error {
+	if len(e.ReadyCollectors) == 0 {
+		return nil
+	}
+	if e.OutputsReadyTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.OutputsReadyTimeout)
+		defer cancel()
+	}
+
+	e.logger.WithField("outputs", len(e.ReadyCollectors)).Info("Waiting for the outputs to be ready")
+	errs := make(chan error, len(e.ReadyCollectors))
+	for _, collector := range e.ReadyCollectors {
+		go func(collector lib.ReadyCollector) {
+			errs <- collector.WaitReady(ctx)
+		}(collector)
+	}
+	var firstErr error
+	for range e.ReadyCollectors {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return errors.Wrap(firstErr, "the outputs weren't ready")
+	}
+	e.logger.Debug("All outputs are ready")
+	return nil
+}
+
+// SetMetricNameMapping configures the engine to rename the metrics with the keys of the
+// given map to the corresponding values, before their samples are processed by the engine
+// and sent to the collectors. The renamed metrics keep the type of the original ones, and
+// their thresholds and submetrics are still the ones defined for the original names.
+func (e *Engine) SetMetricNameMapping(mapping map[string]string) error {
+	targets := make(map[string]string, len(mapping))
+	for from, to := range mapping {
+		if from == "" || to == "" {
+			return errors.Errorf("invalid metric name mapping '%s' -> '%s'", from, to)
+		}
+		if _, ok := mapping[to]; ok {
+			return errors.Errorf("metric '%s' can't be both renamed and a rename target", to)
+		}
+		if other, ok := targets[to]; ok {
+			return errors.Errorf("metrics '%s' and '%s' can't both be renamed to '%s'", other, from, to)
+		}
+		targets[to] = from
+	}
+
+	e.MetricsLock.Lock()
+	defer e.MetricsLock.Unlock()
+	e.metricNames = mapping
+	e.originalNames = targets
+	e.renamedMetrics = make(map[string]*stats.Metric, len(mapping))
+	return nil
+}
+
+// SetMetricPrefix configures the engine to prefix the names of all metrics, e.g. to keep them
+// apart from the ones of other teams in a shared metrics database. The thresholds still use the
+// original names, but the outputs, the summary and the REST API get the prefixed ones.
+func (e *Engine) SetMetricPrefix(prefix string) error {
+	if strings.ContainsAny(prefix, "{}") {
+		return errors.Errorf("invalid metric prefix '%s', it can't contain '{' or '}'", prefix)
+	}
+
+	e.MetricsLock.Lock()
+	defer e.MetricsLock.Unlock()
+	e.metricPrefix = prefix
+	e.prefixedMetrics = make(map[string]*stats.Metric)
+	return nil
+}
+
+// dropDisabledMetrics removes the samples of the disabled metrics that weren't already left out
+// where they are emitted. Containers without any of them are passed through untouched, the rest
+// are copied with WithSamples(), or dropped if nothing is left.
+func (e *Engine) dropDisabledMetrics(sampleContainers []stats.SampleContainer) []stats.SampleContainer {
+	disabled := e.Options.DisabledMetrics
+	if len(disabled) == 0 {
+		return sampleContainers
+	}
+
+	result := sampleContainers[:0]
+	for _, sc := range sampleContainers {
+		samples := sc.GetSamples()
+		var kept []stats.Sample
+		for j, sample := range samples {
+			if !disabled[sample.Metric.Name] {
+				if kept != nil {
+					kept = append(kept, sample)
+				}
+				continue
+			}
+			if kept == nil {
+				kept = make([]stats.Sample, j, len(samples))
+				copy(kept, samples[:j])
+			}
+		}
+		switch {
+		case kept == nil:
+			result = append(result, sc)
+		case len(kept) == 0:
+			continue
+		default:
+			result = append(result, WithSamples(sc, kept))
+		}
+	}
+	return result
+}
+
+// renameMetrics replaces the metrics of the samples according to the metric name mapping.
+func (e *Engine) renameMetrics(sampleContainers []stats.SampleContainer) []stats.SampleContainer {
+	if len(e.metricNames) == 0 {
+		return sampleContainers
+	}
+	return replaceSampleMetrics(sampleContainers, func(metric *stats.Metric) *stats.Metric {
+		to, ok := e.metricNames[metric.Name]
+		if !ok {
+			return nil
+		}
+		m, ok := e.renamedMetrics[metric.Name]
+		if !ok {
+			m = stats.New(to, metric.Type, metric.Contains)
+			e.renamedMetrics[metric.Name] = m
+		}
+		return m
+	})
+}
+
+// prefixMetrics replaces the metrics of the samples with ones with the metric prefix.
+func (e *Engine) prefixMetrics(sampleContainers []stats.SampleContainer) []stats.SampleContainer {
+	if e.metricPrefix == "" {
+		return sampleContainers
+	}
+	return replaceSampleMetrics(sampleContainers, func(metric *stats.Metric) *stats.Metric {
+		m, ok := e.prefixedMetrics[metric.Name]
+		if !ok {
+			m = stats.New(e.metricPrefix+metric.Name, metric.Type, metric.Contains)
+			e.prefixedMetrics[metric.Name] = m
+		}
+		return m
+	})
+}
+
+// replaceSampleMetrics replaces the metrics of the samples with the ones returned by replace,
+// unless it returns nil. Containers without any replaced metrics are passed through untouched,
+// the rest are copied with WithSamples().
+func replaceSampleMetrics(
+	sampleContainers []stats.SampleContainer, replace func(*stats.Metric) *stats.Metric,
+) []stats.SampleContainer {
+	for i, sc := range sampleContainers {
+		samples := sc.GetSamples()
+		var renamed []stats.Sample
+		for j, sample := range samples {
+			m := replace(sample.Metric)
+			if m == nil {
+				continue
+			}
+			if renamed == nil {
+				renamed = make([]stats.Sample, len(samples))
+				copy(renamed, samples)
+			}
+			renamed[j].Metric = m
+		}
+		if renamed != nil {
+			sampleContainers[i] = WithSamples(sc, renamed)
+		}
+	}
+	return sampleContainers
+}
+
+// WithSamples returns a copy of the sample container with the supplied samples instead of its
+// own. The copy has the same type as the original, so the collectors can still handle the HTTP
+// and network trails specially, e.g. the cloud collector aggregates the HTTP trails.
+func WithSamples(sc stats.SampleContainer, samples []stats.Sample) stats.SampleContainer {
+	switch c := sc.(type) {
+	case stats.Sample:
+		if len(samples) == 1 {
+			return samples[0]
+		}
+	case stats.ConnectedSamples:
+		c.Samples = samples
+		return c
+	case *httpext.Trail:
+		trail := *c
+		trail.Samples = samples
+		return &trail
+	case *netext.NetTrail:
+		trail := *c
+		trail.Samples = samples
+		return &trail
+	}
+	if csc, ok := sc.(stats.ConnectedSampleContainer); ok {
+		return stats.ConnectedSamples{Samples: samples, Tags: csc.GetTags(), Time: csc.GetTime()}
+	}
+	return stats.Samples(samples)
+}
+
+// logUnusedMetricNames logs the metric name mappings that didn't match any emitted metric.
+func (e *Engine) logUnusedMetricNames() {
+	e.MetricsLock.Lock()
+	defer e.MetricsLock.Unlock()
+	for from, to := range e.metricNames {
+		if _, ok := e.renamedMetrics[from]; !ok {
+			e.logger.WithFields(log.Fields{"from": from, "to": to}).Debug(
+				"Engine: Ignored the name mapping of a metric that wasn't emitted")
+		}
+	}
+}
+
+// ReplaySamples processes the samples recorded by an earlier test run, e.g. read back from a JSON
+// output file, and then evaluates the thresholds as if the test had run for the given duration.
+// It's meant for engines that don't run a test, so it mustn't be used together with Run().
+func (e *Engine) ReplaySamples(sampleContainers []stats.SampleContainer, duration time.Duration) {
+	e.processSamples(sampleContainers)
+	if !e.NoThresholds {
+		_, _, results := e.runThresholdChecks(duration)
+		e.emitThresholdResults(results)
+	}
+}
+
+// OutputsFlushed returns whether the collectors have stopped at the end of the test, so the
+// backends can be torn down. Whether they delivered all of their samples is told by FailedOutputs().
+func (e *Engine) OutputsFlushed() bool {
+	select {
+	case <-e.outputsFlushed:
+		return true
+	default:
+		return false
+	}
+}
+
+// WaitOutputsFlushed blocks until the collectors have stopped at the end of the test, or until
+// the context is done. It returns an error if some of the outputs failed to deliver their samples.
+func (e *Engine) WaitOutputsFlushed(ctx context.Context) error {
+	select {
+	case <-e.outputsFlushed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if failed := e.FailedOutputs(); len(failed) > 0 {
+		return errors.Errorf("some outputs failed to deliver their samples: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// FailedOutputs returns the labels of the outputs that failed to deliver their samples, sorted,
+// once the collectors have stopped at the end of the test.
+func (e *Engine) FailedOutputs() []string {
+	if !e.OutputsFlushed() {
+		return nil
+	}
+	var failed []string
+	for _, flush := range e.outputFlushes {
+		if flush.Err != nil {
+			failed = append(failed, flush.Output)
+		}
+	}
+	return failed
+}
+
 func (e *Engine) IsTainted() bool {
 	return e.thresholdsTainted
 }
 
+// ThresholdAbortMessages returns the messages of the thresholds that aborted the test, prefixed
+// with the names of their metrics and sorted by them. Thresholds without a message are skipped.
+func (e *Engine) ThresholdAbortMessages() []string {
+	e.MetricsLock.Lock()
+	defer e.MetricsLock.Unlock()
+
+	var messages []string
+	for name, m := range e.Metrics {
+		for _, th := range m.Thresholds.Thresholds {
+			if th.AbortedTest && th.Message != "" {
+				messages = append(messages, name+": "+th.Message)
+			}
+		}
+	}
+	sort.Strings(messages)
+	return messages
+}
+
+// GetMetricsSnapshot returns a copy of all currently observed metrics and their sink values.
+// It's taken while holding the metrics lock, so the result can be safely used (and even
+// modified) by external code without racing with the ingestion of new samples. If any names are
+// given, only the observed metrics with those names are copied.
+func (e *Engine) GetMetricsSnapshot(names ...string) map[string]*stats.Metric {
+	e.MetricsLock.Lock()
+	defer e.MetricsLock.Unlock()
+
+	copyMetric := func(m *stats.Metric) *stats.Metric {
+		metricCopy := *m
+		metricCopy.Sink = stats.CloneSink(m.Sink)
+		return &metricCopy
+	}
+	if len(names) > 0 {
+		snapshot := make(map[string]*stats.Metric, len(names))
+		for _, name := range names {
+			if m, ok := e.Metrics[name]; ok {
+				snapshot[name] = copyMetric(m)
+			}
+		}
+		return snapshot
+	}
+
+	snapshot := make(map[string]*stats.Metric, len(e.Metrics))
+	for name, m := range e.Metrics {
+		snapshot[name] = copyMetric(m)
+	}
+	return snapshot
+}
+
+// GetRegisteredMetrics returns all of the metrics the test may emit: the built-in ones, the custom
+// ones declared by the script and any that were already observed, except for the disabled ones. The
+// observed metrics are the same as in GetMetricsSnapshot(), the rest have no samples yet.
+func (e *Engine) GetRegisteredMetrics() map[string]*stats.Metric {
+	registered := e.GetMetricsSnapshot()
+
+	declared := metrics.Builtin()
+	if e.Executor != nil {
+		if r, ok := e.Executor.GetRunner().(lib.MetricsRunner); ok {
+			declared = append(declared, r.GetCustomMetrics()...)
+		}
+	}
+
+	e.MetricsLock.Lock()
+	defer e.MetricsLock.Unlock()
+	for _, m := range declared {
+		if e.Options.DisabledMetrics[m.Name] {
+			continue
+		}
+		name := m.Name
+		if to, ok := e.metricNames[name]; ok {
+			name = to
+		}
+		name = e.metricPrefix + name
+		if _, ok := registered[name]; !ok {
+			registered[name] = stats.New(name, m.Type, m.Contains)
+		}
+	}
+	return registered
+}
+
 func (e *Engine) SetLogger(l *log.Logger) {
 	e.logger = l
 	e.Executor.SetLogger(l)
@@ -308,12 +771,31 @@ func (e *Engine) runThresholds(ctx context.Context, abort func()) {
 }
 
 func (e *Engine) processThresholds(abort func()) {
+	breached, abortOnFail, results := e.runThresholdChecks(e.Executor.GetTime())
+
+	// The events are emitted after the metrics are unlocked, so handlers can inspect them.
+	e.emitThresholdResults(results)
+	for _, name := range breached {
+		e.emitRunEvent(RunEvent{Type: RunEventThresholdBreached, Metric: name})
+	}
+
+	if abortOnFail && abort != nil {
+		//TODO: When sending this status we get a 422 Unprocessable Entity
+		e.setRunStatus(lib.RunStatusAbortedThreshold)
+		abort()
+	}
+}
+
+// runThresholdChecks runs the thresholds of all metrics at the test time t and returns the names
+// of the metrics whose thresholds were breached for the first time, whether the test should be
+// aborted and the results of the individual thresholds.
+func (e *Engine) runThresholdChecks(t time.Duration) (
+	breached []string, abortOnFail bool, results []stats.ThresholdResult,
+) {
 	e.MetricsLock.Lock()
 	defer e.MetricsLock.Unlock()
 
-	t := e.Executor.GetTime()
-	abortOnFail := false
-
+	now := time.Now()
 	e.thresholdsTainted = false
 	for _, m := range e.Metrics {
 		if len(m.Thresholds.Thresholds) == 0 {
@@ -327,6 +809,17 @@ func (e *Engine) processThresholds(abort func()) {
 			e.logger.WithField("m", m.Name).WithError(err).Error("Threshold error")
 			continue
 		}
+		if len(e.thresholdResultsHandlers) > 0 {
+			for _, th := range m.Thresholds.Thresholds {
+				results = append(results, stats.ThresholdResult{
+					Time:      now,
+					Metric:    m.Name,
+					Threshold: th.Source,
+					Value:     th.Value(),
+					Passed:    !th.LastFailed,
+				})
+			}
+		}
 		if !succ {
 			e.logger.WithField("m", m.Name).Debug("Thresholds failed")
 			m.Tainted = null.BoolFrom(true)
@@ -334,14 +827,46 @@ func (e *Engine) processThresholds(abort func()) {
 			if !abortOnFail && m.Thresholds.Abort {
 				abortOnFail = true
 			}
+			if !e.breachedThresholds[m.Name] {
+				e.breachedThresholds[m.Name] = true
+				breached = append(breached, m.Name)
+			}
 		}
 	}
+	sort.Strings(breached)
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Metric < results[j].Metric })
+	return breached, abortOnFail, results
+}
 
-	if abortOnFail && abort != nil {
-		//TODO: When sending this status we get a 422 Unprocessable Entity
-		e.setRunStatus(lib.RunStatusAbortedThreshold)
-		abort()
+// ThresholdResultsHandler is called with the results of the thresholds every time the engine
+// evaluates them.
+type ThresholdResultsHandler func([]stats.ThresholdResult)
+
+// AddThresholdResultsHandler registers a handler for the threshold results. It has to be called
+// before the engine is started.
+func (e *Engine) AddThresholdResultsHandler(handler ThresholdResultsHandler) {
+	e.thresholdResultsHandlers = append(e.thresholdResultsHandlers, handler)
+}
+
+func (e *Engine) emitThresholdResults(results []stats.ThresholdResult) {
+	if len(results) == 0 {
+		return
 	}
+	for _, handler := range e.thresholdResultsHandlers {
+		handler(results)
+	}
+}
+
+// SetSinkFactory makes the sinks of the metrics of the given type, which the engine creates
+// when their first samples arrive, created by the factory. It replaces the reservoir sampling
+// of --trend-reservoir-size for trends. A nil factory restores the default sinks.
+func (e *Engine) SetSinkFactory(typ stats.MetricType, factory stats.SinkFactory) {
+	e.sinks.SetSinkFactory(typ, factory)
+}
+
+// newMetric creates a new metric for the engine, with a sink from the factory for its type.
+func (e *Engine) newMetric(name string, typ stats.MetricType, contains stats.ValueType) *stats.Metric {
+	return e.sinks.NewMetric(name, typ, contains)
 }
 
 func (e *Engine) processSamplesForMetrics(sampleCointainers []stats.SampleContainer) {
@@ -353,14 +878,24 @@ func (e *Engine) processSamplesForMetrics(sampleCointainers []stats.SampleContai
 		}
 
 		for _, sample := range samples {
-			m, ok := e.Metrics[sample.Metric.Name]
+			// The metrics are stored with the renamed and prefixed names, but the thresholds and
+			// submetrics use the original ones.
+			name := e.metricPrefix + sample.Metric.Name
+			m, ok := e.Metrics[name]
 			if !ok {
-				m = stats.New(sample.Metric.Name, sample.Metric.Type, sample.Metric.Contains)
-				m.Thresholds = e.thresholds[m.Name]
-				m.Submetrics = e.submetrics[m.Name]
-				e.Metrics[m.Name] = m
+				originalName := sample.Metric.Name
+				if from, ok := e.originalNames[originalName]; ok {
+					originalName = from
+				}
+				m = e.newMetric(name, sample.Metric.Type, sample.Metric.Contains)
+				m.Thresholds = e.thresholds[originalName]
+				m.Submetrics = e.submetrics[originalName]
+				e.Metrics[name] = m
 			}
 			m.Sink.Add(sample)
+			if !e.NoThresholds {
+				m.Thresholds.AddSample(sample)
+			}
 
 			for _, sm := range m.Submetrics {
 				if !sample.Tags.Contains(sm.Tags) {
@@ -368,12 +903,18 @@ func (e *Engine) processSamplesForMetrics(sampleCointainers []stats.SampleContai
 				}
 
 				if sm.Metric == nil {
-					sm.Metric = stats.New(sm.Name, sample.Metric.Type, sample.Metric.Contains)
+					smName := m.Name + "{" + sm.Suffix + "}"
+					sm.Metric = e.newMetric(smName, sample.Metric.Type, sample.Metric.Contains)
 					sm.Metric.Sub = *sm
+					sm.Metric.Sub.Name = sm.Metric.Name
+					sm.Metric.Sub.Parent = m.Name
 					sm.Metric.Thresholds = e.thresholds[sm.Name]
-					e.Metrics[sm.Name] = sm.Metric
+					e.Metrics[sm.Metric.Name] = sm.Metric
 				}
 				sm.Metric.Sink.Add(sample)
+				if !e.NoThresholds {
+					sm.Metric.Thresholds.AddSample(sample)
+				}
 			}
 		}
 	}
@@ -386,16 +927,33 @@ func (e *Engine) processSamples(sampleCointainers []stats.SampleContainer) {
 
 	// TODO: optimize this...
 	e.MetricsLock.Lock()
-	defer e.MetricsLock.Unlock()
+
+	sampleCointainers = e.dropDisabledMetrics(sampleCointainers)
+	sampleCointainers = e.renameMetrics(sampleCointainers)
+	e.trackIdleMetric(sampleCointainers, e.idleClock())
+	sampleCointainers = e.clampSampleTimes(sampleCointainers)
+	sampleCointainers = e.tagStages(sampleCointainers)
+
+	metricsContainers, sampleCointainers := e.splitWarmupSamples(sampleCointainers)
 
 	// TODO: run this and the below code in goroutines?
 	if !(e.NoSummary && e.NoThresholds) {
-		e.processSamplesForMetrics(sampleCointainers)
+		e.processSamplesForMetrics(metricsContainers)
 	}
 
-	if len(e.Collectors) > 0 {
-		for _, collector := range e.Collectors {
-			collector.Collect(sampleCointainers)
-		}
+	if len(e.Collectors) == 0 {
+		e.MetricsLock.Unlock()
+		return
+	}
+	sampleCointainers = e.prefixMetrics(sampleCointainers)
+
+	// The outputs may block, e.g. when their buffers are full, so they're passed the samples
+	// after the metrics are unlocked. The collect lock is taken first, so they still get the
+	// samples one batch at a time, in the order they were processed.
+	e.collectLock.Lock()
+	defer e.collectLock.Unlock()
+	e.MetricsLock.Unlock()
+	for _, collector := range e.Collectors {
+		collector.Collect(sampleCointainers)
 	}
 }
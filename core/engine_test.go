@@ -22,8 +22,13 @@ package core
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/url"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -31,6 +36,8 @@ import (
 	"github.com/loadimpact/k6/js"
 	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/lib/netext/httpext"
 	"github.com/loadimpact/k6/lib/testutils"
 	"github.com/loadimpact/k6/lib/types"
 	"github.com/loadimpact/k6/loader"
@@ -38,6 +45,7 @@ import (
 	"github.com/loadimpact/k6/stats/dummy"
 	log "github.com/sirupsen/logrus"
 	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	null "gopkg.in/guregu/null.v3"
@@ -374,6 +382,429 @@ func TestEngine_processSamples(t *testing.T) {
 	})
 }
 
+func TestEngine_SetSinkFactory(t *testing.T) {
+	metric := stats.New("my_metric", stats.Gauge)
+	ths, err := stats.NewThresholds([]string{`value>0`})
+	require.NoError(t, err)
+	e, err := newTestEngine(nil, lib.Options{
+		Thresholds: map[string]stats.Thresholds{"my_metric{a:1}": ths},
+	})
+	require.NoError(t, err)
+
+	created := 0
+	e.SetSinkFactory(stats.Gauge, func() stats.Sink {
+		created++
+		return &stats.TrendSink{}
+	})
+	e.processSamples(
+		[]stats.SampleContainer{stats.Sample{Metric: metric, Value: 1.25, Tags: stats.IntoSampleTags(&map[string]string{"a": "1"})}},
+	)
+	assert.Equal(t, 2, created)
+	assert.Equal(t, uint64(1), e.Metrics["my_metric"].Sink.(*stats.TrendSink).Count)
+	assert.Equal(t, uint64(1), e.Metrics["my_metric{a:1}"].Sink.(*stats.TrendSink).Count)
+
+	t.Run("reservoir", func(t *testing.T) {
+		e, err := newTestEngine(nil, lib.Options{TrendReservoirSize: null.IntFrom(10)})
+		require.NoError(t, err)
+		e.processSamples([]stats.SampleContainer{stats.Sample{Metric: stats.New("my_trend", stats.Trend), Value: 1}})
+		assert.Equal(t, 10, e.Metrics["my_trend"].Sink.(*stats.TrendSink).MaxValues)
+	})
+
+	t.Run("reservoir seed", func(t *testing.T) {
+		sampled := func() []float64 {
+			e, err := newTestEngine(nil, lib.Options{
+				TrendReservoirSize: null.IntFrom(10),
+				TrendReservoirSeed: null.IntFrom(42),
+			})
+			require.NoError(t, err)
+			metric := stats.New("my_trend", stats.Trend)
+			samples := make(stats.Samples, 100)
+			for i := range samples {
+				samples[i] = stats.Sample{Metric: metric, Value: float64(i)}
+			}
+			e.processSamples([]stats.SampleContainer{samples})
+			return e.Metrics["my_trend"].Sink.(*stats.TrendSink).Values
+		}
+		assert.Equal(t, sampled(), sampled())
+	})
+}
+
+func TestEngine_SetMetricNameMapping(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		testdata := map[string]map[string]string{
+			"empty source":    {"": "foo"},
+			"empty target":    {"foo": ""},
+			"chained":         {"foo": "bar", "bar": "baz"},
+			"shared target":   {"foo": "baz", "bar": "baz"},
+			"renamed to self": {"foo": "foo"},
+		}
+		for name, mapping := range testdata {
+			t.Run(name, func(t *testing.T) {
+				e, err := newTestEngine(nil, lib.Options{})
+				require.NoError(t, err)
+				assert.Error(t, e.SetMetricNameMapping(mapping))
+			})
+		}
+	})
+
+	t.Run("Rename", func(t *testing.T) {
+		e, err := newTestEngine(nil, lib.Options{})
+		require.NoError(t, err)
+		c := &dummy.Collector{}
+		e.Collectors = []lib.Collector{c}
+		require.NoError(t, e.SetMetricNameMapping(map[string]string{
+			"my_trend": "renamed_trend",
+			"unknown":  "whatever",
+		}))
+
+		trend := stats.New("my_trend", stats.Trend, stats.Time)
+		other := stats.New("my_counter", stats.Counter)
+		tags := stats.IntoSampleTags(&map[string]string{"a": "1"})
+		now := time.Now()
+		e.processSamples([]stats.SampleContainer{
+			stats.Sample{Metric: trend, Value: 1},
+			stats.ConnectedSamples{
+				Samples: []stats.Sample{{Metric: trend, Value: 2, Tags: tags}, {Metric: other, Value: 3}},
+				Tags:    tags,
+				Time:    now,
+			},
+			stats.Sample{Metric: other, Value: 4},
+		})
+
+		assert.NotContains(t, e.Metrics, "my_trend")
+		require.Contains(t, e.Metrics, "renamed_trend")
+		assert.Equal(t, stats.Trend, e.Metrics["renamed_trend"].Type)
+		assert.Equal(t, stats.Time, e.Metrics["renamed_trend"].Contains)
+		assert.Equal(t, uint64(2), e.Metrics["renamed_trend"].Sink.(*stats.TrendSink).Count)
+		assert.Equal(t, float64(7), e.Metrics["my_counter"].Sink.(*stats.CounterSink).Value)
+		assert.Equal(t, "my_trend", trend.Name)
+
+		require.Len(t, c.Samples, 4)
+		for _, sample := range c.Samples {
+			assert.NotEqual(t, "my_trend", sample.Metric.Name)
+		}
+		assert.Equal(t, "renamed_trend", c.Samples[0].Metric.Name)
+		assert.Equal(t, "renamed_trend", c.Samples[1].Metric.Name)
+		assert.Equal(t, "my_counter", c.Samples[2].Metric.Name)
+		assert.Contains(t, e.renamedMetrics, "my_trend")
+		assert.NotContains(t, e.renamedMetrics, "unknown")
+	})
+
+	t.Run("Thresholds", func(t *testing.T) {
+		thresholds := map[string]stats.Thresholds{
+			"my_trend":      {Thresholds: []*stats.Threshold{{Source: "max<10"}}},
+			"my_trend{a:1}": {Thresholds: []*stats.Threshold{{Source: "max<5"}}},
+			"renamed_trend": {Thresholds: []*stats.Threshold{{Source: "max<1"}}},
+		}
+		e, err := newTestEngine(nil, lib.Options{Thresholds: thresholds})
+		require.NoError(t, err)
+		require.NoError(t, e.SetMetricNameMapping(map[string]string{"my_trend": "renamed_trend"}))
+		require.NoError(t, e.SetMetricPrefix("teamA_"))
+
+		trend := stats.New("my_trend", stats.Trend)
+		tags := stats.IntoSampleTags(&map[string]string{"a": "1"})
+		e.processSamples([]stats.SampleContainer{
+			stats.Sample{Metric: trend, Value: 1, Tags: tags},
+			stats.Sample{Metric: trend, Value: 2},
+		})
+
+		require.Contains(t, e.Metrics, "teamA_renamed_trend")
+		m := e.Metrics["teamA_renamed_trend"]
+		assert.Equal(t, thresholds["my_trend"], m.Thresholds)
+		require.Contains(t, e.Metrics, "teamA_renamed_trend{a:1}")
+		sub := e.Metrics["teamA_renamed_trend{a:1}"]
+		assert.Equal(t, "teamA_renamed_trend", sub.Sub.Parent)
+		assert.Equal(t, thresholds["my_trend{a:1}"], sub.Thresholds)
+		assert.Equal(t, uint64(1), sub.Sink.(*stats.TrendSink).Count)
+	})
+
+	t.Run("Trail", func(t *testing.T) {
+		e, err := newTestEngine(nil, lib.Options{})
+		require.NoError(t, err)
+		c := &dummy.Collector{}
+		e.Collectors = []lib.Collector{c}
+		require.NoError(t, e.SetMetricNameMapping(map[string]string{"http_req_duration": "req_duration"}))
+
+		trail := &httpext.Trail{EndTime: time.Now(), Duration: time.Second}
+		trail.SaveSamples(stats.IntoSampleTags(&map[string]string{"a": "1"}), nil)
+		e.processSamples([]stats.SampleContainer{trail})
+
+		require.Len(t, c.SampleContainers, 1)
+		renamed, ok := c.SampleContainers[0].(*httpext.Trail)
+		require.True(t, ok, "the container should still be an HTTP trail")
+		assert.Equal(t, time.Second, renamed.Duration)
+		assert.Equal(t, trail.Tags, renamed.Tags)
+		assert.Equal(t, "req_duration", renamed.Samples[1].Metric.Name)
+		assert.Equal(t, "http_req_duration", trail.Samples[1].Metric.Name)
+	})
+}
+
+func TestEngine_SetMetricPrefix(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		e, err := newTestEngine(nil, lib.Options{})
+		require.NoError(t, err)
+		assert.Error(t, e.SetMetricPrefix("team{a}_"))
+	})
+
+	t.Run("Prefix", func(t *testing.T) {
+		thresholds := map[string]stats.Thresholds{
+			"my_trend":         {Thresholds: []*stats.Threshold{{Source: "max<10"}}},
+			"my_trend{url:/a}": {Thresholds: []*stats.Threshold{{Source: "max<10"}}},
+		}
+		e, err := newTestEngine(nil, lib.Options{Thresholds: thresholds})
+		require.NoError(t, err)
+		c := &dummy.Collector{}
+		e.Collectors = []lib.Collector{c}
+		require.NoError(t, e.SetMetricPrefix("teamA_"))
+
+		trend := stats.New("my_trend", stats.Trend, stats.Time)
+		tags := stats.IntoSampleTags(&map[string]string{"url": "/a"})
+		e.processSamples([]stats.SampleContainer{
+			stats.Sample{Metric: trend, Value: 1, Tags: tags},
+			stats.Sample{Metric: trend, Value: 2},
+		})
+
+		assert.NotContains(t, e.Metrics, "my_trend")
+		require.Contains(t, e.Metrics, "teamA_my_trend")
+		assert.Equal(t, "teamA_my_trend", e.Metrics["teamA_my_trend"].Name)
+		assert.Equal(t, uint64(2), e.Metrics["teamA_my_trend"].Sink.(*stats.TrendSink).Count)
+		assert.Equal(t, thresholds["my_trend"], e.Metrics["teamA_my_trend"].Thresholds)
+
+		require.Contains(t, e.Metrics, "teamA_my_trend{url:/a}")
+		sub := e.Metrics["teamA_my_trend{url:/a}"]
+		assert.Equal(t, "teamA_my_trend", sub.Sub.Parent)
+		assert.Equal(t, "url:/a", sub.Sub.Suffix)
+		assert.Equal(t, uint64(1), sub.Sink.(*stats.TrendSink).Count)
+		assert.Equal(t, thresholds["my_trend{url:/a}"], sub.Thresholds)
+
+		require.Len(t, c.Samples, 2)
+		for _, sample := range c.Samples {
+			assert.Equal(t, "teamA_my_trend", sample.Metric.Name)
+		}
+		assert.Equal(t, "my_trend", trend.Name)
+
+		assert.Contains(t, e.GetRegisteredMetrics(), "teamA_vus")
+	})
+
+	t.Run("Trails", func(t *testing.T) {
+		e, err := newTestEngine(nil, lib.Options{})
+		require.NoError(t, err)
+		c := &dummy.Collector{}
+		e.Collectors = []lib.Collector{c}
+		require.NoError(t, e.SetMetricPrefix("teamA_"))
+
+		tags := stats.IntoSampleTags(&map[string]string{"a": "1"})
+		httpTrail := &httpext.Trail{EndTime: time.Now(), Duration: time.Second}
+		httpTrail.SaveSamples(tags, nil)
+		dialer := netext.NewDialer(net.Dialer{})
+		dialer.BytesWritten = 10
+		netTrail := dialer.GetTrail(time.Now(), time.Now(), true, tags)
+		e.processSamples([]stats.SampleContainer{httpTrail, netTrail})
+
+		require.Len(t, c.SampleContainers, 2)
+		prefixedHTTPTrail, ok := c.SampleContainers[0].(*httpext.Trail)
+		require.True(t, ok, "the container should still be an HTTP trail")
+		assert.Equal(t, time.Second, prefixedHTTPTrail.Duration)
+		for _, sample := range prefixedHTTPTrail.Samples {
+			assert.True(t, strings.HasPrefix(sample.Metric.Name, "teamA_http_req"), sample.Metric.Name)
+		}
+		prefixedNetTrail, ok := c.SampleContainers[1].(*netext.NetTrail)
+		require.True(t, ok, "the container should still be a network trail")
+		assert.Equal(t, int64(10), prefixedNetTrail.BytesWritten)
+		for _, sample := range prefixedNetTrail.Samples {
+			assert.True(t, strings.HasPrefix(sample.Metric.Name, "teamA_"), sample.Metric.Name)
+		}
+		assert.Equal(t, "http_req_duration", httpTrail.Samples[1].Metric.Name)
+	})
+}
+
+func TestEngineRunEvents(t *testing.T) {
+	t.Run("Run", func(t *testing.T) {
+		e, err := newTestEngine(LF(func(ctx context.Context, out chan<- stats.SampleContainer) error {
+			return nil
+		}), lib.Options{VUs: null.IntFrom(1), VUsMax: null.IntFrom(1), Iterations: null.IntFrom(1)})
+		require.NoError(t, err)
+
+		var events []RunEventType
+		e.AddRunEventHandler(func(event RunEvent) {
+			assert.False(t, event.Time.IsZero())
+			events = append(events, event.Type)
+		})
+		require.NoError(t, e.Run(context.Background()))
+		assert.Equal(t, []RunEventType{
+			RunEventVUsInitialized, RunEventOutputsStarted, RunEventRunStarted,
+			RunEventOutputsFlushed, RunEventRunFinished,
+		}, events)
+	})
+
+	t.Run("Thresholds", func(t *testing.T) {
+		ths, err := stats.NewThresholds([]string{"1+1==3"})
+		require.NoError(t, err)
+		ths.Thresholds[0].AbortOnFail = true
+		e, err := newTestEngine(nil, lib.Options{Thresholds: map[string]stats.Thresholds{"my_metric": ths}})
+		require.NoError(t, err)
+
+		var events []RunEvent
+		e.AddRunEventHandler(func(event RunEvent) {
+			// The metrics shouldn't be locked while the events are handled
+			_ = e.GetMetricsSnapshot()
+			events = append(events, event)
+		})
+		e.processSamples([]stats.SampleContainer{stats.Sample{Metric: stats.New("my_metric", stats.Gauge), Value: 1}})
+		e.processThresholds(func() {})
+		e.processThresholds(nil)
+
+		require.Len(t, events, 2)
+		assert.Equal(t, RunEventThresholdBreached, events[0].Type)
+		assert.Equal(t, "my_metric", events[0].Metric)
+		assert.Equal(t, RunEventAborting, events[1].Type)
+		assert.Equal(t, lib.RunStatusAbortedThreshold, events[1].AbortReason)
+	})
+}
+
+func TestEngineWaitOutputsFlushed(t *testing.T) {
+	e, err := newTestEngine(LF(func(ctx context.Context, out chan<- stats.SampleContainer) error {
+		return nil
+	}), lib.Options{VUs: null.IntFrom(1), VUsMax: null.IntFrom(1), Iterations: null.IntFrom(1)})
+	require.NoError(t, err)
+	e.Collectors = []lib.Collector{&dummy.Collector{}}
+	flushedEvent := false
+	e.AddRunEventHandler(func(event RunEvent) {
+		if event.Type == RunEventOutputsFlushed {
+			flushedEvent = true
+			assert.True(t, e.OutputsFlushed())
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, e.WaitOutputsFlushed(ctx))
+	assert.False(t, e.OutputsFlushed())
+
+	require.NoError(t, e.Run(context.Background()))
+	assert.True(t, flushedEvent)
+	assert.NoError(t, e.WaitOutputsFlushed(context.Background()))
+	assert.True(t, e.OutputsFlushed())
+	assert.Empty(t, e.FailedOutputs())
+
+	t.Run("DeliveryFailure", func(t *testing.T) {
+		e, err := newTestEngine(LF(func(ctx context.Context, out chan<- stats.SampleContainer) error {
+			return nil
+		}), lib.Options{VUs: null.IntFrom(1), VUsMax: null.IntFrom(1), Iterations: null.IntFrom(1)})
+		require.NoError(t, err)
+		good, bad := &failingCollector{}, &failingCollector{}
+		bad.SetDeliveryFailure(errors.New("write failed"))
+		e.Collectors = []lib.Collector{good, bad}
+		e.FailingCollectors = map[string]lib.FailingCollector{"kafka": bad, "influxdb": good}
+		var outputs []OutputFlush
+		e.AddRunEventHandler(func(event RunEvent) {
+			if event.Type == RunEventOutputsFlushed {
+				outputs = event.Outputs
+			}
+		})
+
+		require.NoError(t, e.Run(context.Background()))
+		assert.Equal(t, []OutputFlush{
+			{Output: "influxdb"},
+			{Output: "kafka", Err: errors.New("write failed")},
+		}, outputs)
+		assert.True(t, e.OutputsFlushed())
+		assert.Equal(t, []string{"kafka"}, e.FailedOutputs())
+		assert.EqualError(t, e.WaitOutputsFlushed(context.Background()),
+			"some outputs failed to deliver their samples: kafka")
+	})
+}
+
+type failingCollector struct {
+	dummy.Collector
+	lib.CollectorFailures
+}
+
+type readyCollector struct {
+	dummy.Collector
+	ready chan struct{}
+}
+
+func (c *readyCollector) WaitReady(ctx context.Context) error {
+	select {
+	case <-c.ready:
+		return nil
+	case <-ctx.Done():
+		return errors.New("not ready")
+	}
+}
+
+func TestEngineWaitOutputsReady(t *testing.T) {
+	t.Run("ready", func(t *testing.T) {
+		collector := &readyCollector{ready: make(chan struct{})}
+		var iterations int64
+		e, err := newTestEngine(LF(func(ctx context.Context, out chan<- stats.SampleContainer) error {
+			atomic.AddInt64(&iterations, 1)
+			return nil
+		}), lib.Options{VUs: null.IntFrom(1), VUsMax: null.IntFrom(1), Iterations: null.IntFrom(1)})
+		require.NoError(t, err)
+		e.Collectors = []lib.Collector{collector}
+		e.ReadyCollectors = []lib.ReadyCollector{collector}
+
+		errC := make(chan error)
+		go func() { errC <- e.Run(context.Background()) }()
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, int64(0), atomic.LoadInt64(&iterations))
+		close(collector.ready)
+		select {
+		case err := <-errC:
+			require.NoError(t, err)
+		case <-time.After(10 * time.Second):
+			t.Fatal("Test timed out")
+		}
+		assert.Equal(t, int64(1), atomic.LoadInt64(&iterations))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		collector := &readyCollector{ready: make(chan struct{})}
+		e, err := newTestEngine(LF(func(ctx context.Context, out chan<- stats.SampleContainer) error {
+			t.Error("the executor shouldn't be started")
+			return nil
+		}), lib.Options{VUs: null.IntFrom(1), VUsMax: null.IntFrom(1), Iterations: null.IntFrom(1)})
+		require.NoError(t, err)
+		e.Collectors = []lib.Collector{collector}
+		e.ReadyCollectors = []lib.ReadyCollector{collector}
+		e.OutputsReadyTimeout = 20 * time.Millisecond
+
+		assert.EqualError(t, e.Run(context.Background()), "the outputs weren't ready: not ready")
+		assert.Equal(t, lib.RunStatusAbortedSystem, collector.RunStatus)
+		assert.True(t, e.OutputsFlushed())
+	})
+}
+
+func TestEngine_GetMetricsSnapshot(t *testing.T) {
+	e, err := newTestEngine(nil, lib.Options{})
+	require.NoError(t, err)
+
+	metric := stats.New("my_trend", stats.Trend)
+	e.processSamples([]stats.SampleContainer{stats.Samples{
+		{Metric: metric, Value: 1}, {Metric: metric, Value: 2},
+	}})
+
+	snapshot := e.GetMetricsSnapshot()
+	require.Contains(t, snapshot, "my_trend")
+	assert.Equal(t, uint64(2), snapshot["my_trend"].Sink.(*stats.TrendSink).Count)
+
+	e.processSamples([]stats.SampleContainer{stats.Sample{Metric: metric, Value: 3}})
+	assert.Equal(t, uint64(2), snapshot["my_trend"].Sink.(*stats.TrendSink).Count)
+	assert.Len(t, snapshot["my_trend"].Sink.(*stats.TrendSink).Values, 2)
+	assert.Equal(t, uint64(3), e.Metrics["my_trend"].Sink.(*stats.TrendSink).Count)
+	assert.False(t, e.Metrics["my_trend"] == snapshot["my_trend"])
+
+	t.Run("names", func(t *testing.T) {
+		e.processSamples([]stats.SampleContainer{stats.Sample{Metric: stats.New("my_counter", stats.Counter), Value: 1}})
+		snapshot := e.GetMetricsSnapshot("my_counter", "missing")
+		assert.Len(t, snapshot, 1)
+		require.Contains(t, snapshot, "my_counter")
+		assert.False(t, e.Metrics["my_counter"] == snapshot["my_counter"])
+	})
+}
+
 func TestEngine_runThresholds(t *testing.T) {
 	metric := stats.New("my_metric", stats.Gauge)
 	thresholds := make(map[string]stats.Thresholds, 1)
@@ -483,6 +914,67 @@ func TestEngine_processThresholds(t *testing.T) {
 	}
 }
 
+func TestEngineThresholdResults(t *testing.T) {
+	metric := stats.New("my_metric", stats.Gauge)
+	ths, err := stats.NewThresholds([]string{"value<2", "value>1"})
+	require.NoError(t, err)
+
+	e, err := newTestEngine(nil, lib.Options{Thresholds: map[string]stats.Thresholds{"my_metric": ths}})
+	require.NoError(t, err)
+	var results [][]stats.ThresholdResult
+	e.AddThresholdResultsHandler(func(r []stats.ThresholdResult) {
+		// The metrics shouldn't be locked while the results are handled
+		_ = e.GetMetricsSnapshot()
+		results = append(results, r)
+	})
+
+	for _, value := range []float64{1.5, 2.5} {
+		e.processSamples([]stats.SampleContainer{stats.Sample{Metric: metric, Value: value}})
+		e.processThresholds(nil)
+	}
+
+	require.Len(t, results, 2)
+	for i, passed := range []bool{true, false} {
+		value := []float64{1.5, 2.5}[i]
+		require.Len(t, results[i], 2)
+		assert.False(t, results[i][0].Time.IsZero())
+		assert.Equal(t, "my_metric", results[i][0].Metric)
+		assert.Equal(t, "value<2", results[i][0].Threshold)
+		assert.Equal(t, null.FloatFrom(value), results[i][0].Value)
+		assert.Equal(t, passed, results[i][0].Passed)
+		assert.Equal(t, "value>1", results[i][1].Threshold)
+		assert.True(t, results[i][1].Passed)
+	}
+}
+
+func TestEngine_ReplaySamples(t *testing.T) {
+	metric := stats.New("my_counter", stats.Counter)
+	ths, err := stats.NewThresholds([]string{"rate>=1"})
+	require.NoError(t, err)
+
+	testdata := map[string]struct {
+		duration time.Duration
+		pass     bool
+	}{
+		"passing": {5 * time.Second, true},
+		"failing": {20 * time.Second, false},
+	}
+	for name, data := range testdata {
+		t.Run(name, func(t *testing.T) {
+			e, err := newTestEngine(nil, lib.Options{Thresholds: map[string]stats.Thresholds{"my_counter": ths}})
+			require.NoError(t, err)
+
+			e.ReplaySamples([]stats.SampleContainer{stats.Samples{
+				{Metric: metric, Value: 5}, {Metric: metric, Value: 5},
+			}}, data.duration)
+
+			require.Contains(t, e.Metrics, "my_counter")
+			assert.Equal(t, float64(10), e.Metrics["my_counter"].Sink.(*stats.CounterSink).Value)
+			assert.Equal(t, data.pass, !e.IsTainted())
+		})
+	}
+}
+
 func getMetricSum(collector *dummy.Collector, name string) (result float64) {
 	for _, sc := range collector.SampleContainers {
 		for _, s := range sc.GetSamples() {
@@ -967,3 +1459,526 @@ func TestMinIterationDuration(t *testing.T) {
 	// But we expect the custom counter to be added to 4 times
 	assert.Equal(t, 4.0, getMetricSum(collector, "testcounter"))
 }
+
+func TestEngine_SetSampleTimeClamping(t *testing.T) {
+	e, err := newTestEngine(nil, lib.Options{})
+	require.NoError(t, err)
+	logger, hook := logtest.NewNullLogger()
+	e.SetLogger(logger)
+	c := &dummy.Collector{}
+	e.Collectors = []lib.Collector{c}
+
+	now := time.Unix(1000, 0)
+	e.SetSampleTimeClamping(time.Second)
+	e.timeClamper.now = func() time.Time { return now }
+
+	metric := stats.New("my_gauge", stats.Gauge)
+	tagsA := stats.IntoSampleTags(&map[string]string{"a": "1"})
+	tagsB := stats.IntoSampleTags(&map[string]string{"a": "2"})
+	original := stats.Samples{
+		{Metric: metric, Tags: tagsA, Time: now.Add(-2 * time.Second), Value: 1},
+		{Metric: metric, Tags: tagsA, Time: now.Add(-3 * time.Second), Value: 2}, // out of order
+		{Metric: metric, Tags: tagsB, Time: now.Add(500 * time.Millisecond), Value: 3},
+		{Metric: metric, Tags: tagsB, Time: now.Add(time.Hour), Value: 4}, // too far in the future
+	}
+	trail := &httpext.Trail{EndTime: now.Add(time.Hour)}
+	trail.SaveSamples(tagsA, nil)
+	e.processSamples([]stats.SampleContainer{original, trail})
+
+	require.Len(t, c.Samples, 4+len(trail.Samples))
+	times := []time.Time{}
+	for _, sample := range c.Samples[:4] {
+		times = append(times, sample.Time)
+	}
+	assert.Equal(t, []time.Time{
+		now.Add(-2 * time.Second),
+		now.Add(-3 * time.Second),
+		now.Add(500 * time.Millisecond),
+		now.Add(time.Second),
+	}, times)
+	assert.Equal(t, now.Add(time.Hour), original[3].Time, "the original samples shouldn't be modified")
+
+	require.Len(t, c.SampleContainers, 2)
+	clampedTrail, ok := c.SampleContainers[1].(*httpext.Trail)
+	require.True(t, ok, "the container should still be an HTTP trail")
+	assert.Equal(t, now.Add(time.Second), clampedTrail.EndTime)
+	for _, sample := range clampedTrail.Samples {
+		assert.Equal(t, now.Add(time.Second), sample.Time)
+	}
+	assert.Equal(t, now.Add(time.Hour), trail.EndTime, "the original trail shouldn't be modified")
+
+	// Only the first clamping is logged right away, the rest are summarized at the end.
+	require.Len(t, hook.AllEntries(), 1)
+	e.logClampedSampleTimes()
+	require.Len(t, hook.AllEntries(), 2)
+	assert.Equal(t, int64(1+len(trail.Samples)), hook.LastEntry().Data["samples"])
+}
+
+func TestEngine_SetWarmup(t *testing.T) {
+	e, err := newTestEngine(nil, lib.Options{})
+	require.NoError(t, err)
+	c := &dummy.Collector{}
+	e.Collectors = []lib.Collector{c}
+
+	start := time.Unix(1000, 0)
+	e.SetWarmup(10 * time.Second)
+	e.startTime = start
+
+	metric := stats.New("my_trend", stats.Trend)
+	tags := stats.IntoSampleTags(&map[string]string{"a": "1"})
+	original := stats.Samples{
+		{Metric: metric, Tags: tags, Time: start.Add(time.Second), Value: 1000},
+		{Metric: metric, Tags: tags, Time: start.Add(11 * time.Second), Value: 1},
+		{Metric: metric, Tags: tags, Time: start.Add(9 * time.Second), Value: 2000},
+	}
+	later := stats.Samples{{Metric: metric, Tags: tags, Time: start.Add(12 * time.Second), Value: 2}}
+	e.processSamples([]stats.SampleContainer{original, later})
+
+	sink := e.Metrics["my_trend"].Sink.(*stats.TrendSink)
+	assert.Equal(t, uint64(2), sink.Count)
+	assert.Equal(t, 2.0, sink.Max)
+
+	require.Len(t, c.Samples, 4)
+	warmup := []string{}
+	for _, sample := range c.Samples {
+		value, _ := sample.Tags.Get("warmup")
+		warmup = append(warmup, value)
+		assert.Equal(t, "1", sample.Tags.CloneTags()["a"])
+	}
+	assert.Equal(t, []string{"true", "", "true", ""}, warmup)
+	assert.Equal(t, tags, original[0].Tags, "the original samples shouldn't be modified")
+
+	t.Run("Trail", func(t *testing.T) {
+		trail := &httpext.Trail{EndTime: start.Add(time.Second), Tags: tags, Samples: []stats.Sample{
+			{Metric: metric, Tags: tags, Time: start.Add(time.Second), Value: 1000},
+			{Metric: metric, Tags: tags, Time: start.Add(11 * time.Second), Value: 1},
+		}}
+		forMetrics, forCollectors := e.splitWarmupSamples([]stats.SampleContainer{trail})
+		require.Len(t, forMetrics, 1)
+		metricsTrail, ok := forMetrics[0].(*httpext.Trail)
+		require.True(t, ok)
+		assert.Len(t, metricsTrail.Samples, 1)
+		collectorsTrail, ok := forCollectors[0].(*httpext.Trail)
+		require.True(t, ok)
+		assert.Equal(t, "true", collectorsTrail.Tags.CloneTags()["warmup"])
+		assert.Equal(t, tags, trail.Tags)
+	})
+}
+
+func TestEngine_SetIdleAbort(t *testing.T) {
+	e, err := newTestEngine(nil, lib.Options{})
+	require.NoError(t, err)
+	assert.NoError(t, e.checkIdle(time.Hour))
+
+	e.SetIdleAbort("iterations", 10*time.Second)
+	// The clock of the executor doesn't run before the iterations, e.g. during the setup.
+	assert.Equal(t, time.Duration(0), e.idleClock())
+	assert.NoError(t, e.checkIdle(0))
+	assert.NoError(t, e.checkIdle(5*time.Second))
+	assert.EqualError(t, e.checkIdle(11*time.Second),
+		"no samples of the metric 'iterations' arrived for 10s, aborting the test")
+
+	other := stats.New("http_reqs", stats.Counter)
+	e.trackIdleMetric([]stats.SampleContainer{stats.Sample{Metric: other, Value: 1}}, 8*time.Second)
+	assert.Error(t, e.checkIdle(11*time.Second))
+
+	e.trackIdleMetric([]stats.SampleContainer{stats.Samples{
+		{Metric: other, Value: 1},
+		{Metric: metrics.Iterations, Value: 1},
+	}}, 8*time.Second)
+	assert.NoError(t, e.checkIdle(11*time.Second))
+	assert.Equal(t, IdleAbortError{Metric: "iterations", Timeout: 10 * time.Second},
+		e.checkIdle(19*time.Second))
+}
+
+func TestEngineRunIdleAbort(t *testing.T) {
+	e, err := newTestEngine(LF(func(ctx context.Context, out chan<- stats.SampleContainer) error {
+		<-ctx.Done()
+		return nil
+	}), lib.Options{VUs: null.IntFrom(1), VUsMax: null.IntFrom(1), Duration: types.NullDurationFrom(10 * time.Second)})
+	require.NoError(t, err)
+	e.SetIdleAbort("iterations", 200*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = e.Run(ctx)
+	assert.Equal(t, IdleAbortError{Metric: "iterations", Timeout: 200 * time.Millisecond}, err)
+	assert.NoError(t, ctx.Err())
+
+	t.Run("Paused", func(t *testing.T) {
+		e, err := newTestEngine(LF(func(ctx context.Context, out chan<- stats.SampleContainer) error {
+			<-ctx.Done()
+			return nil
+		}), lib.Options{VUs: null.IntFrom(1), VUsMax: null.IntFrom(1), Duration: types.NullDurationFrom(10 * time.Second)})
+		require.NoError(t, err)
+		e.SetIdleAbort("iterations", 200*time.Millisecond)
+		e.Executor.SetPaused(true)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		assert.NoError(t, e.Run(ctx))
+		assert.Error(t, ctx.Err())
+	})
+}
+
+func TestEngineMetricsCheckpoint(t *testing.T) {
+	thresholds := map[string]stats.Thresholds{}
+	for name, srcs := range map[string][]string{
+		"my_trend":         {"p(50)<=2"},
+		"my_trend{tag:a}":  {"max<2"},
+		"my_rate":          {"rate>0.5"},
+		"my_counter_other": {"count<1"},
+	} {
+		ths, err := stats.NewThresholds(srcs)
+		require.NoError(t, err)
+		thresholds[name] = ths
+	}
+
+	e, err := newTestEngine(nil, lib.Options{})
+	require.NoError(t, err)
+	fs := afero.NewMemMapFs()
+	e.SetCheckpoints(fs, "/tmp/checkpoint.json", time.Second)
+
+	trend := stats.New("my_trend", stats.Trend, stats.Time)
+	counter := stats.New("my_counter", stats.Counter)
+	gauge := stats.New("my_gauge", stats.Gauge)
+	rate := stats.New("my_rate", stats.Rate)
+	tags := stats.IntoSampleTags(&map[string]string{"tag": "a"})
+	e.processSamples([]stats.SampleContainer{stats.Samples{
+		{Metric: trend, Value: 3, Tags: tags},
+		{Metric: trend, Value: 1},
+		{Metric: trend, Value: 2},
+		{Metric: counter, Value: 5},
+		{Metric: gauge, Value: 7},
+		{Metric: gauge, Value: 4},
+		{Metric: rate, Value: 1},
+		{Metric: rate, Value: 0},
+	}})
+	require.NoError(t, e.writeCheckpoint())
+
+	f, err := fs.Open("/tmp/checkpoint.json")
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+	checkpoint, err := ReadMetricsCheckpoint(f)
+	require.NoError(t, err)
+	require.Len(t, checkpoint.Metrics, 4)
+	assert.Equal(t, "my_counter", checkpoint.Metrics[0].Name)
+
+	restored, err := newTestEngine(nil, lib.Options{Thresholds: thresholds})
+	require.NoError(t, err)
+	require.NoError(t, restored.RestoreMetricsCheckpoint(checkpoint))
+
+	for name, m := range e.Metrics {
+		require.Contains(t, restored.Metrics, name)
+		assert.Equal(t, m.Sink.Format(time.Second), restored.Metrics[name].Sink.Format(time.Second), name)
+	}
+	restored.Metrics["my_gauge"].Sink.Add(stats.Sample{Value: 5})
+	assert.Equal(t, 4.0, restored.Metrics["my_gauge"].Sink.(*stats.GaugeSink).Min)
+
+	assert.True(t, restored.IsTainted())
+	assert.False(t, restored.Metrics["my_trend"].Tainted.Bool)
+	assert.True(t, restored.Metrics["my_rate"].Tainted.Bool)
+	assert.NotContains(t, restored.Metrics, "my_counter_other")
+}
+
+func TestEngine_ThresholdAbortMessages(t *testing.T) {
+	e, err := newTestEngine(nil, lib.Options{})
+	require.NoError(t, err)
+	assert.Empty(t, e.ThresholdAbortMessages())
+
+	thresholds, err := stats.NewThresholds([]string{"rate<0.01", "rate<0.5"})
+	require.NoError(t, err)
+	thresholds.Thresholds[0].Message = "error rate too high"
+	thresholds.Thresholds[1].Message = "didn't abort"
+	failed := stats.New("http_req_failed", stats.Rate)
+	failed.Thresholds = thresholds
+	e.Metrics["http_req_failed"] = failed
+	assert.Empty(t, e.ThresholdAbortMessages())
+
+	thresholds.Thresholds[0].AbortedTest = true
+	assert.Equal(t, []string{"http_req_failed: error rate too high"}, e.ThresholdAbortMessages())
+}
+
+type flushingCollector struct {
+	dummy.Collector
+	err     error
+	flushes int32
+}
+
+func (c *flushingCollector) Flush() error {
+	atomic.AddInt32(&c.flushes, 1)
+	return c.err
+}
+
+func TestEngine_FlushOutputs(t *testing.T) {
+	e, err := newTestEngine(nil, lib.Options{})
+	require.NoError(t, err)
+	assert.Empty(t, e.FlushOutputs())
+
+	good := &flushingCollector{}
+	bad := &flushingCollector{err: errors.New("unreachable")}
+	e.SetFlushCollectors(map[string]lib.FlushingCollector{"kafka": bad, "influxdb": good})
+
+	assert.Equal(t, []OutputFlush{
+		{Output: "influxdb"},
+		{Output: "kafka", Err: bad.err},
+	}, e.FlushOutputs())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&good.flushes))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&bad.flushes))
+}
+
+func TestEngineGetRegisteredMetrics(t *testing.T) {
+	script := []byte(`
+		import { Counter, Trend } from "k6/metrics";
+		let myCounter = new Counter("my_counter");
+		let myTrend = new Trend("my_trend", true);
+		export default function () {};
+	`)
+	runner, err := js.New(
+		&loader.SourceData{URL: &url.URL{Path: "/script.js"}, Data: script},
+		nil,
+		lib.RuntimeOptions{},
+	)
+	require.NoError(t, err)
+
+	e, err := newTestEngine(local.New(runner), lib.Options{
+		DisabledMetrics: lib.GetTagSet("http_req_blocked", "my_counter"),
+	})
+	require.NoError(t, err)
+	require.NoError(t, e.SetMetricNameMapping(map[string]string{"checks": "my_checks"}))
+	e.Metrics["iterations"] = stats.New("iterations", stats.Counter)
+	e.Metrics["iterations"].Sink.Add(stats.Sample{Value: 3})
+
+	registered := e.GetRegisteredMetrics()
+	for _, name := range []string{"vus", "http_req_duration", "data_sent", "my_checks", "my_trend"} {
+		if assert.Contains(t, registered, name) {
+			assert.Equal(t, name, registered[name].Name)
+		}
+	}
+	assert.NotContains(t, registered, "checks")
+	assert.NotContains(t, registered, "http_req_blocked")
+	assert.NotContains(t, registered, "my_counter")
+	assert.Equal(t, stats.Trend, registered["my_trend"].Type)
+	assert.Equal(t, stats.Time, registered["my_trend"].Contains)
+	assert.Equal(t, stats.Trend, registered["http_req_duration"].Type)
+	assert.Equal(t, float64(3), registered["iterations"].Sink.(*stats.CounterSink).Value)
+	assert.Equal(t, float64(0), registered["data_sent"].Sink.(*stats.CounterSink).Value)
+}
+
+func TestEngineTagStages(t *testing.T) {
+	metric := stats.New("my_metric", stats.Counter)
+	tags := stats.IntoSampleTags(&map[string]string{"a": "1"})
+	staged := stats.IntoSampleTags(&map[string]string{"stage": "7"})
+	newContainers := func() []stats.SampleContainer {
+		return []stats.SampleContainer{
+			stats.Sample{Metric: metric, Tags: tags, Value: 1},
+			stats.Sample{Metric: metric, Tags: staged, Value: 1},
+			stats.ConnectedSamples{Samples: []stats.Sample{{Metric: metric, Value: 1}}, Tags: tags},
+		}
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		e, err := newTestEngine(nil, lib.Options{
+			Stages: []lib.Stage{{Duration: types.NullDurationFrom(10 * time.Second)}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, newContainers(), e.tagStages(newContainers()))
+	})
+	t.Run("NoStages", func(t *testing.T) {
+		e, err := newTestEngine(nil, lib.Options{SystemTags: lib.GetTagSet("stage")})
+		require.NoError(t, err)
+		assert.Equal(t, newContainers(), e.tagStages(newContainers()))
+	})
+	t.Run("Enabled", func(t *testing.T) {
+		e, err := newTestEngine(nil, lib.Options{
+			Stages:     []lib.Stage{{Duration: types.NullDurationFrom(10 * time.Second)}, {}},
+			SystemTags: lib.GetTagSet("stage"),
+		})
+		require.NoError(t, err)
+		containers := e.tagStages(newContainers())
+		require.Len(t, containers, 3)
+		assert.Equal(t, map[string]string{"a": "1", "stage": "0"}, containers[0].GetSamples()[0].Tags.CloneTags())
+		assert.Equal(t, map[string]string{"stage": "7"}, containers[1].GetSamples()[0].Tags.CloneTags())
+		connected, ok := containers[2].(stats.ConnectedSamples)
+		require.True(t, ok)
+		assert.Equal(t, map[string]string{"a": "1", "stage": "0"}, connected.Tags.CloneTags())
+		assert.Equal(t, map[string]string{"stage": "0"}, connected.Samples[0].Tags.CloneTags())
+	})
+	t.Run("Trail", func(t *testing.T) {
+		e, err := newTestEngine(nil, lib.Options{
+			Stages:     []lib.Stage{{Duration: types.NullDurationFrom(10 * time.Second)}, {}},
+			SystemTags: lib.GetTagSet("stage"),
+		})
+		require.NoError(t, err)
+		trail := &httpext.Trail{EndTime: time.Now(), Duration: time.Second}
+		trail.SaveSamples(tags, nil)
+		containers := e.tagStages([]stats.SampleContainer{trail})
+		require.Len(t, containers, 1)
+		tagged, ok := containers[0].(*httpext.Trail)
+		require.True(t, ok, "the container should still be an HTTP trail")
+		assert.Equal(t, time.Second, tagged.Duration)
+		assert.Equal(t, map[string]string{"a": "1", "stage": "0"}, tagged.Tags.CloneTags())
+		for _, sample := range tagged.Samples {
+			assert.Equal(t, tagged.Tags, sample.Tags)
+		}
+		assert.Equal(t, map[string]string{"a": "1"}, trail.Tags.CloneTags())
+	})
+	t.Run("SampleTimes", func(t *testing.T) {
+		e, err := newTestEngine(nil, lib.Options{
+			Stages: []lib.Stage{
+				{Duration: types.NullDurationFrom(10 * time.Second)},
+				{Duration: types.NullDurationFrom(10 * time.Second)},
+			},
+			SystemTags: lib.GetTagSet("stage"),
+		})
+		require.NoError(t, err)
+		// The executor hasn't started iterating, so its time is 0 now.
+		now := time.Now()
+		containers := e.tagStages([]stats.SampleContainer{stats.Samples{
+			{Metric: metric, Time: now.Add(-time.Minute), Value: 1},
+			{Metric: metric, Time: now.Add(5 * time.Second), Value: 1},
+			{Metric: metric, Time: now.Add(15 * time.Second), Value: 1},
+			{Metric: metric, Time: now.Add(time.Minute), Value: 1},
+		}})
+		require.Len(t, containers, 1)
+		stages := []string{}
+		for _, sample := range containers[0].GetSamples() {
+			stage, _ := sample.Tags.Get("stage")
+			stages = append(stages, stage)
+		}
+		assert.Equal(t, []string{"0", "0", "1", ""}, stages)
+	})
+}
+
+func TestEngineDropDisabledMetrics(t *testing.T) {
+	e, err := newTestEngine(nil, lib.Options{DisabledMetrics: lib.GetTagSet("data_sent", "iterations")})
+	require.NoError(t, err)
+
+	tags := stats.IntoSampleTags(&map[string]string{"a": "1"})
+	containers := e.dropDisabledMetrics([]stats.SampleContainer{
+		stats.Sample{Metric: metrics.Iterations, Value: 1},
+		stats.Sample{Metric: metrics.VUs, Value: 1},
+		stats.ConnectedSamples{Samples: []stats.Sample{
+			{Metric: metrics.DataSent, Value: 10},
+			{Metric: metrics.DataReceived, Value: 20},
+			{Metric: metrics.IterationDuration, Value: 30},
+		}, Tags: tags},
+		stats.Samples{{Metric: metrics.DataSent, Value: 10}},
+	})
+	require.Len(t, containers, 2)
+	assert.Equal(t, stats.Sample{Metric: metrics.VUs, Value: 1}, containers[0])
+	assert.Equal(t, stats.ConnectedSamples{Samples: []stats.Sample{
+		{Metric: metrics.DataReceived, Value: 20},
+		{Metric: metrics.IterationDuration, Value: 30},
+	}, Tags: tags}, containers[1])
+
+	dialer := netext.NewDialer(net.Dialer{})
+	dialer.BytesWritten = 10
+	netTrail := dialer.GetTrail(time.Now(), time.Now(), true, tags)
+	containers = e.dropDisabledMetrics([]stats.SampleContainer{netTrail})
+	require.Len(t, containers, 1)
+	kept, ok := containers[0].(*netext.NetTrail)
+	require.True(t, ok, "the container should still be a network trail")
+	assert.Equal(t, int64(10), kept.BytesWritten)
+	require.Len(t, kept.Samples, 2)
+	assert.Equal(t, metrics.DataReceived, kept.Samples[0].Metric)
+	assert.Equal(t, metrics.IterationDuration, kept.Samples[1].Metric)
+	assert.Len(t, netTrail.Samples, 3)
+
+	e.processSamples([]stats.SampleContainer{stats.Sample{Metric: metrics.Iterations, Value: 1}})
+	assert.NotContains(t, e.Metrics, "iterations")
+}
+
+func TestEngineMetricsCheckpointPrefixed(t *testing.T) {
+	thresholds := map[string]stats.Thresholds{}
+	for name, srcs := range map[string][]string{
+		"my_trend":        {"p(50)<=2"},
+		"my_trend{tag:a}": {"max<2"},
+	} {
+		ths, err := stats.NewThresholds(srcs)
+		require.NoError(t, err)
+		thresholds[name] = ths
+	}
+
+	e, err := newTestEngine(nil, lib.Options{Thresholds: thresholds})
+	require.NoError(t, err)
+	require.NoError(t, e.SetMetricPrefix("team_"))
+	trend := stats.New("my_trend", stats.Trend, stats.Time)
+	tags := stats.IntoSampleTags(&map[string]string{"tag": "a"})
+	e.processSamples([]stats.SampleContainer{stats.Samples{
+		{Metric: trend, Value: 3, Tags: tags},
+		{Metric: trend, Value: 1},
+	}})
+	checkpoint, err := e.GetMetricsCheckpoint()
+	require.NoError(t, err)
+	assert.Equal(t, "team_", checkpoint.MetricPrefix)
+
+	restored, err := newTestEngine(nil, lib.Options{Thresholds: thresholds})
+	require.NoError(t, err)
+	require.NoError(t, restored.RestoreMetricsCheckpoint(checkpoint))
+	require.Contains(t, restored.Metrics, "team_my_trend")
+	require.Contains(t, restored.Metrics, "team_my_trend{tag:a}")
+	assert.Len(t, restored.Metrics["team_my_trend"].Thresholds.Thresholds, 1)
+	assert.Equal(t, "team_my_trend", restored.Metrics["team_my_trend{tag:a}"].Sub.Parent)
+	assert.False(t, restored.Metrics["team_my_trend"].Tainted.Bool)
+	assert.True(t, restored.Metrics["team_my_trend{tag:a}"].Tainted.Bool)
+}
+
+func TestEngineMetricsCheckpointBounded(t *testing.T) {
+	e, err := newTestEngine(nil, lib.Options{})
+	require.NoError(t, err)
+	trend := stats.New("my_trend", stats.Trend, stats.Time)
+	samples := make(stats.Samples, 0, 2*checkpointTrendValues)
+	for i := 0; i < 2*checkpointTrendValues; i++ {
+		samples = append(samples, stats.Sample{Metric: trend, Value: float64(i%1000) + 0.5})
+	}
+	e.processSamples([]stats.SampleContainer{samples})
+
+	checkpoint, err := e.GetMetricsCheckpoint()
+	require.NoError(t, err)
+	data, err := json.Marshal(checkpoint)
+	require.NoError(t, err)
+	assert.True(t, len(data) < 10000, "the checkpoint has %d bytes", len(data))
+
+	restored, err := newTestEngine(nil, lib.Options{})
+	require.NoError(t, err)
+	require.NoError(t, restored.RestoreMetricsCheckpoint(checkpoint))
+	sink := restored.Metrics["my_trend"].Sink.(*stats.TrendSink)
+	assert.Equal(t, uint64(2*checkpointTrendValues), sink.Count)
+	assert.InDelta(t, checkpointTrendValues, len(sink.Values), 1000)
+	expected := e.Metrics["my_trend"].Sink.Format(time.Second)
+	for key, value := range sink.Format(time.Second) {
+		assert.InEpsilon(t, expected[key], value, 0.01, key)
+	}
+}
+
+type blockingCollector struct {
+	dummy.Collector
+	collecting chan struct{}
+	release    chan struct{}
+}
+
+func (c *blockingCollector) Collect(sampleContainers []stats.SampleContainer) {
+	close(c.collecting)
+	<-c.release
+}
+
+func TestEngineCollectWithoutMetricsLock(t *testing.T) {
+	e, err := newTestEngine(nil, lib.Options{})
+	require.NoError(t, err)
+	collector := &blockingCollector{collecting: make(chan struct{}), release: make(chan struct{})}
+	e.Collectors = []lib.Collector{collector}
+
+	done := make(chan struct{})
+	go func() {
+		e.processSamples([]stats.SampleContainer{stats.Sample{Metric: stats.New("my_metric", stats.Counter), Value: 1}})
+		close(done)
+	}()
+	<-collector.collecting
+
+	// The metrics can be read while the collector blocks.
+	snapshot := e.GetMetricsSnapshot()
+	assert.Contains(t, snapshot, "my_metric")
+	close(collector.release)
+	<-done
+}
@@ -0,0 +1,75 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+)
+
+// RunEventType is the type of a test run lifecycle event.
+type RunEventType string
+
+// The test run lifecycle events, emitted roughly in this order.
+const (
+	RunEventVUsInitialized    RunEventType = "vus-initialized"
+	RunEventOutputsStarted    RunEventType = "outputs-started"
+	RunEventRunStarted        RunEventType = "run-started"
+	RunEventThresholdBreached RunEventType = "threshold-breached"
+	RunEventAborting          RunEventType = "aborting"
+	RunEventOutputsFlushed    RunEventType = "outputs-flushed"
+	RunEventRunFinished       RunEventType = "run-finished"
+)
+
+// RunEvent is a test run lifecycle event, which lets embedders react to changes in the
+// state of the run without having to infer them from the logs.
+type RunEvent struct {
+	Type RunEventType
+	Time time.Time
+
+	// The status the run is being aborted with, only set for RunEventAborting.
+	AbortReason lib.RunStatus
+	// The name of the metric with breached thresholds, only set for RunEventThresholdBreached.
+	Metric string
+	// Whether each of the engine's FailingCollectors delivered its samples, only set for
+	// RunEventOutputsFlushed.
+	Outputs []OutputFlush
+}
+
+// RunEventHandler receives the run events. It's called synchronously from the engine,
+// so it shouldn't block.
+type RunEventHandler func(RunEvent)
+
+// AddRunEventHandler registers a handler for the run events. It has to be called before
+// the engine is started.
+func (e *Engine) AddRunEventHandler(handler RunEventHandler) {
+	e.runEventHandlers = append(e.runEventHandlers, handler)
+}
+
+func (e *Engine) emitRunEvent(event RunEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	for _, handler := range e.runEventHandlers {
+		handler(event)
+	}
+}
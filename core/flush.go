@@ -0,0 +1,81 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package core
+
+import (
+	"sort"
+
+	"github.com/loadimpact/k6/lib"
+)
+
+// OutputFlush is the result of flushing one of the outputs, either on demand with FlushOutputs()
+// or when the collectors stop at the end of the test.
+type OutputFlush struct {
+	Output string
+	Err    error
+}
+
+// outputsStopped records whether the FailingCollectors delivered their samples, once all of the
+// collectors have stopped, and lets the embedders know about it.
+func (e *Engine) outputsStopped() {
+	flushes := make([]OutputFlush, 0, len(e.FailingCollectors))
+	for label, collector := range e.FailingCollectors {
+		flushes = append(flushes, OutputFlush{Output: label, Err: collector.DeliveryFailure()})
+	}
+	sort.Slice(flushes, func(i, j int) bool { return flushes[i].Output < flushes[j].Output })
+	e.outputFlushes = flushes
+	close(e.outputsFlushed)
+	e.emitRunEvent(RunEvent{Type: RunEventOutputsFlushed, Outputs: flushes})
+}
+
+// SetFlushCollectors sets the collectors that can be flushed with FlushOutputs(), by their labels.
+// It's safe to call while FlushOutputs() is being called, e.g. by the REST API.
+func (e *Engine) SetFlushCollectors(collectors map[string]lib.FlushingCollector) {
+	e.flushMutex.Lock()
+	defer e.flushMutex.Unlock()
+	e.flushCollectors = collectors
+}
+
+// FlushOutputs makes all of the flush collectors deliver their buffered samples right away,
+// flushing them at the same time, and returns the results sorted by the outputs' labels. It can
+// be called at any time, the collectors take care of not clashing with their own regular flushes.
+func (e *Engine) FlushOutputs() []OutputFlush {
+	e.flushMutex.Lock()
+	collectors := e.flushCollectors
+	e.flushMutex.Unlock()
+
+	results := make(chan OutputFlush, len(collectors))
+	for label, collector := range collectors {
+		go func(label string, collector lib.FlushingCollector) {
+			results <- OutputFlush{Output: label, Err: collector.Flush()}
+		}(label, collector)
+	}
+
+	flushes := make([]OutputFlush, 0, len(collectors))
+	for range collectors {
+		flush := <-results
+		if flush.Err != nil {
+			e.logger.WithError(flush.Err).WithField("output", flush.Output).Warn("Couldn't flush the output")
+		}
+		flushes = append(flushes, flush)
+	}
+	sort.Slice(flushes, func(i, j int) bool { return flushes[i].Output < flushes[j].Output })
+	return flushes
+}
@@ -0,0 +1,89 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/loadimpact/k6/stats"
+)
+
+// IdleAbortError is returned by Engine.Run() when the metric set with SetIdleAbort() didn't get
+// any samples for longer than the idle timeout.
+type IdleAbortError struct {
+	Metric  string
+	Timeout time.Duration
+}
+
+func (e IdleAbortError) Error() string {
+	return fmt.Sprintf("no samples of the metric '%s' arrived for %s, aborting the test", e.Metric, e.Timeout)
+}
+
+// SetIdleAbort makes the engine abort the run with an IdleAbortError when no samples of the metric
+// arrive for longer than the timeout, counting from the start of the iterations, so a test whose
+// requests all hang is stopped right away instead of running until its end. The setup, the
+// teardown and the time the test is paused don't count. The metric is identified by its name after
+// the metric name mapping, like in the thresholds.
+func (e *Engine) SetIdleAbort(metric string, timeout time.Duration) {
+	e.MetricsLock.Lock()
+	defer e.MetricsLock.Unlock()
+	e.idleMetric = metric
+	e.idleTimeout = timeout
+}
+
+// idleClock returns the time the idle timeout is measured with, which is the time of the executor.
+// It only runs while the iterations do, so it's still 0 during the setup, and it stands still
+// while the test is paused and during the teardown.
+func (e *Engine) idleClock() time.Duration {
+	if e.idleTimeout <= 0 {
+		return 0
+	}
+	return e.Executor.GetTime()
+}
+
+// trackIdleMetric records the time when the last samples of the idle metric arrived.
+func (e *Engine) trackIdleMetric(sampleContainers []stats.SampleContainer, now time.Duration) {
+	if e.idleTimeout <= 0 {
+		return
+	}
+	for _, sc := range sampleContainers {
+		for _, sample := range sc.GetSamples() {
+			if sample.Metric.Name == e.idleMetric {
+				e.idleLastSample = now
+				return
+			}
+		}
+	}
+}
+
+// checkIdle returns an IdleAbortError if the idle metric didn't get any samples for longer than
+// the idle timeout, as of the time now of the idle clock.
+func (e *Engine) checkIdle(now time.Duration) error {
+	e.MetricsLock.Lock()
+	defer e.MetricsLock.Unlock()
+	if e.idleTimeout <= 0 || now <= 0 {
+		return nil
+	}
+	if now-e.idleLastSample > e.idleTimeout {
+		return IdleAbortError{Metric: e.idleMetric, Timeout: e.idleTimeout}
+	}
+	return nil
+}
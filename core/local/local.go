@@ -399,6 +399,10 @@ func (e *Executor) SetStages(s []lib.Stage) {
 	e.stages = s
 }
 
+func (e *Executor) GetCurrentStage() (int, bool) {
+	return lib.StageAt(e.stages, e.GetTime())
+}
+
 func (e *Executor) GetIterations() int64 {
 	return atomic.LoadInt64(&e.iters)
 }
@@ -0,0 +1,117 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"time"
+
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/lib/netext/httpext"
+	"github.com/loadimpact/k6/stats"
+	log "github.com/sirupsen/logrus"
+)
+
+// sampleTimeClamper keeps the sample times sane on machines with unreliable clocks, e.g.
+// when NTP corrects the clock during a test, by keeping them from exceeding the current
+// time by more than the tolerance. Past times are left alone, since the samples of a
+// series normally arrive out of order, e.g. from different VUs, and there's no way to
+// tell those apart from the ones skewed by the clock.
+type sampleTimeClamper struct {
+	tolerance time.Duration
+	now       func() time.Time
+	clamped   int64
+}
+
+func newSampleTimeClamper(tolerance time.Duration) *sampleTimeClamper {
+	return &sampleTimeClamper{tolerance: tolerance, now: time.Now}
+}
+
+// clampTime returns the clamped time and whether it was changed.
+func (c *sampleTimeClamper) clampTime(t time.Time) (time.Time, bool) {
+	if maxTime := c.now().Add(c.tolerance); t.After(maxTime) {
+		return maxTime, true
+	}
+	return t, false
+}
+
+// SetSampleTimeClamping enables the clamping of sample times before the samples are processed
+// and sent to the collectors, with the supplied tolerance for sample times in the future.
+func (e *Engine) SetSampleTimeClamping(tolerance time.Duration) {
+	e.timeClamper = newSampleTimeClamper(tolerance)
+}
+
+// clampSampleTimes clamps the times of the samples, if it's enabled. Containers without any
+// clamped samples are passed through untouched, the rest are copied with WithSamples(), and
+// the times of the connected ones are clamped as well.
+func (e *Engine) clampSampleTimes(sampleContainers []stats.SampleContainer) []stats.SampleContainer {
+	if e.timeClamper == nil {
+		return sampleContainers
+	}
+
+	for i, sc := range sampleContainers {
+		samples := sc.GetSamples()
+		var clamped []stats.Sample
+		for j, sample := range samples {
+			t, changed := e.timeClamper.clampTime(sample.Time)
+			if !changed {
+				continue
+			}
+			if clamped == nil {
+				clamped = make([]stats.Sample, len(samples))
+				copy(clamped, samples)
+			}
+			if e.timeClamper.clamped == 0 {
+				e.logger.WithFields(log.Fields{"metric": sample.Metric.Name, "time": sample.Time, "clamped": t}).Warn(
+					"Engine: Clamped the time of a sample, the system clock may be unreliable")
+			}
+			e.timeClamper.clamped++
+			clamped[j].Time = t
+		}
+		if clamped == nil {
+			continue
+		}
+
+		switch sc := WithSamples(sc, clamped).(type) {
+		case *httpext.Trail:
+			sc.EndTime, _ = e.timeClamper.clampTime(sc.EndTime)
+			sampleContainers[i] = sc
+		case *netext.NetTrail:
+			sc.EndTime, _ = e.timeClamper.clampTime(sc.EndTime)
+			sampleContainers[i] = sc
+		case stats.ConnectedSamples:
+			sc.Time, _ = e.timeClamper.clampTime(sc.Time)
+			sampleContainers[i] = sc
+		default:
+			sampleContainers[i] = sc
+		}
+	}
+	return sampleContainers
+}
+
+// logClampedSampleTimes logs how many sample times were clamped during the test.
+func (e *Engine) logClampedSampleTimes() {
+	e.MetricsLock.Lock()
+	defer e.MetricsLock.Unlock()
+	if e.timeClamper != nil && e.timeClamper.clamped > 0 {
+		e.logger.WithField("samples", e.timeClamper.clamped).Warn(
+			"Engine: Clamped the times of samples because of clock skew")
+	}
+}
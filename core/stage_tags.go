@@ -0,0 +1,125 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package core
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/lib/netext/httpext"
+	"github.com/loadimpact/k6/stats"
+)
+
+// stageTag is the system tag with the index of the stage of the executor that was running when
+// a sample was emitted, so the metrics can be broken down by the phases of the test, e.g. the
+// ramp-up and the steady state. It isn't enabled by default.
+const stageTag = "stage"
+
+// stageTagKey identifies a tag set extended with the tag of a stage.
+type stageTagKey struct {
+	tags  *stats.SampleTags
+	stage int
+}
+
+// tagStages adds the stage tag to the samples, if it's enabled, with the stage that was running
+// at the time of each sample. Samples that already have the tag, or that were emitted after all
+// of the stages had ended, are left as they are. Containers without any tagged samples are passed
+// through untouched, the rest are copied with WithSamples(), and the connected ones get the tag of
+// the stage at their own time too.
+func (e *Engine) tagStages(sampleContainers []stats.SampleContainer) []stats.SampleContainer {
+	if !e.Options.SystemTags[stageTag] {
+		return sampleContainers
+	}
+	stages := e.Executor.GetStages()
+	if len(stages) == 0 {
+		return sampleContainers
+	}
+
+	// The time of the executor doesn't run during the setup or while the test is paused, so
+	// the sample times are converted to it from the wall clock time when it was at 0. The samples
+	// from before the iterations, e.g. from the setup, belong to the first stage.
+	zero := time.Now().Add(-e.Executor.GetTime())
+	stageAt := func(t time.Time) (int, bool) {
+		at := t.Sub(zero)
+		if at < 0 {
+			at = 0
+		}
+		return lib.StageAt(stages, at)
+	}
+
+	// The samples of a container usually share its tags, so they share the tagged copy too.
+	taggedTags := map[stageTagKey]*stats.SampleTags{}
+	withStageTag := func(tags *stats.SampleTags, stage int) *stats.SampleTags {
+		key := stageTagKey{tags, stage}
+		if tagged, ok := taggedTags[key]; ok {
+			return tagged
+		}
+		tagsMap := tags.CloneTags()
+		tagsMap[stageTag] = strconv.Itoa(stage)
+		tagged := stats.IntoSampleTags(&tagsMap)
+		taggedTags[key] = tagged
+		return tagged
+	}
+
+	for i, sc := range sampleContainers {
+		samples := sc.GetSamples()
+		var tagged []stats.Sample
+		for j, sample := range samples {
+			if _, ok := sample.Tags.Get(stageTag); ok {
+				continue
+			}
+			stage, ok := stageAt(sample.Time)
+			if !ok {
+				continue
+			}
+			if tagged == nil {
+				tagged = make([]stats.Sample, len(samples))
+				copy(tagged, samples)
+			}
+			tagged[j].Tags = withStageTag(sample.Tags, stage)
+		}
+		if tagged == nil {
+			continue
+		}
+
+		switch sc := WithSamples(sc, tagged).(type) {
+		case *httpext.Trail:
+			if stage, ok := stageAt(sc.EndTime); ok {
+				sc.Tags = withStageTag(sc.Tags, stage)
+			}
+			sampleContainers[i] = sc
+		case *netext.NetTrail:
+			if stage, ok := stageAt(sc.EndTime); ok {
+				sc.Tags = withStageTag(sc.Tags, stage)
+			}
+			sampleContainers[i] = sc
+		case stats.ConnectedSamples:
+			if stage, ok := stageAt(sc.Time); ok {
+				sc.Tags = withStageTag(sc.Tags, stage)
+			}
+			sampleContainers[i] = sc
+		default:
+			sampleContainers[i] = sc
+		}
+	}
+	return sampleContainers
+}
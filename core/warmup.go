@@ -0,0 +1,107 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package core
+
+import (
+	"time"
+
+	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/lib/netext/httpext"
+	"github.com/loadimpact/k6/stats"
+)
+
+// SetWarmup makes the engine leave the samples from the given period after the start of the run
+// out of its metrics, so they don't skew the thresholds and the summary, e.g. the percentiles of
+// the response times while the target is still warming up. The collectors still get them, tagged
+// with warmup=true.
+func (e *Engine) SetWarmup(warmup time.Duration) {
+	e.warmup = warmup
+}
+
+// splitWarmupSamples returns the sample containers for the metrics, without the samples from the
+// warm-up period, and the ones for the collectors, in which those samples are tagged. Containers
+// without any samples from the warm-up period are passed through untouched to both, the rest are
+// copied with WithSamples(), and the connected ones for the collectors are tagged too.
+func (e *Engine) splitWarmupSamples(
+	sampleContainers []stats.SampleContainer,
+) (forMetrics, forCollectors []stats.SampleContainer) {
+	if e.warmup <= 0 || e.startTime.IsZero() {
+		return sampleContainers, sampleContainers
+	}
+
+	end := e.startTime.Add(e.warmup)
+	forMetrics = make([]stats.SampleContainer, 0, len(sampleContainers))
+	forCollectors = make([]stats.SampleContainer, len(sampleContainers))
+
+	// Many samples share the same tags, so each tag set is only extended once.
+	tagged := map[*stats.SampleTags]*stats.SampleTags{}
+	tag := func(tags *stats.SampleTags) *stats.SampleTags {
+		if t, ok := tagged[tags]; ok {
+			return t
+		}
+		data := tags.CloneTags()
+		data["warmup"] = "true"
+		t := stats.IntoSampleTags(&data)
+		tagged[tags] = t
+		return t
+	}
+
+	for i, sc := range sampleContainers {
+		forCollectors[i] = sc
+		samples := sc.GetSamples()
+		var kept, tagSamples []stats.Sample
+		for j, sample := range samples {
+			if !sample.Time.Before(end) {
+				if tagSamples != nil {
+					kept = append(kept, sample)
+				}
+				continue
+			}
+			if tagSamples == nil {
+				kept = append(make([]stats.Sample, 0, len(samples)), samples[:j]...)
+				tagSamples = make([]stats.Sample, len(samples))
+				copy(tagSamples, samples)
+			}
+			tagSamples[j].Tags = tag(sample.Tags)
+		}
+		if tagSamples == nil {
+			forMetrics = append(forMetrics, sc)
+			continue
+		}
+
+		if len(kept) > 0 {
+			forMetrics = append(forMetrics, WithSamples(sc, kept))
+		}
+		switch sc := WithSamples(sc, tagSamples).(type) {
+		case *httpext.Trail:
+			sc.Tags = tag(sc.Tags)
+			forCollectors[i] = sc
+		case *netext.NetTrail:
+			sc.Tags = tag(sc.Tags)
+			forCollectors[i] = sc
+		case stats.ConnectedSamples:
+			sc.Tags = tag(sc.Tags)
+			forCollectors[i] = sc
+		default:
+			forCollectors[i] = sc
+		}
+	}
+	return forMetrics, forCollectors
+}
@@ -0,0 +1,137 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import (
+	"github.com/dop251/goja/ast"
+)
+
+// walkAST calls visit for the node and, if it returns true, for all of the nodes within it, depth
+// first. The function declarations aren't statements, so they're visited after the statements of
+// their scope, and the properties of the object literals aren't nodes, so only their values are.
+func walkAST(node ast.Node, visit func(ast.Node) bool) {
+	if node == nil || !visit(node) {
+		return
+	}
+	walk := func(nodes ...ast.Node) {
+		for _, n := range nodes {
+			if n != nil {
+				walkAST(n, visit)
+			}
+		}
+	}
+	walkExpressions := func(exprs []ast.Expression) {
+		for _, expr := range exprs {
+			walk(expr)
+		}
+	}
+	walkStatements := func(stmts []ast.Statement) {
+		for _, stmt := range stmts {
+			walk(stmt)
+		}
+	}
+	walkFunctions := func(decls []ast.Declaration) {
+		for _, decl := range decls {
+			if fn, ok := decl.(*ast.FunctionDeclaration); ok && fn.Function != nil {
+				walk(fn.Function)
+			}
+		}
+	}
+
+	switch n := node.(type) {
+	case *ast.Program:
+		walkStatements(n.Body)
+		walkFunctions(n.DeclarationList)
+
+	case *ast.ArrayLiteral:
+		walkExpressions(n.Value)
+	case *ast.AssignExpression:
+		walk(n.Left, n.Right)
+	case *ast.BinaryExpression:
+		walk(n.Left, n.Right)
+	case *ast.BracketExpression:
+		walk(n.Left, n.Member)
+	case *ast.CallExpression:
+		walk(n.Callee)
+		walkExpressions(n.ArgumentList)
+	case *ast.ConditionalExpression:
+		walk(n.Test, n.Consequent, n.Alternate)
+	case *ast.DotExpression:
+		walk(n.Left)
+	case *ast.FunctionLiteral:
+		walk(n.Body)
+		walkFunctions(n.DeclarationList)
+	case *ast.NewExpression:
+		walk(n.Callee)
+		walkExpressions(n.ArgumentList)
+	case *ast.ObjectLiteral:
+		for _, prop := range n.Value {
+			walk(prop.Value)
+		}
+	case *ast.SequenceExpression:
+		walkExpressions(n.Sequence)
+	case *ast.UnaryExpression:
+		walk(n.Operand)
+	case *ast.VariableExpression:
+		walk(n.Initializer)
+
+	case *ast.BlockStatement:
+		walkStatements(n.List)
+	case *ast.CaseStatement:
+		walk(n.Test)
+		walkStatements(n.Consequent)
+	case *ast.CatchStatement:
+		walk(n.Body)
+	case *ast.DoWhileStatement:
+		walk(n.Body, n.Test)
+	case *ast.ExpressionStatement:
+		walk(n.Expression)
+	case *ast.ForInStatement:
+		walk(n.Into, n.Source, n.Body)
+	case *ast.ForStatement:
+		walk(n.Initializer, n.Test, n.Update, n.Body)
+	case *ast.IfStatement:
+		walk(n.Test, n.Consequent, n.Alternate)
+	case *ast.LabelledStatement:
+		walk(n.Statement)
+	case *ast.ReturnStatement:
+		walk(n.Argument)
+	case *ast.SwitchStatement:
+		walk(n.Discriminant)
+		for _, c := range n.Body {
+			walk(c)
+		}
+	case *ast.ThrowStatement:
+		walk(n.Argument)
+	case *ast.TryStatement:
+		walk(n.Body)
+		if n.Catch != nil {
+			walk(n.Catch)
+		}
+		walk(n.Finally)
+	case *ast.VariableStatement:
+		walkExpressions(n.List)
+	case *ast.WhileStatement:
+		walk(n.Test, n.Body)
+	case *ast.WithStatement:
+		walk(n.Object, n.Body)
+	}
+}
@@ -0,0 +1,125 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/parser"
+	"github.com/loadimpact/k6/js/modules"
+	"github.com/loadimpact/k6/lib/consts"
+	"github.com/loadimpact/k6/loader"
+	"github.com/pkg/errors"
+)
+
+// checkBuiltinModules returns an error listing the built-in modules ("k6" or "k6/...") that a
+// script or any of the modules it imports require, but that this build of k6 doesn't have. The
+// code is the compiled script, in which the imports have become require() calls, and pwd is what
+// its imports are resolved against. The imported modules are loaded and compiled into the cache
+// of the init context, so they aren't loaded again when the init code runs. The modules that
+// can't be loaded are left for the init code to report, and a module that's only required in a
+// branch that never runs is still reported.
+func (i *InitContext) checkBuiltinModules(code string, filename string, pwd *url.URL) error {
+	missing := map[string]bool{}
+	seen := map[string]bool{}
+	var check func(code, filename string, pwd *url.URL)
+	check = func(code, filename string, pwd *url.URL) {
+		for _, name := range requiredModules(code, filename) {
+			if name == "k6" || strings.HasPrefix(name, "k6/") {
+				if _, ok := modules.Index[name]; !ok {
+					missing[name] = true
+				}
+				continue
+			}
+			fileURL, err := loader.Resolve(pwd, name)
+			if err != nil || seen[fileURL.String()] {
+				continue
+			}
+			seen[fileURL.String()] = true
+			code, ok := i.compileCachedImport(fileURL, name)
+			if ok {
+				check(code, fileURL.String(), loader.Dir(fileURL))
+			}
+		}
+	}
+	check(code, filename, pwd)
+	if len(missing) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(missing))
+	for name := range missing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return errors.Errorf(
+		"the script uses built-in modules that this build of k6 (%s) doesn't have: %s",
+		consts.Version, strings.Join(names, ", "))
+}
+
+// compileCachedImport loads and compiles an imported module into the cache of the init context,
+// like requireFile() does, and returns its compiled code, or false if it can't be loaded.
+func (i *InitContext) compileCachedImport(fileURL *url.URL, name string) (string, bool) {
+	if _, ok := i.programs[fileURL.String()]; ok {
+		// Already loaded, and so already checked.
+		return "", false
+	}
+	data, err := loader.Load(i.filesystems, fileURL, name)
+	if err != nil {
+		return "", false
+	}
+	src := string(data.Data)
+	pgm, code, err := i.compileImport(src, data.URL.String())
+	if err != nil {
+		return "", false
+	}
+	i.programs[fileURL.String()] = programWithSource{pgm: pgm, src: src}
+	return code, true
+}
+
+// requiredModules returns the names of the modules that the compiled code requires with a string
+// literal, e.g. "k6/http" for require("k6/http"), in the order they're first required.
+func requiredModules(code, filename string) []string {
+	program, err := parser.ParseFile(nil, filename, code, 0)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	seen := map[string]bool{}
+	walkAST(program, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || len(call.ArgumentList) == 0 {
+			return true
+		}
+		if callee, ok := call.Callee.(*ast.Identifier); !ok || callee.Name != "require" {
+			return true
+		}
+		if name, ok := call.ArgumentList[0].(*ast.StringLiteral); ok && !seen[name.Value] {
+			seen[name.Value] = true
+			names = append(names, name.Value)
+		}
+		return true
+	})
+	return names
+}
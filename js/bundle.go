@@ -34,6 +34,7 @@ import (
 	jslib "github.com/loadimpact/k6/js/lib"
 	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/loader"
+	"github.com/loadimpact/k6/stats"
 	"github.com/pkg/errors"
 	"github.com/spf13/afero"
 )
@@ -49,6 +50,9 @@ type Bundle struct {
 	BaseInitContext *InitContext
 
 	Env map[string]string
+
+	// Metrics holds the custom metrics declared by the script in its init context.
+	Metrics *stats.Registry
 }
 
 // A BundleInstance is a self-contained instance of a Bundle.
@@ -67,7 +71,7 @@ func NewBundle(src *loader.SourceData, filesystems map[string]afero.Fs, rtOpts l
 
 	// Compile sources, both ES5 and ES6 are supported.
 	code := string(src.Data)
-	pgm, _, err := compiler.Compile(code, src.URL.String(), "", "", true)
+	pgm, compiled, err := compiler.Compile(code, src.URL.String(), "", "", true)
 	if err != nil {
 		return nil, err
 	}
@@ -79,6 +83,13 @@ func NewBundle(src *loader.SourceData, filesystems map[string]afero.Fs, rtOpts l
 		Program:         pgm,
 		BaseInitContext: NewInitContext(rt, compiler, new(context.Context), filesystems, loader.Dir(src.URL)),
 		Env:             rtOpts.Env,
+		Metrics:         stats.NewRegistry(),
+	}
+	if rtOpts.CheckModules.Bool {
+		err := bundle.BaseInitContext.checkBuiltinModules(compiled, src.URL.String(), loader.Dir(src.URL))
+		if err != nil {
+			return nil, err
+		}
 	}
 	if err := bundle.instantiate(rt, bundle.BaseInitContext); err != nil {
 		return nil, err
@@ -137,13 +148,17 @@ func NewBundleFromArchive(arc *lib.Archive, rtOpts lib.RuntimeOptions) (*Bundle,
 	if arc.Type != "js" {
 		return nil, errors.Errorf("expected bundle type 'js', got '%s'", arc.Type)
 	}
-
-	pgm, _, err := compiler.Compile(string(arc.Data), arc.FilenameURL.String(), "", "", true)
+	pgm, compiled, err := compiler.Compile(string(arc.Data), arc.FilenameURL.String(), "", "", true)
 	if err != nil {
 		return nil, err
 	}
 
 	initctx := NewInitContext(goja.New(), compiler, new(context.Context), arc.Filesystems, arc.PwdURL)
+	if rtOpts.CheckModules.Bool {
+		if err := initctx.checkBuiltinModules(compiled, arc.FilenameURL.String(), arc.PwdURL); err != nil {
+			return nil, err
+		}
+	}
 
 	env := arc.Env
 	if env == nil {
@@ -161,6 +176,7 @@ func NewBundleFromArchive(arc *lib.Archive, rtOpts lib.RuntimeOptions) (*Bundle,
 		Options:         arc.Options,
 		BaseInitContext: initctx,
 		Env:             env,
+		Metrics:         stats.NewRegistry(),
 	}
 	if err := bundle.instantiate(bundle.BaseInitContext.runtime, bundle.BaseInitContext); err != nil {
 		return nil, err
@@ -247,7 +263,11 @@ func (b *Bundle) instantiate(rt *goja.Runtime, init *InitContext) error {
 
 	rt.Set("__ENV", b.Env)
 
-	*init.ctxPtr = common.WithRuntime(context.Background(), rt)
+	ctx := common.WithRuntime(context.Background(), rt)
+	if b.Metrics != nil {
+		ctx = common.WithMetricsRegistry(ctx, b.Metrics)
+	}
+	*init.ctxPtr = ctx
 	unbindInit := common.BindToGlobal(rt, common.Bind(rt, init, init.ctxPtr))
 	if _, err := rt.RunProgram(b.Program); err != nil {
 		return err
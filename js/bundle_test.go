@@ -651,3 +651,51 @@ func TestBundleEnv(t *testing.T) {
 		})
 	}
 }
+
+func TestBundleCheckModules(t *testing.T) {
+	src := `
+		import http from "k6/http";
+		import { Kafka } from 'k6/x/kafka';
+		const sql = require("k6/x/sql");
+		export default function() {};
+	`
+	_, err := getSimpleBundleWithOptions("/script.js", src, lib.RuntimeOptions{CheckModules: null.BoolFrom(true)})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "doesn't have: k6/x/kafka, k6/x/sql")
+	}
+
+	// Without the check, the init code fails on the first missing module.
+	_, err = getSimpleBundleWithOptions("/script.js", src, lib.RuntimeOptions{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown builtin module: k6/x/kafka")
+	}
+
+	src = `
+		import http from "k6/http";
+		import { check } from "k6";
+		export default function() {};
+	`
+	_, err = getSimpleBundleWithOptions("/script.js", src, lib.RuntimeOptions{CheckModules: null.BoolFrom(true)})
+	assert.NoError(t, err)
+
+	t.Run("Imports", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fs, "/lib/db.js", []byte(`
+			import sql from "k6/x/sql";
+			export const open = sql.open;
+		`), 0644))
+		src := `
+			// Not a module: import foo from "k6/x/comment";
+			import { open } from "./lib/db.js";
+			const message = 'require("k6/x/string")';
+			export default function() {};
+		`
+		_, err := NewBundle(
+			&loader.SourceData{URL: &url.URL{Path: "/script.js", Scheme: "file"}, Data: []byte(src)},
+			map[string]afero.Fs{"file": fs, "https": afero.NewMemMapFs()},
+			lib.RuntimeOptions{CheckModules: null.BoolFrom(true)},
+		)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "doesn't have: k6/x/sql")
+	})
+}
@@ -24,12 +24,14 @@ import (
 	"context"
 
 	"github.com/dop251/goja"
+	"github.com/loadimpact/k6/stats"
 )
 
 type ctxKey int
 
 const (
 	ctxKeyRuntime ctxKey = iota
+	ctxKeyMetricsRegistry
 )
 
 func WithRuntime(ctx context.Context, rt *goja.Runtime) context.Context {
@@ -43,3 +45,18 @@ func GetRuntime(ctx context.Context) *goja.Runtime {
 	}
 	return v.(*goja.Runtime)
 }
+
+// WithMetricsRegistry attaches the registry that the metrics declared in the init context are
+// added to.
+func WithMetricsRegistry(ctx context.Context, r *stats.Registry) context.Context {
+	return context.WithValue(ctx, ctxKeyMetricsRegistry, r)
+}
+
+// GetMetricsRegistry returns the metrics registry attached to the context, or nil if there's none.
+func GetMetricsRegistry(ctx context.Context) *stats.Registry {
+	v := ctx.Value(ctxKeyMetricsRegistry)
+	if v == nil {
+		return nil
+	}
+	return v.(*stats.Registry)
+}
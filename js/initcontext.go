@@ -23,13 +23,17 @@ package js
 import (
 	"context"
 	"net/url"
+	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/dop251/goja"
 	"github.com/loadimpact/k6/js/common"
 	"github.com/loadimpact/k6/js/compiler"
 	"github.com/loadimpact/k6/js/modules"
+	"github.com/loadimpact/k6/lib/fsext"
 	"github.com/loadimpact/k6/loader"
 	"github.com/pkg/errors"
 	"github.com/spf13/afero"
@@ -152,7 +156,7 @@ func (i *InitContext) requireFile(name string) (goja.Value, error) {
 			pgm.src = string(data.Data)
 
 			// Compile the sources; this handles ES5 vs ES6 automatically.
-			pgm.pgm, err = i.compileImport(pgm.src, data.URL.String())
+			pgm.pgm, _, err = i.compileImport(pgm.src, data.URL.String())
 			if err != nil {
 				return goja.Undefined(), err
 			}
@@ -176,13 +180,48 @@ func (i *InitContext) requireFile(name string) (goja.Value, error) {
 	return pgm.module.Get("exports"), nil
 }
 
-func (i *InitContext) compileImport(src, filename string) (*goja.Program, error) {
-	pgm, _, err := i.compiler.Compile(src, filename, "(function(module, exports){\n", "\n})\n", true)
-	return pgm, err
+// compileImport compiles an imported module, and returns the program and its compiled code.
+func (i *InitContext) compileImport(src, filename string) (*goja.Program, string, error) {
+	return i.compiler.Compile(src, filename, "(function(module, exports){\n", "\n})\n", true)
 }
 
-// Open implements open() in the init context and will read and return the contents of a file
-func (i *InitContext) Open(filename string, args ...string) (goja.Value, error) {
+// Open implements open() in the init context and will read and return the contents of a file.
+// It also accepts an array of filenames, e.g. for fixtures that are sharded across files, and
+// then returns the contents of all of the files concatenated in the order of the array. If any of
+// them can't be read, open() fails as a whole.
+func (i *InitContext) Open(filename goja.Value, args ...string) (goja.Value, error) {
+	filenames := []string{""}
+	if names, ok := filename.Export().([]interface{}); ok {
+		if len(names) == 0 {
+			return nil, errors.New("open() can't be used with an empty array of filenames")
+		}
+		filenames = make([]string, len(names))
+		for n, name := range names {
+			if filenames[n], ok = name.(string); !ok {
+				return nil, errors.Errorf("open() needs an array of filenames, but it contains %v", name)
+			}
+		}
+	} else if !goja.IsUndefined(filename) && !goja.IsNull(filename) {
+		filenames[0] = filename.String()
+	}
+
+	var data []byte
+	for _, name := range filenames {
+		fileData, err := i.readFile(name)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, fileData...)
+	}
+
+	if len(args) > 0 && args[0] == "b" {
+		return i.runtime.ToValue(data), nil
+	}
+	return i.runtime.ToValue(string(data)), nil
+}
+
+// readFile reads a file for open(), resolving its name relative to the script.
+func (i *InitContext) readFile(filename string) ([]byte, error) {
 	if filename == "" {
 		return nil, errors.New("open() can't be used with an empty filename")
 	}
@@ -205,13 +244,43 @@ func (i *InitContext) Open(filename string, args ...string) (goja.Value, error)
 	} else if isDir {
 		return nil, errors.New("open() can't be used with directories")
 	}
-	data, err := afero.ReadFile(fs, filename)
-	if err != nil {
-		return nil, err
+	return afero.ReadFile(fs, filename)
+}
+
+// OpenedFiles implements openedFiles() in the init context and returns the URLs of all files that
+// have been loaded so far, either with open() or as imported modules. These are the files that
+// will end up in an archive of the test.
+func (i *InitContext) OpenedFiles() ([]string, error) {
+	files := []string{}
+	for scheme, filesystem := range i.filesystems {
+		if cachedfs, ok := filesystem.(fsext.CacheOnReadFs); ok {
+			filesystem = cachedfs.GetCachingFs()
+		}
+		walkFunc := filepath.WalkFunc(func(filePath string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			files = append(files, openedFileURL(scheme, path.Join("/", filepath.ToSlash(filePath))))
+			return nil
+		})
+		if err := fsext.Walk(filesystem, afero.FilePathSeparator, walkFunc); err != nil {
+			return nil, err
+		}
 	}
+	sort.Strings(files)
+	return files, nil
+}
 
-	if len(args) > 0 && args[0] == "b" {
-		return i.runtime.ToValue(data), nil
+// openedFileURL returns the URL of a file at the path in the filesystem of the scheme. Remote
+// files are kept under their host, e.g. /example.com/lib.js for https://example.com/lib.js.
+func openedFileURL(scheme, filePath string) string {
+	u := &url.URL{Scheme: scheme, Path: filePath}
+	if scheme != "file" {
+		parts := strings.SplitN(strings.TrimPrefix(filePath, "/"), "/", 2)
+		u.Host, u.Path = parts[0], "/"
+		if len(parts) > 1 {
+			u.Path += parts[1]
+		}
 	}
-	return i.runtime.ToValue(string(data)), nil
+	return u.String()
 }
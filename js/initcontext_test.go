@@ -27,6 +27,7 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"path/filepath"
 	"testing"
 	"time"
@@ -34,7 +35,9 @@ import (
 	"github.com/dop251/goja"
 	"github.com/loadimpact/k6/js/common"
 	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/fsext"
 	"github.com/loadimpact/k6/lib/netext"
+	"github.com/loadimpact/k6/loader"
 	"github.com/loadimpact/k6/stats"
 	"github.com/oxtoacart/bpool"
 	log "github.com/sirupsen/logrus"
@@ -316,6 +319,82 @@ func TestInitContextOpen(t *testing.T) {
 		assert.EqualError(t, err, fmt.Sprintf("GoError: open %s: file does not exist", path))
 	})
 
+	t.Run("Multiple", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fs, "/path/to/part1.csv", []byte("a,b\n"), 0644))
+		require.NoError(t, afero.WriteFile(fs, "/path/to/part2.csv", []byte("c,d\n"), 0644))
+
+		b, err := getSimpleBundleWithFs("/path/to/script.js", `
+			export let data = open(["part2.csv", "/path/to/part1.csv"]);
+			export let binary = open(["part1.csv", "part2.csv"], "b");
+			export default function() {}
+		`, fs)
+		require.NoError(t, err)
+		bi, err := b.Instantiate()
+		require.NoError(t, err)
+		exports := bi.Runtime.Get("exports").ToObject(bi.Runtime)
+		assert.Equal(t, "c,d\na,b\n", exports.Get("data").Export())
+		assert.Equal(t, []byte("a,b\nc,d\n"), exports.Get("binary").Export())
+
+		_, err = getSimpleBundleWithFs("/path/to/script.js",
+			`open(["part1.csv", "missing.csv"]); export default function() {}`, fs)
+		assert.EqualError(t, err,
+			fmt.Sprintf("GoError: open %s: file does not exist", filepath.FromSlash("/path/to/missing.csv")))
+
+		_, err = getSimpleBundleWithFs("/path/to/script.js", `open([]); export default function() {}`, fs)
+		assert.EqualError(t, err, "GoError: open() can't be used with an empty array of filenames")
+
+		_, err = getSimpleBundleWithFs("/path/to/script.js", `open(["part1.csv", 5]); export default function() {}`, fs)
+		assert.EqualError(t, err, "GoError: open() needs an array of filenames, but it contains 5")
+	})
+
+}
+
+func TestInitContextOpenedFiles(t *testing.T) {
+	base := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(base, "/path/to/data.txt", []byte("hi"), 0644))
+	require.NoError(t, afero.WriteFile(base, "/path/to/lib.js", []byte("export default 5;"), 0644))
+	require.NoError(t, afero.WriteFile(base, "/path/to/unused.txt", []byte("nope"), 0644))
+	fs := fsext.NewCacheOnReadFs(base, afero.NewMemMapFs(), 0)
+
+	b, err := getSimpleBundleWithFs("/path/to/script.js", `
+		import lib from "./lib.js";
+		open("data.txt");
+		export let files = openedFiles();
+		export default function() { return typeof openedFiles; };
+	`, fs)
+	require.NoError(t, err)
+
+	bi, err := b.Instantiate()
+	require.NoError(t, err)
+	exports := bi.Runtime.Get("exports").ToObject(bi.Runtime)
+	assert.Equal(t, []string{"file:///path/to/data.txt", "file:///path/to/lib.js"}, exports.Get("files").Export())
+
+	v, err := bi.Default(goja.Undefined())
+	require.NoError(t, err)
+	assert.Equal(t, "undefined", v.Export())
+
+	t.Run("Remote", func(t *testing.T) {
+		remote := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(remote, "/cdn.example.com/lib/util.js", []byte("export default 5;"), 0644))
+		b, err := NewBundle(
+			&loader.SourceData{
+				URL: &url.URL{Path: "/path/to/script.js", Scheme: "file"},
+				Data: []byte(`
+					import util from "https://cdn.example.com/lib/util.js";
+					export let files = openedFiles();
+					export default function() {};
+				`),
+			},
+			map[string]afero.Fs{"file": afero.NewMemMapFs(), "https": remote},
+			lib.RuntimeOptions{},
+		)
+		require.NoError(t, err)
+		bi, err := b.Instantiate()
+		require.NoError(t, err)
+		exports := bi.Runtime.Get("exports").ToObject(bi.Runtime)
+		assert.Equal(t, []string{"https://cdn.example.com/lib/util.js"}, exports.Get("files").Export())
+	})
 }
 
 func TestRequestWithBinaryFile(t *testing.T) {
@@ -43,6 +43,10 @@ var ErrGroupInInitContext = common.NewInitContextError("Using group() in the ini
 // ErrCheckInInitContext is returned when check() are using in the init context
 var ErrCheckInInitContext = common.NewInitContextError("Using check() in the init context is not supported")
 
+// ErrRecordIndexInInitContext is returned when recordIndex() is used in the init context
+var ErrRecordIndexInInitContext = common.NewInitContextError(
+	"Using recordIndex() in the init context is not supported")
+
 func New() *K6 {
 	return &K6{}
 }
@@ -67,6 +71,32 @@ func (*K6) RandomSeed(ctx context.Context, seed int64) {
 	rt.SetRandSource(randSource)
 }
 
+// RecordIndex returns the index of the record from a dataset with the given length that the current
+// iteration should use. Records are handed out to the VUs in a round-robin fashion, so that no two
+// VUs of this instance use the same record until every record of the dataset has been used once.
+func (*K6) RecordIndex(ctx context.Context, length int64) (int64, error) {
+	state := lib.GetState(ctx)
+	if state == nil {
+		return 0, ErrRecordIndexInInitContext
+	}
+	if length <= 0 {
+		return 0, errors.New("recordIndex() requires a positive dataset length")
+	}
+
+	vus := state.Options.VUsMax.Int64
+	if vus < state.Options.VUs.Int64 {
+		vus = state.Options.VUs.Int64
+	}
+	if vus < state.Vu {
+		vus = state.Vu
+	}
+	vu := state.Vu - 1
+	if vu < 0 {
+		vu = 0
+	}
+	return (state.Iteration*vus + vu) % length, nil
+}
+
 func (*K6) Group(ctx context.Context, name string, fn goja.Callable) (goja.Value, error) {
 	state := lib.GetState(ctx)
 	if state == nil {
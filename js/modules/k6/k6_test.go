@@ -35,6 +35,7 @@ import (
 	"github.com/loadimpact/k6/lib/metrics"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
 )
 
 func TestFail(t *testing.T) {
@@ -107,6 +108,41 @@ func TestRandSeed(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestRecordIndex(t *testing.T) {
+	rt := goja.New()
+	baseCtx := common.WithRuntime(context.Background(), rt)
+	ctx := new(context.Context)
+	*ctx = baseCtx
+	rt.Set("k6", common.Bind(rt, New(), ctx))
+
+	t.Run("InitContext", func(t *testing.T) {
+		_, err := common.RunString(rt, `k6.recordIndex(10)`)
+		assert.Contains(t, err.Error(), ErrRecordIndexInInitContext.Error())
+	})
+
+	options := lib.Options{VUs: null.IntFrom(2), VUsMax: null.IntFrom(3)}
+	seen := map[int64]bool{}
+	for iter := int64(0); iter < 3; iter++ {
+		for vu := int64(1); vu <= 3; vu++ {
+			*ctx = lib.WithState(baseCtx, &lib.State{Options: options, Vu: vu, Iteration: iter})
+			v, err := common.RunString(rt, `k6.recordIndex(9)`)
+			require.NoError(t, err)
+			idx := v.ToInteger()
+			assert.False(t, seen[idx], "record %d was used twice", idx)
+			seen[idx] = true
+		}
+	}
+	assert.Len(t, seen, 9)
+
+	*ctx = lib.WithState(baseCtx, &lib.State{Options: options, Vu: 2, Iteration: 3})
+	v, err := common.RunString(rt, `k6.recordIndex(9)`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), v.ToInteger())
+
+	_, err = common.RunString(rt, `k6.recordIndex(0)`)
+	assert.Contains(t, err.Error(), "requires a positive dataset length")
+}
+
 func TestGroup(t *testing.T) {
 	root, err := lib.NewGroup("", nil)
 	assert.NoError(t, err)
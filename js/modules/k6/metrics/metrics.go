@@ -63,8 +63,13 @@ func newMetric(ctxPtr *context.Context, name string, t stats.MetricType, isTime
 		valueType = stats.Time
 	}
 
+	m := stats.New(name, t, valueType)
+	if registry := common.GetMetricsRegistry(*ctxPtr); registry != nil {
+		registry.Register(m)
+	}
+
 	rt := common.GetRuntime(*ctxPtr)
-	return common.Bind(rt, Metric{stats.New(name, t, valueType)}, ctxPtr), nil
+	return common.Bind(rt, Metric{m}, ctxPtr), nil
 }
 
 func (m Metric) Add(ctx context.Context, v goja.Value, addTags ...map[string]string) (bool, error) {
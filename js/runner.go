@@ -50,6 +50,7 @@ var errInterrupt = errors.New("context cancelled")
 
 // Ensure Runner implements the lib.Runner interface
 var _ lib.Runner = &Runner{}
+var _ lib.MetricsRunner = &Runner{}
 
 type Runner struct {
 	Bundle       *Bundle
@@ -263,6 +264,14 @@ func (r *Runner) GetDefaultGroup() *lib.Group {
 	return r.defaultGroup
 }
 
+// GetCustomMetrics returns the custom metrics declared by the script, sorted by name.
+func (r *Runner) GetCustomMetrics() []*stats.Metric {
+	if r.Bundle.Metrics == nil {
+		return nil
+	}
+	return r.Bundle.Metrics.All()
+}
+
 func (r *Runner) GetOptions() lib.Options {
 	return r.Bundle.Options
 }
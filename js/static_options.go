@@ -0,0 +1,370 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/parser"
+	"github.com/dop251/goja/token"
+	"github.com/loadimpact/k6/js/compiler"
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/loader"
+	"github.com/pkg/errors"
+)
+
+// ReadStaticOptions reads the exported options of a script without running it, so no files are
+// opened, no modules are imported and nothing is sent over the network. That only works when
+// the options are a literal, or a top-level variable with a literal value, e.g.:
+//
+//     export let options = { vus: 10, thresholds: { http_req_duration: ["p(95)<500"] } };
+//
+// Options that are computed at runtime, e.g. from environment variables, imported from other
+// modules, or modified anywhere in the script, can't be read this way and result in an error that
+// points to them, instead of a partial result.
+func ReadStaticOptions(src *loader.SourceData) (lib.Options, error) {
+	var opts lib.Options
+	filename := src.URL.String()
+	code := string(src.Data)
+	program, err := parser.ParseFile(nil, filename, code, 0)
+	if err != nil {
+		// Not ES5, so the script is transformed just like when it's compiled.
+		c, cerr := compiler.New()
+		if cerr != nil {
+			return opts, cerr
+		}
+		if code, _, err = c.Transform(code, filename); err != nil {
+			return opts, err
+		}
+		if program, err = parser.ParseFile(nil, filename, code, 0); err != nil {
+			return opts, err
+		}
+	}
+
+	r := &staticOptionsReader{
+		program: program,
+		vars:    make(map[string]ast.Expression),
+		used:    make(map[string]bool),
+		exports: make(map[*ast.AssignExpression]bool),
+	}
+	value, err := r.read()
+	if err != nil || value == nil {
+		return opts, err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return opts, err
+	}
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// staticOptionsReader finds the exported options in the top-level statements of an ES5 script,
+// after its transformation, and evaluates them if they are static.
+type staticOptionsReader struct {
+	program *ast.Program
+
+	// The initializers of the top-level variables, and the ones that the options are read from.
+	vars map[string]ast.Expression
+	used map[string]bool
+
+	// The value of the exported options, the top-level assignments to them, and the second one of
+	// those, if any.
+	options    ast.Expression
+	exported   bool
+	exports    map[*ast.AssignExpression]bool
+	reassigned ast.Expression
+}
+
+func (r *staticOptionsReader) read() (interface{}, error) {
+	for _, stmt := range r.program.Body {
+		switch stmt := stmt.(type) {
+		case *ast.VariableStatement:
+			for _, expr := range stmt.List {
+				if v, ok := expr.(*ast.VariableExpression); ok && v.Initializer != nil {
+					exported := r.exported
+					r.vars[v.Name] = r.findExport(v.Initializer)
+					if !exported && r.exported {
+						// e.g. var options = exports.options = {...}, where the variable may be
+						// modified later on.
+						r.options = &ast.Identifier{Name: v.Name, Idx: v.Idx}
+					}
+				}
+			}
+		case *ast.ExpressionStatement:
+			if err := r.findReExport(stmt.Expression); err != nil {
+				return nil, err
+			}
+			r.findExport(stmt.Expression)
+		}
+	}
+	if !r.exported {
+		return nil, nil
+	}
+	if r.reassigned != nil {
+		return nil, r.dynamicError(r.reassigned, "they are assigned more than once")
+	}
+	value, err := r.evaluate(r.options)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.findWrites(); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// findExport records the options if the expression is a (possibly chained) assignment to
+// exports.options, and returns the assigned value.
+func (r *staticOptionsReader) findExport(expr ast.Expression) ast.Expression {
+	assign, ok := expr.(*ast.AssignExpression)
+	if !ok || assign.Operator != token.ASSIGN {
+		return expr
+	}
+	value := r.findExport(assign.Right)
+	if !isExportedOptions(assign.Left) {
+		return value
+	}
+	r.exports[assign] = true
+	// With imports, Babel first declares the exports as undefined, e.g. exports.options = undefined.
+	if ident, ok := value.(*ast.Identifier); ok && ident.Name == "undefined" {
+		return value
+	}
+	if r.exported {
+		r.reassigned = assign
+	} else {
+		r.options = value
+	}
+	r.exported = true
+	return value
+}
+
+// findReExport returns an error for options that are re-exported from another module, which
+// Babel transforms into a getter defined with Object.defineProperty(exports, "options", ...).
+func (r *staticOptionsReader) findReExport(expr ast.Expression) error {
+	call, ok := expr.(*ast.CallExpression)
+	if !ok || len(call.ArgumentList) < 2 || !isDotExpression(call.Callee, "Object", "defineProperty") {
+		return nil
+	}
+	target, ok := call.ArgumentList[0].(*ast.Identifier)
+	if !ok || target.Name != "exports" {
+		return nil
+	}
+	if name, ok := call.ArgumentList[1].(*ast.StringLiteral); ok && name.Value == "options" {
+		return r.dynamicError(call, "they are imported from another module")
+	}
+	return nil
+}
+
+// evaluate returns the value of a literal expression, as the JSON-compatible Go value that
+// goja would export it as.
+func (r *staticOptionsReader) evaluate(expr ast.Expression) (interface{}, error) {
+	switch expr := expr.(type) {
+	case *ast.ObjectLiteral:
+		obj := make(map[string]interface{}, len(expr.Value))
+		for _, prop := range expr.Value {
+			if prop.Kind != "value" {
+				return nil, r.dynamicError(expr, "they contain a getter or a setter")
+			}
+			value, err := r.evaluate(prop.Value)
+			if err != nil {
+				return nil, err
+			}
+			obj[prop.Key] = value
+		}
+		return obj, nil
+	case *ast.ArrayLiteral:
+		arr := make([]interface{}, len(expr.Value))
+		for i, item := range expr.Value {
+			value, err := r.evaluate(item)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = value
+		}
+		return arr, nil
+	case *ast.StringLiteral:
+		return expr.Value, nil
+	case *ast.NumberLiteral:
+		return expr.Value, nil
+	case *ast.BooleanLiteral:
+		return expr.Value, nil
+	case *ast.NullLiteral:
+		return nil, nil
+	case *ast.UnaryExpression:
+		if num, ok := expr.Operand.(*ast.NumberLiteral); ok && expr.Operator == token.MINUS {
+			switch value := num.Value.(type) {
+			case int64:
+				return -value, nil
+			case float64:
+				return -value, nil
+			}
+		}
+	case *ast.Identifier:
+		value, ok := r.vars[expr.Name]
+		if !ok {
+			return nil, r.dynamicError(expr, "they depend on "+expr.Name)
+		}
+		r.used[expr.Name] = true
+		// The variable is removed while its value is evaluated, in case it refers to itself.
+		delete(r.vars, expr.Name)
+		defer func() { r.vars[expr.Name] = value }()
+		return r.evaluate(value)
+	}
+	return nil, r.dynamicError(expr, "they aren't a literal")
+}
+
+// findWrites returns an error for the first write anywhere in the script, e.g. in a block or a
+// function, to the exported options or to the variables they're read from, other than their
+// declarations and their top-level exports. That's any assignment to them or their properties,
+// and any call that's passed them or is one of their methods, e.g. Object.assign(options, {...})
+// or options.stages.push(...), since it could modify them.
+func (r *staticOptionsReader) findWrites() error {
+	var err error
+	walkAST(r.program, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.AssignExpression:
+			if !r.exports[n] && r.refersToOptions(n.Left) {
+				err = r.modifiedError(n, n.Left)
+			}
+		case *ast.UnaryExpression:
+			switch n.Operator {
+			case token.INCREMENT, token.DECREMENT, token.DELETE:
+				if r.refersToOptions(n.Operand) {
+					err = r.modifiedError(n, n.Operand)
+				}
+			}
+		case *ast.ForInStatement:
+			if r.refersToOptions(n.Into) {
+				err = r.modifiedError(n.Into, n.Into)
+			}
+		case *ast.CallExpression:
+			if dot, ok := n.Callee.(*ast.DotExpression); ok && r.refersToOptions(dot.Left) {
+				err = r.modifiedError(n, dot.Left)
+			}
+			err = r.findArgumentWrite(err, n, n.ArgumentList)
+		case *ast.NewExpression:
+			err = r.findArgumentWrite(err, n, n.ArgumentList)
+		}
+		return err == nil
+	})
+	return err
+}
+
+// findArgumentWrite returns the error for a call that's passed the options, unless there's
+// already an error.
+func (r *staticOptionsReader) findArgumentWrite(err error, call ast.Expression, args []ast.Expression) error {
+	if err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if r.refersToOptions(arg) {
+			return r.modifiedError(call, arg)
+		}
+	}
+	return nil
+}
+
+// refersToOptions returns whether the expression is the exported options, one of the variables
+// they're read from, or a property of those.
+func (r *staticOptionsReader) refersToOptions(expr ast.Expression) bool {
+	for {
+		if isExportedOptions(expr) {
+			return true
+		}
+		switch e := expr.(type) {
+		case *ast.Identifier:
+			return r.used[e.Name]
+		case *ast.DotExpression:
+			expr = e.Left
+		case *ast.BracketExpression:
+			expr = e.Left
+		default:
+			return false
+		}
+	}
+}
+
+// modifiedError returns the error for an expression that may modify the options through target.
+func (r *staticOptionsReader) modifiedError(expr, target ast.Expression) error {
+	if name := rootIdentifier(target); r.used[name] {
+		return r.dynamicError(expr, name+" is modified after it's declared")
+	}
+	return r.dynamicError(expr, "they are modified after they're exported")
+}
+
+// maxDynamicErrorSourceLength is the maximum length of the source shown in the dynamic errors.
+const maxDynamicErrorSourceLength = 60
+
+// dynamicError returns the error for options that are computed at runtime, showing the source of
+// the expression. It's shown instead of its line, since Babel may move the exports around.
+func (r *staticOptionsReader) dynamicError(expr ast.Expression, reason string) error {
+	f := r.program.File
+	source := ""
+	from, to := int(expr.Idx0())-f.Base(), int(expr.Idx1())-f.Base()
+	if unary, ok := expr.(*ast.UnaryExpression); ok && unary.Postfix {
+		// The postfix expressions start at their operator, e.g. the ++ of a++.
+		from = int(unary.Operand.Idx0()) - f.Base()
+	}
+	if from >= 0 && from <= to && to <= len(f.Source()) {
+		source = strings.Join(strings.Fields(f.Source()[from:to]), " ")
+	}
+	if len(source) > maxDynamicErrorSourceLength {
+		source = source[:maxDynamicErrorSourceLength-3] + "..."
+	}
+	return errors.Errorf("the options in %s can't be read without running the script, since %s: %s",
+		f.Name(), reason, source)
+}
+
+// isExportedOptions returns whether the expression is exports.options.
+func isExportedOptions(expr ast.Expression) bool {
+	return isDotExpression(expr, "exports", "options")
+}
+
+func isDotExpression(expr ast.Expression, left, name string) bool {
+	dot, ok := expr.(*ast.DotExpression)
+	if !ok || dot.Identifier.Name != name {
+		return false
+	}
+	ident, ok := dot.Left.(*ast.Identifier)
+	return ok && ident.Name == left
+}
+
+// rootIdentifier returns the name of the variable that an assignment target, like a.b["c"],
+// belongs to, or an empty string if there's none.
+func rootIdentifier(expr ast.Expression) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.Identifier:
+			return e.Name
+		case *ast.DotExpression:
+			expr = e.Left
+		case *ast.BracketExpression:
+			expr = e.Left
+		default:
+			return ""
+		}
+	}
+}
@@ -0,0 +1,144 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/loader"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestReadStaticOptions(t *testing.T) {
+	readOptions := func(t *testing.T, data string) (lib.Options, error) {
+		return ReadStaticOptions(&loader.SourceData{
+			URL:  &url.URL{Scheme: "file", Path: "/script.js"},
+			Data: []byte(data),
+		})
+	}
+
+	t.Run("Static", func(t *testing.T) {
+		testdata := map[string]string{
+			"ES6": `
+				import http from "k6/http";
+				export let options = {
+					vus: 10,
+					duration: "1m",
+					thresholds: { "http_req_duration": ["p(95)<500"] },
+				};
+				export default function() { http.get(open("./url.txt")); }`,
+			"ES6 variable": `
+				const thresholds = { "http_req_duration": ["p(95)<500"] };
+				const opts = { vus: 10, duration: "1m", thresholds: thresholds };
+				export { opts as options };
+				export default function() {}`,
+			"ES5": `
+				exports.options = { vus: 10, duration: "1m", thresholds: { "http_req_duration": ["p(95)<500"] } };
+				exports.default = function() {};`,
+		}
+		for name, data := range testdata {
+			t.Run(name, func(t *testing.T) {
+				opts, err := readOptions(t, data)
+				require.NoError(t, err)
+				assert.Equal(t, null.IntFrom(10), opts.VUs)
+				assert.Equal(t, types.NullDurationFrom(1*time.Minute), opts.Duration)
+				require.Contains(t, opts.Thresholds, "http_req_duration")
+				assert.Equal(t, "p(95)<500", opts.Thresholds["http_req_duration"].Thresholds[0].Source)
+			})
+		}
+	})
+
+	t.Run("None", func(t *testing.T) {
+		opts, err := readOptions(t, `export default function() {}`)
+		require.NoError(t, err)
+		assert.Equal(t, lib.Options{}, opts)
+	})
+
+	t.Run("Negative", func(t *testing.T) {
+		opts, err := readOptions(t, `exports.options = { batch: -1, discardResponseBodies: true, tags: null };`)
+		require.NoError(t, err)
+		assert.Equal(t, null.IntFrom(-1), opts.Batch)
+		assert.Equal(t, null.BoolFrom(true), opts.DiscardResponseBodies)
+	})
+
+	t.Run("Dynamic", func(t *testing.T) {
+		testdata := map[string]struct{ script, err string }{
+			"env": {
+				"export let options = {\n  vus: __ENV.VUS,\n};\nexport default function() {}",
+				"they aren't a literal: __ENV.VUS",
+			},
+			"call": {
+				"export let options = {\n  stages: makeStages(),\n};\nexport default function() {}",
+				"they aren't a literal: makeStages()",
+			},
+			"variable": {
+				"exports.options = { vus: vus };",
+				"they depend on vus: vus",
+			},
+			"modified": {
+				"export let options = { vus: 1 };\noptions.vus = 10;\nexport default function() {}",
+				"options is modified after it's declared: options.vus = 10",
+			},
+			"modified property": {
+				"exports.options = { vus: 1 };\nexports.options.vus = 10;",
+				"they are modified after they're exported: exports.options.vus = 10",
+			},
+			"modified in a block": {
+				"export let options = { vus: 1 };\nif (__ENV.MORE) {\n  options.vus = 10;\n}\nexport default function() {}",
+				"options is modified after it's declared: options.vus = 10",
+			},
+			"modified in a function": {
+				"const opts = { vus: 1 };\nexport { opts as options };\nfunction more() { opts.vus++; }\nmore();",
+				"opts is modified after it's declared: opts.vus++",
+			},
+			"assigned": {
+				"export let options = { vus: 1 };\nObject.assign(options, { duration: __ENV.DURATION });",
+				"options is modified after it's declared: Object.assign(options, { duration: __ENV.DURATION })",
+			},
+			"method": {
+				"export let options = { stages: [] };\noptions.stages.push({ duration: \"1m\", target: 10 });",
+				"options is modified after it's declared: options.stages.push(",
+			},
+			"reassigned": {
+				"exports.options = { vus: 1 };\nif (true) {}\nexports.options = { vus: 2 };",
+				"they are assigned more than once: exports.options = { vus: 2",
+			},
+			"imported": {
+				"export { options } from \"./options.js\";\nexport default function() {}",
+				"they are imported from another module: Object.defineProperty(exports, \"options\", {",
+			},
+		}
+		for name, data := range testdata {
+			t.Run(name, func(t *testing.T) {
+				_, err := readOptions(t, data.script)
+				require.Error(t, err)
+				assert.Contains(t, err.Error(),
+					"the options in file:///script.js can't be read without running the script, since "+data.err)
+			})
+		}
+	})
+}
@@ -23,6 +23,8 @@ package lib
 import (
 	"archive/tar"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -105,6 +107,47 @@ func (arc *Archive) getFs(name string) afero.Fs {
 	return fs
 }
 
+// SourceHash returns the hex-encoded SHA-256 hash of the sources of the test, i.e. of the main
+// script and of all of the files it loaded, with their anonymized paths. Unlike the archive
+// itself, it doesn't depend on the options, the environment or when the files were loaded, so
+// it only changes when the scripts or their data do.
+func (arc *Archive) SourceHash() (string, error) {
+	h := sha256.New()
+	_, _ = h.Write(arc.Data)
+	for _, name := range [...]string{"file", "https"} {
+		filesystem, ok := arc.Filesystems[name]
+		if !ok {
+			continue
+		}
+		if cachedfs, ok := filesystem.(fsext.CacheOnReadFs); ok {
+			filesystem = cachedfs.GetCachingFs()
+		}
+
+		// The files are hashed in a fixed order, and with their length, so that moving bytes from
+		// one file to the next changes the hash.
+		paths := make([]string, 0, 10)
+		files := make(map[string][]byte)
+		walkFunc := filepath.WalkFunc(func(filePath string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			normalizedPath := NormalizeAndAnonymizePath(filePath)
+			paths = append(paths, normalizedPath)
+			files[normalizedPath], err = afero.ReadFile(filesystem, filePath)
+			return err
+		})
+		if err := fsext.Walk(filesystem, afero.FilePathSeparator, walkFunc); err != nil {
+			return "", err
+		}
+		sort.Strings(paths)
+		for _, filePath := range paths {
+			_, _ = fmt.Fprintf(h, "\x00%s://%s\x00%d\x00", name, filePath, len(files[filePath]))
+			_, _ = h.Write(files[filePath])
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 // ReadArchive reads an archive created by Archive.Write from a reader.
 func ReadArchive(in io.Reader) (*Archive, error) {
 	r := tar.NewReader(in)
@@ -201,6 +244,48 @@ func ReadArchive(in io.Reader) (*Archive, error) {
 	return arc, nil
 }
 
+// Extract writes the files of all filesystems in the archive back to the supplied filesystem,
+// under dir, using the same layout as the archive itself: local files are written under
+// dir/file and remote ones under dir/https. The path of the main script is returned.
+func (arc *Archive) Extract(fs afero.Fs, dir string) (string, error) {
+	names := make([]string, 0, len(arc.Filesystems))
+	for name := range arc.Filesystems {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		filesystem := arc.Filesystems[name]
+		if cachedfs, ok := filesystem.(fsext.CacheOnReadFs); ok {
+			filesystem = cachedfs.GetCachingFs()
+		}
+		walkFunc := filepath.WalkFunc(func(filePath string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			data, err := afero.ReadFile(filesystem, filePath)
+			if err != nil {
+				return err
+			}
+			target := filepath.Join(dir, name, filepath.FromSlash(NormalizeAndAnonymizePath(filePath)))
+			if err = fs.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			return afero.WriteFile(fs, target, data, 0644)
+		})
+		if err := fsext.Walk(filesystem, afero.FilePathSeparator, walkFunc); err != nil {
+			return "", err
+		}
+	}
+
+	scheme, pathOnFs := getURLPathOnFs(arc.FilenameURL)
+	pathOnFs, err := url.PathUnescape(pathOnFs)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, scheme, filepath.FromSlash(pathOnFs)), nil
+}
+
 func normalizeAndAnonymizeURL(u *url.URL) {
 	if u.Scheme == "file" {
 		u.Path = NormalizeAndAnonymizePath(u.Path)
@@ -315,6 +315,41 @@ func TestArchiveWithDataNotInFS(t *testing.T) {
 	require.Contains(t, err.Error(), "the main script wasn't present in the cached filesystem")
 }
 
+func TestArchiveSourceHash(t *testing.T) {
+	t.Parallel()
+
+	newArchive := func(files map[string][]byte, env map[string]string) *Archive {
+		return &Archive{
+			Type:        "js",
+			FilenameURL: &url.URL{Scheme: "file", Path: "/path/to/a.js"},
+			Data:        files["/path/to/a.js"],
+			Env:         env,
+			Filesystems: map[string]afero.Fs{"file": makeMemMapFs(t, files)},
+		}
+	}
+	hash := func(arc *Archive) string {
+		h, err := arc.SourceHash()
+		require.NoError(t, err)
+		return h
+	}
+
+	files := map[string][]byte{
+		"/path/to/a.js":     []byte(`open("data.csv"); export default function() {}`),
+		"/path/to/data.csv": []byte("a,b"),
+	}
+	expected := hash(newArchive(files, nil))
+	assert.Len(t, expected, 64)
+	assert.Equal(t, expected, hash(newArchive(files, map[string]string{"FOO": "bar"})),
+		"the environment isn't part of the sources")
+
+	files["/path/to/data.csv"] = []byte("a,c")
+	assert.NotEqual(t, expected, hash(newArchive(files, nil)))
+
+	noFs := newArchive(files, nil)
+	noFs.Filesystems = nil
+	assert.NotEqual(t, expected, hash(noFs))
+}
+
 func TestMalformedMetadata(t *testing.T) {
 	var fs = afero.NewMemMapFs()
 	require.NoError(t, afero.WriteFile(fs, "/metadata.json", []byte("{,}"), 0644))
@@ -400,3 +435,43 @@ func TestStdinArchive(t *testing.T) {
 	require.Equal(t, string(data), "test")
 
 }
+
+func TestArchiveExtract(t *testing.T) {
+	arc := &Archive{
+		Type:        "js",
+		K6Version:   consts.Version,
+		FilenameURL: &url.URL{Scheme: "file", Path: "/path/to/a.js"},
+		Data:        []byte(`// a contents`),
+		PwdURL:      &url.URL{Scheme: "file", Path: "/path/to"},
+		Filesystems: map[string]afero.Fs{
+			"file": makeMemMapFs(t, map[string][]byte{
+				"/path/to/a.js":        []byte(`// a contents`),
+				"/path/to/lib/b.js":    []byte(`// b contents`),
+				"/path/to/data/f.json": []byte(`{}`),
+			}),
+			"https": makeMemMapFs(t, map[string][]byte{
+				"/cdnjs.com/libraries/Faker": []byte(`// faker contents`),
+			}),
+		},
+	}
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, arc.Write(buf))
+	readArc, err := ReadArchive(buf)
+	require.NoError(t, err)
+
+	fs := afero.NewMemMapFs()
+	mainScript, err := readArc.Extract(fs, "/out")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.FromSlash("/out/file/path/to/a.js"), mainScript)
+
+	for path, contents := range map[string]string{
+		"/out/file/path/to/a.js":               `// a contents`,
+		"/out/file/path/to/lib/b.js":           `// b contents`,
+		"/out/file/path/to/data/f.json":        `{}`,
+		"/out/https/cdnjs.com/libraries/Faker": `// faker contents`,
+	} {
+		data, err := afero.ReadFile(fs, filepath.FromSlash(path))
+		require.NoError(t, err, path)
+		assert.Equal(t, contents, string(data), path)
+	}
+}
@@ -22,8 +22,12 @@ package lib
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/loadimpact/k6/stats"
+	log "github.com/sirupsen/logrus"
 )
 
 // RunStatus values can be used by k6 to denote how a script run ends
@@ -46,6 +50,36 @@ const (
 	RunStatusAbortedThreshold   RunStatus = 8
 )
 
+// String returns the name of the run status, e.g. "aborted_threshold".
+func (rs RunStatus) String() string {
+	switch rs {
+	case RunStatusCreated:
+		return "created"
+	case RunStatusValidated:
+		return "validated"
+	case RunStatusQueued:
+		return "queued"
+	case RunStatusInitializing:
+		return "initializing"
+	case RunStatusRunning:
+		return "running"
+	case RunStatusFinished:
+		return "finished"
+	case RunStatusTimedOut:
+		return "timed_out"
+	case RunStatusAbortedUser:
+		return "aborted_user"
+	case RunStatusAbortedSystem:
+		return "aborted_system"
+	case RunStatusAbortedScriptError:
+		return "aborted_script_error"
+	case RunStatusAbortedThreshold:
+		return "aborted_threshold"
+	default:
+		return fmt.Sprintf("RunStatus(%d)", int(rs))
+	}
+}
+
 // A Collector abstracts the process of funneling samples to an external storage backend,
 // such as an InfluxDB instance.
 type Collector interface {
@@ -70,3 +104,209 @@ type Collector interface {
 	// Set run status
 	SetRunStatus(status RunStatus)
 }
+
+// A TestRunCollector is a Collector that creates a test run in its backend, e.g. the cloud
+// collector. The ID of the test run is shown in the end-of-test summary and in the logs,
+// so that local runs can easily be correlated with their results in the backend.
+type TestRunCollector interface {
+	Collector
+
+	// TestRunID returns the ID of the test run, or an empty string if it wasn't created.
+	TestRunID() string
+}
+
+// A SummaryCollector is a Collector that has something to add to the end-of-test summary, e.g.
+// where its results can be found and how much was written or uploaded.
+type SummaryCollector interface {
+	Collector
+
+	// SummaryLines returns the lines shown under the output in the summary, after the test has
+	// finished, or nothing if there's nothing to show.
+	SummaryLines() []string
+}
+
+// A FailingCollector is a Collector that reports when it gave up on delivering samples to its
+// backend, e.g. because a write still failed after all of its retries. With --strict-outputs,
+// such failures abort the test, so a passing test means that its results were delivered.
+type FailingCollector interface {
+	Collector
+
+	// DeliveryFailure returns the error of the last failed delivery, or nil if there was none.
+	DeliveryFailure() error
+}
+
+// A SampleFailuresCollector is a FailingCollector that also counts the samples it gave up on
+// delivering, so the diagnostics can tell how much was lost rather than just the last error.
+type SampleFailuresCollector interface {
+	FailingCollector
+
+	// FailedSamples returns the number of samples that weren't delivered.
+	FailedSamples() int64
+}
+
+// A BufferingCollector is a Collector that reports how many samples it has buffered and not yet
+// delivered to its backend, so that their number can be limited with --sample-buffer-limit.
+type BufferingCollector interface {
+	Collector
+
+	// BufferedSamples returns the number of samples that weren't delivered yet.
+	BufferedSamples() int
+}
+
+// A SpillingCollector is a BufferingCollector that can give up the samples it hasn't delivered yet,
+// so they can be saved elsewhere when k6 has to stop without flushing them.
+type SpillingCollector interface {
+	BufferingCollector
+
+	// SpillSamples takes the samples that weren't delivered yet out of the buffer and returns them,
+	// in the containers they were collected in, so the ones that are also buffered by other
+	// collectors can be told apart from equal samples.
+	SpillSamples() []stats.SampleContainer
+}
+
+// A FlushingCollector is a Collector that can deliver the samples it has buffered right away,
+// instead of waiting for its next regular flush, e.g. when that's requested through the REST API.
+type FlushingCollector interface {
+	Collector
+
+	// Flush delivers the buffered samples and returns once they're delivered. It must be safe to
+	// call while the collector is running and flushing on its own.
+	Flush() error
+}
+
+// A StatusCollector is a Collector that goes through phases worth showing to the user, e.g. while
+// it's flushing a large backlog of samples. Its status is shown in the progress bar.
+type StatusCollector interface {
+	Collector
+
+	// Status returns the current status, or an empty string if there's nothing to show.
+	Status() string
+}
+
+// A ReadyCollector is a Collector that can tell when its backend is ready to receive samples, e.g.
+// a database that's still starting up. With --wait-outputs-ready, the VUs aren't started until all
+// such collectors are ready, so the first samples of the test aren't lost.
+type ReadyCollector interface {
+	Collector
+
+	// WaitReady is called after Run() was started and blocks until the backend is ready, or
+	// until the context is done, in which case it returns why the backend isn't ready.
+	WaitReady(ctx context.Context) error
+}
+
+// A TagRequiringCollector is a Collector whose backend needs some tags on all samples, e.g. a team
+// tag for cost attribution. The test doesn't start if any of them isn't among the tags of the run,
+// instead of producing data that can't be attributed.
+type TagRequiringCollector interface {
+	Collector
+
+	// GetRequiredTags returns the keys of the tags the samples must have.
+	GetRequiredTags() []string
+}
+
+// A ThresholdsCollector is a Collector that also records the results of the thresholds every
+// time they're evaluated, giving a time series of their health rather than just the verdict.
+type ThresholdsCollector interface {
+	Collector
+
+	// CollectThresholdResults receives the results of one evaluation of the thresholds.
+	CollectThresholdResults(results []stats.ThresholdResult)
+}
+
+// A LabeledCollector is a Collector that's told the label of its output, e.g. "json (primary)" for
+// `-o json=out.json,name=primary`, so its logs tell it apart from other outputs of the same type.
+type LabeledCollector interface {
+	Collector
+
+	// SetLabel is called with the label of the output before Init().
+	SetLabel(label string)
+}
+
+// A StartTimeCollector is a Collector that's told when the test run started, e.g. to give the
+// samples times relative to it. That's after Init(), unless the output is only initialized when
+// the test fails.
+type StartTimeCollector interface {
+	Collector
+
+	// SetStartTime is called with the start time of the test run, before any samples of it are
+	// collected.
+	SetStartTime(t time.Time)
+}
+
+// CollectorFailures can be embedded in collectors to implement FailingCollector and
+// SampleFailuresCollector.
+type CollectorFailures struct {
+	mutex  sync.Mutex
+	err    error
+	failed int64
+}
+
+// SetDeliveryFailure records a failed delivery of samples.
+func (f *CollectorFailures) SetDeliveryFailure(err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.err = err
+}
+
+// RecordFailedSamples records a failed delivery of the given number of samples, which the
+// collector gave up on.
+func (f *CollectorFailures) RecordFailedSamples(err error, samples int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.err = err
+	f.failed += int64(samples)
+}
+
+// FailedSamples returns the number of samples that weren't delivered.
+func (f *CollectorFailures) FailedSamples() int64 {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.failed
+}
+
+// DeliveryFailure returns the error of the last failed delivery, or nil if there was none.
+func (f *CollectorFailures) DeliveryFailure() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.err
+}
+
+// CollectorLabel can be embedded in collectors to implement LabeledCollector.
+type CollectorLabel struct {
+	label string
+}
+
+// SetLabel sets the label of the output.
+func (l *CollectorLabel) SetLabel(label string) {
+	l.label = label
+}
+
+// Logger returns a logger that adds the label of the output to the entries as their "output"
+// field, if the label was set.
+func (l *CollectorLabel) Logger() *log.Entry {
+	if l.label == "" {
+		return log.NewEntry(log.StandardLogger())
+	}
+	return log.WithField("output", l.label)
+}
+
+// TransientStatus can be embedded in executors and collectors to implement StatusExecutor and
+// StatusCollector.
+type TransientStatus struct {
+	mutex  sync.Mutex
+	status string
+}
+
+// SetStatus sets the status shown in the progress bar, an empty one clears it.
+func (s *TransientStatus) SetStatus(status string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.status = status
+}
+
+// Status returns the current status, or an empty string if it isn't set.
+func (s *TransientStatus) Status() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.status
+}
@@ -8,6 +8,11 @@ import (
 //nolint:gochecknoglobals
 var Version = "0.25.2-dev"
 
+// BuildDate is when k6 was built, in RFC 3339 format. It's empty unless it's set at build time,
+// e.g. with -ldflags "-X github.com/loadimpact/k6/lib/consts.BuildDate=$(date -u +%FT%TZ)".
+//nolint:gochecknoglobals
+var BuildDate = ""
+
 // Banner contains the ASCII-art banner with the k6 logo and stylized website URL
 //TODO: make these into methods, only the version needs to be a variable
 //nolint:gochecknoglobals
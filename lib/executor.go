@@ -49,9 +49,11 @@ type Executor interface {
 	GetLogger() *log.Logger
 	SetLogger(l *log.Logger)
 
-	// Get and set the list of stages.
+	// Get and set the list of stages, and get the index of the stage that's currently running,
+	// or false if there are no stages or they have all ended.
 	GetStages() []Stage
 	SetStages(s []Stage)
+	GetCurrentStage() (int, bool)
 
 	// Get iterations executed so far, get and set how many to end the test after.
 	GetIterations() int64
@@ -85,3 +87,12 @@ type Executor interface {
 	SetRunSetup(r bool)
 	SetRunTeardown(r bool)
 }
+
+// A StatusExecutor is an Executor that goes through phases worth showing to the user, e.g.
+// "warming up" or "draining". Its status is shown in the progress bar instead of the default one.
+type StatusExecutor interface {
+	Executor
+
+	// Status returns the current status, or an empty string to show the default one.
+	Status() string
+}
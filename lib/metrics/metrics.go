@@ -60,3 +60,15 @@ var (
 	DataSent     = stats.New("data_sent", stats.Counter, stats.Data)
 	DataReceived = stats.New("data_received", stats.Counter, stats.Data)
 )
+
+// Builtin returns all of the built-in metrics, emitted by k6 itself.
+func Builtin() []*stats.Metric {
+	return []*stats.Metric{
+		VUs, VUsMax, Iterations, IterationDuration, Errors,
+		Checks, GroupDuration,
+		HTTPReqs, HTTPReqDuration, HTTPReqBlocked, HTTPReqConnecting, HTTPReqTLSHandshaking,
+		HTTPReqSending, HTTPReqWaiting, HTTPReqReceiving,
+		WSSessions, WSMessagesSent, WSMessagesReceived, WSPing, WSSessionDuration, WSConnecting,
+		DataSent, DataReceived,
+	}
+}
@@ -0,0 +1,142 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// CachingDialer dials hosts by the addresses they resolved to within the last TTL, instead of
+// resolving them for every new connection, e.g. for the connections of an output to its ingest
+// host. When resolving an expired host fails, its old addresses keep being used, so long-running
+// tests aren't affected by transient DNS outages.
+type CachingDialer struct {
+	Dialer *net.Dialer
+	TTL    time.Duration
+
+	lookup func(ctx context.Context, host string) ([]net.IPAddr, error)
+	now    func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedAddrs
+}
+
+type cachedAddrs struct {
+	addrs    []net.IPAddr
+	resolved time.Time
+}
+
+// NewCachingDialer returns a CachingDialer that dials with the given dialer and keeps the
+// resolved addresses for the given TTL.
+func NewCachingDialer(dialer *net.Dialer, ttl time.Duration) *CachingDialer {
+	return &CachingDialer{
+		Dialer: dialer,
+		TTL:    ttl,
+		lookup: net.DefaultResolver.LookupIPAddr,
+		now:    time.Now,
+		cache:  make(map[string]cachedAddrs),
+	}
+}
+
+// DialContext connects to the address, dialing all of the cached addresses of its host at once
+// and keeping the first connection that's made, so unreachable addresses, e.g. of an address
+// family the network doesn't route, don't hold it up.
+func (d *CachingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) != nil {
+		return d.Dialer.DialContext(ctx, network, addr)
+	}
+
+	addrs, err := d.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 1 {
+		return d.Dialer.DialContext(ctx, network, net.JoinHostPort(addrs[0].String(), port))
+	}
+
+	type dialResult struct {
+		conn net.Conn
+		err  error
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan dialResult, len(addrs))
+	for _, ip := range addrs {
+		go func(ip net.IPAddr) {
+			conn, err := d.Dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+			results <- dialResult{conn, err}
+		}(ip)
+	}
+	var firstErr error
+	for remaining := len(addrs); remaining > 0; remaining-- {
+		result := <-results
+		if result.err == nil {
+			// The dials that are still running are canceled, the ones that made it anyway are closed.
+			go func(remaining int) {
+				for ; remaining > 0; remaining-- {
+					if result := <-results; result.conn != nil {
+						_ = result.conn.Close()
+					}
+				}
+			}(remaining - 1)
+			return result.conn, nil
+		}
+		if firstErr == nil {
+			firstErr = result.err
+		}
+	}
+	return nil, firstErr
+}
+
+// resolve returns the addresses of the host, from the cache if they aren't older than the TTL.
+func (d *CachingDialer) resolve(ctx context.Context, host string) ([]net.IPAddr, error) {
+	d.mu.Lock()
+	cached, ok := d.cache[host]
+	d.mu.Unlock()
+	if ok && d.now().Sub(cached.resolved) < d.TTL {
+		return cached.addrs, nil
+	}
+
+	addrs, err := d.lookup(ctx, host)
+	if err == nil && len(addrs) == 0 {
+		err = &net.DNSError{Err: "no such host", Name: host}
+	}
+	if err != nil {
+		if !ok {
+			return nil, err
+		}
+		// The old addresses are kept for another TTL, instead of resolving the host again
+		// for every new connection while it can't be resolved.
+		addrs = cached.addrs
+	}
+
+	d.mu.Lock()
+	d.cache[host] = cachedAddrs{addrs: addrs, resolved: d.now()}
+	d.mu.Unlock()
+	return addrs, nil
+}
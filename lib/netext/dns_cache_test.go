@@ -0,0 +1,125 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachingDialer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = listener.Close() }()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+
+	now := time.Unix(0, 0)
+	lookups := 0
+	var lookupErr error
+	d := NewCachingDialer(&net.Dialer{Timeout: time.Second}, time.Minute)
+	d.now = func() time.Time { return now }
+	d.lookup = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		assert.Equal(t, "ingest.example.com", host)
+		lookups++
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+		return []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}, nil
+	}
+
+	dial := func() error {
+		conn, err := d.DialContext(context.Background(), "tcp", net.JoinHostPort("ingest.example.com", port))
+		if err == nil {
+			_ = conn.Close()
+		}
+		return err
+	}
+
+	require.NoError(t, dial())
+	require.NoError(t, dial())
+	assert.Equal(t, 1, lookups)
+
+	now = now.Add(2 * time.Minute)
+	require.NoError(t, dial())
+	assert.Equal(t, 2, lookups)
+
+	now = now.Add(2 * time.Minute)
+	lookupErr = errors.New("dns outage")
+	require.NoError(t, dial())
+	assert.Equal(t, 3, lookups)
+	// The old addresses are kept for another TTL.
+	require.NoError(t, dial())
+	assert.Equal(t, 3, lookups)
+
+	delete(d.cache, "ingest.example.com")
+	assert.EqualError(t, dial(), "dns outage")
+
+	conn, err := d.DialContext(context.Background(), "tcp", listener.Addr().String())
+	require.NoError(t, err)
+	_ = conn.Close()
+	assert.Equal(t, 4, lookups)
+}
+
+func TestCachingDialerParallel(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = listener.Close() }()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+
+	d := NewCachingDialer(&net.Dialer{Timeout: 10 * time.Second}, time.Minute)
+	d.lookup = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		// The first address is reserved for documentation, so it's never reached.
+		return []net.IPAddr{{IP: net.ParseIP("192.0.2.1")}, {IP: net.ParseIP("127.0.0.1")}}, nil
+	}
+
+	start := time.Now()
+	conn, err := d.DialContext(context.Background(), "tcp", net.JoinHostPort("ingest.example.com", port))
+	require.NoError(t, err)
+	_ = conn.Close()
+	assert.True(t, time.Since(start) < 5*time.Second, "waited for the unreachable address")
+	assert.Equal(t, listener.Addr().String(), conn.RemoteAddr().String())
+}
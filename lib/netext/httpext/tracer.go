@@ -28,6 +28,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/lib/metrics"
 	"github.com/loadimpact/k6/stats"
 )
@@ -61,19 +62,29 @@ type Trail struct {
 	Samples []stats.Sample
 }
 
-// SaveSamples populates the Trail's sample slice so they're accesible via GetSamples()
-func (tr *Trail) SaveSamples(tags *stats.SampleTags) {
+// SaveSamples populates the Trail's sample slice so they're accesible via GetSamples(), without
+// the samples of the disabled metrics.
+func (tr *Trail) SaveSamples(tags *stats.SampleTags, disabled lib.TagSet) {
 	tr.Tags = tags
-	tr.Samples = []stats.Sample{
-		{Metric: metrics.HTTPReqs, Time: tr.EndTime, Tags: tags, Value: 1},
-		{Metric: metrics.HTTPReqDuration, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.Duration)},
-
-		{Metric: metrics.HTTPReqBlocked, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.Blocked)},
-		{Metric: metrics.HTTPReqConnecting, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.Connecting)},
-		{Metric: metrics.HTTPReqTLSHandshaking, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.TLSHandshaking)},
-		{Metric: metrics.HTTPReqSending, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.Sending)},
-		{Metric: metrics.HTTPReqWaiting, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.Waiting)},
-		{Metric: metrics.HTTPReqReceiving, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.Receiving)},
+	tr.Samples = make([]stats.Sample, 0, 8)
+	for _, s := range []struct {
+		metric *stats.Metric
+		value  float64
+	}{
+		{metrics.HTTPReqs, 1},
+		{metrics.HTTPReqDuration, stats.D(tr.Duration)},
+
+		{metrics.HTTPReqBlocked, stats.D(tr.Blocked)},
+		{metrics.HTTPReqConnecting, stats.D(tr.Connecting)},
+		{metrics.HTTPReqTLSHandshaking, stats.D(tr.TLSHandshaking)},
+		{metrics.HTTPReqSending, stats.D(tr.Sending)},
+		{metrics.HTTPReqWaiting, stats.D(tr.Waiting)},
+		{metrics.HTTPReqReceiving, stats.D(tr.Receiving)},
+	} {
+		if disabled[s.metric.Name] {
+			continue
+		}
+		tr.Samples = append(tr.Samples, stats.Sample{Metric: s.metric, Time: tr.EndTime, Tags: tags, Value: s.value})
 	}
 }
 
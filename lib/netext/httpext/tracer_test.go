@@ -35,6 +35,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/lib/metrics"
 	"github.com/loadimpact/k6/lib/netext"
 	"github.com/loadimpact/k6/stats"
@@ -83,7 +84,7 @@ func TestTracer(t *testing.T) {
 			assert.NoError(t, err)
 			assert.NoError(t, res.Body.Close())
 			trail := tracer.Done()
-			trail.SaveSamples(stats.IntoSampleTags(&map[string]string{"tag": "value"}))
+			trail.SaveSamples(stats.IntoSampleTags(&map[string]string{"tag": "value"}), nil)
 			samples := trail.GetSamples()
 
 			assert.Empty(t, tracer.protoErrors)
@@ -184,12 +185,27 @@ func TestTracerNegativeHttpSendingValues(t *testing.T) {
 		assert.NoError(t, err)
 		assert.NoError(t, res.Body.Close())
 		trail := tracer.Done()
-		trail.SaveSamples(nil)
+		trail.SaveSamples(nil, nil)
 
 		require.True(t, trail.Sending > 0)
 	}
 }
 
+func TestTrailSaveSamplesDisabled(t *testing.T) {
+	trail := &Trail{EndTime: time.Now(), Duration: time.Second, Blocked: time.Millisecond}
+	trail.SaveSamples(nil, nil)
+	assert.Len(t, trail.GetSamples(), 8)
+
+	trail.SaveSamples(nil, lib.GetTagSet("http_req_blocked", "http_req_connecting", "http_req_tls_handshaking"))
+	names := []string{}
+	for _, s := range trail.GetSamples() {
+		names = append(names, s.Metric.Name)
+	}
+	assert.Equal(t, []string{
+		"http_reqs", "http_req_duration", "http_req_sending", "http_req_waiting", "http_req_receiving",
+	}, names)
+}
+
 func TestTracerError(t *testing.T) {
 	t.Parallel()
 	srv := httptest.NewTLSServer(httpbin.New().Handler())
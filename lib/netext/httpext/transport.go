@@ -143,7 +143,7 @@ func (t *transport) measureAndEmitMetrics(unfReq *unfinishedRequest) *finishedRe
 		}
 	}
 
-	trail.SaveSamples(stats.IntoSampleTags(&tags))
+	trail.SaveSamples(stats.IntoSampleTags(&tags), t.state.Options.DisabledMetrics)
 	stats.PushIfNotCancelled(unfReq.ctx, t.state.Samples, trail)
 
 	return result
@@ -42,7 +42,7 @@ import (
 const DefaultSchedulerName = "default"
 
 // DefaultSystemTagList includes all of the system tags emitted with metrics by default.
-// Other tags that are not enabled by default include: iter, vu, ocsp_status, ip
+// Other tags that are not enabled by default include: iter, vu, ocsp_status, ip, stage
 var DefaultSystemTagList = []string{
 
 	"proto", "subproto", "status", "method", "url", "name", "group", "check", "error", "error_code", "tls_version",
@@ -320,9 +320,50 @@ type Options struct {
 	// Summary time unit for summary metrics (response times) in CLI output
 	SummaryTimeUnit null.String `json:"summaryTimeUnit" envconfig:"summary_time_unit"`
 
+	// If set, the values in the CLI summary are shown with this many decimal places. The exported
+	// summaries and the REST API keep the full precision.
+	SummaryPrecision null.Int `json:"summaryPrecision" envconfig:"summary_precision"`
+
+	// The number of sub-groups per group and submetrics per metric shown in the CLI summary, the
+	// ones with the most samples first, with the rest folded into one line. 0 shows all of them.
+	SummaryMaxEntries null.Int `json:"summaryMaxEntries" envconfig:"summary_max_entries"`
+
+	// If set, trend metrics keep at most this many values, selected by reservoir sampling,
+	// and their median and percentiles are approximated from them
+	TrendReservoirSize null.Int `json:"trendReservoirSize" envconfig:"trend_reservoir_size"`
+
+	// The seed of the reservoir sampling of the trend metrics, so the sampled values of a run
+	// can be reproduced. If it isn't set, every run samples different values
+	TrendReservoirSeed null.Int `json:"trendReservoirSeed" envconfig:"trend_reservoir_seed"`
+
+	// If set, the end-of-test summary is also exported as JSON to this file
+	SummaryExport null.String `json:"summaryExport" envconfig:"summary_export"`
+
+	// If set, the end-of-test summary is also written as JUnit XML to this file, with a test case
+	// for every threshold and the summary of the metrics as the output of the test suite
+	SummaryJUnit null.String `json:"summaryJUnit" envconfig:"summary_junit"`
+
+	// If set, the distributions of the trend metrics are also written to this file as an
+	// HdrHistogram log, with a histogram for every metric
+	SummaryHDR null.String `json:"summaryHDR" envconfig:"summary_hdr"`
+
+	// If set, a chart of the key trend metrics over the test is also written to this file as SVG
+	SummarySVG null.String `json:"summarySVG" envconfig:"summary_svg"`
+
+	// The maximum number of raw values of each trend metric included in the exported summary
+	SummaryTrendValues null.Int `json:"summaryTrendValues" envconfig:"summary_trend_values"`
+
+	// Whether the exported summary includes the data needed to merge it with the summaries of
+	// the other parts of a distributed test, like the histograms of the trend metrics
+	SummaryMergeData null.Bool `json:"summaryMergeData" envconfig:"summary_merge_data"`
+
 	// Which system tags to include with metrics ("method", "vu" etc.)
 	SystemTags TagSet `json:"systemTags" envconfig:"system_tags"`
 
+	// Built-in metrics that are neither sampled nor processed, to reduce the overhead of the
+	// ones that aren't needed, e.g. the detailed HTTP timings
+	DisabledMetrics TagSet `json:"disabledMetrics" envconfig:"disabled_metrics"`
+
 	// Tags to be applied to all samples for this running
 	RunTags *stats.SampleTags `json:"tags" envconfig:"tags"`
 
@@ -465,9 +506,42 @@ func (o Options) Apply(opts Options) Options {
 	if opts.SummaryTimeUnit.Valid {
 		o.SummaryTimeUnit = opts.SummaryTimeUnit
 	}
+	if opts.SummaryPrecision.Valid {
+		o.SummaryPrecision = opts.SummaryPrecision
+	}
+	if opts.SummaryMaxEntries.Valid {
+		o.SummaryMaxEntries = opts.SummaryMaxEntries
+	}
+	if opts.TrendReservoirSize.Valid {
+		o.TrendReservoirSize = opts.TrendReservoirSize
+	}
+	if opts.TrendReservoirSeed.Valid {
+		o.TrendReservoirSeed = opts.TrendReservoirSeed
+	}
+	if opts.SummaryExport.Valid {
+		o.SummaryExport = opts.SummaryExport
+	}
+	if opts.SummaryJUnit.Valid {
+		o.SummaryJUnit = opts.SummaryJUnit
+	}
+	if opts.SummaryHDR.Valid {
+		o.SummaryHDR = opts.SummaryHDR
+	}
+	if opts.SummarySVG.Valid {
+		o.SummarySVG = opts.SummarySVG
+	}
+	if opts.SummaryTrendValues.Valid {
+		o.SummaryTrendValues = opts.SummaryTrendValues
+	}
+	if opts.SummaryMergeData.Valid {
+		o.SummaryMergeData = opts.SummaryMergeData
+	}
 	if opts.SystemTags != nil {
 		o.SystemTags = opts.SystemTags
 	}
+	if opts.DisabledMetrics != nil {
+		o.DisabledMetrics = opts.DisabledMetrics
+	}
 	if !opts.RunTags.IsEmpty() {
 		o.RunTags = opts.RunTags
 	}
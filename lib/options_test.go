@@ -396,6 +396,57 @@ func TestOptions(t *testing.T) {
 		opts := Options{}.Apply(Options{RunTags: tags})
 		assert.Equal(t, tags, opts.RunTags)
 	})
+	t.Run("SummaryExport", func(t *testing.T) {
+		opts := Options{}.Apply(Options{SummaryExport: null.StringFrom("summary.json")})
+		assert.True(t, opts.SummaryExport.Valid)
+		assert.Equal(t, "summary.json", opts.SummaryExport.String)
+	})
+	t.Run("SummaryJUnit", func(t *testing.T) {
+		opts := Options{}.Apply(Options{SummaryJUnit: null.StringFrom("junit.xml")})
+		assert.True(t, opts.SummaryJUnit.Valid)
+		assert.Equal(t, "junit.xml", opts.SummaryJUnit.String)
+	})
+	t.Run("SummaryHDR", func(t *testing.T) {
+		opts := Options{}.Apply(Options{SummaryHDR: null.StringFrom("summary.hlog")})
+		assert.True(t, opts.SummaryHDR.Valid)
+		assert.Equal(t, "summary.hlog", opts.SummaryHDR.String)
+	})
+	t.Run("SummarySVG", func(t *testing.T) {
+		opts := Options{}.Apply(Options{SummarySVG: null.StringFrom("summary.svg")})
+		assert.True(t, opts.SummarySVG.Valid)
+		assert.Equal(t, "summary.svg", opts.SummarySVG.String)
+	})
+	t.Run("DisabledMetrics", func(t *testing.T) {
+		opts := Options{}.Apply(Options{DisabledMetrics: GetTagSet("http_req_blocked")})
+		assert.Equal(t, TagSet{"http_req_blocked": true}, opts.DisabledMetrics)
+		opts = opts.Apply(Options{})
+		assert.Equal(t, TagSet{"http_req_blocked": true}, opts.DisabledMetrics)
+	})
+	t.Run("SummaryMaxEntries", func(t *testing.T) {
+		opts := Options{}.Apply(Options{SummaryMaxEntries: null.IntFrom(5)})
+		assert.True(t, opts.SummaryMaxEntries.Valid)
+		assert.Equal(t, int64(5), opts.SummaryMaxEntries.Int64)
+	})
+	t.Run("SummaryTrendValues", func(t *testing.T) {
+		opts := Options{}.Apply(Options{SummaryTrendValues: null.IntFrom(500)})
+		assert.True(t, opts.SummaryTrendValues.Valid)
+		assert.Equal(t, int64(500), opts.SummaryTrendValues.Int64)
+	})
+	t.Run("SummaryMergeData", func(t *testing.T) {
+		opts := Options{}.Apply(Options{SummaryMergeData: null.BoolFrom(true)})
+		assert.True(t, opts.SummaryMergeData.Valid)
+		assert.True(t, opts.SummaryMergeData.Bool)
+	})
+	t.Run("TrendReservoirSize", func(t *testing.T) {
+		opts := Options{}.Apply(Options{TrendReservoirSize: null.IntFrom(1000)})
+		assert.True(t, opts.TrendReservoirSize.Valid)
+		assert.Equal(t, int64(1000), opts.TrendReservoirSize.Int64)
+	})
+	t.Run("TrendReservoirSeed", func(t *testing.T) {
+		opts := Options{}.Apply(Options{TrendReservoirSeed: null.IntFrom(42)})
+		assert.True(t, opts.TrendReservoirSeed.Valid)
+		assert.Equal(t, int64(42), opts.TrendReservoirSeed.Int64)
+	})
 	t.Run("DiscardResponseBodies", func(t *testing.T) {
 		opts := Options{}.Apply(Options{DiscardResponseBodies: null.BoolFrom(true)})
 		assert.True(t, opts.DiscardResponseBodies.Valid)
@@ -68,6 +68,15 @@ type Runner interface {
 	SetOptions(opts Options) error
 }
 
+// A MetricsRunner is a Runner that knows which custom metrics its script declares, even before
+// any samples for them were emitted.
+type MetricsRunner interface {
+	Runner
+
+	// Returns the custom metrics declared by the script, sorted by name.
+	GetCustomMetrics() []*stats.Metric
+}
+
 // A VU is a Virtual User, that can be scheduled by an Executor.
 type VU interface {
 	// Runs the VU once. The VU is responsible for handling the Halting Problem, eg. making sure
@@ -20,7 +20,10 @@
 
 package lib
 
-import null "gopkg.in/guregu/null.v3"
+import (
+	"github.com/loadimpact/k6/lib/types"
+	null "gopkg.in/guregu/null.v3"
+)
 
 // RuntimeOptions are settings passed onto the goja JS runtime
 type RuntimeOptions struct {
@@ -29,6 +32,15 @@ type RuntimeOptions struct {
 
 	// Environment variables passed onto the runner
 	Env map[string]string `json:"env" envconfig:"env"`
+
+	// How many times remote modules and files are fetched before giving up and how long to
+	// wait before the first retry, the wait is doubled for every subsequent one
+	ModuleFetchAttempts null.Int           `json:"moduleFetchAttempts" envconfig:"module_fetch_attempts"`
+	ModuleFetchBackoff  types.NullDuration `json:"moduleFetchBackoff" envconfig:"module_fetch_backoff"`
+
+	// Whether to check that all the built-in modules the script imports are in this build of
+	// k6 before running its init code
+	CheckModules null.Bool `json:"checkModules" envconfig:"check_modules"`
 }
 
 // Apply overwrites the receiver RuntimeOptions' fields with any that are set
@@ -40,5 +52,14 @@ func (o RuntimeOptions) Apply(opts RuntimeOptions) RuntimeOptions {
 	if opts.Env != nil {
 		o.Env = opts.Env
 	}
+	if opts.ModuleFetchAttempts.Valid {
+		o.ModuleFetchAttempts = opts.ModuleFetchAttempts
+	}
+	if opts.ModuleFetchBackoff.Valid {
+		o.ModuleFetchBackoff = opts.ModuleFetchBackoff
+	}
+	if opts.CheckModules.Valid {
+		o.CheckModules = opts.CheckModules
+	}
 	return o
 }
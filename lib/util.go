@@ -22,6 +22,7 @@ package lib
 
 import (
 	"strings"
+	"time"
 
 	"github.com/loadimpact/k6/lib/types"
 )
@@ -37,6 +38,22 @@ func SumStages(stages []Stage) (d types.NullDuration) {
 	return d
 }
 
+// StageAt returns the index of the stage that runs at the given time, or false if all of the
+// stages have ended by then. A stage without a duration runs forever.
+func StageAt(stages []Stage, t time.Duration) (int, bool) {
+	var end time.Duration
+	for i, stage := range stages {
+		if !stage.Duration.Valid {
+			return i, true
+		}
+		end += time.Duration(stage.Duration.Duration)
+		if t <= end {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
 // Splits a string in the form "key=value".
 func SplitKV(s string) (key, value string) {
 	parts := strings.SplitN(s, "=", 2)
@@ -60,6 +60,35 @@ func TestSumStages(t *testing.T) {
 	}
 }
 
+func TestStageAt(t *testing.T) {
+	stages := []Stage{
+		{Duration: types.NullDurationFrom(5 * time.Second)},
+		{Duration: types.NullDurationFrom(5 * time.Second)},
+	}
+	testdata := map[time.Duration]struct {
+		Index int
+		OK    bool
+	}{
+		0:                {0, true},
+		5 * time.Second:  {0, true},
+		6 * time.Second:  {1, true},
+		10 * time.Second: {1, true},
+		11 * time.Second: {0, false},
+	}
+	for at, data := range testdata {
+		index, ok := StageAt(stages, at)
+		assert.Equal(t, data.Index, index, at)
+		assert.Equal(t, data.OK, ok, at)
+	}
+
+	index, ok := StageAt(append(stages, Stage{}), time.Hour)
+	assert.True(t, ok)
+	assert.Equal(t, 2, index)
+
+	_, ok = StageAt(nil, 0)
+	assert.False(t, ok)
+}
+
 func TestSplitKV(t *testing.T) {
 	testdata := map[string]struct {
 		k string
@@ -21,6 +21,7 @@
 package loader
 
 import (
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -66,6 +67,51 @@ var (
 	errNoLoaderMatched = errors.New("no loader matched")
 )
 
+// FetchRetryPolicy configures the retries of the failed fetches of remote modules and files,
+// e.g. because of a flaky network during the init. Only network errors and server-side
+// errors are retried, with an exponential backoff. By default, fetches aren't retried.
+type FetchRetryPolicy struct {
+	// The total number of fetch attempts, values lower than 1 are treated as 1.
+	Attempts int
+	// How long to wait before the first retry, doubled for every subsequent one.
+	Backoff time.Duration
+}
+
+// DefaultFetchRetryPolicy returns the default policy, with which failed fetches aren't retried.
+func DefaultFetchRetryPolicy() FetchRetryPolicy {
+	return FetchRetryPolicy{Attempts: 1, Backoff: 1 * time.Second}
+}
+
+//nolint: gochecknoglobals
+var fetchRetryPolicy = DefaultFetchRetryPolicy()
+
+// SetFetchRetryPolicy sets the retry policy for all subsequent remote fetches. It's not safe
+// to call it while something is being loaded.
+func SetFetchRetryPolicy(policy FetchRetryPolicy) {
+	fetchRetryPolicy = policy
+}
+
+// fetchStatusError is returned when a remote fetch gets an unexpected HTTP status code.
+type fetchStatusError struct {
+	url        string
+	statusCode int
+}
+
+func (e fetchStatusError) Error() string {
+	if e.statusCode == http.StatusNotFound {
+		return "not found: " + e.url
+	}
+	return fmt.Sprintf("wrong status code (%d) for: %s", e.statusCode, e.url)
+}
+
+// isRetriableFetchError returns whether a failed fetch can succeed if it's retried.
+func isRetriableFetchError(err error) bool {
+	if statusErr, ok := err.(fetchStatusError); ok {
+		return statusErr.statusCode >= 500 || statusErr.statusCode == http.StatusTooManyRequests
+	}
+	return true
+}
+
 // Resolve a relative path to an absolute one.
 func Resolve(pwd *url.URL, moduleSpecifier string) (*url.URL, error) {
 	if moduleSpecifier == "" {
@@ -260,7 +306,27 @@ func pickLoader(path string) (string, loaderFunc, []string) {
 	return "", nil, nil
 }
 
+// fetch fetches the given URL, retrying failed attempts according to the fetch retry policy.
+// If all attempts fail, the error of the last one is returned.
 func fetch(u string) ([]byte, error) {
+	policy := fetchRetryPolicy
+	backoff := policy.Backoff
+	for attempt := 1; ; attempt++ {
+		data, err := fetchOnce(u)
+		if err == nil || attempt >= policy.Attempts || !isRetriableFetchError(err) {
+			return data, err
+		}
+		log.WithError(err).WithFields(log.Fields{
+			"url":     u,
+			"attempt": attempt,
+			"backoff": backoff,
+		}).Warn("Fetching source failed, retrying...")
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func fetchOnce(u string) ([]byte, error) {
 	log.WithField("url", u).Debug("Fetching source...")
 	startTime := time.Now()
 	res, err := http.Get(u)
@@ -270,12 +336,7 @@ func fetch(u string) ([]byte, error) {
 	defer func() { _ = res.Body.Close() }()
 
 	if res.StatusCode != 200 {
-		switch res.StatusCode {
-		case 404:
-			return nil, errors.Errorf("not found: %s", u)
-		default:
-			return nil, errors.Errorf("wrong status code (%d) for: %s", res.StatusCode, u)
-		}
+		return nil, fetchStatusError{url: u, statusCode: res.StatusCode}
 	}
 
 	data, err := ioutil.ReadAll(res.Body)
@@ -25,7 +25,9 @@ import (
 	"net/http"
 	"net/url"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/loadimpact/k6/lib/testutils"
 	"github.com/loadimpact/k6/loader"
@@ -261,3 +263,64 @@ func TestLoad(t *testing.T) {
 		}
 	})
 }
+
+func TestFetchRetries(t *testing.T) {
+	tb := testutils.NewHTTPMultiBin(t)
+	defer tb.Cleanup()
+	sr := tb.Replacer.Replace
+	oldHTTPTransport := http.DefaultTransport
+	http.DefaultTransport = tb.HTTPTransport
+	defer func() { http.DefaultTransport = oldHTTPTransport }()
+	defer loader.SetFetchRetryPolicy(loader.DefaultFetchRetryPolicy())
+
+	var flakyRequests, missingRequests int64
+	tb.Mux.HandleFunc("/flaky", func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first 3 requests, i.e. the first attempt with and without _k6=1 and the
+		// first retry, and then succeed.
+		if atomic.AddInt64(&flakyRequests, 1) <= 3 {
+			http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_, err := fmt.Fprint(w, "export default 42;")
+		assert.NoError(t, err)
+	})
+	tb.Mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&missingRequests, 1)
+		http.NotFound(w, r)
+	})
+
+	root, err := url.Parse("file:///")
+	require.NoError(t, err)
+	load := func(moduleSpecifier string) (*loader.SourceData, error) {
+		moduleSpecifierURL, err := loader.Resolve(root, moduleSpecifier)
+		require.NoError(t, err)
+		filesystems := map[string]afero.Fs{"https": afero.NewMemMapFs()}
+		return loader.Load(filesystems, moduleSpecifierURL, moduleSpecifier)
+	}
+
+	t.Run("NoRetries", func(t *testing.T) {
+		atomic.StoreInt64(&flakyRequests, 0)
+		loader.SetFetchRetryPolicy(loader.FetchRetryPolicy{Attempts: 1})
+		_, err := load(sr("HTTPSBIN_URL/flaky"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "wrong status code (503)")
+		assert.Equal(t, int64(2), atomic.LoadInt64(&flakyRequests))
+	})
+
+	t.Run("Retried", func(t *testing.T) {
+		atomic.StoreInt64(&flakyRequests, 0)
+		loader.SetFetchRetryPolicy(loader.FetchRetryPolicy{Attempts: 3, Backoff: time.Millisecond})
+		src, err := load(sr("HTTPSBIN_URL/flaky"))
+		require.NoError(t, err)
+		assert.Equal(t, "export default 42;", string(src.Data))
+		assert.Equal(t, int64(4), atomic.LoadInt64(&flakyRequests))
+	})
+
+	t.Run("NotFoundNotRetried", func(t *testing.T) {
+		loader.SetFetchRetryPolicy(loader.FetchRetryPolicy{Attempts: 3, Backoff: time.Millisecond})
+		_, err := load(sr("HTTPSBIN_URL/missing"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not found: "+sr("HTTPSBIN_URL/missing"))
+		assert.Equal(t, int64(2), atomic.LoadInt64(&missingRequests))
+	})
+}
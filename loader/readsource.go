@@ -1,17 +1,70 @@
 package loader
 
 import (
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"net/url"
 	"path/filepath"
+	"strings"
 
 	"github.com/loadimpact/k6/lib/fsext"
 	"github.com/pkg/errors"
 	"github.com/spf13/afero"
 )
 
-// ReadSource Reads a source file from any supported destination.
+// EntrypointConfigFile is the file in a test directory that can declare the script to run with
+// its "main" field, e.g. {"main": "tests/smoke.js"}.
+const EntrypointConfigFile = "k6.config.json"
+
+// EntrypointCandidates are the conventional names of the script to run in a test directory
+// without an EntrypointConfigFile.
+var EntrypointCandidates = []string{"index.js", "main.js"}
+
+// ResolveEntrypoint returns the path of the script to run from a test directory: the "main" of
+// its EntrypointConfigFile, or else the one of EntrypointCandidates it has. It doesn't guess if
+// the directory has more than one of them.
+func ResolveEntrypoint(fs afero.Fs, dir string) (string, error) {
+	configPath := filepath.Join(dir, EntrypointConfigFile)
+	if ok, _ := afero.Exists(fs, configPath); ok {
+		data, err := afero.ReadFile(fs, configPath)
+		if err != nil {
+			return "", err
+		}
+		var config struct {
+			Main string `json:"main"`
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return "", errors.Wrapf(err, "couldn't parse %s", configPath)
+		}
+		if config.Main == "" {
+			return "", errors.Errorf("%s doesn't declare the script to run with \"main\"", configPath)
+		}
+		return filepath.Join(dir, filepath.FromSlash(config.Main)), nil
+	}
+
+	var found []string
+	for _, name := range EntrypointCandidates {
+		if ok, _ := afero.Exists(fs, filepath.Join(dir, name)); ok {
+			found = append(found, name)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", errors.Errorf(
+			"the directory %s has none of the entrypoints %s, and no %s declaring one",
+			dir, strings.Join(EntrypointCandidates, ", "), EntrypointConfigFile)
+	case 1:
+		return filepath.Join(dir, found[0]), nil
+	default:
+		return "", errors.Errorf(
+			"the directory %s has multiple entrypoints: %s, run one of them or declare it in %s",
+			dir, strings.Join(found, ", "), EntrypointConfigFile)
+	}
+}
+
+// ReadSource Reads a source file from any supported destination. For a local directory, it reads
+// the entrypoint returned by ResolveEntrypoint().
 func ReadSource(src, pwd string, filesystems map[string]afero.Fs, stdin io.Reader) (*SourceData, error) {
 	if src == "-" {
 		data, err := ioutil.ReadAll(stdin)
@@ -34,6 +87,17 @@ func ReadSource(src, pwd string, filesystems map[string]afero.Fs, stdin io.Reade
 	// All paths should start with a / in all fses. This is mostly for windows where it will start
 	// with a volume name : C:\something.js
 	srcLocalPath = filepath.Clean(afero.FilePathSeparator + srcLocalPath)
+	if ok, _ := afero.IsDir(filesystems["file"], srcLocalPath); ok {
+		entrypoint, err := ResolveEntrypoint(filesystems["file"], srcLocalPath)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(srcLocalPath, entrypoint)
+		if err != nil {
+			return nil, err
+		}
+		src, srcLocalPath = filepath.Join(src, rel), entrypoint
+	}
 	if ok, _ := afero.Exists(filesystems["file"], srcLocalPath); ok {
 		// there is file on the local disk ... lets use it :)
 		return Load(filesystems, &url.URL{Scheme: "file", Path: filepath.ToSlash(srcLocalPath)}, src)
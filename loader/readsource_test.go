@@ -65,6 +65,54 @@ func TestReadSourceAbsolute(t *testing.T) {
 		Data: data}, sourceData)
 }
 
+func TestReadSourceDirectory(t *testing.T) {
+	var data = []byte(`test contents`)
+
+	t.Run("Candidate", func(t *testing.T) {
+		var fs = afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fs, "/path/to/pwd/mytest/index.js", data, 0644))
+		require.NoError(t, afero.WriteFile(fs, "/path/to/pwd/mytest/lib.js", []byte("lib"), 0644))
+		sourceData, err := ReadSource("./mytest/", "/path/to/pwd", map[string]afero.Fs{"file": fs}, nil)
+		require.NoError(t, err)
+		require.Equal(t, &SourceData{
+			URL:  &url.URL{Scheme: "file", Path: "/path/to/pwd/mytest/index.js"},
+			Data: data}, sourceData)
+	})
+
+	t.Run("Config", func(t *testing.T) {
+		var fs = afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fs, "/mytest/index.js", []byte("wrong"), 0644))
+		require.NoError(t, afero.WriteFile(fs, "/mytest/tests/smoke.js", data, 0644))
+		require.NoError(t, afero.WriteFile(fs, "/mytest/k6.config.json", []byte(`{"main": "tests/smoke.js"}`), 0644))
+		sourceData, err := ReadSource("/mytest", "/c", map[string]afero.Fs{"file": fs}, nil)
+		require.NoError(t, err)
+		require.Equal(t, &SourceData{
+			URL:  &url.URL{Scheme: "file", Path: "/mytest/tests/smoke.js"},
+			Data: data}, sourceData)
+
+		require.NoError(t, afero.WriteFile(fs, "/mytest/k6.config.json", []byte(`{}`), 0644))
+		_, err = ReadSource("/mytest", "/c", map[string]afero.Fs{"file": fs}, nil)
+		require.EqualError(t, err, `/mytest/k6.config.json doesn't declare the script to run with "main"`)
+	})
+
+	t.Run("Ambiguous", func(t *testing.T) {
+		var fs = afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fs, "/mytest/index.js", data, 0644))
+		require.NoError(t, afero.WriteFile(fs, "/mytest/main.js", data, 0644))
+		_, err := ReadSource("/mytest", "/c", map[string]afero.Fs{"file": fs}, nil)
+		require.EqualError(t, err,
+			"the directory /mytest has multiple entrypoints: index.js, main.js, run one of them or declare it in k6.config.json")
+	})
+
+	t.Run("None", func(t *testing.T) {
+		var fs = afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(fs, "/mytest/lib.js", data, 0644))
+		_, err := ReadSource("/mytest", "/c", map[string]afero.Fs{"file": fs}, nil)
+		require.EqualError(t, err,
+			"the directory /mytest has none of the entrypoints index.js, main.js, and no k6.config.json declaring one")
+	})
+}
+
 func TestReadSourceHttps(t *testing.T) {
 	var data = []byte(`test contents`)
 	var fs = afero.NewMemMapFs()
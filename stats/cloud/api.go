@@ -75,7 +75,7 @@ func (c *Client) CreateTestRun(testRun *TestRun) (*CreateTestRunResponse, error)
 	}
 
 	ctrr := CreateTestRunResponse{}
-	err = c.Do(req, &ctrr)
+	err = c.doWithRetries(req, &ctrr, c.createRetries, c.createBackoff, true)
 	if err != nil {
 		return nil, err
 	}
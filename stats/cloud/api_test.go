@@ -23,19 +23,23 @@ package cloud
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/loadimpact/k6/lib/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
 )
 
 func init() {
@@ -70,6 +74,86 @@ func TestCreateTestRun(t *testing.T) {
 	assert.False(t, resp.ConfigOverride.AggregationMinSamples.Valid)
 }
 
+func TestClientConfigureTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fprintf(t, w, `{"reference_id": "1"}`)
+	}))
+	defer server.Close()
+
+	conf := NewConfig().Apply(Config{
+		MaxIdleConns:    null.IntFrom(3),
+		IdleConnTimeout: types.NullDurationFrom(5 * time.Second),
+		DNSCacheTTL:     types.NullDurationFrom(0),
+		HTTP2:           null.BoolFrom(false),
+	})
+	client := NewClient("token", server.URL, "1.0")
+	require.NoError(t, client.ConfigureTransport(conf))
+
+	transport, ok := client.client.Transport.(*http.Transport)
+	require.True(t, ok)
+	assert.Equal(t, 3, transport.MaxIdleConns)
+	assert.Equal(t, 3, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 5*time.Second, transport.IdleConnTimeout)
+	assert.Nil(t, transport.TLSNextProto)
+
+	resp, err := client.CreateTestRun(&TestRun{Name: "test"})
+	require.NoError(t, err)
+	assert.Equal(t, "1", resp.ReferenceID)
+
+	require.NoError(t, client.ConfigureTransport(NewConfig()))
+	transport, ok = client.client.Transport.(*http.Transport)
+	require.True(t, ok)
+	assert.Equal(t, 10, transport.MaxIdleConns)
+	assert.NotNil(t, transport.TLSNextProto)
+
+	// The default DNS cache is used for the connections to the cloud.
+	resp, err = client.CreateTestRun(&TestRun{Name: "test"})
+	require.NoError(t, err)
+	assert.Equal(t, "1", resp.ReferenceID)
+}
+
+func TestClientWarmUp(t *testing.T) {
+	var conns, requests int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Method == "HEAD" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fprintf(t, w, `{"reference_id": "1"}`)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client := NewClient("token", server.URL, "1.0")
+	require.NoError(t, client.ConfigureTransport(NewConfig().Apply(Config{HTTP2: null.BoolFrom(false)})))
+	require.NoError(t, client.WarmUp(context.Background(), 3))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&conns))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+
+	// The following requests reuse the warm connections.
+	_, err := client.CreateTestRun(&TestRun{Name: "test"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&conns))
+
+	// With HTTP/2 all requests would share one connection, so only that one is opened.
+	atomic.StoreInt32(&conns, 0)
+	atomic.StoreInt32(&requests, 0)
+	client = NewClient("token", server.URL, "1.0")
+	require.NoError(t, client.ConfigureTransport(NewConfig().Apply(Config{HTTP2: null.BoolFrom(true)})))
+	require.NoError(t, client.WarmUp(context.Background(), 3))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	server.Close()
+	assert.Error(t, client.WarmUp(context.Background(), 2))
+}
+
 func TestPublishMetric(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		g, err := gzip.NewReader(r.Body)
@@ -22,14 +22,18 @@ package cloud
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"time"
 
+	"github.com/loadimpact/k6/lib/netext"
 	"github.com/pkg/errors"
+	"golang.org/x/net/http2"
 )
 
 const (
@@ -50,6 +54,14 @@ type Client struct {
 
 	retries       int
 	retryInterval time.Duration
+
+	// Whether the transport uses HTTP/2, which multiplexes all requests over one connection.
+	http2 bool
+
+	// How many times CreateTestRun() tries to create a test run, and how long it waits before
+	// the first retry. The wait doubles before each further retry.
+	createRetries int
+	createBackoff time.Duration
 }
 
 func NewClient(token, host, version string) *Client {
@@ -60,10 +72,93 @@ func NewClient(token, host, version string) *Client {
 		version:       version,
 		retries:       MaxRetries,
 		retryInterval: RetryInterval,
+		createRetries: MaxRetries,
+		createBackoff: RetryInterval,
 	}
 	return c
 }
 
+// SetCreateTestRunRetries sets how many times CreateTestRun() tries to create a test run, as long
+// as it fails with transient errors, and how long it waits before the first retry. The wait
+// doubles before each further retry.
+func (c *Client) SetCreateTestRunRetries(attempts int, backoff time.Duration) {
+	c.createRetries = attempts
+	c.createBackoff = backoff
+}
+
+// ConfigureTransport replaces the client's HTTP transport with one that uses the connection
+// reuse, DNS caching and HTTP/2 settings from the supplied config, so long-running tests can
+// efficiently keep reusing their connections to the ingest service.
+func (c *Client) ConfigureTransport(conf Config) error {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	dialContext := dialer.DialContext
+	if ttl := time.Duration(conf.DNSCacheTTL.Duration); ttl > 0 {
+		dialContext = netext.NewCachingDialer(dialer, ttl).DialContext
+	}
+	tlsConfig, err := conf.TLS.TLSConfig()
+	if err != nil {
+		return err
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		TLSClientConfig:       tlsConfig,
+		DialContext:           dialContext,
+		MaxIdleConns:          int(conf.MaxIdleConns.Int64),
+		MaxIdleConnsPerHost:   int(conf.MaxIdleConns.Int64),
+		IdleConnTimeout:       time.Duration(conf.IdleConnTimeout.Duration),
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	if conf.HTTP2.Bool {
+		if err := http2.ConfigureTransport(transport); err != nil {
+			return err
+		}
+	}
+	c.client.Transport = transport
+	c.http2 = conf.HTTP2.Bool
+	return nil
+}
+
+// WarmUp opens up to conns connections to the cloud concurrently, with HEAD requests that
+// don't need to succeed, and keeps them for reuse by the following requests. It returns the
+// first error of the connections that couldn't be opened before the context was done.
+//
+// With HTTP/2, the concurrent requests would all share one connection, like the following ones
+// do, so only a single connection is opened.
+func (c *Client) WarmUp(ctx context.Context, conns int) error {
+	if c.http2 && conns > 1 {
+		conns = 1
+	}
+	errs := make(chan error, conns)
+	for i := 0; i < conns; i++ {
+		go func() {
+			req, err := http.NewRequest("HEAD", c.baseURL, nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			req.Header.Set("User-Agent", "k6cloud/"+c.version)
+			resp, err := c.client.Do(req.WithContext(ctx))
+			if err != nil {
+				errs <- err
+				return
+			}
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			errs <- resp.Body.Close()
+		}()
+	}
+	var firstErr error
+	for i := 0; i < conns; i++ {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
 func (c *Client) NewRequest(method, url string, data interface{}) (*http.Request, error) {
 	var buf io.Reader
 
@@ -80,6 +175,15 @@ func (c *Client) NewRequest(method, url string, data interface{}) (*http.Request
 }
 
 func (c *Client) Do(req *http.Request, v interface{}) error {
+	return c.doWithRetries(req, v, c.retries, c.retryInterval, false)
+}
+
+// doWithRetries sends the request, and retries it up to attempts times in total as long as it
+// fails with transient errors. It waits for the interval before each retry, which doubles every
+// time if backoff is set.
+func (c *Client) doWithRetries(
+	req *http.Request, v interface{}, attempts int, interval time.Duration, backoff bool,
+) error {
 	var originalBody []byte
 	var err error
 
@@ -94,15 +198,18 @@ func (c *Client) Do(req *http.Request, v interface{}) error {
 		}
 	}
 
-	for i := 1; i <= c.retries; i++ {
+	for i := 1; i <= attempts; i++ {
 		if len(originalBody) > 0 {
 			req.Body = ioutil.NopCloser(bytes.NewBuffer(originalBody))
 		}
 
-		retry, err := c.do(req, v, i)
+		retry, err := c.do(req, v, i, attempts)
 
 		if retry {
-			time.Sleep(c.retryInterval)
+			time.Sleep(interval)
+			if backoff {
+				interval *= 2
+			}
 			continue
 		}
 
@@ -112,7 +219,7 @@ func (c *Client) Do(req *http.Request, v interface{}) error {
 	return err
 }
 
-func (c *Client) do(req *http.Request, v interface{}, attempt int) (retry bool, err error) {
+func (c *Client) do(req *http.Request, v interface{}, attempt, attempts int) (retry bool, err error) {
 	if req.Header.Get("Content-Type") == "" {
 		req.Header.Set("Content-Type", "application/json")
 	}
@@ -130,7 +237,7 @@ func (c *Client) do(req *http.Request, v interface{}, attempt int) (retry bool,
 		}
 	}()
 
-	if shouldRetry(resp, err, attempt, c.retries) {
+	if shouldRetry(resp, err, attempt, attempts) {
 		return true, err
 	}
 
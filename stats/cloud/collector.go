@@ -23,10 +23,14 @@ package cloud
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"math"
+	"os"
 	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/loadimpact/k6/lib/metrics"
 	"github.com/loadimpact/k6/lib/netext"
 	"github.com/loadimpact/k6/lib/netext/httpext"
@@ -55,9 +59,13 @@ type Collector struct {
 	anonymous bool
 	runStatus lib.RunStatus
 
-	bufferMutex      sync.Mutex
-	bufferHTTPTrails []*httpext.Trail
-	bufferSamples    []*Sample
+	// The tags identifying this machine in a distributed test, added to every series.
+	machineTags map[string]string
+
+	bufferMutex          sync.Mutex
+	bufferHTTPTrails     []*httpext.Trail
+	bufferCounterSamples []stats.Sample
+	bufferSamples        []*Sample
 
 	opts lib.Options
 
@@ -70,10 +78,31 @@ type Collector struct {
 	// checks basically O(1). And even if for some reason there are occasional metrics with past times that
 	// don't fit in the chosen ring buffer size, we could just send them along to the buffer unaggregated
 	aggrBuckets map[int64]aggregationBucket
+
+	// With the delta counter mode, the samples of counter metrics are folded into these.
+	counterAggrBuckets map[int64]counterAggregationBucket
+
+	// Upload statistics for the summary, only updated by pushMetrics().
+	uploadStatsMutex sync.Mutex
+	uploadedSamples  int
+	uploadedBytes    int
+	uploadRequests   int
+	failedSamples    int
+
+	lib.CollectorFailures
+	lib.CollectorLabel
 }
 
-// Verify that Collector implements lib.Collector
-var _ lib.Collector = &Collector{}
+// Verify that Collector implements lib.TestRunCollector, lib.FailingCollector, lib.BufferingCollector,
+// lib.SummaryCollector and lib.FlushingCollector
+var (
+	_ lib.TestRunCollector   = &Collector{}
+	_ lib.FailingCollector   = &Collector{}
+	_ lib.BufferingCollector = &Collector{}
+	_ lib.SummaryCollector   = &Collector{}
+	_ lib.FlushingCollector  = &Collector{}
+	_ lib.LabeledCollector   = &Collector{}
+)
 
 // MergeFromExternal merges three fields from json in a loadimact key of the provided external map
 func MergeFromExternal(external map[string]json.RawMessage, conf *Config) error {
@@ -107,6 +136,49 @@ func New(conf Config, src *loader.SourceData, opts lib.Options, version string)
 		return nil, errors.New("Aggregation cannot be enabled if the 'vu' or 'iter' system tag is also enabled")
 	}
 
+	if conf.GaugePrecision.Int64 < 0 {
+		return nil, errors.Errorf("gaugePrecision must not be negative, not %d", conf.GaugePrecision.Int64)
+	}
+	for name, precision := range conf.GaugeMetricPrecisions {
+		if precision < 0 {
+			return nil, errors.Errorf("the gauge precision of %s must not be negative, not %d", name, precision)
+		}
+	}
+
+	machineTags := map[string]string{}
+	if conf.TagHostname.Bool {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't get the hostname for tagging the series")
+		}
+		machineTags["hostname"] = hostname
+	}
+	if conf.Segment.String != "" {
+		machineTags["segment"] = conf.Segment.String
+	}
+
+	switch conf.CounterMode.String {
+	case CounterModeSamples:
+	case CounterModeDelta:
+		if conf.AggregationPeriod.Duration <= 0 {
+			return nil, errors.Errorf("the %s counter mode requires aggregation to be enabled with aggregationPeriod",
+				conf.CounterMode.String)
+		}
+	default:
+		return nil, errors.Errorf("invalid counter mode '%s', it must be '%s' or '%s'",
+			conf.CounterMode.String, CounterModeSamples, CounterModeDelta)
+	}
+
+	if conf.CreateAttempts.Int64 < 1 {
+		return nil, errors.Errorf("createAttempts must be at least 1, not %d", conf.CreateAttempts.Int64)
+	}
+	switch conf.CreateFailurePolicy.String {
+	case CreateFailureAbort, CreateFailureContinue:
+	default:
+		return nil, errors.Errorf("invalid create failure policy '%s', it must be '%s' or '%s'",
+			conf.CreateFailurePolicy.String, CreateFailureAbort, CreateFailureContinue)
+	}
+
 	if !conf.Name.Valid || conf.Name.String == "" {
 		conf.Name = null.StringFrom(filepath.Base(src.URL.Path))
 	}
@@ -135,15 +207,26 @@ func New(conf Config, src *loader.SourceData, opts lib.Options, version string)
 		log.Warn("K6CLOUD_TOKEN is deprecated and will be removed. Use K6_CLOUD_TOKEN instead.")
 		conf.Token = conf.DeprecatedToken
 	}
+	if err := LoadTokenFile(&conf); err != nil {
+		return nil, err
+	}
+
+	client := NewClient(conf.Token.String, conf.Host.String, version)
+	if err := client.ConfigureTransport(conf); err != nil {
+		return nil, err
+	}
+	client.SetCreateTestRunRetries(int(conf.CreateAttempts.Int64), time.Duration(conf.CreateBackoff.Duration))
 
 	return &Collector{
-		config:      conf,
-		thresholds:  thresholds,
-		client:      NewClient(conf.Token.String, conf.Host.String, version),
-		anonymous:   !conf.Token.Valid,
-		duration:    duration,
-		opts:        opts,
-		aggrBuckets: map[int64]aggregationBucket{},
+		config:             conf,
+		thresholds:         thresholds,
+		client:             client,
+		anonymous:          !conf.Token.Valid,
+		duration:           duration,
+		opts:               opts,
+		aggrBuckets:        map[int64]aggregationBucket{},
+		counterAggrBuckets: map[int64]counterAggregationBucket{},
+		machineTags:        machineTags,
 	}, nil
 }
 
@@ -154,7 +237,7 @@ func (c *Collector) Init() error {
 
 	for name, t := range c.thresholds {
 		for _, threshold := range t {
-			thresholds[name] = append(thresholds[name], threshold.Source)
+			thresholds[name] = append(thresholds[name], threshold.Expression())
 		}
 	}
 
@@ -168,18 +251,30 @@ func (c *Collector) Init() error {
 
 	response, err := c.client.CreateTestRun(testRun)
 	if err != nil {
-		return err
+		if c.config.CreateFailurePolicy.String != CreateFailureContinue {
+			return err
+		}
+		c.Logger().WithError(err).Warn("Cloud: Couldn't create the test run, continuing without the cloud output")
+		return nil
 	}
 	c.referenceID = response.ReferenceID
 
 	if response.ConfigOverride != nil {
-		log.WithFields(log.Fields{
+		c.Logger().WithFields(log.Fields{
 			"override": response.ConfigOverride,
 		}).Debug("Cloud: overriding config options")
 		c.config = c.config.Apply(*response.ConfigOverride)
 	}
 
-	log.WithFields(log.Fields{
+	if conns := c.config.WarmupConns.Int64; conns > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.config.WarmupTimeout.Duration))
+		if err := c.client.WarmUp(ctx, int(conns)); err != nil {
+			c.Logger().WithError(err).Warn("Cloud: Couldn't warm up the connections, continuing without them")
+		}
+		cancel()
+	}
+
+	c.Logger().WithFields(log.Fields{
 		"name":        c.config.Name,
 		"projectId":   c.config.ProjectID,
 		"duration":    c.duration,
@@ -190,9 +285,17 @@ func (c *Collector) Init() error {
 
 // Link return a link that is shown to the user.
 func (c *Collector) Link() string {
+	if c.referenceID == "" {
+		return ""
+	}
 	return URLForResults(c.referenceID, c.config)
 }
 
+// TestRunID returns the reference ID of the test run created in the cloud.
+func (c *Collector) TestRunID() string {
+	return c.referenceID
+}
+
 // Run is called in a goroutine and starts the collector. Should commit samples to the backend
 // at regular intervals and when the context is terminated.
 func (c *Collector) Run(ctx context.Context) {
@@ -208,10 +311,13 @@ func (c *Collector) Run(ctx context.Context) {
 				select {
 				case <-aggregationTicker.C:
 					c.aggregateHTTPTrails(time.Duration(c.config.AggregationWaitPeriod.Duration))
+					c.aggregateCounters(time.Duration(c.config.AggregationWaitPeriod.Duration))
 				case <-ctx.Done():
 					c.aggregateHTTPTrails(0)
 					c.flushHTTPTrails()
-					c.pushMetrics()
+					c.aggregateCounters(0)
+					c.flushCounters()
+					_ = c.pushMetrics()
 					wg.Done()
 					return
 				}
@@ -228,14 +334,27 @@ func (c *Collector) Run(ctx context.Context) {
 	for {
 		select {
 		case <-pushTicker.C:
-			c.pushMetrics()
+			_ = c.pushMetrics()
 		case <-ctx.Done():
-			c.pushMetrics()
+			_ = c.pushMetrics()
 			return
 		}
 	}
 }
 
+// roundGaugeValue rounds the value of a gauge to the precision configured for it, if any.
+func (c *Collector) roundGaugeValue(metric string, value float64) float64 {
+	precision, ok := c.config.GaugeMetricPrecisions[metric]
+	if !ok {
+		if !c.config.GaugePrecision.Valid {
+			return value
+		}
+		precision = c.config.GaugePrecision.Int64
+	}
+	scale := math.Pow10(int(precision))
+	return math.Round(value*scale) / scale
+}
+
 // Collect receives a set of samples. This method is never called concurrently, and only while
 // the context for Run() is valid, but should defer as much work as possible to Run().
 func (c *Collector) Collect(sampleContainers []stats.SampleContainer) {
@@ -245,12 +364,15 @@ func (c *Collector) Collect(sampleContainers []stats.SampleContainer) {
 
 	newSamples := []*Sample{}
 	newHTTPTrails := []*httpext.Trail{}
+	newCounterSamples := []stats.Sample{}
+	aggregationEnabled := c.config.AggregationPeriod.Duration > 0
+	foldCounters := aggregationEnabled && c.config.CounterMode.String != CounterModeSamples
 
 	for _, sampleContainer := range sampleContainers {
 		switch sc := sampleContainer.(type) {
 		case *httpext.Trail:
 			// Check if aggregation is enabled,
-			if c.config.AggregationPeriod.Duration > 0 {
+			if aggregationEnabled {
 				newHTTPTrails = append(newHTTPTrails, sc)
 			} else {
 				newSamples = append(newSamples, NewSampleFromTrail(sc))
@@ -276,6 +398,14 @@ func (c *Collector) Collect(sampleContainers []stats.SampleContainer) {
 				}})
 		default:
 			for _, sample := range sampleContainer.GetSamples() {
+				if foldCounters && sample.Metric.Type == stats.Counter {
+					newCounterSamples = append(newCounterSamples, sample)
+					continue
+				}
+				value := sample.Value
+				if sample.Metric.Type == stats.Gauge {
+					value = c.roundGaugeValue(sample.Metric.Name, value)
+				}
 				newSamples = append(newSamples, &Sample{
 					Type:   DataTypeSingle,
 					Metric: sample.Metric.Name,
@@ -283,7 +413,7 @@ func (c *Collector) Collect(sampleContainers []stats.SampleContainer) {
 						Type:  sample.Metric.Type,
 						Time:  Timestamp(sample.Time),
 						Tags:  sample.Tags,
-						Value: sample.Value,
+						Value: value,
 					},
 				})
 			}
@@ -291,14 +421,79 @@ func (c *Collector) Collect(sampleContainers []stats.SampleContainer) {
 		}
 	}
 
-	if len(newSamples) > 0 || len(newHTTPTrails) > 0 {
+	if len(newSamples) > 0 || len(newHTTPTrails) > 0 ||
+		len(newCounterSamples) > 0 {
 		c.bufferMutex.Lock()
 		c.bufferSamples = append(c.bufferSamples, newSamples...)
 		c.bufferHTTPTrails = append(c.bufferHTTPTrails, newHTTPTrails...)
+		c.bufferCounterSamples = append(c.bufferCounterSamples, newCounterSamples...)
+		c.bufferMutex.Unlock()
+	}
+}
+
+// aggregateCounters folds all newly buffered counter samples into their aggregation buckets
+// and sends the buckets older than the supplied wait period.
+func (c *Collector) aggregateCounters(waitPeriod time.Duration) {
+	c.bufferMutex.Lock()
+	newCounterSamples := c.bufferCounterSamples
+	c.bufferCounterSamples = nil
+	c.bufferMutex.Unlock()
+
+	aggrPeriod := int64(c.config.AggregationPeriod.Duration)
+	for _, sample := range newCounterSamples {
+		bucketID := sample.Time.UnixNano() / aggrPeriod
+		bucket, ok := c.counterAggrBuckets[bucketID]
+		if !ok {
+			bucket = counterAggregationBucket{}
+			c.counterAggrBuckets[bucketID] = bucket
+		}
+		bucket.add(sample, Timestamp(time.Unix(0, bucketID*aggrPeriod+aggrPeriod/2)))
+	}
+
+	bucketCutoffID := time.Now().Add(-waitPeriod).UnixNano() / aggrPeriod
+	newSamples := []*Sample{}
+	for bucketID, bucket := range c.counterAggrBuckets {
+		if bucketID > bucketCutoffID {
+			continue
+		}
+		newSamples = append(newSamples, bucket.samples()...)
+		delete(c.counterAggrBuckets, bucketID)
+	}
+
+	if len(newSamples) > 0 {
+		c.bufferMutex.Lock()
+		c.bufferSamples = append(c.bufferSamples, newSamples...)
 		c.bufferMutex.Unlock()
 	}
 }
 
+// flushCounters sends all remaining counter aggregation buckets, regardless of their time.
+func (c *Collector) flushCounters() {
+	newSamples := []*Sample{}
+	for _, bucket := range c.counterAggrBuckets {
+		newSamples = append(newSamples, bucket.samples()...)
+	}
+	c.counterAggrBuckets = map[int64]counterAggregationBucket{}
+
+	c.bufferMutex.Lock()
+	c.bufferSamples = append(c.bufferSamples, newSamples...)
+	c.bufferMutex.Unlock()
+}
+
+// sendRawTrails returns whether the HTTP trails of a time bucket should be sent individually,
+// without any aggregation, because raw samples are enabled and the bucket's rate is low enough.
+func (c *Collector) sendRawTrails(bucket aggregationBucket) bool {
+	if !c.config.AggregationRawSamples.Bool {
+		return false
+	}
+	trailCount := 0
+	for _, httpTrails := range bucket {
+		trailCount += len(httpTrails)
+	}
+	rate := float64(trailCount) / time.Duration(c.config.AggregationPeriod.Duration).Seconds()
+	return rate <= c.config.AggregationRawSamplesMaxRate.Float64
+}
+
 func (c *Collector) aggregateHTTPTrails(waitPeriod time.Duration) {
 	c.bufferMutex.Lock()
 	newHTTPTrails := c.bufferHTTPTrails
@@ -346,6 +541,16 @@ func (c *Collector) aggregateHTTPTrails(waitPeriod time.Duration) {
 			continue
 		}
 
+		if c.sendRawTrails(subBuckets) {
+			for _, httpTrails := range subBuckets {
+				for _, trail := range httpTrails {
+					newSamples = append(newSamples, NewSampleFromTrail(trail))
+				}
+			}
+			delete(c.aggrBuckets, bucketID)
+			continue
+		}
+
 		for tags, httpTrails := range subBuckets {
 			trailCount := int64(len(httpTrails))
 			if trailCount < c.config.AggregationMinSamples.Int64 {
@@ -402,7 +607,7 @@ func (c *Collector) aggregateHTTPTrails(waitPeriod time.Duration) {
 			aggrData.CalcAverages()
 
 			if aggrData.Count > 0 {
-				log.WithFields(log.Fields{
+				c.Logger().WithFields(log.Fields{
 					"http_samples": aggrData.Count,
 				}).Debug("Aggregated HTTP metrics")
 				newSamples = append(newSamples, &Sample{
@@ -442,33 +647,223 @@ func (c *Collector) flushHTTPTrails() {
 	c.aggrBuckets = map[int64]aggregationBucket{}
 	c.bufferSamples = append(c.bufferSamples, newSamples...)
 }
-func (c *Collector) pushMetrics() {
+
+// BufferedSamples returns the number of samples and HTTP trails that weren't sent or aggregated
+// yet. The HTTP trails in the aggregation buckets, waiting for their period to end, aren't counted.
+func (c *Collector) BufferedSamples() int {
+	c.bufferMutex.Lock()
+	defer c.bufferMutex.Unlock()
+	return len(c.bufferSamples) + len(c.bufferHTTPTrails) +
+		len(c.bufferCounterSamples)
+}
+
+// Flush uploads the buffered samples right away. The HTTP trails that are still being aggregated
+// aren't in the buffer yet, so they're uploaded with the next regular push.
+func (c *Collector) Flush() error {
+	return c.pushMetrics()
+}
+
+func (c *Collector) pushMetrics() error {
 	c.bufferMutex.Lock()
 	if len(c.bufferSamples) == 0 {
 		c.bufferMutex.Unlock()
-		return
+		return nil
 	}
 	buffer := c.bufferSamples
 	c.bufferSamples = nil
 	c.bufferMutex.Unlock()
 
-	log.WithFields(log.Fields{
+	c.Logger().WithFields(log.Fields{
 		"samples": len(buffer),
 	}).Debug("Pushing metrics to cloud")
 
-	for len(buffer) > 0 {
-		var size = len(buffer)
-		if size > int(c.config.MaxMetricSamplesPerPackage.Int64) {
-			size = int(c.config.MaxMetricSamplesPerPackage.Int64)
-		}
-		err := c.client.PushMetric(c.referenceID, c.config.NoCompress.Bool, buffer[:size])
+	c.addMachineTags(buffer)
+
+	packages := splitPackages(buffer, int(c.config.MaxMetricSamplesPerPackage.Int64), int(c.config.MaxMetricPayloadSize.Int64))
+	var lastErr error
+	for i, pkg := range packages {
+		err := c.client.PushMetric(c.referenceID, c.config.NoCompress.Bool, pkg.samples)
+		c.updateUploadStats(len(pkg.samples), pkg.size, err)
 		if err != nil {
-			log.WithFields(log.Fields{
-				"error": err,
+			c.Logger().WithFields(log.Fields{
+				"error":    err,
+				"package":  i + 1,
+				"packages": len(packages),
+				"samples":  len(pkg.samples),
+				"bytes":    pkg.size,
 			}).Warn("Failed to send metrics to cloud")
+			c.RecordFailedSamples(err, len(pkg.samples))
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (c *Collector) updateUploadStats(samples, size int, err error) {
+	c.uploadStatsMutex.Lock()
+	defer c.uploadStatsMutex.Unlock()
+	if err != nil {
+		c.failedSamples += samples
+		return
+	}
+	c.uploadedSamples += samples
+	c.uploadedBytes += size
+	c.uploadRequests++
+}
+
+// SummaryLines returns how many samples were uploaded to the cloud, for the end-of-test summary.
+func (c *Collector) SummaryLines() []string {
+	if c.referenceID == "" {
+		return nil
+	}
+	c.uploadStatsMutex.Lock()
+	defer c.uploadStatsMutex.Unlock()
+	requests := "requests"
+	if c.uploadRequests == 1 {
+		requests = "request"
+	}
+	lines := []string{fmt.Sprintf("uploaded %d samples (%s) in %d %s",
+		c.uploadedSamples, humanize.Bytes(uint64(c.uploadedBytes)), c.uploadRequests, requests)}
+	if c.failedSamples > 0 {
+		lines = append(lines, fmt.Sprintf("failed to upload %d samples", c.failedSamples))
+	}
+	return lines
+}
+
+// addMachineTags adds the tags identifying this machine to the samples, if any are configured.
+// The aggregated samples get them too, since it's done right before they're sent.
+func (c *Collector) addMachineTags(samples []*Sample) {
+	if len(c.machineTags) == 0 {
+		return
+	}
+
+	// Many samples share the same tags, so each tag set is only extended once.
+	extended := map[*stats.SampleTags]*stats.SampleTags{}
+	extend := func(tags *stats.SampleTags) *stats.SampleTags {
+		if ext, ok := extended[tags]; ok {
+			return ext
+		}
+		data := tags.CloneTags()
+		for k, v := range c.machineTags {
+			if _, ok := data[k]; !ok {
+				data[k] = v
+			}
+		}
+		ext := stats.IntoSampleTags(&data)
+		extended[tags] = ext
+		return ext
+	}
+
+	for _, sample := range samples {
+		switch data := sample.Data.(type) {
+		case *SampleDataSingle:
+			data.Tags = extend(data.Tags)
+		case *SampleDataMap:
+			data.Tags = extend(data.Tags)
+		case *SampleDataAggregatedHTTPReqs:
+			data.Tags = extend(data.Tags)
+		}
+	}
+}
+
+// metricsPackage is a batch of samples that are sent to the cloud in a single request.
+type metricsPackage struct {
+	samples []*Sample
+	size    int // the approximate size of the uncompressed JSON
+}
+
+// splitPackages splits the samples into packages with at most maxSamples samples and at most
+// maxSize bytes of JSON, if maxSize is positive. The samples of a series, i.e. of the same
+// metric and with the same tags, are kept in the same package, unless they don't fit in one.
+func splitPackages(samples []*Sample, maxSamples, maxSize int) []metricsPackage {
+	if maxSize <= 0 {
+		packages := []metricsPackage{}
+		for len(samples) > 0 {
+			size := len(samples)
+			if maxSamples > 0 && size > maxSamples {
+				size = maxSamples
+			}
+			packages = append(packages, metricsPackage{samples: samples[:size]})
+			samples = samples[size:]
+		}
+		return packages
+	}
+
+	// Group the samples by series, in the order of their first appearance.
+	seriesKeys := []string{}
+	series := map[string][]*Sample{}
+	for _, sample := range samples {
+		key := sampleSeriesKey(sample)
+		if _, ok := series[key]; !ok {
+			seriesKeys = append(seriesKeys, key)
+		}
+		series[key] = append(series[key], sample)
+	}
+
+	packages := []metricsPackage{}
+	current := metricsPackage{}
+	fits := func(count, size int) bool {
+		return (maxSamples <= 0 || count <= maxSamples) && size <= maxSize
+	}
+	closeCurrent := func() {
+		if len(current.samples) > 0 {
+			packages = append(packages, current)
+			current = metricsPackage{}
+		}
+	}
+	for _, key := range seriesKeys {
+		seriesSamples := series[key]
+		sizes := make([]int, len(seriesSamples))
+		seriesSize := 0
+		for i, sample := range seriesSamples {
+			sizes[i] = sampleJSONSize(sample)
+			seriesSize += sizes[i]
+		}
+
+		if !fits(len(current.samples)+len(seriesSamples), current.size+seriesSize) {
+			closeCurrent()
+		}
+		if fits(len(seriesSamples), seriesSize) {
+			current.samples = append(current.samples, seriesSamples...)
+			current.size += seriesSize
+			continue
+		}
+
+		// The series doesn't fit in a single package, so it has to be split.
+		for i, sample := range seriesSamples {
+			if !fits(len(current.samples)+1, current.size+sizes[i]) {
+				closeCurrent()
+			}
+			current.samples = append(current.samples, sample)
+			current.size += sizes[i]
 		}
-		buffer = buffer[size:]
 	}
+	closeCurrent()
+	return packages
+}
+
+// sampleSeriesKey returns a key that identifies the series of the sample.
+func sampleSeriesKey(sample *Sample) string {
+	var tags *stats.SampleTags
+	switch data := sample.Data.(type) {
+	case *SampleDataSingle:
+		tags = data.Tags
+	case *SampleDataMap:
+		tags = data.Tags
+	case *SampleDataAggregatedHTTPReqs:
+		tags = data.Tags
+	}
+	tagsJSON, _ := tags.MarshalJSON()
+	return sample.Type + "|" + sample.Metric + "|" + string(tagsJSON)
+}
+
+// sampleJSONSize returns the size of the sample's JSON, including the separating comma.
+func sampleJSONSize(sample *Sample) int {
+	data, err := json.Marshal(sample)
+	if err != nil {
+		return 0
+	}
+	return len(data) + 1
 }
 
 func (c *Collector) testFinished() {
@@ -481,14 +876,14 @@ func (c *Collector) testFinished() {
 	for name, thresholds := range c.thresholds {
 		thresholdResults[name] = make(map[string]bool)
 		for _, t := range thresholds {
-			thresholdResults[name][t.Source] = t.LastFailed
+			thresholdResults[name][t.Expression()] = t.LastFailed
 			if t.LastFailed {
 				testTainted = true
 			}
 		}
 	}
 
-	log.WithFields(log.Fields{
+	c.Logger().WithFields(log.Fields{
 		"ref":     c.referenceID,
 		"tainted": testTainted,
 	}).Debug("Sending test finished")
@@ -500,7 +895,7 @@ func (c *Collector) testFinished() {
 
 	err := c.client.TestFinished(c.referenceID, thresholdResults, testTainted, runStatus)
 	if err != nil {
-		log.WithFields(log.Fields{
+		c.Logger().WithFields(log.Fields{
 			"error": err,
 		}).Warn("Failed to send test finished to cloud")
 	}
@@ -27,8 +27,13 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -354,4 +359,431 @@ func TestCloudCollectorMaxPerPacket(t *testing.T) {
 	cancel()
 	wg.Wait()
 	require.True(t, gotTheLimit)
+
+	lines := collector.SummaryLines()
+	require.Len(t, lines, 1)
+	assert.Regexp(t, `^uploaded \d+ samples \(.+B\) in \d+ requests$`, lines[0])
+}
+
+func TestCloudCollectorGaugePrecision(t *testing.T) {
+	t.Parallel()
+	script := &loader.SourceData{
+		Data: []byte(""),
+		URL:  &url.URL{Path: "/script.js"},
+	}
+	options := lib.Options{
+		Duration: types.NullDurationFrom(1 * time.Second),
+	}
+	queueDepth := stats.New("queue_depth", stats.Gauge)
+	ratio := stats.New("ratio", stats.Gauge)
+	samples := []stats.SampleContainer{
+		stats.Sample{Time: time.Unix(100, 0), Metric: metrics.VUs, Value: 4.6},
+		stats.Sample{Time: time.Unix(100, 0), Metric: queueDepth, Value: 12.345},
+		stats.Sample{Time: time.Unix(100, 0), Metric: ratio, Value: 0.12345},
+		stats.Sample{Time: time.Unix(100, 0), Metric: metrics.HTTPReqDuration, Value: 12.345},
+	}
+
+	testdata := map[string]struct {
+		config Config
+		values []float64
+	}{
+		"default": {Config{}, []float64{4.6, 12.345, 0.12345, 12.345}},
+		"global":  {Config{GaugePrecision: null.IntFrom(1)}, []float64{4.6, 12.3, 0.1, 12.345}},
+		"metric": {
+			Config{GaugeMetricPrecisions: map[string]int64{"vus": 0, "queue_depth": 0}},
+			[]float64{5, 12, 0.12345, 12.345},
+		},
+		"both": {
+			Config{GaugePrecision: null.IntFrom(2), GaugeMetricPrecisions: map[string]int64{"vus": 0}},
+			[]float64{5, 12.35, 0.12, 12.345},
+		},
+	}
+	for name, data := range testdata {
+		data := data
+		t.Run(name, func(t *testing.T) {
+			collector, err := New(NewConfig().Apply(data.config), script, options, "1.0")
+			require.NoError(t, err)
+			collector.referenceID = "123"
+
+			collector.Collect(samples)
+			require.Len(t, collector.bufferSamples, len(data.values))
+			for i, sample := range collector.bufferSamples {
+				sampleData, ok := sample.Data.(*SampleDataSingle)
+				require.True(t, ok)
+				assert.InDelta(t, data.values[i], sampleData.Value, 1e-9, sample.Metric)
+			}
+		})
+	}
+
+	t.Run("negative", func(t *testing.T) {
+		_, err := New(NewConfig().Apply(Config{GaugePrecision: null.IntFrom(-1)}), script, options, "1.0")
+		assert.EqualError(t, err, "gaugePrecision must not be negative, not -1")
+		_, err = New(NewConfig().Apply(Config{GaugeMetricPrecisions: map[string]int64{"vus": -2}}), script, options, "1.0")
+		assert.EqualError(t, err, "the gauge precision of vus must not be negative, not -2")
+	})
+}
+
+func TestCloudCollectorCounterMode(t *testing.T) {
+	t.Parallel()
+	script := &loader.SourceData{
+		Data: []byte(""),
+		URL:  &url.URL{Path: "/script.js"},
+	}
+	options := lib.Options{
+		Duration: types.NullDurationFrom(1 * time.Second),
+	}
+	myCounter := stats.New("my_counter", stats.Counter)
+	now := time.Unix(100, 0)
+	samples := []stats.SampleContainer{}
+	for i := 1; i <= 5; i++ {
+		// 1, 2 and 3 in the first second, 4 and 5 in the next one
+		samples = append(samples, stats.Sample{
+			Time: now.Add(time.Duration(i) * 300 * time.Millisecond), Metric: myCounter, Value: float64(i),
+		})
+	}
+	samples = append(samples, stats.Sample{Time: now, Metric: metrics.VUs, Value: 5})
+
+	counterValues := func(t *testing.T, collector *Collector) []float64 {
+		values := []float64{}
+		for _, sample := range collector.bufferSamples {
+			assert.Equal(t, DataTypeSingle, sample.Type)
+			assert.Equal(t, "my_counter", sample.Metric)
+			data, ok := sample.Data.(*SampleDataSingle)
+			require.True(t, ok)
+			assert.Equal(t, stats.Counter, data.Type)
+			values = append(values, data.Value)
+		}
+		return values
+	}
+
+	t.Run("samples", func(t *testing.T) {
+		config := NewConfig().Apply(Config{AggregationPeriod: types.NullDurationFrom(1 * time.Second)})
+		collector, err := New(config, script, options, "1.0")
+		require.NoError(t, err)
+		collector.referenceID = "123"
+
+		collector.Collect(samples)
+		assert.Len(t, collector.bufferSamples, 6)
+		assert.Empty(t, collector.bufferCounterSamples)
+	})
+
+	t.Run("delta", func(t *testing.T) {
+		config := NewConfig().Apply(Config{
+			AggregationPeriod: types.NullDurationFrom(1 * time.Second),
+			CounterMode:       null.StringFrom(CounterModeDelta),
+		})
+		collector, err := New(config, script, options, "1.0")
+		require.NoError(t, err)
+		collector.referenceID = "123"
+
+		collector.Collect(samples)
+		require.Len(t, collector.bufferSamples, 1)
+		assert.Equal(t, metrics.VUs.Name, collector.bufferSamples[0].Metric)
+		collector.bufferSamples = nil
+
+		collector.aggregateCounters(0)
+		assert.Empty(t, collector.counterAggrBuckets)
+		values := counterValues(t, collector)
+		sort.Float64s(values)
+		assert.Equal(t, []float64{6, 9}, values)
+
+		// Every bucket only carries what was counted in its own period
+		collector.bufferSamples = nil
+		collector.Collect([]stats.SampleContainer{stats.Sample{
+			Time: now.Add(3 * time.Second), Metric: myCounter, Value: 10,
+		}})
+		collector.aggregateCounters(0)
+		collector.flushCounters()
+		assert.Equal(t, []float64{10}, counterValues(t, collector))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := New(NewConfig().Apply(Config{CounterMode: null.StringFrom("rate")}), script, options, "1.0")
+		assert.EqualError(t, err, "invalid counter mode 'rate', it must be 'samples' or 'delta'")
+		_, err = New(NewConfig().Apply(Config{CounterMode: null.StringFrom(CounterModeDelta)}), script, options, "1.0")
+		assert.EqualError(t, err, "the delta counter mode requires aggregation to be enabled with aggregationPeriod")
+	})
+}
+
+func TestCloudCollectorMachineTags(t *testing.T) {
+	t.Parallel()
+	script := &loader.SourceData{
+		Data: []byte(""),
+		URL:  &url.URL{Path: "/script.js"},
+	}
+	options := lib.Options{
+		Duration: types.NullDurationFrom(1 * time.Second),
+	}
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	newSamples := func() []*Sample {
+		tags := stats.IntoSampleTags(&map[string]string{"url": "http://example.com", "segment": "custom"})
+		return []*Sample{
+			{Type: DataTypeSingle, Metric: "vus", Data: &SampleDataSingle{Value: 1}},
+			{Type: DataTypeSingle, Metric: "my_gauge", Data: &SampleDataSingle{Tags: tags, Value: 1}},
+			{Type: DataTypeMap, Metric: "iter_li_all", Data: &SampleDataMap{Tags: tags}},
+		}
+	}
+	tagsOf := func(sample *Sample) map[string]string {
+		switch data := sample.Data.(type) {
+		case *SampleDataSingle:
+			return data.Tags.CloneTags()
+		case *SampleDataMap:
+			return data.Tags.CloneTags()
+		}
+		return nil
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		collector, err := New(NewConfig(), script, options, "1.0")
+		require.NoError(t, err)
+		samples := newSamples()
+		collector.addMachineTags(samples)
+		assert.Empty(t, tagsOf(samples[0]))
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		config := NewConfig().Apply(Config{TagHostname: null.BoolFrom(true), Segment: null.StringFrom("2/4")})
+		collector, err := New(config, script, options, "1.0")
+		require.NoError(t, err)
+		samples := newSamples()
+		collector.addMachineTags(samples)
+		assert.Equal(t, map[string]string{"hostname": hostname, "segment": "2/4"}, tagsOf(samples[0]))
+		expected := map[string]string{"url": "http://example.com", "segment": "custom", "hostname": hostname}
+		assert.Equal(t, expected, tagsOf(samples[1]))
+		assert.Equal(t, expected, tagsOf(samples[2]))
+	})
+}
+
+func TestCloudCollectorTokenFile(t *testing.T) {
+	t.Parallel()
+	script := &loader.SourceData{
+		Data: []byte(""),
+		URL:  &url.URL{Path: "/script.js"},
+	}
+	options := lib.Options{
+		Duration: types.NullDurationFrom(1 * time.Second),
+	}
+
+	dir, err := ioutil.TempDir("", "k6-cloud-token")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	tokenFile := filepath.Join(dir, "token")
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("secret-token\n"), 0600))
+	emptyFile := filepath.Join(dir, "empty")
+	require.NoError(t, ioutil.WriteFile(emptyFile, []byte(" \n"), 0600))
+
+	t.Run("Valid", func(t *testing.T) {
+		config := NewConfig().Apply(Config{
+			Token:     null.StringFrom("config-token"),
+			TokenFile: null.StringFrom(tokenFile),
+		})
+		collector, err := New(config, script, options, "1.0")
+		require.NoError(t, err)
+		assert.Equal(t, null.StringFrom("secret-token"), collector.config.Token)
+		assert.Equal(t, "secret-token", collector.client.token)
+		assert.False(t, collector.anonymous)
+	})
+	t.Run("Missing", func(t *testing.T) {
+		config := NewConfig().Apply(Config{TokenFile: null.StringFrom(filepath.Join(dir, "missing"))})
+		_, err := New(config, script, options, "1.0")
+		assert.Error(t, err)
+	})
+	t.Run("Empty", func(t *testing.T) {
+		config := NewConfig().Apply(Config{TokenFile: null.StringFrom(emptyFile)})
+		_, err := New(config, script, options, "1.0")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "empty")
+	})
+}
+
+func TestCloudCollectorAggregationRawSamples(t *testing.T) {
+	t.Parallel()
+	script := &loader.SourceData{
+		Data: []byte(""),
+		URL:  &url.URL{Path: "/script.js"},
+	}
+	options := lib.Options{
+		Duration: types.NullDurationFrom(1 * time.Second),
+	}
+	config := NewConfig().Apply(Config{
+		AggregationPeriod:               types.NullDurationFrom(1 * time.Second),
+		AggregationMinSamples:           null.IntFrom(2),
+		AggregationSkipOutlierDetection: null.BoolFrom(true),
+		AggregationRawSamples:           null.BoolFrom(true),
+		AggregationRawSamplesMaxRate:    null.FloatFrom(5),
+	})
+	collector, err := New(config, script, options, "1.0")
+	require.NoError(t, err)
+	collector.referenceID = "123"
+
+	tags := stats.IntoSampleTags(&map[string]string{"url": "http://example.com"})
+	now := time.Unix(100, 0)
+	trails := []stats.SampleContainer{}
+	addTrails := func(start time.Time, count int) {
+		for i := 0; i < count; i++ {
+			trails = append(trails, &httpext.Trail{
+				EndTime:  start.Add(time.Duration(i) * time.Millisecond),
+				Duration: 100 * time.Millisecond,
+				Tags:     tags,
+			})
+		}
+	}
+	addTrails(now, 4)                     // 4 trails/s, sent raw
+	addTrails(now.Add(1*time.Second), 10) // 10 trails/s, aggregated
+	collector.Collect(trails)
+
+	collector.aggregateHTTPTrails(0)
+	counts := map[string]int{}
+	for _, sample := range collector.bufferSamples {
+		counts[sample.Type]++
+	}
+	assert.Equal(t, map[string]int{DataTypeMap: 4, DataTypeAggregatedHTTPReqs: 1}, counts)
+	assert.Empty(t, collector.aggrBuckets)
+}
+
+func TestSplitPackages(t *testing.T) {
+	t.Parallel()
+	newSample := func(metric, tag string, value float64) *Sample {
+		return &Sample{
+			Type:   DataTypeSingle,
+			Metric: metric,
+			Data: &SampleDataSingle{
+				Type:  stats.Gauge,
+				Time:  Timestamp(time.Unix(100, 0)),
+				Tags:  stats.IntoSampleTags(&map[string]string{"tag": tag}),
+				Value: value,
+			},
+		}
+	}
+	a1, b1, a2, c1, b2 := newSample("m", "a", 1), newSample("m", "b", 1), newSample("m", "a", 2),
+		newSample("n", "a", 1), newSample("m", "b", 2)
+	samples := []*Sample{a1, b1, a2, c1, b2}
+	sampleSize := sampleJSONSize(a1)
+	for _, s := range samples {
+		require.Equal(t, sampleSize, sampleJSONSize(s))
+	}
+
+	getSamples := func(packages []metricsPackage) [][]*Sample {
+		result := [][]*Sample{}
+		for _, pkg := range packages {
+			result = append(result, pkg.samples)
+		}
+		return result
+	}
+
+	t.Run("CountOnly", func(t *testing.T) {
+		packages := splitPackages(samples, 2, 0)
+		assert.Equal(t, [][]*Sample{{a1, b1}, {a2, c1}, {b2}}, getSamples(packages))
+	})
+	t.Run("KeepsSeriesTogether", func(t *testing.T) {
+		packages := splitPackages(samples, 100, 3*sampleSize)
+		assert.Equal(t, [][]*Sample{{a1, a2}, {b1, b2, c1}}, getSamples(packages))
+		assert.Equal(t, 2*sampleSize, packages[0].size)
+		assert.Equal(t, 3*sampleSize, packages[1].size)
+	})
+	t.Run("SplitsBigSeries", func(t *testing.T) {
+		packages := splitPackages(samples, 100, sampleSize)
+		assert.Equal(t, [][]*Sample{{a1}, {a2}, {b1}, {b2}, {c1}}, getSamples(packages))
+	})
+	t.Run("Empty", func(t *testing.T) {
+		assert.Empty(t, splitPackages(nil, 100, 1000))
+		assert.Empty(t, splitPackages(nil, 100, 0))
+	})
+}
+
+func TestCloudCollectorCreateRetries(t *testing.T) {
+	t.Parallel()
+	script := &loader.SourceData{
+		Data: []byte(""),
+		URL:  &url.URL{Path: "/script.js"},
+	}
+	options := lib.Options{
+		Duration: types.NullDurationFrom(1 * time.Second),
+	}
+
+	// The API fails with the given status the given number of times before it succeeds.
+	newCollector := func(t *testing.T, status, failures int, config Config) (*Collector, *int32, func()) {
+		var attempts int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if int(atomic.AddInt32(&attempts, 1)) <= failures {
+				w.WriteHeader(status)
+				_, _ = fmt.Fprint(w, `{"error": {"message": "failed"}}`)
+				return
+			}
+			_, _ = fmt.Fprint(w, `{"reference_id": "123"}`)
+		}))
+
+		config = NewConfig().Apply(Config{
+			Host:          null.StringFrom(srv.URL),
+			CreateBackoff: types.NullDurationFrom(time.Millisecond),
+		}).Apply(config)
+		collector, err := New(config, script, options, "1.0")
+		require.NoError(t, err)
+		return collector, &attempts, srv.Close
+	}
+
+	t.Run("Retried", func(t *testing.T) {
+		collector, attempts, closeServer := newCollector(t, http.StatusServiceUnavailable, 2, Config{})
+		defer closeServer()
+		require.NoError(t, collector.Init())
+		assert.Equal(t, int32(3), atomic.LoadInt32(attempts))
+		assert.Equal(t, "123", collector.TestRunID())
+	})
+	t.Run("Abort", func(t *testing.T) {
+		collector, attempts, closeServer := newCollector(t, http.StatusServiceUnavailable, 3, Config{})
+		defer closeServer()
+		assert.Error(t, collector.Init())
+		assert.Equal(t, int32(3), atomic.LoadInt32(attempts))
+	})
+	t.Run("Continue", func(t *testing.T) {
+		collector, attempts, closeServer := newCollector(t, http.StatusServiceUnavailable, 5, Config{
+			CreateAttempts:      null.IntFrom(2),
+			CreateFailurePolicy: null.StringFrom(CreateFailureContinue),
+		})
+		defer closeServer()
+		require.NoError(t, collector.Init())
+		assert.Equal(t, int32(2), atomic.LoadInt32(attempts))
+		assert.Empty(t, collector.TestRunID())
+		assert.Empty(t, collector.Link())
+	})
+	t.Run("NotTransient", func(t *testing.T) {
+		collector, attempts, closeServer := newCollector(t, http.StatusBadRequest, 1, Config{})
+		defer closeServer()
+		assert.Error(t, collector.Init())
+		assert.Equal(t, int32(1), atomic.LoadInt32(attempts))
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := New(NewConfig().Apply(Config{CreateAttempts: null.IntFrom(0)}), script, options, "1.0")
+		assert.EqualError(t, err, "createAttempts must be at least 1, not 0")
+		_, err = New(NewConfig().Apply(Config{CreateFailurePolicy: null.StringFrom("retry")}), script, options, "1.0")
+		assert.EqualError(t, err, "invalid create failure policy 'retry', it must be 'abort' or 'continue'")
+	})
+}
+
+func TestCloudCollectorThresholdExpressions(t *testing.T) {
+	t.Parallel()
+	var testRun TestRun
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&testRun))
+		_, _ = fmt.Fprint(w, `{"reference_id": "123"}`)
+	}))
+	defer srv.Close()
+
+	thresholds, err := stats.NewThresholds([]string{"p(95)<1.5s", "max<=500µs over 1m"})
+	require.NoError(t, err)
+	script := &loader.SourceData{
+		Data: []byte(""),
+		URL:  &url.URL{Path: "/script.js"},
+	}
+	options := lib.Options{
+		Duration:   types.NullDurationFrom(1 * time.Second),
+		Thresholds: map[string]stats.Thresholds{"http_req_duration": thresholds},
+	}
+	collector, err := New(NewConfig().Apply(Config{Host: null.StringFrom(srv.URL)}), script, options, "1.0")
+	require.NoError(t, err)
+	require.NoError(t, collector.Init())
+	assert.Equal(t, map[string][]string{"http_req_duration": {"p(95)<1500", "max<=0.5"}}, testRun.Thresholds)
 }
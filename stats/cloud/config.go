@@ -21,12 +21,31 @@
 package cloud
 
 import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
 	"time"
 
 	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/stats/tlsconfig"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
 	"gopkg.in/guregu/null.v3"
 )
 
+// How the samples of counter metrics are sent, see Config.CounterMode.
+const (
+	CounterModeSamples = "samples"
+	CounterModeDelta   = "delta"
+)
+
+// What happens when the test run can't be created in the cloud, see Config.CreateFailurePolicy.
+const (
+	CreateFailureAbort    = "abort"
+	CreateFailureContinue = "continue"
+)
+
 // Config holds all the necessary data and options for sending metrics to the Load Impact cloud.
 type Config struct {
 	// TODO: refactor common stuff between cloud execution and output
@@ -35,15 +54,85 @@ type Config struct {
 	ProjectID       null.Int    `json:"projectID" envconfig:"CLOUD_PROJECT_ID"`
 	Name            null.String `json:"name" envconfig:"CLOUD_NAME"`
 
+	// Path to a file with the token, e.g. a mounted secret. It's read once, when the collector
+	// is created, and the token in it takes precedence over the one in Token.
+	TokenFile null.String `json:"tokenFile" envconfig:"CLOUD_TOKEN_FILE"`
+
+	// Path to a JSON file with a whole cloud config, e.g. for CI agents that switch between
+	// accounts. It overrides the cloud config from the k6 config file, but the environment
+	// variables and the CLI flags still take precedence over it.
+	ConfigFile null.String `json:"-" envconfig:"CLOUD_CONFIG_FILE"`
+
+	// How many times creating the test run in the cloud is attempted when it fails with a
+	// transient error, and how long to wait before the first retry, the wait is doubled for
+	// every subsequent one. If it still fails, the test is aborted with CreateFailureAbort,
+	// the default, or runs without the cloud output with CreateFailureContinue.
+	CreateAttempts      null.Int           `json:"createAttempts" envconfig:"CLOUD_CREATE_ATTEMPTS"`
+	CreateBackoff       types.NullDuration `json:"createBackoff" envconfig:"CLOUD_CREATE_BACKOFF"`
+	CreateFailurePolicy null.String        `json:"createFailurePolicy" envconfig:"CLOUD_CREATE_FAILURE_POLICY"`
+
 	Host       null.String `json:"host" envconfig:"CLOUD_HOST"`
 	WebAppURL  null.String `json:"webAppURL" envconfig:"CLOUD_WEB_APP_URL"`
 	NoCompress null.Bool   `json:"noCompress" envconfig:"CLOUD_NO_COMPRESS"`
 
 	MaxMetricSamplesPerPackage null.Int `json:"maxMetricSamplesPerPackage" envconfig:"CLOUD_MAX_METRIC_SAMPLES_PER_PACKAGE"`
 
+	// The maximum size in bytes of the uncompressed JSON of a metrics package. Bigger pushes are
+	// split into multiple packages, which keep the samples of a series (i.e. with the same metric
+	// and tags) together, unless a single series doesn't fit in a package. 0 disables the limit.
+	MaxMetricPayloadSize null.Int `json:"maxMetricPayloadSize" envconfig:"CLOUD_MAX_METRIC_PAYLOAD_SIZE"`
+
 	// The time interval between periodic API calls for sending samples to the cloud ingest service.
 	MetricPushInterval types.NullDuration `json:"metricPushInterval" envconfig:"CLOUD_METRIC_PUSH_INTERVAL"`
 
+	// Connection reuse settings for the HTTP client used to talk with the cloud ingest service.
+	// MaxIdleConns is the maximum number of idle keep-alive connections that are kept open and
+	// IdleConnTimeout is how long they are kept before being closed.
+	MaxIdleConns    null.Int           `json:"maxIdleConns" envconfig:"CLOUD_MAX_IDLE_CONNS"`
+	IdleConnTimeout types.NullDuration `json:"idleConnTimeout" envconfig:"CLOUD_IDLE_CONN_TIMEOUT"`
+
+	// How long the resolved addresses of the cloud hosts are cached, so new connections don't
+	// have to resolve them again. If resolving them fails after that, the old addresses keep
+	// being used. 0, the default, resolves them for every new connection.
+	DNSCacheTTL types.NullDuration `json:"dnsCacheTTL" envconfig:"CLOUD_DNS_CACHE_TTL"`
+
+	// Whether HTTP/2 is used when the ingest service supports it. It can be disabled
+	// for networks with proxies that don't handle HTTP/2 connections correctly.
+	HTTP2 null.Bool `json:"http2" envconfig:"CLOUD_HTTP2"`
+
+	// How many connections to the ingest service are opened before the test starts, so the first
+	// pushes of metrics don't have to wait for the TLS handshakes. Opening them is bounded by
+	// WarmupTimeout and its failures are only logged. Connections above MaxIdleConns are closed
+	// again right away, so it doesn't make sense to open more than that. With HTTP2, all requests
+	// share one connection, so only that one is opened.
+	WarmupConns   null.Int           `json:"warmupConns" envconfig:"CLOUD_WARMUP_CONNS"`
+	WarmupTimeout types.NullDuration `json:"warmupTimeout" envconfig:"CLOUD_WARMUP_TIMEOUT"`
+
+	// The TLS configuration for the connections to the cloud, e.g. through a proxy with its
+	// own CA in a corporate network.
+	TLS tlsconfig.Config `json:"tls" envconfig:"CLOUD_TLS"`
+
+	// The number of decimal places the values of gauges are rounded to before they are sent,
+	// e.g. 0 for gauges of quantized quantities like the number of active VUs. GaugePrecision
+	// applies to all gauges and GaugeMetricPrecisions to specific ones, by metric name, taking
+	// precedence over it. By default, the values aren't rounded.
+	GaugePrecision        null.Int         `json:"gaugePrecision" envconfig:"CLOUD_GAUGE_PRECISION"`
+	GaugeMetricPrecisions map[string]int64 `json:"gaugeMetricPrecisions" envconfig:"CLOUD_GAUGE_METRIC_PRECISIONS"`
+
+	// How the samples of counter metrics are sent: with CounterModeSamples, the default, every
+	// sample is sent as is. CounterModeDelta requires aggregation and folds the samples with the
+	// same metric name and tags in an AggregationPeriod-sized time bucket into a single one with
+	// their sum, i.e. what was counted in that period.
+	CounterMode null.String `json:"counterMode" envconfig:"CLOUD_COUNTER_MODE"`
+
+	// For tests distributed over multiple machines, the series can be tagged with the hostname
+	// of the machine that produced them, if TagHostname is enabled, and with the Segment of the
+	// test it runs, e.g. "2/4", if it's set. Both are off by default, since they only add
+	// cardinality to the series of single-machine tests. Tags with the same keys that were
+	// already set on the samples take precedence.
+	TagHostname null.Bool   `json:"tagHostname" envconfig:"CLOUD_TAG_HOSTNAME"`
+	Segment     null.String `json:"segment" envconfig:"CLOUD_SEGMENT"`
+
 	// Aggregation docs:
 	//
 	// If AggregationPeriod is specified and if it is greater than 0, HTTP metric aggregation
@@ -101,6 +190,9 @@ type Config struct {
 	//     - Finally, all non-outliers are aggregated and the resultig single metric is also
 	//       added to the default sample buffer for sending to the cloud ingest service
 	//       on the next MetricPushInterval event.
+	// - If AggregationRawSamples is enabled, the time buckets with at most
+	//   AggregationRawSamplesMaxRate HTTP trails per second aren't aggregated at all,
+	//   and all of their HTTP trails are sent individually instead.
 
 	// If specified and is greater than 0, sample aggregation with that period is enabled
 	AggregationPeriod types.NullDuration `json:"aggregationPeriod" envconfig:"CLOUD_AGGREGATION_PERIOD"`
@@ -144,6 +236,16 @@ type Config struct {
 
 	// Connection or request times with how many IQRs above Q3 to consier as non-aggregatable outliers.
 	AggregationOutlierIqrCoefUpper null.Float `json:"aggregationOutlierIqrCoefUpper" envconfig:"CLOUD_AGGREGATION_OUTLIER_IQR_COEF_UPPER"`
+
+	// If aggregation is enabled and this is too, the HTTP trails of the AggregationPeriod-sized
+	// time buckets with a rate of at most AggregationRawSamplesMaxRate trails per second won't be
+	// aggregated at all, but sent individually, so that the cloud shows the exact values of small
+	// tests. Busier buckets are still aggregated, so large tests don't flood the cloud service.
+	AggregationRawSamples null.Bool `json:"aggregationRawSamples" envconfig:"CLOUD_AGGREGATION_RAW_SAMPLES"`
+
+	// The maximum rate of HTTP trails per second of a time bucket, for it to be sent raw when
+	// AggregationRawSamples is enabled.
+	AggregationRawSamplesMaxRate null.Float `json:"aggregationRawSamplesMaxRate" envconfig:"CLOUD_AGGREGATION_RAW_SAMPLES_MAX_RATE"`
 }
 
 // NewConfig creates a new Config instance with default values for some fields.
@@ -151,8 +253,19 @@ func NewConfig() Config {
 	return Config{
 		Host:                       null.NewString("https://ingest.loadimpact.com", false),
 		WebAppURL:                  null.NewString("https://app.loadimpact.com", false),
+		CreateAttempts:             null.NewInt(3, false),
+		CreateBackoff:              types.NewNullDuration(1*time.Second, false),
+		CreateFailurePolicy:        null.NewString(CreateFailureAbort, false),
 		MetricPushInterval:         types.NewNullDuration(1*time.Second, false),
 		MaxMetricSamplesPerPackage: null.NewInt(100000, false),
+		MaxMetricPayloadSize:       null.NewInt(10*1024*1024, false),
+		MaxIdleConns:               null.NewInt(10, false),
+		IdleConnTimeout:            types.NewNullDuration(90*time.Second, false),
+		DNSCacheTTL:                types.NewNullDuration(0, false),
+		HTTP2:                      null.NewBool(true, false),
+		WarmupConns:                null.NewInt(0, false),
+		WarmupTimeout:              types.NewNullDuration(5*time.Second, false),
+		CounterMode:                null.NewString(CounterModeSamples, false),
 		// Aggregation is disabled by default, since AggregationPeriod has no default value
 		// but if it's enabled manually or from the cloud service, those are the default values it will use:
 		AggregationCalcInterval:         types.NewNullDuration(3*time.Second, false),
@@ -162,6 +275,8 @@ func NewConfig() Config {
 		AggregationOutlierIqrRadius:     null.NewFloat(0.25, false),
 		AggregationOutlierIqrCoefLower:  null.NewFloat(1.5, false),
 		AggregationOutlierIqrCoefUpper:  null.NewFloat(1.3, false),
+		AggregationRawSamples:           null.NewBool(false, false),
+		AggregationRawSamplesMaxRate:    null.NewFloat(100, false),
 	}
 }
 
@@ -173,6 +288,12 @@ func (c Config) Apply(cfg Config) Config {
 	if cfg.DeprecatedToken.Valid {
 		c.DeprecatedToken = cfg.DeprecatedToken
 	}
+	if cfg.TokenFile.Valid && cfg.TokenFile.String != "" {
+		c.TokenFile = cfg.TokenFile
+	}
+	if cfg.ConfigFile.Valid && cfg.ConfigFile.String != "" {
+		c.ConfigFile = cfg.ConfigFile
+	}
 	if cfg.Name.Valid && cfg.Name.String != "" {
 		c.Name = cfg.Name
 	}
@@ -194,6 +315,52 @@ func (c Config) Apply(cfg Config) Config {
 	if cfg.MaxMetricSamplesPerPackage.Valid {
 		c.MaxMetricSamplesPerPackage = cfg.MaxMetricSamplesPerPackage
 	}
+	if cfg.MaxMetricPayloadSize.Valid {
+		c.MaxMetricPayloadSize = cfg.MaxMetricPayloadSize
+	}
+	if cfg.MaxIdleConns.Valid {
+		c.MaxIdleConns = cfg.MaxIdleConns
+	}
+	if cfg.IdleConnTimeout.Valid {
+		c.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	if cfg.DNSCacheTTL.Valid {
+		c.DNSCacheTTL = cfg.DNSCacheTTL
+	}
+	if cfg.HTTP2.Valid {
+		c.HTTP2 = cfg.HTTP2
+	}
+	if cfg.WarmupConns.Valid {
+		c.WarmupConns = cfg.WarmupConns
+	}
+	if cfg.WarmupTimeout.Valid {
+		c.WarmupTimeout = cfg.WarmupTimeout
+	}
+	if cfg.CreateAttempts.Valid {
+		c.CreateAttempts = cfg.CreateAttempts
+	}
+	if cfg.CreateBackoff.Valid {
+		c.CreateBackoff = cfg.CreateBackoff
+	}
+	if cfg.CreateFailurePolicy.Valid {
+		c.CreateFailurePolicy = cfg.CreateFailurePolicy
+	}
+	c.TLS = c.TLS.Apply(cfg.TLS)
+	if cfg.GaugePrecision.Valid {
+		c.GaugePrecision = cfg.GaugePrecision
+	}
+	if len(cfg.GaugeMetricPrecisions) > 0 {
+		c.GaugeMetricPrecisions = cfg.GaugeMetricPrecisions
+	}
+	if cfg.CounterMode.Valid {
+		c.CounterMode = cfg.CounterMode
+	}
+	if cfg.TagHostname.Valid {
+		c.TagHostname = cfg.TagHostname
+	}
+	if cfg.Segment.Valid {
+		c.Segment = cfg.Segment
+	}
 	if cfg.AggregationPeriod.Valid {
 		c.AggregationPeriod = cfg.AggregationPeriod
 	}
@@ -221,5 +388,46 @@ func (c Config) Apply(cfg Config) Config {
 	if cfg.AggregationOutlierIqrCoefUpper.Valid {
 		c.AggregationOutlierIqrCoefUpper = cfg.AggregationOutlierIqrCoefUpper
 	}
+	if cfg.AggregationRawSamples.Valid {
+		c.AggregationRawSamples = cfg.AggregationRawSamples
+	}
+	if cfg.AggregationRawSamplesMaxRate.Valid {
+		c.AggregationRawSamplesMaxRate = cfg.AggregationRawSamplesMaxRate
+	}
 	return c
 }
+
+// LoadTokenFile reads the token from the file specified by TokenFile, if there is one, and
+// saves it in the Token field of the supplied config.
+func LoadTokenFile(conf *Config) error {
+	if !conf.TokenFile.Valid || conf.TokenFile.String == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(conf.TokenFile.String)
+	if err != nil {
+		return errors.Wrap(err, "couldn't read the cloud token file")
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return errors.Errorf("the cloud token file '%s' is empty", conf.TokenFile.String)
+	}
+	conf.Token = null.StringFrom(token)
+	return nil
+}
+
+// ReadConfigFile reads a cloud config from a JSON file with the same keys as the cloud section of
+// the k6 config file. Unknown keys are rejected, so mistakes like using the config of another
+// tool or account are caught before any test is started.
+func ReadConfigFile(fs afero.Fs, filename string) (Config, error) {
+	var conf Config
+	data, err := afero.ReadFile(fs, filename)
+	if err != nil {
+		return conf, errors.Wrap(err, "couldn't read the cloud config file")
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&conf); err != nil {
+		return conf, errors.Wrapf(err, "invalid cloud config file '%s'", filename)
+	}
+	return conf, nil
+}
@@ -0,0 +1,56 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cloud
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestReadConfigFile(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/cloud.json",
+		[]byte(`{"token": "ci-token", "projectID": 123, "name": "ci"}`), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/unknown.json",
+		[]byte(`{"token": "ci-token", "project": 123}`), 0644))
+	require.NoError(t, afero.WriteFile(fs, "/invalid.json", []byte(`{"token": `), 0644))
+
+	conf, err := ReadConfigFile(fs, "/cloud.json")
+	require.NoError(t, err)
+	assert.Equal(t, null.StringFrom("ci-token"), conf.Token)
+	assert.Equal(t, null.IntFrom(123), conf.ProjectID)
+	assert.Equal(t, null.StringFrom("ci"), conf.Name)
+	assert.False(t, conf.Host.Valid)
+
+	_, err = ReadConfigFile(fs, "/unknown.json")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown field "project"`)
+
+	_, err = ReadConfigFile(fs, "/invalid.json")
+	assert.Error(t, err)
+
+	_, err = ReadConfigFile(fs, "/missing.json")
+	assert.Error(t, err)
+}
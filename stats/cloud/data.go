@@ -222,6 +222,32 @@ func (am *AggregatedMetric) Calc(count float64) {
 
 type aggregationBucket map[*stats.SampleTags][]*httpext.Trail
 
+// counterAggregationBucket holds the sums of the counter samples for a single
+// aggregation period, grouped by their metric name and tags.
+type counterAggregationBucket map[string][]*SampleDataSingle
+
+func (cab counterAggregationBucket) add(sample stats.Sample, bucketTime Timestamp) {
+	aggrs := cab[sample.Metric.Name]
+	for _, aggr := range aggrs {
+		if aggr.Tags.IsEqual(sample.Tags) {
+			aggr.Value += sample.Value
+			return
+		}
+	}
+	aggr := &SampleDataSingle{Time: bucketTime, Type: stats.Counter, Tags: sample.Tags, Value: sample.Value}
+	cab[sample.Metric.Name] = append(aggrs, aggr)
+}
+
+func (cab counterAggregationBucket) samples() []*Sample {
+	samples := []*Sample{}
+	for metricName, aggrs := range cab {
+		for _, aggr := range aggrs {
+			samples = append(samples, &Sample{Type: DataTypeSingle, Metric: metricName, Data: aggr})
+		}
+	}
+	return samples
+}
+
 type durations []time.Duration
 
 func (d durations) Len() int           { return len(d) }
@@ -0,0 +1,255 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package graphite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+)
+
+// pathReplacer replaces the characters that have a special meaning in graphite paths or that
+// would break the plaintext protocol.
+var pathReplacer = strings.NewReplacer(".", "_", " ", "_", "/", "_", "\t", "_", "\n", "_")
+
+// Collector writes the samples to a graphite server in its plaintext protocol, as lines of
+// "metric.path value timestamp".
+type Collector struct {
+	Config Config
+
+	Samples  []stats.SampleContainer
+	buffered int // the number of samples in Samples
+	lock     sync.Mutex
+
+	// sendLock serializes the pushes from Run and Flush, and guards conn.
+	sendLock sync.Mutex
+	conn     net.Conn
+
+	lib.CollectorFailures
+	lib.CollectorLabel
+}
+
+// Verify that Collector implements lib.FailingCollector, lib.BufferingCollector,
+// lib.SpillingCollector and lib.FlushingCollector
+var (
+	_ lib.FailingCollector   = &Collector{}
+	_ lib.BufferingCollector = &Collector{}
+	_ lib.SpillingCollector  = &Collector{}
+	_ lib.FlushingCollector  = &Collector{}
+	_ lib.LabeledCollector   = &Collector{}
+)
+
+// New creates an instance of the collector
+func New(conf Config) (*Collector, error) {
+	return &Collector{Config: conf}, nil
+}
+
+// Init checks that an address is configured, the connection itself is made lazily when the
+// first batch is sent.
+func (c *Collector) Init() error {
+	if c.Config.Addr.String == "" {
+		return errors.New("graphite: no address was configured")
+	}
+	return nil
+}
+
+// Run sends the buffered samples every push interval until the context is done.
+func (c *Collector) Run(ctx context.Context) {
+	c.Logger().Debug("Graphite: Running!")
+	ticker := time.NewTicker(time.Duration(c.Config.PushInterval.Duration))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = c.pushMetrics()
+		case <-ctx.Done():
+			_ = c.pushMetrics()
+			c.sendLock.Lock()
+			c.closeConn()
+			c.sendLock.Unlock()
+			return
+		}
+	}
+}
+
+// Collect appends all of the samples passed to it to the internal sample slice.
+func (c *Collector) Collect(scs []stats.SampleContainer) {
+	c.lock.Lock()
+	c.Samples = append(c.Samples, scs...)
+	for _, sc := range scs {
+		c.buffered += len(sc.GetSamples())
+	}
+	c.lock.Unlock()
+}
+
+// BufferedSamples returns the number of samples that weren't sent yet.
+func (c *Collector) BufferedSamples() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.buffered
+}
+
+// SpillSamples takes the samples that weren't sent yet out of the buffer and returns them.
+func (c *Collector) SpillSamples() []stats.SampleContainer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	containers := c.Samples
+	c.Samples, c.buffered = nil, 0
+	return containers
+}
+
+// Link returns the address of the graphite server
+func (c *Collector) Link() string {
+	return c.Config.Addr.String
+}
+
+// GetRequiredSystemTags returns which sample tags are needed by this collector
+func (c *Collector) GetRequiredSystemTags() lib.TagSet {
+	return lib.TagSet{} // There are no required tags for this collector
+}
+
+// SetRunStatus does nothing in the graphite collector
+func (c *Collector) SetRunStatus(status lib.RunStatus) {}
+
+// Flush sends the buffered samples right away.
+func (c *Collector) Flush() error {
+	return c.pushMetrics()
+}
+
+// Format returns the plaintext protocol lines for the given samples.
+func (c *Collector) Format(samples []stats.Sample) []byte {
+	var buf bytes.Buffer
+	for _, sample := range samples {
+		buf.WriteString(c.path(sample))
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatFloat(sample.Value, 'f', -1, 64))
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatInt(sample.Time.Unix(), 10))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// missingTagSegment is the path segment of the tags in Config.TagsAsPath that a sample doesn't
+// have, so every tag keeps its position in the path.
+const missingTagSegment = "none"
+
+// path builds the metric path out of the prefix, the metric name and the values of the tags
+// in Config.TagsAsPath, in that order.
+func (c *Collector) path(sample stats.Sample) string {
+	parts := make([]string, 0, len(c.Config.TagsAsPath)+2)
+	if prefix := c.Config.Prefix.String; prefix != "" {
+		parts = append(parts, prefix)
+	}
+	parts = append(parts, pathReplacer.Replace(sample.Metric.Name))
+	for _, key := range c.Config.TagsAsPath {
+		if v, ok := sample.Tags.Get(key); ok && v != "" {
+			parts = append(parts, pathReplacer.Replace(v))
+		} else {
+			parts = append(parts, missingTagSegment)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func (c *Collector) pushMetrics() error {
+	c.sendLock.Lock()
+	defer c.sendLock.Unlock()
+
+	c.lock.Lock()
+	containers := c.Samples
+	c.Samples, c.buffered = nil, 0
+	c.lock.Unlock()
+	samples := stats.FlattenSampleContainers(containers)
+
+	if len(samples) == 0 {
+		return nil
+	}
+
+	startTime := time.Now()
+	c.Logger().WithField("samples", len(samples)).Debug("Graphite: Delivering...")
+
+	data := c.Format(samples)
+	if n, err := c.write(data); err != nil {
+		c.Logger().WithError(err).Error("Graphite: Couldn't send the samples, will reconnect and retry")
+		c.SetDeliveryFailure(err)
+
+		// Put the samples whose lines weren't sent completely back in front of the ones
+		// collected in the meantime, so they're retried with the next batch.
+		unsent := containers
+		sent := bytes.Count(data[:n], []byte{'\n'})
+		if sent > 0 {
+			unsent = []stats.SampleContainer{stats.Samples(samples[sent:])}
+		}
+		c.lock.Lock()
+		c.Samples = append(unsent, c.Samples...)
+		c.buffered += len(samples) - sent
+		c.lock.Unlock()
+		return err
+	}
+
+	c.Logger().WithField("t", time.Since(startTime)).Debug("Graphite: Delivered!")
+	return nil
+}
+
+// write sends the data over the connection, dialing it first if there isn't one, and returns
+// how much of it was sent. On failure the connection is dropped, so the next write dials a new
+// one.
+func (c *Collector) write(data []byte) (int, error) {
+	timeout := time.Duration(c.Config.Timeout.Duration)
+	if c.conn == nil {
+		conn, err := net.DialTimeout("tcp", c.Config.Addr.String, timeout)
+		if err != nil {
+			return 0, err
+		}
+		c.conn = conn
+	}
+
+	if timeout > 0 {
+		if err := c.conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+			c.closeConn()
+			return 0, err
+		}
+	}
+	n, err := c.conn.Write(data)
+	if err != nil {
+		c.closeConn()
+	}
+	return n, err
+}
+
+func (c *Collector) closeConn() {
+	if c.conn == nil {
+		return
+	}
+	if err := c.conn.Close(); err != nil {
+		c.Logger().WithError(err).Debug("Graphite: Failed to close the connection")
+	}
+	c.conn = nil
+}
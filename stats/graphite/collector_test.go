@@ -0,0 +1,151 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package graphite
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/stats"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestFormat(t *testing.T) {
+	c := Collector{Config: NewConfig().Apply(Config{TagsAsPath: []string{"method", "url"}})}
+	metric := stats.New("http_req_duration", stats.Trend)
+	now := time.Unix(1500000000, 0)
+	samples := []stats.Sample{
+		{Metric: metric, Time: now, Value: 1.25, Tags: stats.IntoSampleTags(&map[string]string{
+			"method": "GET", "url": "http://test.loadimpact.com/a b",
+		})},
+		{Metric: metric, Time: now, Value: 2, Tags: stats.IntoSampleTags(&map[string]string{"status": "200"})},
+	}
+
+	assert.Equal(t,
+		"k6.http_req_duration.GET.http:__test_loadimpact_com_a_b 1.25 1500000000\n"+
+			"k6.http_req_duration.none.none 2 1500000000\n",
+		string(c.Format(samples)))
+
+	c.Config.Prefix = null.StringFrom("")
+	assert.Equal(t, "http_req_duration.none.none 2 1500000000\n", string(c.Format(samples[1:])))
+}
+
+// partialConn is a connection that only accepts the first n bytes written to it.
+type partialConn struct {
+	net.Conn
+	n       int
+	written []byte
+}
+
+func (c *partialConn) Write(b []byte) (int, error) {
+	if len(b) > c.n {
+		c.written = append(c.written, b[:c.n]...)
+		return c.n, errors.New("connection reset by peer")
+	}
+	c.written = append(c.written, b...)
+	return len(b), nil
+}
+
+func (c *partialConn) SetWriteDeadline(time.Time) error { return nil }
+
+func (c *partialConn) Close() error { return nil }
+
+func TestPushMetricsPartialWrite(t *testing.T) {
+	c, err := New(NewConfig())
+	require.NoError(t, err)
+	metric := stats.New("vus", stats.Gauge)
+	tags := stats.IntoSampleTags(&map[string]string{})
+	now := time.Unix(1500000000, 0)
+	c.Collect([]stats.SampleContainer{stats.Samples{
+		{Metric: metric, Time: now, Value: 1, Tags: tags},
+		{Metric: metric, Time: now, Value: 2, Tags: tags},
+		{Metric: metric, Time: now, Value: 3, Tags: tags},
+	}})
+
+	// The first line and half of the second one are sent before the connection breaks.
+	line := len("k6.vus 1 1500000000\n")
+	c.conn = &partialConn{n: line + line/2}
+	assert.Error(t, c.pushMetrics())
+	assert.Equal(t, 2, c.BufferedSamples())
+
+	conn := &partialConn{n: 1 << 20}
+	c.conn = conn
+	require.NoError(t, c.pushMetrics())
+	assert.Equal(t, "k6.vus 2 1500000000\nk6.vus 3 1500000000\n", string(conn.written))
+	assert.Equal(t, 0, c.BufferedSamples())
+}
+
+func TestFlushReconnects(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	c, err := New(NewConfig().Apply(Config{Addr: null.StringFrom(addr)}))
+	require.NoError(t, err)
+	require.NoError(t, c.Init())
+
+	metric := stats.New("vus", stats.Gauge)
+	c.Collect([]stats.SampleContainer{stats.Sample{
+		Metric: metric, Time: time.Unix(1500000000, 0), Value: 10, Tags: stats.IntoSampleTags(&map[string]string{}),
+	}})
+
+	// Nothing is listening, so the samples stay in the buffer.
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+	c.SetLabel("graphite (primary)")
+	assert.Error(t, c.Flush())
+	require.NotNil(t, hook.LastEntry())
+	assert.Equal(t, "graphite (primary)", hook.LastEntry().Data["output"])
+	assert.Error(t, c.DeliveryFailure())
+	assert.Equal(t, 1, c.BufferedSamples())
+
+	l, err = net.Listen("tcp", addr)
+	require.NoError(t, err)
+	defer func() { _ = l.Close() }()
+
+	lines := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		lines <- line
+	}()
+
+	require.NoError(t, c.Flush())
+	assert.Equal(t, 0, c.BufferedSamples())
+	select {
+	case line := <-lines:
+		assert.Equal(t, "k6.vus 10 1500000000\n", line)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the sample wasn't received")
+	}
+	c.closeConn()
+}
@@ -0,0 +1,131 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package graphite
+
+import (
+	"strings"
+	"time"
+
+	"github.com/kubernetes/helm/pkg/strvals"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/mitchellh/mapstructure"
+	"gopkg.in/guregu/null.v3"
+)
+
+// Config is the config for the graphite collector
+type Config struct {
+	// Connection.
+	Addr    null.String        `json:"addr" envconfig:"GRAPHITE_ADDR"`
+	Timeout types.NullDuration `json:"timeout" envconfig:"GRAPHITE_TIMEOUT"`
+
+	// Samples.
+	Prefix       null.String        `json:"prefix" envconfig:"GRAPHITE_PREFIX"`
+	TagsAsPath   []string           `json:"tagsAsPath" envconfig:"GRAPHITE_TAGS_AS_PATH"`
+	PushInterval types.NullDuration `json:"push_interval" envconfig:"GRAPHITE_PUSH_INTERVAL"`
+}
+
+// config is a duplicate of Config as we can not mapstructure.Decode into
+// null types so we duplicate the struct with primitive types to Decode into
+type config struct {
+	Addr       string   `mapstructure:"addr"`
+	Prefix     string   `mapstructure:"prefix"`
+	TagsAsPath []string `mapstructure:"tagsAsPath"`
+}
+
+// NewConfig creates a new Config instance with default values for some fields.
+func NewConfig() Config {
+	return Config{
+		Addr:         null.NewString("localhost:2003", false),
+		Timeout:      types.NewNullDuration(5*time.Second, false),
+		Prefix:       null.NewString("k6", false),
+		PushInterval: types.NewNullDuration(1*time.Second, false),
+	}
+}
+
+// Apply saves config non-zero config values from the passed config in the receiver.
+func (c Config) Apply(cfg Config) Config {
+	if cfg.Addr.Valid {
+		c.Addr = cfg.Addr
+	}
+	if cfg.Timeout.Valid {
+		c.Timeout = cfg.Timeout
+	}
+	if cfg.Prefix.Valid {
+		c.Prefix = cfg.Prefix
+	}
+	if len(cfg.TagsAsPath) > 0 {
+		c.TagsAsPath = cfg.TagsAsPath
+	}
+	if cfg.PushInterval.Valid {
+		c.PushInterval = cfg.PushInterval
+	}
+	return c
+}
+
+// ParseArg takes an arg string and converts it to a config. The arg is either just the address
+// of the server, e.g. "localhost:2003", or a list of options, e.g.
+// "addr=localhost:2003,prefix=loadtest,tagsAsPath={method,status}".
+func ParseArg(arg string) (Config, error) {
+	c := Config{}
+	if !strings.Contains(arg, "=") {
+		c.Addr = null.StringFrom(arg)
+		return c, nil
+	}
+
+	params, err := strvals.Parse(arg)
+	if err != nil {
+		return c, err
+	}
+
+	if v, ok := params["tagsAsPath"].(string); ok {
+		params["tagsAsPath"] = []string{v}
+	}
+
+	if v, ok := params["push_interval"].(string); ok {
+		if err := c.PushInterval.UnmarshalText([]byte(v)); err != nil {
+			return c, err
+		}
+		delete(params, "push_interval")
+	}
+
+	if v, ok := params["timeout"].(string); ok {
+		if err := c.Timeout.UnmarshalText([]byte(v)); err != nil {
+			return c, err
+		}
+		delete(params, "timeout")
+	}
+
+	var cfg config
+	if err := mapstructure.Decode(params, &cfg); err != nil {
+		return c, err
+	}
+
+	if cfg.Addr != "" {
+		c.Addr = null.StringFrom(cfg.Addr)
+	}
+	// An empty prefix is valid, it leaves the metric name at the root of the path.
+	if _, ok := params["prefix"]; ok {
+		c.Prefix = null.StringFrom(cfg.Prefix)
+	}
+	c.TagsAsPath = cfg.TagsAsPath
+
+	return c, nil
+}
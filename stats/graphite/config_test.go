@@ -0,0 +1,60 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package graphite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestConfigParseArg(t *testing.T) {
+	c, err := ParseArg("graphite.example.com:2003")
+	require.NoError(t, err)
+	assert.Equal(t, Config{Addr: null.StringFrom("graphite.example.com:2003")}, c)
+
+	c, err = ParseArg("addr=graphite.example.com:2003,prefix=loadtest,tagsAsPath=method,push_interval=5s")
+	require.NoError(t, err)
+	assert.Equal(t, Config{
+		Addr:         null.StringFrom("graphite.example.com:2003"),
+		Prefix:       null.StringFrom("loadtest"),
+		TagsAsPath:   []string{"method"},
+		PushInterval: types.NullDurationFrom(5 * time.Second),
+	}, c)
+
+	c, err = ParseArg("tagsAsPath={method,status},timeout=1s")
+	require.NoError(t, err)
+	assert.Equal(t, Config{
+		TagsAsPath: []string{"method", "status"},
+		Timeout:    types.NullDurationFrom(1 * time.Second),
+	}, c)
+
+	c, err = ParseArg("addr=graphite.example.com:2003,prefix=")
+	require.NoError(t, err)
+	assert.Equal(t, Config{Addr: null.StringFrom("graphite.example.com:2003"), Prefix: null.StringFrom("")}, c)
+
+	_, err = ParseArg("push_interval=nope")
+	assert.Error(t, err)
+}
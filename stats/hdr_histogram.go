@@ -0,0 +1,354 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package stats
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+)
+
+// The cookies of the V2 encoding of HdrHistogram, as written by its Java, Go and other libraries.
+const (
+	hdrEncodingCookie           int32 = 0x1c849303 | 0x10
+	hdrCompressedEncodingCookie int32 = 0x1c849304 | 0x10
+)
+
+// hdrSignificantDigits is the number of significant decimal digits kept by the histograms.
+const hdrSignificantDigits = 3
+
+// HDRValueScale converts the values of the trend metrics to the integers an HDR histogram
+// records, so that time metrics are recorded in microseconds.
+const HDRValueScale = 1000
+
+// HDRHistogram is a minimal HDR histogram, with the same bucket layout as the HdrHistogram
+// libraries, so it can be encoded in their format. It only tracks values from 0 up to the
+// highest trackable value it's created with, with a precision of 3 significant digits.
+type HDRHistogram struct {
+	highestTrackableValue int64
+
+	subBucketHalfCountMagnitude uint
+	subBucketHalfCount          int64
+	subBucketMask               int64
+
+	counts   []int64
+	maxValue int64
+}
+
+// NewHDRHistogram returns an empty histogram that tracks the values up to highestTrackableValue.
+func NewHDRHistogram(highestTrackableValue int64) *HDRHistogram {
+	if highestTrackableValue < 2 {
+		highestTrackableValue = 2
+	}
+
+	largestValueWithSingleUnitResolution := 2 * int64(math.Pow10(hdrSignificantDigits))
+	subBucketCountMagnitude := uint(math.Ceil(math.Log2(float64(largestValueWithSingleUnitResolution))))
+	subBucketHalfCountMagnitude := subBucketCountMagnitude - 1
+	subBucketCount := int64(1) << subBucketCountMagnitude
+
+	bucketCount := 1
+	for smallestUntrackableValue := subBucketCount; smallestUntrackableValue <= highestTrackableValue; {
+		if smallestUntrackableValue > math.MaxInt64/2 {
+			bucketCount++
+			break
+		}
+		smallestUntrackableValue <<= 1
+		bucketCount++
+	}
+
+	return &HDRHistogram{
+		highestTrackableValue:       highestTrackableValue,
+		subBucketHalfCountMagnitude: subBucketHalfCountMagnitude,
+		subBucketHalfCount:          subBucketCount / 2,
+		subBucketMask:               subBucketCount - 1,
+		counts:                      make([]int64, int64(bucketCount+1)*(subBucketCount/2)),
+	}
+}
+
+// HighestTrackableValue returns the highest value the histogram tracks.
+func (h *HDRHistogram) HighestTrackableValue() int64 {
+	return h.highestTrackableValue
+}
+
+// MaxValue returns the highest recorded value, to the precision of the histogram.
+func (h *HDRHistogram) MaxValue() int64 {
+	return h.maxValue
+}
+
+// EachValue calls fn with every recorded value, to the precision of the histogram, and how many
+// times it was recorded, in ascending order of the values.
+func (h *HDRHistogram) EachValue(fn func(value, count int64)) {
+	for i, count := range h.counts {
+		if count > 0 {
+			fn(h.valueFromIndex(i), count)
+		}
+	}
+}
+
+// countsIndex returns the index of the count of a value.
+func (h *HDRHistogram) countsIndex(v int64) int {
+	pow2Ceiling := 64 - bits.LeadingZeros64(uint64(v|h.subBucketMask))
+	bucketIndex := pow2Ceiling - int(h.subBucketHalfCountMagnitude+1)
+	subBucketIndex := v >> uint(bucketIndex)
+	bucketBaseIndex := int64(bucketIndex+1) << h.subBucketHalfCountMagnitude
+	return int(bucketBaseIndex + subBucketIndex - h.subBucketHalfCount)
+}
+
+// valueFromIndex returns the lowest value that's counted at an index of the counts.
+func (h *HDRHistogram) valueFromIndex(i int) int64 {
+	bucketIndex := (i >> h.subBucketHalfCountMagnitude) - 1
+	subBucketIndex := int64(i)&(h.subBucketHalfCount-1) + h.subBucketHalfCount
+	if bucketIndex < 0 {
+		subBucketIndex -= h.subBucketHalfCount
+		bucketIndex = 0
+	}
+	return subBucketIndex << uint(bucketIndex)
+}
+
+// Record adds a value to the histogram, the values out of its range are clamped to it.
+func (h *HDRHistogram) Record(v int64) {
+	h.RecordCount(v, 1)
+}
+
+// RecordCount adds a value to the histogram count times.
+func (h *HDRHistogram) RecordCount(v, count int64) {
+	if v < 0 {
+		v = 0
+	}
+	if v > h.highestTrackableValue {
+		v = h.highestTrackableValue
+	}
+	h.counts[h.countsIndex(v)] += count
+	if v > h.maxValue {
+		h.maxValue = v
+	}
+}
+
+// Merge adds the counts of another histogram to this one.
+func (h *HDRHistogram) Merge(other *HDRHistogram) {
+	for i, count := range other.counts {
+		if count > 0 {
+			h.RecordCount(other.valueFromIndex(i), count)
+		}
+	}
+}
+
+// TotalCount returns the number of recorded values.
+func (h *HDRHistogram) TotalCount() int64 {
+	var total int64
+	for _, count := range h.counts {
+		total += count
+	}
+	return total
+}
+
+// ValueAtPercentile returns the value below which the percentage pct of the recorded values
+// are, to the precision of the histogram.
+func (h *HDRHistogram) ValueAtPercentile(pct float64) int64 {
+	total := h.TotalCount()
+	target := int64(pct/100*float64(total) + 0.5)
+	if target < 1 {
+		target = 1
+	}
+	var seen int64
+	for i, count := range h.counts {
+		seen += count
+		if seen >= target {
+			return h.valueFromIndex(i)
+		}
+	}
+	return h.maxValue
+}
+
+// Encode returns the histogram in the compressed V2 encoding of HdrHistogram.
+func (h *HDRHistogram) Encode() ([]byte, error) {
+	// The counts are ZigZag LEB128 encoded, up to the one of the maximum value, and the runs of
+	// more than one empty count are written as their negated length.
+	var payload bytes.Buffer
+	limit := h.countsIndex(h.maxValue) + 1
+	for i := 0; i < limit; {
+		count := h.counts[i]
+		i++
+		if count == 0 {
+			zeros := int64(1)
+			for i < limit && h.counts[i] == 0 {
+				zeros++
+				i++
+			}
+			if zeros > 1 {
+				count = -zeros
+			}
+		}
+		writeZigZag(&payload, count)
+	}
+
+	var raw bytes.Buffer
+	header := []interface{}{
+		hdrEncodingCookie,
+		int32(payload.Len()),
+		int32(0), // normalizing index offset
+		int32(hdrSignificantDigits),
+		int64(1), // lowest discernible value
+		h.highestTrackableValue,
+		float64(1), // integer to double value conversion ratio
+	}
+	for _, v := range header {
+		if err := binary.Write(&raw, binary.BigEndian, v); err != nil {
+			return nil, err
+		}
+	}
+	_, _ = payload.WriteTo(&raw)
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := raw.WriteTo(zw); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	var res bytes.Buffer
+	if err := binary.Write(&res, binary.BigEndian, hdrCompressedEncodingCookie); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(&res, binary.BigEndian, int32(compressed.Len())); err != nil {
+		return nil, err
+	}
+	_, _ = compressed.WriteTo(&res)
+	return res.Bytes(), nil
+}
+
+// DecodeHDRHistogram decodes a histogram in the compressed V2 encoding of HdrHistogram, as
+// written by Encode.
+func DecodeHDRHistogram(data []byte) (*HDRHistogram, error) {
+	r := bytes.NewReader(data)
+	var cookie, length int32
+	if err := binary.Read(r, binary.BigEndian, &cookie); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	if cookie != hdrCompressedEncodingCookie {
+		return nil, fmt.Errorf("unsupported histogram encoding %#x", cookie)
+	}
+
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var header struct {
+		Cookie, PayloadLength, NormalizingIndexOffset, SignificantDigits int32
+		Lowest, Highest                                                  int64
+		Ratio                                                            float64
+	}
+	if err := binary.Read(zr, binary.BigEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.Cookie != hdrEncodingCookie || header.SignificantDigits != hdrSignificantDigits ||
+		header.NormalizingIndexOffset != 0 || header.Lowest != 1 || header.Ratio != 1 {
+		return nil, fmt.Errorf("unsupported histogram, it wasn't written by k6")
+	}
+
+	payload := make([]byte, header.PayloadLength)
+	if _, err := io.ReadFull(zr, payload); err != nil {
+		return nil, err
+	}
+	pr := bytes.NewReader(payload)
+	h := NewHDRHistogram(header.Highest)
+	for i := 0; pr.Len() > 0; {
+		count, err := readZigZag(pr)
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			i += int(-count)
+			continue
+		}
+		if i >= len(h.counts) {
+			return nil, fmt.Errorf("the histogram has more counts than its highest value allows")
+		}
+		if count > 0 {
+			h.counts[i] = count
+			h.maxValue = h.valueFromIndex(i)
+		}
+		i++
+	}
+	return h, nil
+}
+
+// writeZigZag writes a value with the ZigZag LEB128 variant of HdrHistogram, whose ninth byte
+// holds the 8 remaining bits.
+func writeZigZag(buf *bytes.Buffer, v int64) {
+	u := uint64((v << 1) ^ (v >> 63))
+	for i := 0; i < 8; i++ {
+		if u < 0x80 {
+			buf.WriteByte(byte(u))
+			return
+		}
+		buf.WriteByte(byte(u&0x7f | 0x80))
+		u >>= 7
+	}
+	buf.WriteByte(byte(u))
+}
+
+// readZigZag reads a value written by writeZigZag.
+func readZigZag(r io.ByteReader) (int64, error) {
+	var u uint64
+	for i := uint(0); i < 64; i += 7 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if i == 56 {
+			u |= uint64(b) << i
+			break
+		}
+		u |= uint64(b&0x7f) << i
+		if b < 0x80 {
+			break
+		}
+	}
+	return int64(u>>1) ^ -int64(u&1), nil
+}
+
+// NewTrendHistogram returns the histogram of the values kept by the sink of a trend metric.
+func NewTrendHistogram(sink *TrendSink) *HDRHistogram {
+	h := NewHDRHistogram(HDRValue(sink.Max))
+	for _, v := range sink.Values {
+		h.Record(HDRValue(v))
+	}
+	return h
+}
+
+// HDRValue converts a value of a trend metric to the integer recorded by an HDR histogram.
+func HDRValue(v float64) int64 {
+	v = math.Round(v * HDRValueScale)
+	if v > math.MaxInt64/2 {
+		return math.MaxInt64 / 2
+	}
+	return int64(v)
+}
@@ -0,0 +1,63 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package stats
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHDRHistogramCountsIndex(t *testing.T) {
+	h := NewHDRHistogram(3600 * 1000 * 1000)
+	testdata := map[int64]int{0: 0, 1: 1, 2047: 2047, 2048: 2048, 2050: 2049, 4095: 3071, 4096: 3072}
+	for v, index := range testdata {
+		assert.Equal(t, index, h.countsIndex(v), v)
+	}
+}
+
+func TestHDRHistogramDecodeMerge(t *testing.T) {
+	a := NewHDRHistogram(250000)
+	for _, v := range []int64{0, 1, 500, 2050, 250000} {
+		a.Record(v)
+	}
+	encoded, err := a.Encode()
+	require.NoError(t, err)
+	decoded, err := DecodeHDRHistogram(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, a.counts, decoded.counts)
+	assert.Equal(t, a.countsIndex(250000), decoded.countsIndex(decoded.maxValue))
+	for i := range a.counts {
+		assert.Equal(t, i, a.countsIndex(a.valueFromIndex(i)), i)
+	}
+
+	b := NewHDRHistogram(1000000)
+	b.Merge(decoded)
+	b.Record(1000000)
+	assert.Equal(t, int64(6), b.TotalCount())
+	assert.Equal(t, int64(500), b.ValueAtPercentile(50))
+	assert.Equal(t, int64(0), b.ValueAtPercentile(0))
+	assert.InEpsilon(t, 1000000, b.ValueAtPercentile(100), 0.001)
+
+	_, err = DecodeHDRHistogram([]byte("not a histogram"))
+	assert.Error(t, err)
+}
@@ -23,40 +23,65 @@ package influxdb
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/influxdata/influxdb/client/v2"
 	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/stats"
-	log "github.com/sirupsen/logrus"
+	"github.com/pkg/errors"
 )
 
-const (
-	pushInterval = 1 * time.Second
+// Verify that Collector implements lib.FailingCollector, lib.BufferingCollector, lib.ReadyCollector
+// and lib.FlushingCollector
+var (
+	_ lib.FailingCollector   = &Collector{}
+	_ lib.BufferingCollector = &Collector{}
+	_ lib.ReadyCollector     = &Collector{}
+	_ lib.FlushingCollector  = &Collector{}
+	_ lib.LabeledCollector   = &Collector{}
 )
 
-// Verify that Collector implements lib.Collector
-var _ lib.Collector = &Collector{}
+// How often the server is pinged while waiting for it to become ready.
+var readyPingInterval = 1 * time.Second
 
 type Collector struct {
 	Client    client.Client
 	Config    Config
 	BatchConf client.BatchPointsConfig
 
-	buffer     []stats.Sample
+	buffer     []stats.SampleContainer
+	buffered   int // the number of samples in the buffer
 	bufferLock sync.Mutex
+	// The number of samples in the batches that are waiting to be written or being written.
+	pendingSamples int64
+
+	// Limits the number of batches that are written at the same time.
+	semaphoreCh chan struct{}
+	wg          sync.WaitGroup
+
+	lib.CollectorFailures
+	lib.CollectorLabel
 }
 
 func New(conf Config) (*Collector, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
 	cl, err := MakeClient(conf)
 	if err != nil {
 		return nil, err
 	}
 	batchConf := MakeBatchConfig(conf)
+	concurrentWrites := conf.ConcurrentWrites.Int64
+	if concurrentWrites < 1 {
+		concurrentWrites = 1
+	}
 	return &Collector{
-		Client:    cl,
-		Config:    conf,
-		BatchConf: batchConf,
+		Client:      cl,
+		Config:      conf,
+		BatchConf:   batchConf,
+		semaphoreCh: make(chan struct{}, concurrentWrites),
 	}, nil
 }
 
@@ -65,14 +90,18 @@ func (c *Collector) Init() error {
 	// usually means we're either a non-admin user to an existing DB or connecting over UDP.
 	_, err := c.Client.Query(client.NewQuery("CREATE DATABASE "+c.BatchConf.Database, "", ""))
 	if err != nil {
-		log.WithError(err).Debug("InfluxDB: Couldn't create database; most likely harmless")
+		c.Logger().WithError(err).Debug("InfluxDB: Couldn't create database; most likely harmless")
 	}
 
 	return nil
 }
 
 func (c *Collector) Run(ctx context.Context) {
-	log.Debug("InfluxDB: Running!")
+	c.Logger().Debug("InfluxDB: Running!")
+	pushInterval := time.Duration(c.Config.PushInterval.Duration)
+	if pushInterval <= 0 {
+		pushInterval = time.Duration(NewConfig().PushInterval.Duration)
+	}
 	ticker := time.NewTicker(pushInterval)
 	for {
 		select {
@@ -80,16 +109,36 @@ func (c *Collector) Run(ctx context.Context) {
 			c.commit()
 		case <-ctx.Done():
 			c.commit()
+			c.wg.Wait()
 			return
 		}
 	}
 }
 
+// WaitReady pings the InfluxDB server until it responds. Over UDP, it's always ready.
+func (c *Collector) WaitReady(ctx context.Context) error {
+	ticker := time.NewTicker(readyPingInterval)
+	defer ticker.Stop()
+	for {
+		_, _, err := c.Client.Ping(readyPingInterval)
+		if err == nil {
+			return nil
+		}
+		c.Logger().WithError(err).Debug("InfluxDB: The server isn't ready yet")
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return errors.Wrap(err, "InfluxDB server isn't ready")
+		}
+	}
+}
+
 func (c *Collector) Collect(scs []stats.SampleContainer) {
 	c.bufferLock.Lock()
 	defer c.bufferLock.Unlock()
+	c.buffer = append(c.buffer, scs...)
 	for _, sc := range scs {
-		c.buffer = append(c.buffer, sc.GetSamples()...)
+		c.buffered += len(sc.GetSamples())
 	}
 }
 
@@ -97,26 +146,88 @@ func (c *Collector) Link() string {
 	return c.Config.Addr.String
 }
 
-func (c *Collector) commit() {
+// BufferedSamples returns the number of samples that weren't written yet.
+func (c *Collector) BufferedSamples() int {
+	c.bufferLock.Lock()
+	defer c.bufferLock.Unlock()
+	return c.buffered + int(atomic.LoadInt64(&c.pendingSamples))
+}
+
+// SpillSamples takes the samples that weren't committed yet out of the buffer and returns them.
+// The ones in the batches that are being written aren't included.
+func (c *Collector) SpillSamples() []stats.SampleContainer {
+	c.bufferLock.Lock()
+	defer c.bufferLock.Unlock()
+	containers := c.buffer
+	c.buffer, c.buffered = nil, 0
+	return containers
+}
+
+// takeBuffer takes the samples out of the buffer.
+func (c *Collector) takeBuffer() []stats.Sample {
 	c.bufferLock.Lock()
-	samples := c.buffer
-	c.buffer = nil
+	containers := c.buffer
+	c.buffer, c.buffered = nil, 0
 	c.bufferLock.Unlock()
+	return stats.FlattenSampleContainers(containers)
+}
+
+func (c *Collector) commit() {
+	samples := c.takeBuffer()
 
-	log.Debug("InfluxDB: Committing...")
+	c.Logger().Debug("InfluxDB: Committing...")
 
 	batch, err := c.batchFromSamples(samples)
 	if err != nil {
+		c.RecordFailedSamples(err, len(samples))
 		return
 	}
 
-	log.WithField("points", len(batch.Points())).Debug("InfluxDB: Writing...")
+	// Wait for a free write slot, so slow writes don't pile up, and write the batch in the
+	// background, so multiple batches can be written at the same time if configured.
+	atomic.AddInt64(&c.pendingSamples, int64(len(samples)))
+	c.semaphoreCh <- struct{}{}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		_ = c.write(batch, len(samples))
+	}()
+}
+
+// Flush writes the buffered samples right away and waits until they're written. It takes a write
+// slot like the regular commits do, so it's safe to call while they're running.
+func (c *Collector) Flush() error {
+	samples := c.takeBuffer()
+
+	batch, err := c.batchFromSamples(samples)
+	if err != nil {
+		c.RecordFailedSamples(err, len(samples))
+		return err
+	}
+
+	atomic.AddInt64(&c.pendingSamples, int64(len(samples)))
+	c.semaphoreCh <- struct{}{}
+	return c.write(batch, len(samples))
+}
+
+// write writes a batch of the given number of samples and frees the write slot that was taken
+// for it.
+func (c *Collector) write(batch client.BatchPoints, samples int) error {
+	defer func() {
+		<-c.semaphoreCh
+		atomic.AddInt64(&c.pendingSamples, -int64(samples))
+	}()
+
+	c.Logger().WithField("points", len(batch.Points())).Debug("InfluxDB: Writing...")
 	startTime := time.Now()
 	if err := c.Client.Write(batch); err != nil {
-		log.WithError(err).Error("InfluxDB: Couldn't write stats")
+		c.Logger().WithError(err).Error("InfluxDB: Couldn't write stats")
+		c.RecordFailedSamples(err, samples)
+		return err
 	}
 	t := time.Since(startTime)
-	log.WithField("t", t).Debug("InfluxDB: Batch written!")
+	c.Logger().WithField("t", t).Debug("InfluxDB: Batch written!")
+	return nil
 }
 
 func (c *Collector) extractTagsToValues(tags map[string]string, values map[string]interface{}) map[string]interface{} {
@@ -132,7 +243,7 @@ func (c *Collector) extractTagsToValues(tags map[string]string, values map[strin
 func (c *Collector) batchFromSamples(samples []stats.Sample) (client.BatchPoints, error) {
 	batch, err := client.NewBatchPoints(c.BatchConf)
 	if err != nil {
-		log.WithError(err).Error("InfluxDB: Couldn't make a batch")
+		c.Logger().WithError(err).Error("InfluxDB: Couldn't make a batch")
 		return nil, err
 	}
 
@@ -162,7 +273,7 @@ func (c *Collector) batchFromSamples(samples []stats.Sample) (client.BatchPoints
 			sample.Time,
 		)
 		if err != nil {
-			log.WithError(err).Error("InfluxDB: Couldn't make point from sample!")
+			c.Logger().WithError(err).Error("InfluxDB: Couldn't make point from sample!")
 			return nil, err
 		}
 		batch.AddPoint(p)
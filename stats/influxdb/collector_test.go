@@ -0,0 +1,214 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package influxdb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestNewValidatesConfig(t *testing.T) {
+	_, err := New(Config{Precision: null.StringFrom("minutes")})
+	assert.EqualError(t, err, "precision must be a time unit like ns, us, ms or s, not minutes")
+
+	_, err = New(Config{ConcurrentWrites: null.IntFrom(0)})
+	assert.EqualError(t, err, "concurrentWrites must be a positive number, not 0")
+
+	_, err = New(Config{PushInterval: types.NullDurationFrom(0)})
+	assert.EqualError(t, err, "pushInterval must be a positive duration, not 0s")
+
+	for _, precision := range []string{"", "ns", "us", "µs", "ms", "s", "m", "h"} {
+		_, err = New(Config{Precision: null.StringFrom(precision)})
+		assert.NoError(t, err, precision)
+	}
+}
+
+func TestCollectorConcurrentWrites(t *testing.T) {
+	var (
+		mu                 sync.Mutex
+		inFlight, maxWrite int
+		precisions         []string
+	)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxWrite {
+			maxWrite = inFlight
+		}
+		precisions = append(precisions, r.URL.Query().Get("precision"))
+		mu.Unlock()
+
+		started <- struct{}{}
+		<-release
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c, err := New(NewConfig().Apply(Config{
+		Addr:             null.StringFrom(srv.URL),
+		ConcurrentWrites: null.IntFrom(2),
+		Precision:        null.StringFrom("ms"),
+	}))
+	require.NoError(t, err)
+
+	metric := stats.New("test_gauge", stats.Gauge)
+	for i := 0; i < 2; i++ {
+		c.Collect([]stats.SampleContainer{stats.Sample{Metric: metric, Time: time.Now(), Value: float64(i)}})
+		c.commit()
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the concurrent writes")
+		}
+	}
+	close(release)
+	c.wg.Wait()
+
+	assert.Equal(t, 2, maxWrite)
+	assert.Equal(t, []string{"ms", "ms"}, precisions)
+}
+
+func TestCollectorPushInterval(t *testing.T) {
+	written := make(chan struct{}, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/write" {
+			written <- struct{}{}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c, err := New(NewConfig().Apply(Config{
+		Addr:         null.StringFrom(srv.URL),
+		PushInterval: types.NullDurationFrom(10 * time.Millisecond),
+	}))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Run(ctx)
+
+	metric := stats.New("test_gauge", stats.Gauge)
+	c.Collect([]stats.SampleContainer{stats.Sample{Metric: metric, Time: time.Now(), Value: 1}})
+	select {
+	case <-written:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the samples to be pushed before the end of the test")
+	}
+}
+
+func TestCollectorDeliveryFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	c, err := New(NewConfig().Apply(Config{Addr: null.StringFrom(srv.URL)}))
+	require.NoError(t, err)
+	assert.NoError(t, c.DeliveryFailure())
+
+	metric := stats.New("test_gauge", stats.Gauge)
+	c.Collect([]stats.SampleContainer{stats.Sample{Metric: metric, Time: time.Now(), Value: 1}})
+	c.commit()
+	c.wg.Wait()
+	assert.Error(t, c.DeliveryFailure())
+}
+
+func TestCollectorFlush(t *testing.T) {
+	var mu sync.Mutex
+	writes := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		writes++
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c, err := New(NewConfig().Apply(Config{Addr: null.StringFrom(srv.URL)}))
+	require.NoError(t, err)
+
+	metric := stats.New("test_gauge", stats.Gauge)
+	c.Collect([]stats.SampleContainer{stats.Sample{Metric: metric, Time: time.Now(), Value: 1}})
+	require.NoError(t, c.Flush())
+
+	mu.Lock()
+	assert.Equal(t, 1, writes)
+	mu.Unlock()
+	assert.Equal(t, 0, c.BufferedSamples())
+}
+
+func TestCollectorWaitReady(t *testing.T) {
+	defer func(interval time.Duration) { readyPingInterval = interval }(readyPingInterval)
+	readyPingInterval = 10 * time.Millisecond
+
+	var mu sync.Mutex
+	pings := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ping" {
+			mu.Lock()
+			pings++
+			ready := pings > 2
+			mu.Unlock()
+			if !ready {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c, err := New(NewConfig().Apply(Config{Addr: null.StringFrom(srv.URL)}))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, c.WaitReady(ctx))
+	assert.Equal(t, 3, pings)
+
+	t.Run("timeout", func(t *testing.T) {
+		srv.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err := c.WaitReady(ctx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "InfluxDB server isn't ready")
+	})
+}
@@ -24,9 +24,11 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/kubernetes/helm/pkg/strvals"
 	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/stats/tlsconfig"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 	null "gopkg.in/guregu/null.v3"
@@ -40,6 +42,21 @@ type Config struct {
 	Insecure    null.Bool   `json:"insecure,omitempty" envconfig:"INFLUXDB_INSECURE"`
 	PayloadSize null.Int    `json:"payloadSize,omitempty" envconfig:"INFLUXDB_PAYLOAD_SIZE"`
 
+	// The TLS configuration for https:// addresses. It can also be set with the tls_ query
+	// parameters of the output URL, e.g. tls_ca=/path/to/ca.pem, and insecure is the same as
+	// the insecure option of the TLS configuration.
+	TLS tlsconfig.Config `json:"tls" envconfig:"INFLUXDB_TLS"`
+
+	// The maximum number of batches that are written at the same time. With more than 1,
+	// the batches may be written out of order, which InfluxDB handles fine, since all
+	// points have their own timestamps.
+	ConcurrentWrites null.Int `json:"concurrentWrites,omitempty" envconfig:"INFLUXDB_CONCURRENT_WRITES"`
+
+	// How often the buffered samples are written. Each InfluxDB output has its own interval,
+	// independent of the other outputs, and it can also be set with the push_interval query
+	// parameter of the output URL, e.g. when there are multiple InfluxDB outputs.
+	PushInterval types.NullDuration `json:"pushInterval,omitempty" envconfig:"INFLUXDB_PUSH_INTERVAL"`
+
 	// Samples.
 	DB           null.String `json:"db" envconfig:"INFLUXDB_DB"`
 	Precision    null.String `json:"precision,omitempty" envconfig:"INFLUXDB_PRECISION"`
@@ -50,13 +67,32 @@ type Config struct {
 
 func NewConfig() *Config {
 	c := &Config{
-		Addr:         null.NewString("http://localhost:8086", false),
-		DB:           null.NewString("k6", false),
-		TagsAsFields: []string{"vu", "iter", "url"},
+		Addr:             null.NewString("http://localhost:8086", false),
+		DB:               null.NewString("k6", false),
+		TagsAsFields:     []string{"vu", "iter", "url"},
+		ConcurrentWrites: null.NewInt(1, false),
+		PushInterval:     types.NewNullDuration(1*time.Second, false),
 	}
 	return c
 }
 
+// Validate checks the values of the options that can't be validated when they are parsed.
+func (c Config) Validate() error {
+	if c.ConcurrentWrites.Valid && c.ConcurrentWrites.Int64 < 1 {
+		return errors.Errorf("concurrentWrites must be a positive number, not %d", c.ConcurrentWrites.Int64)
+	}
+	if c.PushInterval.Valid && c.PushInterval.Duration <= 0 {
+		return errors.Errorf("pushInterval must be a positive duration, not %s", c.PushInterval.Duration)
+	}
+	// The same check as the client's, which accepts any time unit, defaulting to ns
+	if c.Precision.String != "" {
+		if _, err := time.ParseDuration("1" + c.Precision.String); err != nil {
+			return errors.Errorf("precision must be a time unit like ns, us, ms or s, not %s", c.Precision.String)
+		}
+	}
+	return nil
+}
+
 func (c Config) Apply(cfg Config) Config {
 	if cfg.Addr.Valid {
 		c.Addr = cfg.Addr
@@ -70,9 +106,16 @@ func (c Config) Apply(cfg Config) Config {
 	if cfg.Insecure.Valid {
 		c.Insecure = cfg.Insecure
 	}
+	c.TLS = c.TLS.Apply(cfg.TLS)
 	if cfg.PayloadSize.Valid && cfg.PayloadSize.Int64 > 0 {
 		c.PayloadSize = cfg.PayloadSize
 	}
+	if cfg.ConcurrentWrites.Valid {
+		c.ConcurrentWrites = cfg.ConcurrentWrites
+	}
+	if cfg.PushInterval.Valid {
+		c.PushInterval = cfg.PushInterval
+	}
 	if cfg.DB.Valid {
 		c.DB = cfg.DB
 	}
@@ -155,6 +198,12 @@ func ParseURL(text string) (Config, error) {
 			var size int
 			size, err = strconv.Atoi(vs[0])
 			c.PayloadSize = null.IntFrom(int64(size))
+		case "concurrent_writes":
+			var writes int
+			writes, err = strconv.Atoi(vs[0])
+			c.ConcurrentWrites = null.IntFrom(int64(writes))
+		case "push_interval":
+			err = c.PushInterval.UnmarshalText([]byte(vs[0]))
 		case "precision":
 			c.Precision = null.StringFrom(vs[0])
 		case "retention":
@@ -164,7 +213,13 @@ func ParseURL(text string) (Config, error) {
 		case "tagsAsFields":
 			c.TagsAsFields = vs
 		default:
-			return c, errors.Errorf("unknown query parameter: %s", k)
+			ok, tlsErr := c.TLS.ParseQueryParam(k, vs[0])
+			if tlsErr != nil {
+				return c, tlsErr
+			}
+			if !ok {
+				return c, errors.Errorf("unknown query parameter: %s", k)
+			}
 		}
 	}
 	return c, err
@@ -22,7 +22,10 @@ package influxdb
 
 import (
 	"testing"
+	"time"
 
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/stats/tlsconfig"
 	"github.com/stretchr/testify/assert"
 	null "gopkg.in/guregu/null.v3"
 )
@@ -35,6 +38,8 @@ func TestParseArg(t *testing.T) {
 		"addr=http://localhost:8086,db=dbname": {Addr: null.StringFrom("http://localhost:8086"), DB: null.StringFrom("dbname")},
 		"addr=http://localhost:8086,db=dbname,insecure=false,payloadSize=69,":                    {Addr: null.StringFrom("http://localhost:8086"), DB: null.StringFrom("dbname"), Insecure: null.BoolFrom(false), PayloadSize: null.IntFrom(69)},
 		"addr=http://localhost:8086,db=dbname,insecure=false,payloadSize=69,tagsAsFields={fake}": {Addr: null.StringFrom("http://localhost:8086"), DB: null.StringFrom("dbname"), Insecure: null.BoolFrom(false), PayloadSize: null.IntFrom(69), TagsAsFields: []string{"fake"}},
+		"concurrentWrites=4,precision=s":                                                         {ConcurrentWrites: null.IntFrom(4), Precision: null.StringFrom("s")},
+		"pushInterval=5s":                                                                        {PushInterval: types.NullDurationFrom(5 * time.Second)},
 	}
 
 	for str, expConfig := range testdata {
@@ -59,12 +64,20 @@ func TestParseURL(t *testing.T) {
 		Config Config
 		Err    string
 	}{
-		"?":                {Config{}, ""},
-		"?insecure=false":  {Config{Insecure: null.BoolFrom(false)}, ""},
-		"?insecure=true":   {Config{Insecure: null.BoolFrom(true)}, ""},
-		"?insecure=ture":   {Config{}, "insecure must be true or false, not ture"},
-		"?payload_size=69": {Config{PayloadSize: null.IntFrom(69)}, ""},
-		"?payload_size=a":  {Config{}, "strconv.Atoi: parsing \"a\": invalid syntax"},
+		"?":                    {Config{}, ""},
+		"?insecure=false":      {Config{Insecure: null.BoolFrom(false)}, ""},
+		"?insecure=true":       {Config{Insecure: null.BoolFrom(true)}, ""},
+		"?insecure=ture":       {Config{}, "insecure must be true or false, not ture"},
+		"?payload_size=69":     {Config{PayloadSize: null.IntFrom(69)}, ""},
+		"?payload_size=a":      {Config{}, "strconv.Atoi: parsing \"a\": invalid syntax"},
+		"?concurrent_writes=4": {Config{ConcurrentWrites: null.IntFrom(4)}, ""},
+		"?precision=ms":        {Config{Precision: null.StringFrom("ms")}, ""},
+		"?push_interval=100ms": {Config{PushInterval: types.NullDurationFrom(100 * time.Millisecond)}, ""},
+		"?tls_ca=/ca.pem&tls_server_name=influx": {Config{TLS: tlsconfig.Config{
+			CA: null.StringFrom("/ca.pem"), ServerName: null.StringFrom("influx"),
+		}}, ""},
+		"?tls_insecure=ture": {Config{}, "tls_insecure must be true or false, not ture"},
+		"?tls_unknown=1":     {Config{}, "unknown query parameter: tls_unknown"},
 	}
 	for str, data := range testdata {
 		t.Run(str, func(t *testing.T) {
@@ -37,12 +37,21 @@ func MakeClient(conf Config) (client.Client, error) {
 	if conf.Addr.String == "" {
 		conf.Addr = null.StringFrom("http://localhost:8086")
 	}
+	tlsConf := conf.TLS
+	if !tlsConf.Insecure.Valid {
+		tlsConf.Insecure = conf.Insecure
+	}
+	tlsConfig, err := tlsConf.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
 	return client.NewHTTPClient(client.HTTPConfig{
 		Addr:               conf.Addr.String,
 		Username:           conf.Username.String,
 		Password:           conf.Password.String,
 		UserAgent:          "k6",
 		InsecureSkipVerify: conf.Insecure.Bool,
+		TLSConfig:          tlsConfig,
 	})
 }
 
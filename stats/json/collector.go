@@ -21,14 +21,19 @@
 package json
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
+	"sync"
+	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/stats"
-	log "github.com/sirupsen/logrus"
+	"github.com/pkg/errors"
 	"github.com/spf13/afero"
 )
 
@@ -36,10 +41,39 @@ type Collector struct {
 	outfile     io.WriteCloser
 	fname       string
 	seenMetrics []string
+	thresholds  bool
+
+	// If relativeTime is enabled, the samples get their time relative to startTime too.
+	relativeTime bool
+	startTime    time.Time
+	// If greater than 0, the times of the samples are truncated to a multiple of it.
+	timeResolution time.Duration
+	// The number of goroutines that serialize the samples of large batches. With more than one,
+	// they're started by Init() and get the chunks of the batches through jobs, which is nil
+	// until then and after the end of the test.
+	workers      int
+	workersMutex sync.Mutex
+	jobs         chan serializeJob
+
+	// If checks is enabled, the results of the checks are counted, in the order they're first
+	// seen, and written at the end of the test.
+	checks       bool
+	checkResults map[checkKey]*JSONCheckResult
+	checkOrder   []*JSONCheckResult
+
+	// The threshold results are written from another goroutine than the samples.
+	writeMutex sync.Mutex
+	written    int64
+
+	lib.CollectorFailures
+	lib.CollectorLabel
 }
 
-// Verify that Collector implements lib.Collector
-var _ lib.Collector = &Collector{}
+// Verify that Collector implements lib.FailingCollector, lib.ThresholdsCollector and lib.SummaryCollector
+var _ lib.FailingCollector = &Collector{}
+var _ lib.ThresholdsCollector = &Collector{}
+var _ lib.SummaryCollector = &Collector{}
+var _ lib.LabeledCollector = &Collector{}
 
 // Similar to ioutil.NopCloser, but for writers
 type nopCloser struct {
@@ -57,11 +91,43 @@ func (c *Collector) HasSeenMetric(str string) bool {
 	return false
 }
 
-func New(fs afero.Fs, fname string) (*Collector, error) {
+// New creates a JSON output, which writes the samples to the given file, to the standard
+// output for "" or "-", or sends them to an HTTP(S) endpoint for http:// and https:// URLs.
+func New(fs afero.Fs, fname string, conf Config) (*Collector, error) {
+	if conf.TimeResolution.Duration < 0 {
+		return nil, errors.Errorf("the time resolution can't be negative, got %s", time.Duration(conf.TimeResolution.Duration))
+	}
+	workers := int(conf.Workers.Int64)
+	if !conf.Workers.Valid {
+		workers = 1
+	}
+	if workers < 1 {
+		return nil, errors.Errorf("the number of workers has to be at least 1, got %d", workers)
+	}
+	if isHTTPTarget(fname) {
+		tlsConfig, err := conf.TLS.TLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		return &Collector{
+			outfile:        newHTTPWriter(fname, conf.HTTPGzip.Bool, tlsConfig),
+			fname:          fname,
+			thresholds:     conf.Thresholds.Bool,
+			checks:         conf.Checks.Bool,
+			relativeTime:   conf.RelativeTime.Bool,
+			timeResolution: time.Duration(conf.TimeResolution.Duration),
+			workers:        workers,
+		}, nil
+	}
 	if fname == "" || fname == "-" {
 		return &Collector{
-			outfile: nopCloser{os.Stdout},
-			fname:   "-",
+			outfile:        nopCloser{os.Stdout},
+			fname:          "-",
+			thresholds:     conf.Thresholds.Bool,
+			checks:         conf.Checks.Bool,
+			relativeTime:   conf.RelativeTime.Bool,
+			timeResolution: time.Duration(conf.TimeResolution.Duration),
+			workers:        workers,
 		}, nil
 	}
 
@@ -70,26 +136,116 @@ func New(fs afero.Fs, fname string) (*Collector, error) {
 		return nil, err
 	}
 	return &Collector{
-		outfile: logfile,
-		fname:   fname,
+		outfile:        logfile,
+		fname:          fname,
+		thresholds:     conf.Thresholds.Bool,
+		checks:         conf.Checks.Bool,
+		relativeTime:   conf.RelativeTime.Bool,
+		timeResolution: time.Duration(conf.TimeResolution.Duration),
+		workers:        workers,
 	}, nil
 }
 
+// Init records the current time as the start of the test, which the relative times of the
+// samples are based on, unless it was already set by SetStartTime.
 func (c *Collector) Init() error {
+	if c.startTime.IsZero() {
+		c.startTime = time.Now()
+	}
+	if c.workers > 1 {
+		c.jobs = make(chan serializeJob)
+		for i := 0; i < c.workers; i++ {
+			go func(jobs <-chan serializeJob) {
+				for job := range jobs {
+					job.run(c)
+				}
+			}(c.jobs)
+		}
+	}
 	return nil
 }
 
+// stopWorkers stops the workers, if they were started. The samples collected afterwards are
+// serialized by Collect() itself.
+func (c *Collector) stopWorkers() {
+	c.workersMutex.Lock()
+	defer c.workersMutex.Unlock()
+	if c.jobs != nil {
+		close(c.jobs)
+		c.jobs = nil
+	}
+}
+
+// SetStartTime sets the start of the test run, which the relative times of the samples are
+// based on.
+func (c *Collector) SetStartTime(t time.Time) {
+	c.startTime = t
+}
+
 func (c *Collector) SetRunStatus(status lib.RunStatus) {}
 
 func (c *Collector) Run(ctx context.Context) {
-	log.WithField("filename", c.fname).Debug("JSON: Writing JSON metrics")
+	c.Logger().WithField("filename", c.fname).Debug("JSON: Writing JSON metrics")
+	defer c.stopWorkers()
+	if w, ok := c.outfile.(*httpWriter); ok {
+		ticker := time.NewTicker(httpPushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.flushHTTP(w)
+			case <-ctx.Done():
+				c.writeCheckResults()
+				c.flushHTTP(w)
+				return
+			}
+		}
+	}
 	<-ctx.Done()
+	c.writeCheckResults()
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
 	_ = c.outfile.Close()
 }
 
+// flushHTTP sends the rows buffered by the HTTP(S) writer, counting the samples of a batch that
+// couldn't be sent as failed.
+func (c *Collector) flushHTTP(w *httpWriter) {
+	if batch, err := w.flush(); err != nil {
+		c.Logger().WithError(err).WithField("url", c.fname).Error("JSON: Couldn't send the samples")
+		c.RecordFailedSamples(err, countSampleRows(batch))
+	}
+}
+
+func (c *Collector) write(row []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	n, err := c.outfile.Write(row)
+	c.written += int64(n)
+	return err
+}
+
+// SummaryLines returns where the samples were written and how much, for the end-of-test summary.
+// There's nothing to show for the standard output and HTTP(S) targets.
+func (c *Collector) SummaryLines() []string {
+	if c.fname == "-" || isHTTPTarget(c.fname) {
+		return nil
+	}
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	return []string{fmt.Sprintf("written to %s (%s)", c.fname, humanize.Bytes(uint64(c.written)))}
+}
+
 func (c *Collector) HandleMetric(m *stats.Metric) {
+	if row := c.metricRow(m); row != nil {
+		c.writeRow(row)
+	}
+}
+
+// metricRow returns the row describing the metric, or nil if it was already seen.
+func (c *Collector) metricRow(m *stats.Metric) []byte {
 	if c.HasSeenMetric(m.Name) {
-		return
+		return nil
 	}
 
 	c.seenMetrics = append(c.seenMetrics, m.Name)
@@ -97,40 +253,208 @@ func (c *Collector) HandleMetric(m *stats.Metric) {
 	row, err := json.Marshal(env)
 
 	if env == nil || err != nil {
-		log.WithField("filename", c.fname).Warning(
+		c.Logger().WithField("filename", c.fname).Warning(
 			"JSON: Envelope is nil or Metric couldn't be marshalled to JSON")
-		return
+		return nil
 	}
+	return append(row, '\n')
+}
 
-	row = append(row, '\n')
-	_, err = c.outfile.Write(row)
-	if err != nil {
-		log.WithField("filename", c.fname).Error("JSON: Error writing to file")
+// sampleRow returns the row of the sample, or nil if it can't be serialized. It's safe to call
+// from multiple goroutines.
+func (c *Collector) sampleRow(sample stats.Sample) []byte {
+	if c.timeResolution > 0 {
+		sample.Time = sample.Time.Truncate(c.timeResolution)
+	}
+	env := WrapSample(&sample)
+	if c.relativeTime && env != nil {
+		relativeTime := sample.Time.Sub(c.startTime).Seconds()
+		env.Data.(*JSONSample).RelativeTime = &relativeTime
+	}
+	row, err := json.Marshal(env)
+
+	if err != nil || env == nil {
+		// Skip metric if it can't be made into JSON or envelope is null.
+		c.Logger().WithField("filename", c.fname).Warning(
+			"JSON: Envelope is nil or Sample couldn't be marshalled to JSON")
+		return nil
+	}
+	return append(row, '\n')
+}
+
+func (c *Collector) writeRow(row []byte) {
+	if err := c.write(row); err != nil {
+		c.Logger().WithField("filename", c.fname).Error("JSON: Error writing to file")
+		c.RecordFailedSamples(err, countSampleRows(row))
 	}
 }
 
+// sampleRowPrefix is how the rows of the samples start, so they can be told from the others.
+var sampleRowPrefix = []byte(`{"type":"Point",`) //nolint:gochecknoglobals
+
+// countSampleRows returns the number of rows of samples in the rows.
+func countSampleRows(rows []byte) int {
+	return bytes.Count(rows, sampleRowPrefix)
+}
+
+// The least number of samples each worker gets, smaller batches aren't worth spreading.
+const minSamplesPerWorker = 100
+
+// Collect writes the samples, and the metrics the first time they're seen. With more than one
+// worker, large batches are split in as many chunks, which are serialized in parallel and
+// written in order, so the result is the same as with a single worker.
 func (c *Collector) Collect(scs []stats.SampleContainer) {
+	if c.workers == 1 {
+		for _, sc := range scs {
+			for _, sample := range sc.GetSamples() {
+				c.collectSample(sample)
+			}
+		}
+		return
+	}
+
+	var samples []stats.Sample
 	for _, sc := range scs {
-		for _, sample := range sc.GetSamples() {
-			c.HandleMetric(sample.Metric)
+		samples = append(samples, sc.GetSamples()...)
+	}
+	c.workersMutex.Lock()
+	defer c.workersMutex.Unlock()
 
-			env := WrapSample(&sample)
-			row, err := json.Marshal(env)
+	workers := c.workers
+	if max := len(samples) / minSamplesPerWorker; workers > max {
+		workers = max
+	}
+	if workers <= 1 || c.jobs == nil {
+		for _, sample := range samples {
+			c.collectSample(sample)
+		}
+		return
+	}
 
-			if err != nil || env == nil {
-				// Skip metric if it can't be made into JSON or envelope is null.
-				log.WithField("filename", c.fname).Warning(
-					"JSON: Envelope is nil or Sample couldn't be marshalled to JSON")
-				continue
-			}
-			row = append(row, '\n')
-			_, err = c.outfile.Write(row)
-			if err != nil {
-				log.WithField("filename", c.fname).Error("JSON: Error writing to file")
-				continue
-			}
+	// The checks are counted and the metrics are seen in order, before the samples are split
+	// between the workers.
+	metricRows := make(map[int][]byte)
+	for i, sample := range samples {
+		if c.checks {
+			c.countCheck(sample)
+		}
+		if row := c.metricRow(sample.Metric); row != nil {
+			metricRows[i] = row
 		}
 	}
+
+	chunks := make([][]byte, workers)
+	chunkSize := (len(samples) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for w := range chunks {
+		start, end := w*chunkSize, (w+1)*chunkSize
+		if end > len(samples) {
+			end = len(samples)
+		}
+		wg.Add(1)
+		c.jobs <- serializeJob{
+			samples: samples, metricRows: metricRows, start: start, end: end, chunk: &chunks[w], done: &wg,
+		}
+	}
+	wg.Wait()
+
+	for _, chunk := range chunks {
+		if len(chunk) > 0 {
+			c.writeRow(chunk)
+		}
+	}
+}
+
+// collectSample writes the sample, and its metric the first time it's seen.
+func (c *Collector) collectSample(sample stats.Sample) {
+	if c.checks {
+		c.countCheck(sample)
+	}
+	c.HandleMetric(sample.Metric)
+	if row := c.sampleRow(sample); row != nil {
+		c.writeRow(row)
+	}
+}
+
+// serializeJob is a chunk of a batch of samples, which a worker serializes together with the
+// rows of the metrics seen first in it.
+type serializeJob struct {
+	samples    []stats.Sample
+	metricRows map[int][]byte
+	start, end int
+	chunk      *[]byte
+	done       *sync.WaitGroup
+}
+
+func (job serializeJob) run(c *Collector) {
+	defer job.done.Done()
+	var chunk []byte
+	for i := job.start; i < job.end; i++ {
+		chunk = append(chunk, job.metricRows[i]...)
+		chunk = append(chunk, c.sampleRow(job.samples[i])...)
+	}
+	*job.chunk = chunk
+}
+
+type checkKey struct {
+	group, name string
+}
+
+// countCheck counts the result of a check, if the sample is one. The check samples are told by
+// their check tag, since the checks metric may have been renamed or prefixed.
+func (c *Collector) countCheck(sample stats.Sample) {
+	if sample.Metric == nil || sample.Metric.Type != stats.Rate {
+		return
+	}
+	name, ok := sample.Tags.Get("check")
+	if !ok {
+		return
+	}
+	group, _ := sample.Tags.Get("group")
+	key := checkKey{group, name}
+	result, ok := c.checkResults[key]
+	if !ok {
+		if c.checkResults == nil {
+			c.checkResults = make(map[checkKey]*JSONCheckResult)
+		}
+		result = &JSONCheckResult{Name: name, Group: group, metric: sample.Metric.Name}
+		c.checkResults[key] = result
+		c.checkOrder = append(c.checkOrder, result)
+	}
+	if sample.Value != 0 {
+		result.Passes++
+	} else {
+		result.Fails++
+	}
+}
+
+// writeCheckResults writes the results of the checks, if that's enabled.
+func (c *Collector) writeCheckResults() {
+	for _, result := range c.checkOrder {
+		row, err := json.Marshal(WrapCheckResult(result))
+		if err != nil {
+			c.Logger().WithField("filename", c.fname).Warning(
+				"JSON: Check result couldn't be marshalled to JSON")
+			continue
+		}
+		c.writeRow(append(row, '\n'))
+	}
+}
+
+// CollectThresholdResults writes the results of the thresholds, if that's enabled.
+func (c *Collector) CollectThresholdResults(results []stats.ThresholdResult) {
+	if !c.thresholds {
+		return
+	}
+	for _, result := range results {
+		row, err := json.Marshal(WrapThresholdResult(result))
+		if err != nil {
+			c.Logger().WithField("filename", c.fname).Warning(
+				"JSON: Threshold result couldn't be marshalled to JSON")
+			continue
+		}
+		c.writeRow(append(row, '\n'))
+	}
 }
 
 func (c *Collector) Link() string {
@@ -139,5 +463,9 @@ func (c *Collector) Link() string {
 
 // GetRequiredSystemTags returns which sample tags are needed by this collector
 func (c *Collector) GetRequiredSystemTags() lib.TagSet {
-	return lib.TagSet{} // There are no required tags for this collector
+	if c.checks {
+		// The results of the checks are grouped by these.
+		return lib.GetTagSet("check", "group")
+	}
+	return lib.TagSet{}
 }
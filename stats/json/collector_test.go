@@ -21,11 +21,23 @@
 package json
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/stats"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
 )
 
 func TestNew(t *testing.T) {
@@ -39,7 +51,7 @@ func TestNew(t *testing.T) {
 		t.Run("path="+path, func(t *testing.T) {
 			defer func() { _ = os.Remove(path) }()
 
-			collector, err := New(afero.NewOsFs(), path)
+			collector, err := New(afero.NewOsFs(), path, NewConfig())
 			if succ {
 				assert.NoError(t, err)
 				assert.NotNil(t, collector)
@@ -50,3 +62,291 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+func TestCollectThresholdResults(t *testing.T) {
+	results := []stats.ThresholdResult{{
+		Time:      time.Unix(1500000000, 0).UTC(),
+		Metric:    "http_req_duration",
+		Threshold: "p(95)<500",
+		Value:     null.FloatFrom(612.5),
+	}}
+
+	for _, enabled := range []bool{false, true} {
+		fs := afero.NewMemMapFs()
+		conf := NewConfig()
+		conf.Thresholds = null.BoolFrom(enabled)
+		collector, err := New(fs, "/out.json", conf)
+		require.NoError(t, err)
+
+		collector.CollectThresholdResults(results)
+		data, err := afero.ReadFile(fs, "/out.json")
+		require.NoError(t, err)
+		if !enabled {
+			assert.Empty(t, data)
+			continue
+		}
+		assert.Equal(t,
+			`{"type":"Threshold","data":{"time":"2017-07-14T02:40:00Z","threshold":"p(95)\u003c500","value":612.5,"passed":false},"metric":"http_req_duration"}`,
+			strings.TrimSpace(string(data)))
+	}
+}
+
+func TestCollectRelativeTime(t *testing.T) {
+	metric := stats.New("my_metric", stats.Gauge)
+	for _, enabled := range []bool{false, true} {
+		fs := afero.NewMemMapFs()
+		conf := NewConfig()
+		conf.RelativeTime = null.BoolFrom(enabled)
+		collector, err := New(fs, "/out.json", conf)
+		require.NoError(t, err)
+		// The start of the run comes first when the output is only initialized on failure.
+		collector.SetStartTime(time.Unix(1500000000, 0).UTC())
+		require.NoError(t, collector.Init())
+
+		collector.Collect([]stats.SampleContainer{stats.Sample{
+			Metric: metric,
+			Time:   collector.startTime.Add(1500 * time.Millisecond),
+			Value:  1,
+		}})
+		data, err := afero.ReadFile(fs, "/out.json")
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		require.Len(t, lines, 2)
+		if enabled {
+			assert.Equal(t,
+				`{"type":"Point","data":{"time":"2017-07-14T02:40:01.5Z","value":1,"tags":null,"relativeTime":1.5},"metric":"my_metric"}`,
+				lines[1])
+		} else {
+			assert.NotContains(t, lines[1], "relativeTime")
+		}
+	}
+}
+
+func TestCollectTimeResolution(t *testing.T) {
+	metric := stats.New("my_metric", stats.Gauge)
+	sampleTime := time.Unix(1500000000, 0).UTC().Add(1567 * time.Millisecond)
+	testdata := map[time.Duration]string{
+		0:                      "2017-07-14T02:40:01.567Z",
+		100 * time.Millisecond: "2017-07-14T02:40:01.5Z",
+		time.Second:            "2017-07-14T02:40:01Z",
+	}
+	for resolution, expected := range testdata {
+		fs := afero.NewMemMapFs()
+		conf := NewConfig()
+		conf.TimeResolution = types.NullDurationFrom(resolution)
+		collector, err := New(fs, "/out.json", conf)
+		require.NoError(t, err)
+
+		collector.Collect([]stats.SampleContainer{stats.Sample{Metric: metric, Time: sampleTime, Value: 1}})
+		data, err := afero.ReadFile(fs, "/out.json")
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		require.Len(t, lines, 2)
+		assert.Contains(t, lines[1], `"time":"`+expected+`"`, resolution.String())
+	}
+
+	conf := NewConfig()
+	conf.TimeResolution = types.NullDurationFrom(-time.Second)
+	_, err := New(afero.NewMemMapFs(), "/out.json", conf)
+	assert.EqualError(t, err, "the time resolution can't be negative, got -1s")
+}
+
+func manySamples(n int) []stats.SampleContainer {
+	metrics := []*stats.Metric{
+		stats.New("my_gauge", stats.Gauge),
+		stats.New("my_trend", stats.Trend, stats.Time),
+		stats.New("my_counter", stats.Counter),
+	}
+	tags := stats.IntoSampleTags(&map[string]string{"url": "http://example.com/"})
+	start := time.Unix(1500000000, 0).UTC()
+	samples := make(stats.Samples, n)
+	for i := range samples {
+		samples[i] = stats.Sample{
+			Metric: metrics[i%len(metrics)],
+			Time:   start.Add(time.Duration(i) * time.Millisecond),
+			Tags:   tags,
+			Value:  float64(i),
+		}
+	}
+	return []stats.SampleContainer{samples}
+}
+
+func TestCollectWorkers(t *testing.T) {
+	write := func(workers int64, run bool) []byte {
+		fs := afero.NewMemMapFs()
+		conf := NewConfig()
+		conf.Workers = null.IntFrom(workers)
+		collector, err := New(fs, "/out.json", conf)
+		require.NoError(t, err)
+		if run {
+			require.NoError(t, collector.Init())
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				collector.Run(ctx)
+				close(done)
+			}()
+			defer func() {
+				cancel()
+				<-done
+				assert.Nil(t, collector.jobs)
+			}()
+		}
+		collector.Collect(manySamples(10))
+		collector.Collect(manySamples(1000))
+		data, err := afero.ReadFile(fs, "/out.json")
+		require.NoError(t, err)
+		return data
+	}
+
+	expected := write(1, true)
+	assert.Equal(t, 3+1010, strings.Count(string(expected), "\n"))
+	assert.Equal(t, string(expected), string(write(4, true)))
+	assert.Equal(t, string(expected), string(write(100, true)))
+	// Without the workers, which are started by Init(), the batches are serialized by Collect().
+	assert.Equal(t, string(expected), string(write(4, false)))
+
+	conf := NewConfig()
+	conf.Workers = null.IntFrom(0)
+	_, err := New(afero.NewMemMapFs(), "/out.json", conf)
+	assert.EqualError(t, err, "the number of workers has to be at least 1, got 0")
+}
+
+func BenchmarkCollect(b *testing.B) {
+	samples := manySamples(10000)
+	for _, workers := range []int64{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			conf := NewConfig()
+			conf.Workers = null.IntFrom(workers)
+			collector, err := New(afero.NewMemMapFs(), "/out.json", conf)
+			require.NoError(b, err)
+			collector.outfile = nopCloser{ioutil.Discard}
+			require.NoError(b, collector.Init())
+			defer collector.stopWorkers()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				collector.Collect(samples)
+			}
+		})
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
+func (failingWriter) Close() error                { return nil }
+
+func TestCollectFailedSamples(t *testing.T) {
+	conf := NewConfig()
+	conf.Workers = null.IntFrom(2)
+	collector, err := New(afero.NewMemMapFs(), "/out.json", conf)
+	require.NoError(t, err)
+	collector.outfile = failingWriter{}
+	require.NoError(t, collector.Init())
+	defer collector.stopWorkers()
+
+	collector.Collect(manySamples(10))
+	collector.Collect(manySamples(1000))
+	assert.EqualError(t, collector.DeliveryFailure(), "disk full")
+	assert.Equal(t, int64(1010), collector.FailedSamples())
+}
+
+func TestSummaryLines(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	collector, err := New(fs, "/out.json", NewConfig())
+	require.NoError(t, err)
+	collector.Collect([]stats.SampleContainer{stats.Sample{
+		Metric: stats.New("my_metric", stats.Gauge),
+		Time:   time.Unix(1500000000, 0).UTC(),
+		Value:  1,
+	}})
+	data, err := afero.ReadFile(fs, "/out.json")
+	require.NoError(t, err)
+	assert.Equal(t, []string{fmt.Sprintf("written to /out.json (%d B)", len(data))}, collector.SummaryLines())
+
+	collector, err = New(fs, "-", NewConfig())
+	require.NoError(t, err)
+	assert.Empty(t, collector.SummaryLines())
+}
+
+func TestCollectChecks(t *testing.T) {
+	check := func(group, name string, value float64) stats.Sample {
+		return stats.Sample{
+			Metric: metrics.Checks,
+			Time:   time.Unix(1500000000, 0).UTC(),
+			Tags:   stats.IntoSampleTags(&map[string]string{"group": group, "check": name}),
+			Value:  value,
+		}
+	}
+
+	for _, enabled := range []bool{false, true} {
+		fs := afero.NewMemMapFs()
+		conf := NewConfig()
+		conf.Checks = null.BoolFrom(enabled)
+		collector, err := New(fs, "/out.json", conf)
+		require.NoError(t, err)
+		require.NoError(t, collector.Init())
+		if enabled {
+			assert.Equal(t, lib.GetTagSet("check", "group"), collector.GetRequiredSystemTags())
+		} else {
+			assert.Empty(t, collector.GetRequiredSystemTags())
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			collector.Run(ctx)
+			close(done)
+		}()
+		collector.Collect([]stats.SampleContainer{
+			check("", "status is 200", 1),
+			check("::login", "logged in", 0),
+			check("", "status is 200", 0),
+			check("::login", "logged in", 0),
+			check("", "status is 200", 1),
+		})
+		cancel()
+		<-done
+
+		data, err := afero.ReadFile(fs, "/out.json")
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		if !enabled {
+			assert.Len(t, lines, 6)
+			assert.NotContains(t, string(data), `"type":"Check"`)
+			continue
+		}
+		require.Len(t, lines, 8)
+		assert.Equal(t, []string{
+			`{"type":"Check","data":{"name":"status is 200","group":"","passes":2,"fails":1},"metric":"checks"}`,
+			`{"type":"Check","data":{"name":"logged in","group":"::login","passes":0,"fails":2},"metric":"checks"}`,
+		}, lines[6:])
+	}
+
+	t.Run("Prefixed", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+		conf := NewConfig()
+		conf.Checks = null.BoolFrom(true)
+		collector, err := New(fs, "/out.json", conf)
+		require.NoError(t, err)
+		require.NoError(t, collector.Init())
+		prefixed := check("", "status is 200", 1)
+		prefixed.Metric = stats.New("teamA_checks", stats.Rate)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			collector.Run(ctx)
+			close(done)
+		}()
+		collector.Collect([]stats.SampleContainer{prefixed})
+		cancel()
+		<-done
+
+		data, err := afero.ReadFile(fs, "/out.json")
+		require.NoError(t, err)
+		assert.Contains(t, string(data),
+			`{"type":"Check","data":{"name":"status is 200","group":"","passes":1,"fails":0},"metric":"teamA_checks"}`)
+	})
+}
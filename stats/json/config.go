@@ -0,0 +1,98 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package json
+
+import (
+	"github.com/loadimpact/k6/lib/types"
+	"github.com/loadimpact/k6/stats/tlsconfig"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// Config is the configuration of the JSON output.
+type Config struct {
+	// Whether the batches of samples sent to an HTTP(S) target are gzip-compressed. There's no
+	// reliable way to negotiate the compression of POST requests, so it has to be enabled
+	// explicitly. If the target rejects a compressed batch, it's sent again uncompressed and
+	// the compression is disabled for the rest of the test.
+	HTTPGzip null.Bool `json:"httpGzip" envconfig:"JSON_HTTP_GZIP"`
+
+	// Whether the results of the thresholds are written too, as "Threshold" entries, every time
+	// the engine evaluates them.
+	Thresholds null.Bool `json:"thresholds" envconfig:"JSON_THRESHOLDS"`
+
+	// Whether the results of the checks are written too, as a "Check" entry for every check with
+	// its group and how many times it passed and failed, at the end of the test. The samples of
+	// the checks metric are still written as usual.
+	Checks null.Bool `json:"checks" envconfig:"JSON_CHECKS"`
+
+	// Whether the samples also get their time relative to the start of the test, in seconds, so
+	// the samples of several test runs can be compared without post-processing.
+	RelativeTime null.Bool `json:"relativeTime" envconfig:"JSON_RELATIVE_TIME"`
+
+	// If set, the times of the samples are truncated to a multiple of it, e.g. 1s for whole
+	// seconds, for smaller files that are easier to diff. This is lossy: the samples within the
+	// same interval can't be told apart by their times anymore, and the original times are lost.
+	TimeResolution types.NullDuration `json:"timeResolution" envconfig:"JSON_TIME_RESOLUTION"`
+
+	// The number of goroutines that serialize the samples, for tests with so many samples that
+	// serializing them on a single goroutine is a bottleneck. The samples are still written in
+	// the order they were collected in.
+	Workers null.Int `json:"workers" envconfig:"JSON_WORKERS"`
+
+	// The TLS configuration for https:// targets.
+	TLS tlsconfig.Config `json:"tls" envconfig:"JSON_TLS"`
+}
+
+// NewConfig returns the default configuration of the JSON output.
+func NewConfig() Config {
+	return Config{
+		HTTPGzip:       null.NewBool(false, false),
+		Thresholds:     null.NewBool(false, false),
+		Checks:         null.NewBool(false, false),
+		RelativeTime:   null.NewBool(false, false),
+		TimeResolution: types.NewNullDuration(0, false),
+		Workers:        null.NewInt(1, false),
+	}
+}
+
+// Apply overwrites the fields of the configuration with the ones set in the argument.
+func (c Config) Apply(cfg Config) Config {
+	if cfg.HTTPGzip.Valid {
+		c.HTTPGzip = cfg.HTTPGzip
+	}
+	if cfg.Thresholds.Valid {
+		c.Thresholds = cfg.Thresholds
+	}
+	if cfg.Checks.Valid {
+		c.Checks = cfg.Checks
+	}
+	if cfg.RelativeTime.Valid {
+		c.RelativeTime = cfg.RelativeTime
+	}
+	if cfg.TimeResolution.Valid {
+		c.TimeResolution = cfg.TimeResolution
+	}
+	if cfg.Workers.Valid {
+		c.Workers = cfg.Workers
+	}
+	c.TLS = c.TLS.Apply(cfg.TLS)
+	return c
+}
@@ -0,0 +1,160 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package json
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// httpPushInterval is how often the buffered rows are sent to an HTTP(S) target.
+const httpPushInterval = 1 * time.Second
+
+// isHTTPTarget returns whether the JSON output should send its rows to an HTTP(S) endpoint
+// instead of writing them to a file.
+func isHTTPTarget(fname string) bool {
+	return strings.HasPrefix(fname, "http://") || strings.HasPrefix(fname, "https://")
+}
+
+// httpWriter buffers the written rows and POSTs them in batches to an HTTP(S) endpoint,
+// as newline-delimited JSON, optionally gzip-compressed.
+type httpWriter struct {
+	url    string
+	client *http.Client
+
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+	gzip   bool
+}
+
+// newHTTPWriter creates a writer for the given URL. The TLS configuration is optional, with nil
+// the default transport is used.
+func newHTTPWriter(url string, gzip bool, tlsConfig *tls.Config) *httpWriter {
+	client := &http.Client{Timeout: 30 * time.Second}
+	if tlsConfig != nil {
+		client.Transport = &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig}
+	}
+	return &httpWriter{url: url, client: client, gzip: gzip}
+}
+
+// Write buffers the rows until the next flush.
+func (w *httpWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.buffer.Write(p)
+}
+
+// Close sends the remaining rows.
+func (w *httpWriter) Close() error {
+	return w.Flush()
+}
+
+// Flush sends the buffered rows. If a compressed batch is rejected with a response that means
+// the target doesn't support the encoding, it's retried uncompressed and the compression is
+// disabled. Any other failure, e.g. a server or network error, leaves the compression on.
+func (w *httpWriter) Flush() error {
+	_, err := w.flush()
+	return err
+}
+
+// flush sends the buffered rows like Flush, and returns them, so a caller can tell what was lost
+// if they couldn't be sent.
+func (w *httpWriter) flush() ([]byte, error) {
+	w.mutex.Lock()
+	if w.buffer.Len() == 0 {
+		w.mutex.Unlock()
+		return nil, nil
+	}
+	batch := append([]byte{}, w.buffer.Bytes()...)
+	w.buffer.Reset()
+	useGzip := w.gzip
+	w.mutex.Unlock()
+
+	if useGzip {
+		err := w.post(batch, true)
+		if err == nil || !isEncodingRejected(err) {
+			return batch, err
+		}
+		log.WithError(err).WithField("url", w.url).Warn(
+			"JSON: Couldn't send a compressed batch, disabling the compression")
+		w.mutex.Lock()
+		w.gzip = false
+		w.mutex.Unlock()
+	}
+	return batch, w.post(batch, false)
+}
+
+func (w *httpWriter) post(batch []byte, useGzip bool) error {
+	body := batch
+	if useGzip {
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		if _, err := gz.Write(batch); err != nil {
+			return err
+		}
+		if err := gz.Close(); err != nil {
+			return err
+		}
+		body = compressed.Bytes()
+	}
+
+	req, err := http.NewRequest("POST", w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if useGzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	res, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return statusError{code: res.StatusCode, status: res.Status}
+	}
+	return nil
+}
+
+// statusError is returned for the responses with an unsuccessful status.
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e statusError) Error() string {
+	return "unexpected response status " + e.status
+}
+
+// isEncodingRejected returns whether the error is a response that means the target doesn't
+// support the content encoding of the request.
+func isEncodingRejected(err error) bool {
+	serr, ok := err.(statusError)
+	return ok && (serr.code == http.StatusUnsupportedMediaType || serr.code == http.StatusBadRequest)
+}
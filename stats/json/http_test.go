@@ -0,0 +1,182 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package json
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/stats"
+	"github.com/loadimpact/k6/stats/tlsconfig"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+type receivedBatch struct {
+	encoding string
+	body     string
+}
+
+func newBatchServer(t *testing.T, acceptGzip bool) (*httptest.Server, func() []receivedBatch) {
+	var (
+		mutex   sync.Mutex
+		batches []receivedBatch
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := r.Header.Get("Content-Encoding")
+		if encoding == "gzip" && !acceptGzip {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		body := r.Body
+		if encoding == "gzip" {
+			gz, err := gzip.NewReader(r.Body)
+			require.NoError(t, err)
+			body = gz
+		}
+		data, err := ioutil.ReadAll(body)
+		require.NoError(t, err)
+		assert.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+
+		mutex.Lock()
+		batches = append(batches, receivedBatch{encoding, string(data)})
+		mutex.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return srv, func() []receivedBatch {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]receivedBatch{}, batches...)
+	}
+}
+
+func TestHTTPWriter(t *testing.T) {
+	t.Run("Gzip", func(t *testing.T) {
+		srv, batches := newBatchServer(t, true)
+		defer srv.Close()
+
+		w := newHTTPWriter(srv.URL, true, nil)
+		require.NoError(t, w.Flush()) // nothing to send
+		_, _ = w.Write([]byte("{\"a\":1}\n"))
+		_, _ = w.Write([]byte("{\"b\":2}\n"))
+		require.NoError(t, w.Flush())
+		assert.Equal(t, []receivedBatch{{"gzip", "{\"a\":1}\n{\"b\":2}\n"}}, batches())
+	})
+	t.Run("Uncompressed", func(t *testing.T) {
+		srv, batches := newBatchServer(t, true)
+		defer srv.Close()
+
+		w := newHTTPWriter(srv.URL, false, nil)
+		_, _ = w.Write([]byte("{\"a\":1}\n"))
+		require.NoError(t, w.Close())
+		assert.Equal(t, []receivedBatch{{"", "{\"a\":1}\n"}}, batches())
+	})
+	t.Run("GzipFallback", func(t *testing.T) {
+		srv, batches := newBatchServer(t, false)
+		defer srv.Close()
+
+		w := newHTTPWriter(srv.URL, true, nil)
+		_, _ = w.Write([]byte("{\"a\":1}\n"))
+		require.NoError(t, w.Flush())
+		_, _ = w.Write([]byte("{\"b\":2}\n"))
+		require.NoError(t, w.Flush())
+		assert.Equal(t, []receivedBatch{{"", "{\"a\":1}\n"}, {"", "{\"b\":2}\n"}}, batches())
+		assert.False(t, w.gzip)
+	})
+	t.Run("GzipServerError", func(t *testing.T) {
+		var requests int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+
+		w := newHTTPWriter(srv.URL, true, nil)
+		_, _ = w.Write([]byte("{\"a\":1}\n"))
+		assert.EqualError(t, w.Flush(), "unexpected response status 503 Service Unavailable")
+		assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+		assert.True(t, w.gzip)
+	})
+	t.Run("TLS", func(t *testing.T) {
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer srv.Close()
+
+		w := newHTTPWriter(srv.URL, false, nil)
+		_, _ = w.Write([]byte("{\"a\":1}\n"))
+		assert.Error(t, w.Flush(), "the test server's certificate isn't trusted")
+
+		tlsConfig, err := tlsconfig.Config{Insecure: null.BoolFrom(true)}.TLSConfig()
+		require.NoError(t, err)
+		w = newHTTPWriter(srv.URL, false, tlsConfig)
+		_, _ = w.Write([]byte("{\"a\":1}\n"))
+		assert.NoError(t, w.Flush())
+	})
+	t.Run("Error", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer srv.Close()
+
+		w := newHTTPWriter(srv.URL, false, nil)
+		_, _ = w.Write([]byte("{\"a\":1}\n"))
+		assert.EqualError(t, w.Flush(), "unexpected response status 500 Internal Server Error")
+	})
+}
+
+func TestCollectorHTTPTarget(t *testing.T) {
+	srv, batches := newBatchServer(t, true)
+	defer srv.Close()
+
+	c, err := New(afero.NewMemMapFs(), srv.URL, NewConfig().Apply(Config{HTTPGzip: null.BoolFrom(true)}))
+	require.NoError(t, err)
+	require.IsType(t, &httpWriter{}, c.outfile)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+	metric := stats.New("my_metric", stats.Gauge)
+	c.Collect([]stats.SampleContainer{stats.Sample{Metric: metric, Time: time.Now(), Value: 1}})
+	cancel()
+	<-done
+
+	received := batches()
+	require.Len(t, received, 1)
+	assert.Equal(t, "gzip", received[0].encoding)
+	lines := strings.Split(strings.TrimSuffix(received[0].body, "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"type":"Metric"`)
+	assert.Contains(t, lines[1], `"type":"Point"`)
+}
@@ -23,7 +23,9 @@ package json
 import (
 	"time"
 
+	"github.com/loadimpact/k6/lib/metrics"
 	"github.com/loadimpact/k6/stats"
+	null "gopkg.in/guregu/null.v3"
 )
 
 type Envelope struct {
@@ -36,6 +38,9 @@ type JSONSample struct {
 	Time  time.Time         `json:"time"`
 	Value float64           `json:"value"`
 	Tags  *stats.SampleTags `json:"tags"`
+
+	// The time since the start of the test in seconds, only set if it's enabled.
+	RelativeTime *float64 `json:"relativeTime,omitempty"`
 }
 
 func NewJSONSample(sample *stats.Sample) *JSONSample {
@@ -68,3 +73,46 @@ func WrapMetric(metric *stats.Metric) *Envelope {
 		Data:   metric,
 	}
 }
+
+// JSONThresholdResult is the data of a "Threshold" entry, the result of a threshold evaluation.
+type JSONThresholdResult struct {
+	Time      time.Time  `json:"time"`
+	Threshold string     `json:"threshold"`
+	Value     null.Float `json:"value"`
+	Passed    bool       `json:"passed"`
+}
+
+// JSONCheckResult is the data of a "Check" entry, the results of a check in a group.
+type JSONCheckResult struct {
+	Name   string `json:"name"`
+	Group  string `json:"group"`
+	Passes int64  `json:"passes"`
+	Fails  int64  `json:"fails"`
+
+	metric string // the name of the checks metric, if it was renamed or prefixed
+}
+
+func WrapCheckResult(result *JSONCheckResult) *Envelope {
+	metric := result.metric
+	if metric == "" {
+		metric = metrics.Checks.Name
+	}
+	return &Envelope{
+		Type:   "Check",
+		Metric: metric,
+		Data:   result,
+	}
+}
+
+func WrapThresholdResult(result stats.ThresholdResult) *Envelope {
+	return &Envelope{
+		Type:   "Threshold",
+		Metric: result.Metric,
+		Data: &JSONThresholdResult{
+			Time:      result.Time,
+			Threshold: result.Threshold,
+			Value:     result.Value,
+			Passed:    result.Passed,
+		},
+	}
+}
@@ -39,10 +39,22 @@ type Collector struct {
 	Producer sarama.SyncProducer
 	Config   Config
 
-	Samples []stats.Sample
-	lock    sync.Mutex
+	Samples  []stats.SampleContainer
+	buffered int // the number of samples in Samples
+	lock     sync.Mutex
+
+	lib.CollectorFailures
+	lib.CollectorLabel
 }
 
+// Verify that Collector implements lib.FailingCollector, lib.BufferingCollector and lib.FlushingCollector
+var (
+	_ lib.FailingCollector   = &Collector{}
+	_ lib.BufferingCollector = &Collector{}
+	_ lib.FlushingCollector  = &Collector{}
+	_ lib.LabeledCollector   = &Collector{}
+)
+
 // New creates an instance of the collector
 func New(conf Config) (*Collector, error) {
 	producer, err := sarama.NewSyncProducer(conf.Brokers, nil)
@@ -61,18 +73,18 @@ func (c *Collector) Init() error { return nil }
 
 // Run just blocks until the context is done
 func (c *Collector) Run(ctx context.Context) {
-	log.Debug("Kafka: Running!")
+	c.Logger().Debug("Kafka: Running!")
 	ticker := time.NewTicker(time.Duration(c.Config.PushInterval.Duration))
 	for {
 		select {
 		case <-ticker.C:
-			c.pushMetrics()
+			_ = c.pushMetrics()
 		case <-ctx.Done():
-			c.pushMetrics()
+			_ = c.pushMetrics()
 
 			err := c.Producer.Close()
 			if err != nil {
-				log.WithError(err).Error("Kafka: Failed to close producer.")
+				c.Logger().WithError(err).Error("Kafka: Failed to close producer.")
 			}
 			return
 		}
@@ -87,12 +99,29 @@ func (c *Collector) Run(ctx context.Context) {
 // being collected, it only has to be initialized.
 func (c *Collector) Collect(scs []stats.SampleContainer) {
 	c.lock.Lock()
+	c.Samples = append(c.Samples, scs...)
 	for _, sc := range scs {
-		c.Samples = append(c.Samples, sc.GetSamples()...)
+		c.buffered += len(sc.GetSamples())
 	}
 	c.lock.Unlock()
 }
 
+// BufferedSamples returns the number of samples that weren't sent yet.
+func (c *Collector) BufferedSamples() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.buffered
+}
+
+// SpillSamples takes the samples that weren't sent yet out of the buffer and returns them.
+func (c *Collector) SpillSamples() []stats.SampleContainer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	containers := c.Samples
+	c.Samples, c.buffered = nil, 0
+	return containers
+}
+
 // Link returns a dummy string, it's only included to satisfy the lib.Collector interface
 func (c *Collector) Link() string {
 	return ""
@@ -135,31 +164,42 @@ func (c *Collector) formatSamples(samples stats.Samples) ([]string, error) {
 	return metrics, nil
 }
 
-func (c *Collector) pushMetrics() {
+// Flush sends the buffered samples right away, returning the last error if any of them couldn't
+// be sent.
+func (c *Collector) Flush() error {
+	return c.pushMetrics()
+}
+
+func (c *Collector) pushMetrics() error {
 	startTime := time.Now()
 
 	c.lock.Lock()
-	samples := c.Samples
-	c.Samples = nil
+	containers := c.Samples
+	c.Samples, c.buffered = nil, 0
 	c.lock.Unlock()
+	samples := stats.FlattenSampleContainers(containers)
 
 	// Format the samples
 	formattedSamples, err := c.formatSamples(samples)
 	if err != nil {
-		log.WithError(err).Error("Kafka: Couldn't format the samples")
-		return
+		c.Logger().WithError(err).Error("Kafka: Couldn't format the samples")
+		c.RecordFailedSamples(err, len(samples))
+		return err
 	}
 
 	// Send the samples
-	log.Debug("Kafka: Delivering...")
+	c.Logger().Debug("Kafka: Delivering...")
 
+	var lastErr error
 	for _, sample := range formattedSamples {
 		msg := &sarama.ProducerMessage{Topic: c.Config.Topic.String, Value: sarama.StringEncoder(sample)}
 		partition, offset, err := c.Producer.SendMessage(msg)
 		if err != nil {
-			log.WithError(err).Error("Kafka: failed to send message.")
+			c.Logger().WithError(err).Error("Kafka: failed to send message.")
+			c.RecordFailedSamples(err, 1)
+			lastErr = err
 		} else {
-			log.WithFields(log.Fields{
+			c.Logger().WithFields(log.Fields{
 				"partition": partition,
 				"offset":    offset,
 			}).Debug("Kafka: message sent.")
@@ -167,5 +207,6 @@ func (c *Collector) pushMetrics() {
 	}
 
 	t := time.Since(startTime)
-	log.WithField("t", t).Debug("Kafka: Delivered!")
+	c.Logger().WithField("t", t).Debug("Kafka: Delivered!")
+	return lastErr
 }
@@ -0,0 +1,99 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package stats
+
+import (
+	"sort"
+	"sync"
+)
+
+// A Registry keeps track of the metrics that were declared, so that they can be listed even
+// before they have any samples, and of the factories of the sinks used for each metric type.
+// It's safe for concurrent use.
+type Registry struct {
+	mutex         sync.Mutex
+	metrics       map[string]*Metric
+	sinkFactories map[MetricType]SinkFactory
+}
+
+// NewRegistry returns a new, empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics:       make(map[string]*Metric),
+		sinkFactories: make(map[MetricType]SinkFactory),
+	}
+}
+
+// SetSinkFactory makes the sinks for the metrics of the given type created by the factory,
+// e.g. to use another percentile backend for all trends. A nil factory restores the default.
+func (r *Registry) SetSinkFactory(typ MetricType, factory SinkFactory) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if factory == nil {
+		delete(r.sinkFactories, typ)
+		return
+	}
+	r.sinkFactories[typ] = factory
+}
+
+// NewSink returns a new sink for a metric of the given type, created by its factory, if one
+// was set, or the default sink otherwise.
+func (r *Registry) NewSink(typ MetricType) Sink {
+	r.mutex.Lock()
+	factory := r.sinkFactories[typ]
+	r.mutex.Unlock()
+	if factory != nil {
+		return factory()
+	}
+	return NewSink(typ)
+}
+
+// NewMetric returns a new metric, like New, but with the sink created by NewSink. The metric
+// isn't registered.
+func (r *Registry) NewMetric(name string, typ MetricType, t ...ValueType) *Metric {
+	m := New(name, typ, t...)
+	if m != nil {
+		m.Sink = r.NewSink(typ)
+	}
+	return m
+}
+
+// Register adds the metric to the registry. If a metric with the same name was already
+// registered, the first one is kept.
+func (r *Registry) Register(m *Metric) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.metrics[m.Name]; !ok {
+		r.metrics[m.Name] = m
+	}
+}
+
+// All returns the registered metrics, sorted by name.
+func (r *Registry) All() []*Metric {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	metrics := make([]*Metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		metrics = append(metrics, m)
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+	return metrics
+}
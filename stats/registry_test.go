@@ -0,0 +1,61 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package stats
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	assert.Empty(t, r.All())
+
+	first := New("my_trend", Trend, Time)
+	r.Register(New("my_counter", Counter))
+	r.Register(first)
+	r.Register(New("my_trend", Trend))
+
+	all := r.All()
+	if assert.Len(t, all, 2) {
+		assert.Equal(t, "my_counter", all[0].Name)
+		assert.Equal(t, first, all[1])
+	}
+}
+
+func TestRegistrySinkFactory(t *testing.T) {
+	r := NewRegistry()
+	assert.IsType(t, &TrendSink{}, r.NewSink(Trend))
+
+	r.SetSinkFactory(Trend, func() Sink { return NewReservoirTrendSink(10, 1) })
+	m := r.NewMetric("my_trend", Trend, Time)
+	if assert.IsType(t, &TrendSink{}, m.Sink) {
+		assert.Equal(t, 10, m.Sink.(*TrendSink).MaxValues)
+	}
+	assert.Equal(t, Time, m.Contains)
+	assert.IsType(t, &CounterSink{}, r.NewSink(Counter))
+	assert.Empty(t, r.All())
+
+	r.SetSinkFactory(Trend, nil)
+	assert.Equal(t, 0, r.NewSink(Trend).(*TrendSink).MaxValues)
+	assert.Nil(t, r.NewMetric("my_metric", MetricType(-1)))
+}
@@ -23,6 +23,7 @@ package stats
 import (
 	"errors"
 	"math"
+	"math/rand"
 	"sort"
 	"time"
 )
@@ -56,9 +57,14 @@ func (c *CounterSink) Add(s Sample) {
 func (c *CounterSink) Calc() {}
 
 func (c *CounterSink) Format(t time.Duration) map[string]float64 {
+	// Without a duration there's no rate, and NaN or Inf values can't be encoded in JSON.
+	var rate float64
+	if t > 0 {
+		rate = c.Value / (float64(t) / float64(time.Second))
+	}
 	return map[string]float64{
 		"count": c.Value,
-		"rate":  c.Value / (float64(t) / float64(time.Second)),
+		"rate":  rate,
 	}
 }
 
@@ -79,6 +85,12 @@ func (g *GaugeSink) Add(s Sample) {
 	}
 }
 
+// RestoreGaugeSink returns a GaugeSink in the given state, e.g. read back from a checkpoint, which
+// keeps tracking the minimum correctly when more samples are added to it.
+func RestoreGaugeSink(value, min, max float64) *GaugeSink {
+	return &GaugeSink{Value: value, Min: min, Max: max, minSet: true}
+}
+
 func (g *GaugeSink) Calc() {}
 
 func (g *GaugeSink) Format(t time.Duration) map[string]float64 {
@@ -93,11 +105,27 @@ type TrendSink struct {
 	Min, Max float64
 	Sum, Avg float64
 	Med      float64
+
+	// If MaxValues is greater than 0, at most that many values are kept in Values, selected
+	// by reservoir sampling, and the median and percentiles become approximations.
+	MaxValues int
+	seed      int64
+	rng       *rand.Rand
+}
+
+// NewReservoirTrendSink returns a TrendSink that keeps a bounded random sample of at most
+// size values. The sample is selected with a pseudo-random generator initialized with the
+// supplied seed, so the same samples in the same order always produce the same results.
+func NewReservoirTrendSink(size int, seed int64) *TrendSink {
+	return &TrendSink{
+		Values:    make([]float64, 0, size),
+		MaxValues: size,
+		seed:      seed,
+		rng:       rand.New(rand.NewSource(seed)), //nolint:gosec
+	}
 }
 
 func (t *TrendSink) Add(s Sample) {
-	t.Values = append(t.Values, s.Value)
-	t.jumbled = true
 	t.Count += 1
 	t.Sum += s.Value
 	t.Avg = t.Sum / float64(t.Count)
@@ -108,11 +136,29 @@ func (t *TrendSink) Add(s Sample) {
 	if s.Value < t.Min || t.Count == 1 {
 		t.Min = s.Value
 	}
+
+	if t.MaxValues <= 0 || len(t.Values) < t.MaxValues {
+		t.Values = append(t.Values, s.Value)
+		t.jumbled = true
+		return
+	}
+
+	// Reservoir sampling (Algorithm R), every value has an equal chance to be kept
+	if i := t.rng.Int63n(int64(t.Count)); i < int64(t.MaxValues) {
+		t.Values[i] = s.Value
+		t.jumbled = true
+	}
+}
+
+// IsApproximate returns true if not all values are retained by the sink, so the
+// median and the percentiles are estimated from a random sample of them.
+func (t *TrendSink) IsApproximate() bool {
+	return t.Count > uint64(len(t.Values))
 }
 
 // P calculates the given percentile from sink values.
 func (t *TrendSink) P(pct float64) float64 {
-	switch t.Count {
+	switch len(t.Values) {
 	case 0:
 		return 0
 	case 1:
@@ -122,7 +168,7 @@ func (t *TrendSink) P(pct float64) float64 {
 		// If percentile does not fall on a value in Values slice, we calculate (linear interpolation)
 		// the value that would fall at percentile, given the values above and below that percentile.
 		t.Calc()
-		i := pct * (float64(t.Count) - 1.0)
+		i := pct * (float64(len(t.Values)) - 1.0)
 		j := t.Values[int(math.Floor(i))]
 		k := t.Values[int(math.Ceil(i))]
 		f := i - math.Floor(i)
@@ -139,10 +185,11 @@ func (t *TrendSink) Calc() {
 	t.jumbled = false
 
 	// The median of an even number of values is the average of the middle two.
-	if (t.Count & 0x01) == 0 {
-		t.Med = (t.Values[(t.Count/2)-1] + t.Values[(t.Count/2)]) / 2
+	count := len(t.Values)
+	if (count & 0x01) == 0 {
+		t.Med = (t.Values[(count/2)-1] + t.Values[(count/2)]) / 2
 	} else {
-		t.Med = t.Values[t.Count/2]
+		t.Med = t.Values[count/2]
 	}
 }
 
@@ -173,6 +220,9 @@ func (r *RateSink) Add(s Sample) {
 func (r RateSink) Calc() {}
 
 func (r RateSink) Format(t time.Duration) map[string]float64 {
+	if r.Total == 0 {
+		return map[string]float64{"rate": 0}
+	}
 	return map[string]float64{"rate": float64(r.Trues) / float64(r.Total)}
 }
 
@@ -187,3 +237,60 @@ func (d DummySink) Calc() {}
 func (d DummySink) Format(t time.Duration) map[string]float64 {
 	return map[string]float64(d)
 }
+
+// A SinkFactory creates the sinks of the metrics of a type, instead of the default ones.
+// The sinks of trend metrics should have a P(pct float64) float64 method, which the percentile
+// thresholds use, like TrendSink.
+type SinkFactory func() Sink
+
+// NewSink returns the default sink for the metrics of a type, or nil for an unknown type.
+func NewSink(typ MetricType) Sink {
+	switch typ {
+	case Counter:
+		return &CounterSink{}
+	case Gauge:
+		return &GaugeSink{}
+	case Trend:
+		return &TrendSink{}
+	case Rate:
+		return &RateSink{}
+	default:
+		return nil
+	}
+}
+
+// CloneSink returns a copy of the supplied sink that doesn't share any mutable state with
+// the original, so it can be read while the original continues to receive samples.
+func CloneSink(s Sink) Sink {
+	switch sink := s.(type) {
+	case *CounterSink:
+		c := *sink
+		return &c
+	case *GaugeSink:
+		c := *sink
+		return &c
+	case *TrendSink:
+		c := *sink
+		c.Values = make([]float64, len(sink.Values))
+		copy(c.Values, sink.Values)
+		if sink.rng != nil {
+			// The clone samples on its own, without advancing the generator of the sink, which
+			// would make the sampling of the sink depend on when it was cloned.
+			c.rng = rand.New(rand.NewSource(sink.seed ^ int64(sink.Count))) //nolint:gosec
+		}
+		return &c
+	case *RateSink:
+		c := *sink
+		return &c
+	case DummySink:
+		c := make(DummySink, len(sink))
+		for k, v := range sink {
+			c[k] = v
+		}
+		return c
+	case interface{ Clone() Sink }:
+		return sink.Clone()
+	default:
+		return s
+	}
+}
@@ -59,6 +59,7 @@ func TestCounterSink(t *testing.T) {
 			sink.Add(Sample{Metric: &Metric{}, Value: s, Time: now})
 		}
 		assert.Equal(t, map[string]float64{"count": 145, "rate": 145.0}, sink.Format(1*time.Second))
+		assert.Equal(t, map[string]float64{"count": 145, "rate": 0}, sink.Format(0))
 	})
 }
 
@@ -214,6 +215,37 @@ func TestTrendSink(t *testing.T) {
 	})
 }
 
+func TestReservoirTrendSink(t *testing.T) {
+	fill := func(seed int64) *TrendSink {
+		sink := NewReservoirTrendSink(100, seed)
+		for i := 1; i <= 10000; i++ {
+			sink.Add(Sample{Metric: &Metric{}, Value: float64(i)})
+		}
+		return sink
+	}
+
+	sink := fill(1)
+	assert.Len(t, sink.Values, 100)
+	assert.Equal(t, uint64(10000), sink.Count)
+	assert.Equal(t, 1.0, sink.Min)
+	assert.Equal(t, 10000.0, sink.Max)
+	assert.Equal(t, 5000.5, sink.Avg)
+	assert.True(t, sink.IsApproximate())
+	assert.InDelta(t, 5000, sink.P(0.5), 1500)
+
+	assert.Equal(t, sink.Format(0), fill(1).Format(0))
+
+	t.Run("below size", func(t *testing.T) {
+		sink := NewReservoirTrendSink(100, 1)
+		for i := 1; i <= 10; i++ {
+			sink.Add(Sample{Metric: &Metric{}, Value: float64(i)})
+		}
+		assert.Len(t, sink.Values, 10)
+		assert.False(t, sink.IsApproximate())
+		assert.Equal(t, 5.5, sink.P(0.5))
+	})
+}
+
 func TestRateSink(t *testing.T) {
 	samples6 := []float64{1.0, 0.0, 1.0, 0.0, 0.0, 1.0}
 
@@ -251,6 +283,7 @@ func TestRateSink(t *testing.T) {
 			sink.Add(Sample{Metric: &Metric{}, Value: s})
 		}
 		assert.Equal(t, map[string]float64{"rate": 0.5}, sink.Format(0))
+		assert.Equal(t, map[string]float64{"rate": 0}, RateSink{}.Format(0))
 	})
 }
 
@@ -269,3 +302,42 @@ func TestDummySinkCalcDoesNothing(t *testing.T) {
 func TestDummySinkFormatReturnsItself(t *testing.T) {
 	assert.Equal(t, map[string]float64{"a": 1}, DummySink{"a": 1}.Format(0))
 }
+
+func TestCloneSink(t *testing.T) {
+	trend := &TrendSink{}
+	trend.Add(Sample{Value: 1})
+	trendCopy := CloneSink(trend).(*TrendSink)
+	trend.Add(Sample{Value: 2})
+	assert.Equal(t, []float64{1}, trendCopy.Values)
+	assert.Equal(t, uint64(1), trendCopy.Count)
+
+	counter := &CounterSink{}
+	counter.Add(Sample{Value: 5})
+	counterCopy := CloneSink(counter).(*CounterSink)
+	counter.Add(Sample{Value: 5})
+	assert.Equal(t, 5.0, counterCopy.Value)
+
+	dummy := DummySink{"a": 1}
+	dummyCopy := CloneSink(dummy).(DummySink)
+	dummy["a"] = 2
+	assert.Equal(t, 1.0, dummyCopy["a"])
+
+	t.Run("reservoir", func(t *testing.T) {
+		fill := func(sink *TrendSink, from, to int) {
+			for i := from; i <= to; i++ {
+				sink.Add(Sample{Value: float64(i)})
+			}
+		}
+		sink, cloned := NewReservoirTrendSink(10, 1), NewReservoirTrendSink(10, 1)
+		fill(sink, 1, 100)
+		fill(cloned, 1, 100)
+		reservoirCopy := CloneSink(cloned).(*TrendSink)
+		assert.True(t, cloned.rng != reservoirCopy.rng)
+
+		// Cloning and using the clone doesn't change what the sink samples.
+		fill(reservoirCopy, 101, 1000)
+		fill(sink, 101, 1000)
+		fill(cloned, 101, 1000)
+		assert.Equal(t, sink.Values, cloned.Values)
+	})
+}
@@ -25,6 +25,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -387,6 +388,19 @@ func GetBufferedSamples(input <-chan SampleContainer) (result []SampleContainer)
 	}
 }
 
+// FlattenSampleContainers returns the samples of all of the containers, in order.
+func FlattenSampleContainers(containers []SampleContainer) []Sample {
+	n := 0
+	for _, sc := range containers {
+		n += len(sc.GetSamples())
+	}
+	samples := make([]Sample, 0, n)
+	for _, sc := range containers {
+		samples = append(samples, sc.GetSamples()...)
+	}
+	return samples
+}
+
 // PushIfNotCancelled first checks if the supplied context is cancelled and doesn't push
 // the sample container if it is.
 func PushIfNotCancelled(ctx context.Context, output chan<- SampleContainer, sample SampleContainer) bool {
@@ -416,17 +430,8 @@ func New(name string, typ MetricType, t ...ValueType) *Metric {
 	if len(t) > 0 {
 		vt = t[0]
 	}
-	var sink Sink
-	switch typ {
-	case Counter:
-		sink = &CounterSink{}
-	case Gauge:
-		sink = &GaugeSink{}
-	case Trend:
-		sink = &TrendSink{}
-	case Rate:
-		sink = &RateSink{}
-	default:
+	sink := NewSink(typ)
+	if sink == nil {
 		return nil
 	}
 	return &Metric{Name: name, Type: typ, Contains: vt, Sink: sink}
@@ -439,8 +444,19 @@ var unitMap = map[string][]interface{}{
 }
 
 func (m *Metric) HumanizeValue(v float64, timeUnit string) string {
+	return m.HumanizeValueWithPrecision(v, timeUnit, -1)
+}
+
+// HumanizeValueWithPrecision is like HumanizeValue, but it always shows the given number of
+// decimal places, unless the precision is negative. Times without a time unit are shown in the
+// largest unit that keeps them above 1, and data sizes in SI units.
+func (m *Metric) HumanizeValueWithPrecision(v float64, timeUnit string, precision int) string {
 	switch m.Type {
 	case Rate:
+		if precision >= 0 {
+			scale := math.Pow10(precision)
+			return strconv.FormatFloat(math.Trunc(v*100*scale)/scale, 'f', precision, 64) + "%"
+		}
 		// Truncate instead of round when decreasing precision to 2 decimal places
 		return strconv.FormatFloat(float64(int(v*100*100))/100, 'f', 2, 64) + "%"
 	default:
@@ -449,7 +465,13 @@ func (m *Metric) HumanizeValue(v float64, timeUnit string) string {
 			d := ToD(v)
 
 			if v, ok := unitMap[timeUnit]; ok {
-				return fmt.Sprintf("%.2f%s", float64(d.Nanoseconds())/float64(v[1].(time.Duration)), v[0])
+				if precision < 0 {
+					precision = 2
+				}
+				return fmt.Sprintf("%.*f%s", precision, float64(d.Nanoseconds())/float64(v[1].(time.Duration)), v[0])
+			}
+			if precision >= 0 {
+				return humanizeDurationWithPrecision(d, precision)
 			}
 
 			switch {
@@ -464,13 +486,45 @@ func (m *Metric) HumanizeValue(v float64, timeUnit string) string {
 			}
 			return d.String()
 		case Data:
+			if precision >= 0 {
+				return humanizeBytesWithPrecision(v, precision)
+			}
 			return humanize.Bytes(uint64(v))
 		default:
+			if precision >= 0 {
+				return strconv.FormatFloat(v, 'f', precision, 64)
+			}
 			return humanize.Ftoa(v)
 		}
 	}
 }
 
+func humanizeDurationWithPrecision(d time.Duration, precision int) string {
+	abs := d
+	if abs < 0 {
+		abs = -abs
+	}
+	unit, suffix := time.Nanosecond, "ns"
+	switch {
+	case abs >= time.Second:
+		unit, suffix = time.Second, "s"
+	case abs >= time.Millisecond:
+		unit, suffix = time.Millisecond, "ms"
+	case abs >= time.Microsecond:
+		unit, suffix = time.Microsecond, "µs"
+	}
+	return strconv.FormatFloat(float64(d)/float64(unit), 'f', precision, 64) + suffix
+}
+
+func humanizeBytesWithPrecision(v float64, precision int) string {
+	sizes := []string{"B", "kB", "MB", "GB", "TB", "PB", "EB"}
+	i := 0
+	for ; math.Abs(v) >= 1000 && i < len(sizes)-1; i++ {
+		v /= 1000
+	}
+	return strconv.FormatFloat(v, 'f', precision, 64) + " " + sizes[i]
+}
+
 // A Submetric represents a filtered dataset based on a parent metric.
 type Submetric struct {
 	Name   string      `json:"name"`
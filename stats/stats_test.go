@@ -143,6 +143,32 @@ func TestMetricHumanizeValue(t *testing.T) {
 	}
 }
 
+func TestMetricHumanizeValueWithPrecision(t *testing.T) {
+	testdata := []struct {
+		metric    *Metric
+		value     float64
+		timeUnit  string
+		precision int
+		expected  string
+	}{
+		{New("", Trend, Time), 123.456, "", -1, "123.45ms"},
+		{New("", Trend, Time), 123.456, "", 1, "123.5ms"},
+		{New("", Trend, Time), 1234.5, "", 2, "1.23s"},
+		{New("", Trend, Time), 0.5, "", 0, "500µs"},
+		{New("", Trend, Time), 123.456, "s", 4, "0.1235s"},
+		{New("", Trend, Time), 123.456, "ms", 0, "123ms"},
+		{New("", Rate), 0.98765, "", 1, "98.7%"},
+		{New("", Rate), 0.98765, "", 0, "98%"},
+		{New("", Counter, Data), 1234567, "", 2, "1.23 MB"},
+		{New("", Counter, Data), 999, "", 1, "999.0 B"},
+		{New("", Gauge), 1.23456, "", 3, "1.235"},
+		{New("", Gauge), 2, "", 2, "2.00"},
+	}
+	for _, data := range testdata {
+		assert.Equal(t, data.expected, data.metric.HumanizeValueWithPrecision(data.value, data.timeUnit, data.precision))
+	}
+}
+
 func TestNew(t *testing.T) {
 	t.Parallel()
 	testdata := map[string]struct {
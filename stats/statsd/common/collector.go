@@ -32,7 +32,12 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-var _ lib.Collector = &Collector{}
+var (
+	_ lib.FailingCollector   = &Collector{}
+	_ lib.BufferingCollector = &Collector{}
+	_ lib.FlushingCollector  = &Collector{}
+	_ lib.LabeledCollector   = &Collector{}
+)
 
 // Collector sends result data to statsd daemons with the ability to send to datadog as well
 type Collector struct {
@@ -47,11 +52,14 @@ type Collector struct {
 	startTime  time.Time
 	buffer     []*Sample
 	bufferLock sync.Mutex
+
+	lib.CollectorFailures
+	lib.CollectorLabel
 }
 
 // Init sets up the collector
 func (c *Collector) Init() (err error) {
-	c.logger = log.WithField("type", c.Type)
+	c.logger = c.Logger().WithField("type", c.Type)
 	if address := c.Config.Addr.String; address == "" {
 		err = fmt.Errorf(
 			"connection string is invalid. Received: \"%+s\"",
@@ -90,9 +98,9 @@ func (c *Collector) Run(ctx context.Context) {
 	for {
 		select {
 		case <-ticker.C:
-			c.pushMetrics()
+			_ = c.pushMetrics()
 		case <-ctx.Done():
-			c.pushMetrics()
+			_ = c.pushMetrics()
 			c.finish()
 			return
 		}
@@ -124,11 +132,23 @@ func (c *Collector) Collect(containers []stats.SampleContainer) {
 	}
 }
 
-func (c *Collector) pushMetrics() {
+// BufferedSamples returns the number of samples that weren't sent yet.
+func (c *Collector) BufferedSamples() int {
+	c.bufferLock.Lock()
+	defer c.bufferLock.Unlock()
+	return len(c.buffer)
+}
+
+// Flush sends the buffered samples right away.
+func (c *Collector) Flush() error {
+	return c.pushMetrics()
+}
+
+func (c *Collector) pushMetrics() error {
 	c.bufferLock.Lock()
 	if len(c.buffer) == 0 {
 		c.bufferLock.Unlock()
-		return
+		return nil
 	}
 	buffer := c.buffer
 	c.buffer = nil
@@ -142,7 +162,10 @@ func (c *Collector) pushMetrics() {
 		c.logger.
 			WithError(err).
 			Error("Couldn't commit a batch")
+		c.RecordFailedSamples(err, len(buffer))
+		return err
 	}
+	return nil
 }
 
 func (c *Collector) finish() {
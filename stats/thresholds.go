@@ -22,11 +22,16 @@ package stats
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
 	"time"
 
 	"github.com/dop251/goja"
 	"github.com/loadimpact/k6/lib/types"
 	"github.com/pkg/errors"
+	null "gopkg.in/guregu/null.v3"
 )
 
 const jsEnvSrc = `
@@ -45,6 +50,21 @@ func init() {
 	jsEnv = pgm
 }
 
+// thresholdValueRE matches the left side of a threshold comparison, e.g. "p(95)" in "p(95)<500".
+var thresholdValueRE = regexp.MustCompile(`^\s*(.+?)\s*(?:===|!==|==|!=|<=|>=|<|>)`)
+
+// thresholdTimeUnitRE matches the numbers with a time unit in a threshold, e.g. "500ms" in
+// "p(95)<500ms", which are converted to milliseconds, the unit of the time metrics.
+var thresholdTimeUnitRE = regexp.MustCompile(`(^|[^\w.])(\d+(?:\.\d+)?)(us|µs|ms|s|m|h)\b`)
+
+// thresholdComparedValueRE matches the numbers that the metrics are compared with, along with
+// their unit, if any, e.g. "500" and "ms" in "p(95)<500ms".
+var thresholdComparedValueRE = regexp.MustCompile(`(?:===|!==|==|!=|<=|>=|<|>)\s*-?\d+(?:\.\d+)?(µs|\w*)`)
+
+// thresholdWindowRE matches the sliding window at the end of a threshold, e.g. "1m" in
+// "p(95)<500 over 1m".
+var thresholdWindowRE = regexp.MustCompile(`^(.*?)\s+over\s+(\S+)\s*$`)
+
 // Threshold is a representation of a single threshold for a single metric
 type Threshold struct {
 	// Source is the text based source of the threshold
@@ -56,26 +76,148 @@ type Threshold struct {
 	// AbortGracePeriod is a the minimum amount of time a test should be running before a failing
 	// this threshold will abort the test
 	AbortGracePeriod types.NullDuration
+	// Message is an optional explanation shown when the threshold fails, e.g. a remediation hint
+	Message string
+	// AbortedTest marks if the failure of this threshold is what aborted the test
+	AbortedTest bool
+	// Window is the duration of the sliding window the threshold is evaluated over, e.g. one
+	// minute for "p(95)<500 over 1m", or 0 if it's evaluated over the whole test run
+	Window time.Duration
+
+	// The source without the window and with the time units converted to milliseconds.
+	expression string
+
+	// Whether the metric is compared with plain numbers, or with numbers with a time unit.
+	hasPlainValues bool
+	hasTimeUnits   bool
+
+	pgm      *goja.Program
+	valuePgm *goja.Program
+	rt       *goja.Runtime
+
+	// The partial sinks of the samples of the metric within the window, oldest first, and the
+	// type of the metric, which are only kept for the thresholds evaluated over a window.
+	windowBuckets []windowBucket
+	windowType    *MetricType
+}
+
+// How many time slots the window of a threshold is split into. The samples are aggregated into a
+// partial sink per slot, which is dropped as a whole once the slot has left the window, so the
+// window is only as precise as one slot.
+const thresholdWindowBuckets = 60
 
-	pgm *goja.Program
-	rt  *goja.Runtime
+// windowBucket is the partial sink of the samples of a windowed threshold within a time slot.
+type windowBucket struct {
+	start time.Time
+	sink  Sink
 }
 
 func newThreshold(src string, newThreshold *goja.Runtime, abortOnFail bool, gracePeriod types.NullDuration) (*Threshold, error) {
-	pgm, err := goja.Compile("__threshold__", src, true)
+	code, window := src, time.Duration(0)
+	if m := thresholdWindowRE.FindStringSubmatch(src); m != nil {
+		d, err := time.ParseDuration(m[2])
+		if err != nil || d <= 0 {
+			return nil, errors.Errorf("invalid window '%s', it should be a positive duration, e.g. 1m", m[2])
+		}
+		code, window = m[1], d
+
+		// The thresholds of a metric share the runtime with its whole-run values, so the ones
+		// evaluated over a window need their own.
+		newThreshold = goja.New()
+		if _, err := newThreshold.RunProgram(jsEnv); err != nil {
+			return nil, errors.Wrap(err, "builtin")
+		}
+	}
+
+	var hasPlainValues, hasTimeUnits bool
+	for _, m := range thresholdComparedValueRE.FindAllStringSubmatch(code, -1) {
+		if m[1] == "" {
+			hasPlainValues = true
+		} else {
+			hasTimeUnits = true
+		}
+	}
+
+	code = convertThresholdTimeUnits(code)
+	pgm, err := goja.Compile("__threshold__", code, true)
 	if err != nil {
 		return nil, err
 	}
 
+	// The value a threshold compares against is only informative, so thresholds that aren't
+	// simple comparisons just don't have one.
+	var valuePgm *goja.Program
+	if m := thresholdValueRE.FindStringSubmatch(code); m != nil {
+		valuePgm, _ = goja.Compile("__threshold_value__", m[1], true)
+	}
+
 	return &Threshold{
 		Source:           src,
 		AbortOnFail:      abortOnFail,
 		AbortGracePeriod: gracePeriod,
+		Window:           window,
+		expression:       code,
+		hasPlainValues:   hasPlainValues,
+		hasTimeUnits:     hasTimeUnits,
 		pgm:              pgm,
+		valuePgm:         valuePgm,
 		rt:               newThreshold,
 	}, nil
 }
 
+// convertThresholdTimeUnits replaces the numbers with a time unit in the source of a threshold
+// with the same amount of milliseconds, e.g. "p(95)<1.5s" with "p(95)<1500".
+func convertThresholdTimeUnits(src string) string {
+	return thresholdTimeUnitRE.ReplaceAllStringFunc(src, func(match string) string {
+		m := thresholdTimeUnitRE.FindStringSubmatch(match)
+		d, err := time.ParseDuration(m[2] + m[3])
+		if err != nil {
+			return match
+		}
+		return m[1] + strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64)
+	})
+}
+
+// Expression returns the threshold as the plain JS expression it's evaluated as, i.e. without its
+// window and with the values with a time unit converted to milliseconds, e.g. "p(95)<1500" for
+// "p(95)<1.5s over 1m", for consumers that don't understand the extended syntax.
+func (t Threshold) Expression() string {
+	return t.expression
+}
+
+// Value returns the current value of the left side of the threshold's comparison, e.g. of
+// "p(95)" for "p(95)<500", or an invalid value if it can't be determined.
+func (t Threshold) Value() null.Float {
+	if t.valuePgm == nil {
+		return null.Float{}
+	}
+	v, err := t.rt.RunProgram(t.valuePgm)
+	if err != nil {
+		return null.Float{}
+	}
+	f, ok := v.Export().(float64)
+	if !ok {
+		i, isInt := v.Export().(int64)
+		if !isInt {
+			return null.Float{}
+		}
+		f = float64(i)
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return null.Float{}
+	}
+	return null.FloatFrom(f)
+}
+
+// A ThresholdResult is the outcome of a single evaluation of a threshold.
+type ThresholdResult struct {
+	Time      time.Time  `json:"time"`
+	Metric    string     `json:"metric"`
+	Threshold string     `json:"threshold"`
+	Value     null.Float `json:"value"`
+	Passed    bool       `json:"passed"`
+}
+
 func (t Threshold) runNoTaint() (bool, error) {
 	v, err := t.rt.RunProgram(t.pgm)
 	if err != nil {
@@ -94,6 +236,7 @@ type thresholdConfig struct {
 	Threshold        string             `json:"threshold"`
 	AbortOnFail      bool               `json:"abortOnFail"`
 	AbortGracePeriod types.NullDuration `json:"delayAbortEval"`
+	Message          string             `json:"message,omitempty"`
 }
 
 //used internally for JSON marshalling
@@ -110,7 +253,7 @@ func (tc *thresholdConfig) UnmarshalJSON(data []byte) error {
 }
 
 func (tc thresholdConfig) MarshalJSON() ([]byte, error) {
-	if tc.AbortOnFail {
+	if tc.AbortOnFail || tc.Message != "" {
 		return json.Marshal(rawThresholdConfig(tc))
 	}
 	return json.Marshal(tc.Threshold)
@@ -123,6 +266,25 @@ type Thresholds struct {
 	Abort      bool
 }
 
+// UnitWarnings returns a warning for every threshold whose values look like they're in the
+// wrong unit for a metric with the given value type. Plain numbers are milliseconds for time
+// metrics, which is often not what's meant, and time units make no sense for other metrics.
+func (ts Thresholds) UnitWarnings(contains ValueType) []string {
+	var warnings []string
+	for _, t := range ts.Thresholds {
+		switch {
+		case contains == Time && t.hasPlainValues:
+			warnings = append(warnings, fmt.Sprintf(
+				"The threshold %q compares a time metric with a plain number, which is taken as "+
+					"milliseconds; specify the unit explicitly, e.g. 500ms or 2s", t.Source))
+		case contains != Time && t.hasTimeUnits:
+			warnings = append(warnings, fmt.Sprintf(
+				"The threshold %q has a time unit, but the metric doesn't contain times", t.Source))
+		}
+	}
+	return warnings
+}
+
 // NewThresholds returns Thresholds objects representing the provided source strings
 func NewThresholds(sources []string) (Thresholds, error) {
 	tcs := make([]thresholdConfig, len(sources))
@@ -145,6 +307,7 @@ func newThresholdsWithConfig(configs []thresholdConfig) (Thresholds, error) {
 		if err != nil {
 			return Thresholds{}, errors.Wrapf(err, "%d", i)
 		}
+		t.Message = config.Message
 		ts[i] = t
 	}
 
@@ -160,6 +323,129 @@ func (ts *Thresholds) updateVM(sink Sink, t time.Duration) error {
 	return nil
 }
 
+// AddSample adds a sample of the metric to the thresholds that are evaluated over a window.
+// The samples are aggregated by time slot, and the slots are dropped once they are older than
+// the window of a threshold, as of the newest slot.
+func (ts Thresholds) AddSample(s Sample) {
+	for _, t := range ts.Thresholds {
+		if t.Window > 0 {
+			t.addWindowSample(s)
+		}
+	}
+}
+
+func (t *Threshold) addWindowSample(s Sample) {
+	if t.windowType == nil {
+		if s.Metric == nil {
+			return
+		}
+		typ := s.Metric.Type
+		t.windowType = &typ
+	}
+
+	// The samples mostly arrive in order, so their slot is searched from the newest one.
+	width := t.Window / thresholdWindowBuckets
+	start := s.Time.Truncate(width)
+	i := len(t.windowBuckets)
+	for i > 0 && t.windowBuckets[i-1].start.After(start) {
+		i--
+	}
+	if i == 0 || !t.windowBuckets[i-1].start.Equal(start) {
+		t.windowBuckets = append(t.windowBuckets, windowBucket{})
+		copy(t.windowBuckets[i+1:], t.windowBuckets[i:])
+		t.windowBuckets[i] = windowBucket{start: start, sink: NewSink(*t.windowType)}
+	} else {
+		i--
+	}
+	t.windowBuckets[i].sink.Add(s)
+
+	t.pruneWindow(t.windowBuckets[len(t.windowBuckets)-1].start.Add(width))
+}
+
+// pruneWindow drops the time slots that have entirely left the window of the threshold at the
+// time now.
+func (t *Threshold) pruneWindow(now time.Time) {
+	cutoff := now.Add(-t.Window)
+	width := t.Window / thresholdWindowBuckets
+	i := 0
+	for i < len(t.windowBuckets) && !t.windowBuckets[i].start.Add(width).After(cutoff) {
+		i++
+	}
+	if i == 0 {
+		return
+	}
+	n := copy(t.windowBuckets, t.windowBuckets[i:])
+	for j := n; j < len(t.windowBuckets); j++ {
+		t.windowBuckets[j] = windowBucket{}
+	}
+	t.windowBuckets = t.windowBuckets[:n]
+}
+
+// updateWindowVM drops the time slots that have left the window of the threshold at the time
+// now, and sets the values of the remaining ones in its runtime. Until the threshold has any
+// samples, the sink of the whole test run, which is still empty too, is used instead.
+func (t *Threshold) updateWindowVM(sink Sink, now time.Time, elapsed time.Duration) {
+	t.pruneWindow(now)
+	if t.windowType != nil {
+		sink = NewSink(*t.windowType)
+		for _, b := range t.windowBuckets {
+			mergeSink(sink, b.sink)
+		}
+	}
+
+	// The rates are per second of the window, once the test has run for that long.
+	if elapsed > t.Window {
+		elapsed = t.Window
+	}
+	t.rt.Set("__sink__", sink)
+	for k, v := range sink.Format(elapsed) {
+		t.rt.Set(k, v)
+	}
+}
+
+// mergeSink adds the samples aggregated in a sink to another sink of the same type, as if they had
+// been added to it directly, after the ones it already has.
+func mergeSink(dst, src Sink) {
+	switch dst := dst.(type) {
+	case *CounterSink:
+		src := src.(*CounterSink)
+		dst.Value += src.Value
+		if dst.First.IsZero() {
+			dst.First = src.First
+		}
+	case *GaugeSink:
+		src := src.(*GaugeSink)
+		dst.Value = src.Value
+		if src.Max > dst.Max {
+			dst.Max = src.Max
+		}
+		if src.minSet && (src.Min < dst.Min || !dst.minSet) {
+			dst.Min = src.Min
+			dst.minSet = true
+		}
+	case *TrendSink:
+		src := src.(*TrendSink)
+		if src.Count == 0 {
+			return
+		}
+		if src.Min < dst.Min || dst.Count == 0 {
+			dst.Min = src.Min
+		}
+		if src.Max > dst.Max {
+			dst.Max = src.Max
+		}
+		dst.Count += src.Count
+		dst.Sum += src.Sum
+		dst.Avg = dst.Sum / float64(dst.Count)
+		dst.Values = append(dst.Values, src.Values...)
+		dst.jumbled = true
+	case *RateSink:
+		src := src.(*RateSink)
+		dst.Trues += src.Trues
+		dst.Total += src.Total
+	}
+}
+
 func (ts *Thresholds) runAll(t time.Duration) (bool, error) {
 	succ := true
 	for i, th := range ts.Thresholds {
@@ -176,6 +462,7 @@ func (ts *Thresholds) runAll(t time.Duration) (bool, error) {
 
 			ts.Abort = !th.AbortGracePeriod.Valid ||
 				th.AbortGracePeriod.Duration < types.Duration(t)
+			th.AbortedTest = ts.Abort
 		}
 	}
 	return succ, nil
@@ -187,6 +474,12 @@ func (ts *Thresholds) Run(sink Sink, t time.Duration) (bool, error) {
 	if err := ts.updateVM(sink, t); err != nil {
 		return false, err
 	}
+	now := time.Now()
+	for _, th := range ts.Thresholds {
+		if th.Window > 0 {
+			th.updateWindowVM(sink, now, t)
+		}
+	}
 	return ts.runAll(t)
 }
 
@@ -211,6 +504,7 @@ func (ts Thresholds) MarshalJSON() ([]byte, error) {
 		configs[i].Threshold = t.Source
 		configs[i].AbortOnFail = t.AbortOnFail
 		configs[i].AbortGracePeriod = t.AbortGracePeriod
+		configs[i].Message = t.Message
 	}
 	return json.Marshal(configs)
 }
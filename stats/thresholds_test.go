@@ -28,6 +28,8 @@ import (
 	"github.com/dop251/goja"
 	"github.com/loadimpact/k6/lib/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
 )
 
 func TestNewThreshold(t *testing.T) {
@@ -115,8 +117,8 @@ func TestNewThresholdsWithConfig(t *testing.T) {
 	})
 	t.Run("two", func(t *testing.T) {
 		configs := []thresholdConfig{
-			{`1+1==2`, false, types.NullDuration{}},
-			{`1+1==4`, true, types.NullDuration{}},
+			{`1+1==2`, false, types.NullDuration{}, ""},
+			{`1+1==4`, true, types.NullDuration{}, "hint"},
 		}
 		ts, err := newThresholdsWithConfig(configs)
 		assert.NoError(t, err)
@@ -125,6 +127,7 @@ func TestNewThresholdsWithConfig(t *testing.T) {
 			assert.Equal(t, configs[i].Threshold, th.Source)
 			assert.False(t, th.LastFailed)
 			assert.Equal(t, configs[i].AbortOnFail, th.AbortOnFail)
+			assert.Equal(t, configs[i].Message, th.Message)
 			assert.NotNil(t, th.pgm)
 			assert.Equal(t, ts.Runtime, th.rt)
 		}
@@ -189,6 +192,7 @@ func TestThresholdsRunAll(t *testing.T) {
 			} else {
 				assert.False(t, ts.Abort)
 			}
+			assert.Equal(t, ts.Abort, ts.Thresholds[0].AbortedTest)
 		})
 	}
 }
@@ -216,6 +220,86 @@ func TestThresholdsRun(t *testing.T) {
 	})
 }
 
+func TestThresholdsRunWindow(t *testing.T) {
+	ts, err := NewThresholds([]string{"p(95)<500 over 1m", "max<500"})
+	require.NoError(t, err)
+	windowed, whole := ts.Thresholds[0], ts.Thresholds[1]
+	assert.Equal(t, time.Minute, windowed.Window)
+	assert.Equal(t, "p(95)<500 over 1m", windowed.Source)
+	assert.NotEqual(t, ts.Runtime, windowed.rt)
+	assert.Zero(t, whole.Window)
+
+	metric := New("my_trend", Trend)
+	now := time.Now()
+	add := func(age time.Duration, value float64) {
+		sample := Sample{Metric: metric, Time: now.Add(-age), Value: value}
+		metric.Sink.Add(sample)
+		ts.AddSample(sample)
+	}
+
+	// The slow requests are older than the window, so only the whole-run threshold fails.
+	for i := 0; i < 10; i++ {
+		add(2*time.Minute, 1000)
+	}
+	for i := 0; i < 10; i++ {
+		add(10*time.Second, 100)
+	}
+	b, err := ts.Run(metric.Sink, 3*time.Minute)
+	require.NoError(t, err)
+	assert.False(t, b)
+	assert.False(t, windowed.LastFailed)
+	assert.True(t, whole.LastFailed)
+	require.Len(t, windowed.windowBuckets, 1)
+	assert.Equal(t, uint64(10), windowed.windowBuckets[0].sink.(*TrendSink).Count)
+	assert.Equal(t, null.FloatFrom(100), windowed.Value())
+
+	add(0, 1000)
+	b, err = ts.Run(metric.Sink, 3*time.Minute)
+	require.NoError(t, err)
+	assert.False(t, b)
+	assert.True(t, windowed.LastFailed)
+
+	t.Run("bounded", func(t *testing.T) {
+		ts, err := NewThresholds([]string{"count<1000 over 1m"})
+		require.NoError(t, err)
+		th := ts.Thresholds[0]
+		metric := New("my_counter", Counter)
+		start := time.Now().Truncate(time.Second)
+		for i := 0; i < 10000; i++ {
+			ts.AddSample(Sample{Metric: metric, Time: start.Add(time.Duration(i) * 100 * time.Millisecond), Value: 1})
+		}
+		// The samples of the last minute are kept in slots of a second, even if they're never evaluated.
+		assert.Len(t, th.windowBuckets, thresholdWindowBuckets)
+
+		b, err := ts.Run(metric.Sink, 3*time.Minute)
+		require.NoError(t, err)
+		assert.True(t, b)
+		assert.Equal(t, null.FloatFrom(600), th.Value())
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		samples := []float64{3, -1, 7, 2, 5}
+		for _, typ := range []MetricType{Counter, Gauge, Trend, Rate} {
+			whole, merged := NewSink(typ), NewSink(typ)
+			for i, v := range samples {
+				s := Sample{Time: now.Add(time.Duration(i) * time.Second), Value: v}
+				whole.Add(s)
+				part := NewSink(typ)
+				part.Add(s)
+				mergeSink(merged, part)
+			}
+			assert.Equal(t, whole.Format(time.Second), merged.Format(time.Second), typ.String())
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, src := range []string{"p(95)<500 over 1", "p(95)<500 over -1m", "p(95)<500 over 0s"} {
+			_, err := NewThresholds([]string{src})
+			assert.Error(t, err, src)
+		}
+	})
+}
+
 func TestThresholdsJSON(t *testing.T) {
 	var testdata = []struct {
 		JSON        string
@@ -305,6 +389,19 @@ func TestThresholdsJSON(t *testing.T) {
 		})
 	}
 
+	t.Run("message", func(t *testing.T) {
+		src := `[{"threshold":"1+1==2","abortOnFail":false,"delayAbortEval":null,"message":"check upstream"},"1+1==3"]`
+		var ts Thresholds
+		require.NoError(t, json.Unmarshal([]byte(src), &ts))
+		require.Len(t, ts.Thresholds, 2)
+		assert.Equal(t, "check upstream", ts.Thresholds[0].Message)
+		assert.Empty(t, ts.Thresholds[1].Message)
+
+		data, err := json.Marshal(ts)
+		require.NoError(t, err)
+		assert.Equal(t, src, string(data))
+	})
+
 	t.Run("bad JSON", func(t *testing.T) {
 		var ts Thresholds
 		assert.Error(t, json.Unmarshal([]byte("42"), &ts))
@@ -321,3 +418,76 @@ func TestThresholdsJSON(t *testing.T) {
 		assert.False(t, ts.Abort)
 	})
 }
+
+func TestThresholdValue(t *testing.T) {
+	rt := goja.New()
+	_, err := rt.RunProgram(jsEnv)
+	require.NoError(t, err)
+	rt.Set("__sink__", &TrendSink{Values: []float64{1, 2, 3, 4}})
+	rt.Set("avg", 2.5)
+	rt.Set("count", int64(4))
+
+	testdata := map[string]null.Float{
+		"avg<200":     null.FloatFrom(2.5),
+		" count >= 4": null.FloatFrom(4),
+		"p(50)<=10":   null.FloatFrom(2.5),
+		"avg*2 == 5":  null.FloatFrom(5),
+		"true":        {},
+		"missing<1":   {},
+	}
+	for src, value := range testdata {
+		t.Run(src, func(t *testing.T) {
+			th, err := newThreshold(src, rt, false, types.NullDuration{})
+			require.NoError(t, err)
+			assert.Equal(t, value, th.Value())
+		})
+	}
+}
+
+func TestThresholdTimeUnits(t *testing.T) {
+	rt := goja.New()
+	rt.Set("avg", 1500.0)
+	rt.Set("max", 0.5)
+
+	testdata := map[string]bool{
+		"avg<2s":          true,
+		"avg<1.5s":        false,
+		"avg<=1.5s":       true,
+		"avg>1499999us":   true,
+		"avg<1m && avg>1": true,
+		"max<500µs":       false,
+		"max<=500µs":      true,
+		"avg<1500ms":      false,
+	}
+	for src, passed := range testdata {
+		t.Run(src, func(t *testing.T) {
+			th, err := newThreshold(src, rt, false, types.NullDuration{})
+			require.NoError(t, err)
+			assert.Equal(t, src, th.Source)
+			b, err := th.runNoTaint()
+			require.NoError(t, err)
+			assert.Equal(t, passed, b)
+		})
+	}
+
+	t.Run("Expression", func(t *testing.T) {
+		th, err := newThreshold("p(95)<1.5s && max<=500µs over 1m", rt, false, types.NullDuration{})
+		require.NoError(t, err)
+		assert.Equal(t, "p(95)<1500 && max<=0.5", th.Expression())
+	})
+}
+
+func TestThresholdsUnitWarnings(t *testing.T) {
+	ts, err := NewThresholds([]string{"p(95)<500", "p(99)<1s", "avg<200ms && max<2000"})
+	require.NoError(t, err)
+	warnings := ts.UnitWarnings(Time)
+	require.Len(t, warnings, 2)
+	assert.Contains(t, warnings[0], `"p(95)<500"`)
+	assert.Contains(t, warnings[1], `"avg<200ms && max<2000"`)
+	assert.Len(t, ts.UnitWarnings(Default), 2)
+
+	ts, err = NewThresholds([]string{"rate>0.95", "count<10"})
+	require.NoError(t, err)
+	assert.Empty(t, ts.UnitWarnings(Default))
+	assert.Len(t, ts.UnitWarnings(Time), 2)
+}
@@ -0,0 +1,137 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package tlsconfig contains the TLS configuration shared by the outputs that send their samples
+// to HTTPS endpoints, so that they can all be configured in the same way.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// queryParamPrefix is the prefix of the TLS options given as query parameters of an output URL.
+const queryParamPrefix = "tls_"
+
+// Config is the TLS configuration of an output, e.g. for an endpoint with a certificate signed by
+// an internal CA. It's nested in the configurations of the outputs, so its environment variables
+// are prefixed with the ones of the output, e.g. K6_INFLUXDB_TLS_CA.
+type Config struct {
+	// A PEM file with the certificates of the CAs used to verify the endpoint's certificate,
+	// instead of the ones of the system.
+	CA null.String `json:"ca,omitempty" split_words:"true"`
+
+	// The PEM files with the client certificate and its key, for endpoints that require one.
+	Cert null.String `json:"cert,omitempty" split_words:"true"`
+	Key  null.String `json:"key,omitempty" split_words:"true"`
+
+	// The name used to verify the endpoint's certificate, if it differs from the host name.
+	ServerName null.String `json:"serverName,omitempty" split_words:"true"`
+
+	// Whether the endpoint's certificate isn't verified at all.
+	Insecure null.Bool `json:"insecure,omitempty" split_words:"true"`
+}
+
+// Apply overwrites the fields of the configuration with the ones set in the argument.
+func (c Config) Apply(cfg Config) Config {
+	if cfg.CA.Valid {
+		c.CA = cfg.CA
+	}
+	if cfg.Cert.Valid {
+		c.Cert = cfg.Cert
+	}
+	if cfg.Key.Valid {
+		c.Key = cfg.Key
+	}
+	if cfg.ServerName.Valid {
+		c.ServerName = cfg.ServerName
+	}
+	if cfg.Insecure.Valid {
+		c.Insecure = cfg.Insecure
+	}
+	return c
+}
+
+// ParseQueryParam sets the option of a query parameter of an output URL, e.g. tls_ca or
+// tls_insecure. It returns false if the parameter isn't a TLS option.
+func (c *Config) ParseQueryParam(key, value string) (bool, error) {
+	if !strings.HasPrefix(key, queryParamPrefix) {
+		return false, nil
+	}
+	switch strings.TrimPrefix(key, queryParamPrefix) {
+	case "ca":
+		c.CA = null.StringFrom(value)
+	case "cert":
+		c.Cert = null.StringFrom(value)
+	case "key":
+		c.Key = null.StringFrom(value)
+	case "server_name":
+		c.ServerName = null.StringFrom(value)
+	case "insecure":
+		insecure, err := strconv.ParseBool(value)
+		if err != nil {
+			return true, errors.Errorf("%s must be true or false, not %s", key, value)
+		}
+		c.Insecure = null.BoolFrom(insecure)
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// TLSConfig returns the tls.Config for the configuration, with the CA and client certificates
+// loaded from their files. It returns nil if nothing is configured, so the defaults are used.
+func (c Config) TLSConfig() (*tls.Config, error) {
+	if c == (Config{}) {
+		return nil, nil
+	}
+	if c.Cert.String != "" && c.Key.String == "" || c.Cert.String == "" && c.Key.String != "" {
+		return nil, errors.New("the TLS client certificate and key must be specified together")
+	}
+
+	conf := &tls.Config{
+		ServerName:         c.ServerName.String,
+		InsecureSkipVerify: c.Insecure.Bool,
+	}
+	if c.CA.String != "" {
+		pem, err := ioutil.ReadFile(c.CA.String)
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't read the TLS CA file")
+		}
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no valid certificates in the TLS CA file %s", c.CA.String)
+		}
+	}
+	if c.Cert.String != "" {
+		cert, err := tls.LoadX509KeyPair(c.Cert.String, c.Key.String)
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't load the TLS client certificate")
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
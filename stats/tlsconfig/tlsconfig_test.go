@@ -0,0 +1,115 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package tlsconfig
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestConfigApply(t *testing.T) {
+	c := Config{CA: null.StringFrom("/ca.pem"), Insecure: null.BoolFrom(true)}
+	c = c.Apply(Config{Insecure: null.BoolFrom(false), ServerName: null.StringFrom("example.com")})
+	assert.Equal(t, Config{
+		CA:         null.StringFrom("/ca.pem"),
+		ServerName: null.StringFrom("example.com"),
+		Insecure:   null.BoolFrom(false),
+	}, c)
+}
+
+func TestConfigParseQueryParam(t *testing.T) {
+	c := Config{}
+	for key, value := range map[string]string{
+		"tls_ca": "/ca.pem", "tls_cert": "/cert.pem", "tls_key": "/key.pem",
+		"tls_server_name": "example.com", "tls_insecure": "true",
+	} {
+		ok, err := c.ParseQueryParam(key, value)
+		require.NoError(t, err)
+		assert.True(t, ok, key)
+	}
+	assert.Equal(t, Config{
+		CA:         null.StringFrom("/ca.pem"),
+		Cert:       null.StringFrom("/cert.pem"),
+		Key:        null.StringFrom("/key.pem"),
+		ServerName: null.StringFrom("example.com"),
+		Insecure:   null.BoolFrom(true),
+	}, c)
+
+	for _, key := range []string{"db", "tls_other"} {
+		ok, err := c.ParseQueryParam(key, "1")
+		assert.NoError(t, err)
+		assert.False(t, ok, key)
+	}
+	ok, err := c.ParseQueryParam("tls_insecure", "yes please")
+	assert.True(t, ok)
+	assert.EqualError(t, err, "tls_insecure must be true or false, not yes please")
+}
+
+func TestConfigTLSConfig(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		tlsConfig, err := Config{}.TLSConfig()
+		assert.NoError(t, err)
+		assert.Nil(t, tlsConfig)
+	})
+	t.Run("Insecure", func(t *testing.T) {
+		tlsConfig, err := Config{Insecure: null.BoolFrom(true), ServerName: null.StringFrom("example.com")}.TLSConfig()
+		require.NoError(t, err)
+		assert.True(t, tlsConfig.InsecureSkipVerify)
+		assert.Equal(t, "example.com", tlsConfig.ServerName)
+		assert.Nil(t, tlsConfig.RootCAs)
+	})
+	t.Run("CertWithoutKey", func(t *testing.T) {
+		_, err := Config{Cert: null.StringFrom("/cert.pem")}.TLSConfig()
+		assert.EqualError(t, err, "the TLS client certificate and key must be specified together")
+	})
+	t.Run("CA", func(t *testing.T) {
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer srv.Close()
+
+		dir, err := ioutil.TempDir("", "k6-tlsconfig")
+		require.NoError(t, err)
+		defer func() { _ = os.RemoveAll(dir) }()
+		caFile := filepath.Join(dir, "ca.pem")
+		certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+		require.NoError(t, ioutil.WriteFile(caFile, certPEM, 0644))
+
+		tlsConfig, err := Config{CA: null.StringFrom(caFile)}.TLSConfig()
+		require.NoError(t, err)
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		res, err := client.Get(srv.URL)
+		require.NoError(t, err)
+		_ = res.Body.Close()
+
+		invalidFile := filepath.Join(dir, "invalid.pem")
+		require.NoError(t, ioutil.WriteFile(invalidFile, []byte("not a certificate"), 0644))
+		_, err = Config{CA: null.StringFrom(invalidFile)}.TLSConfig()
+		assert.EqualError(t, err, "no valid certificates in the TLS CA file "+invalidFile)
+	})
+}
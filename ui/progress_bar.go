@@ -35,6 +35,34 @@ type ProgressBar struct {
 	Width       int
 	Progress    float64
 	Left, Right func() string
+
+	// If set, Status is shown instead of the result of Left.
+	Status string
+}
+
+// A ProgressBarOption changes a property of a progress bar, see ProgressBar.Modify().
+type ProgressBarOption func(*ProgressBar)
+
+// WithProgress sets the progress, between 0 and 1, of a progress bar.
+func WithProgress(progress float64) ProgressBarOption {
+	return func(b *ProgressBar) {
+		b.Progress = progress
+	}
+}
+
+// WithStatus sets a transient status, e.g. "draining", shown instead of the left text of a
+// progress bar. An empty status restores the left text.
+func WithStatus(status string) ProgressBarOption {
+	return func(b *ProgressBar) {
+		b.Status = status
+	}
+}
+
+// Modify applies the given options to the progress bar.
+func (b *ProgressBar) Modify(options ...ProgressBarOption) {
+	for _, option := range options {
+		option(b)
+	}
 }
 
 func (b ProgressBar) String() string {
@@ -58,7 +86,9 @@ func (b ProgressBar) String() string {
 	}
 
 	var left, right string
-	if b.Left != nil {
+	if b.Status != "" {
+		left = b.Status + " "
+	} else if b.Left != nil {
 		left = b.Left() + " "
 	}
 	if b.Right != nil {
@@ -0,0 +1,45 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ui
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressBarModify(t *testing.T) {
+	bar := ProgressBar{
+		Width: 12,
+		Left:  func() string { return "running" },
+		Right: func() string { return "1s" },
+	}
+	bar.Modify(WithProgress(1))
+	assert.Equal(t, "running [==========] 1s", bar.String())
+
+	bar.Modify(WithProgress(0.5), WithStatus("draining"))
+	assert.Equal(t, 0.5, bar.Progress)
+	assert.Contains(t, bar.String(), "draining [====>")
+	assert.NotContains(t, bar.String(), "running")
+
+	bar.Modify(WithStatus(""))
+	assert.Contains(t, bar.String(), "running [====>")
+}
@@ -159,10 +159,54 @@ func StrWidth(s string) (n int) {
 
 // SummaryData represents data passed to Summarize.
 type SummaryData struct {
-	Opts    lib.Options
-	Root    *lib.Group
-	Metrics map[string]*stats.Metric
-	Time    time.Duration
+	Opts     lib.Options
+	Root     *lib.Group
+	Metrics  map[string]*stats.Metric
+	Time     time.Duration
+	TestRuns []SummaryTestRun
+	Outputs  []SummaryOutput
+
+	// The hash of the script and of the files it loaded, if it's known.
+	ScriptHash string
+}
+
+// SummaryTestRun is a test run created by an output, e.g. in the cloud.
+type SummaryTestRun struct {
+	Output string `json:"output"`
+	ID     string `json:"id"`
+	URL    string `json:"url,omitempty"`
+}
+
+// SummarizeTestRuns writes the IDs and result URLs of the test runs created by outputs.
+func SummarizeTestRuns(w io.Writer, indent string, testRuns []SummaryTestRun) {
+	for _, run := range testRuns {
+		_, _ = fmt.Fprintf(w, "%stest run: %s (%s)\n", indent, run.ID, run.Output)
+		if run.URL != "" {
+			_, _ = fmt.Fprintf(w, "%s results: %s\n", indent, run.URL)
+		}
+	}
+	if len(testRuns) > 0 {
+		_, _ = fmt.Fprintf(w, "\n")
+	}
+}
+
+// SummaryOutput is what an output contributes to the summary.
+type SummaryOutput struct {
+	Output string   `json:"output"`
+	Lines  []string `json:"lines"`
+}
+
+// SummarizeOutputs writes the lines contributed by the outputs, under their labels.
+func SummarizeOutputs(w io.Writer, indent string, outputs []SummaryOutput) {
+	for _, output := range outputs {
+		_, _ = fmt.Fprintf(w, "%soutput: %s\n", indent, output.Output)
+		for _, line := range output.Lines {
+			_, _ = fmt.Fprintf(w, "%s %s\n", indent, line)
+		}
+	}
+	if len(outputs) > 0 {
+		_, _ = fmt.Fprintf(w, "\n")
+	}
 }
 
 func SummarizeCheck(w io.Writer, indent string, check *lib.Check) {
@@ -182,7 +226,33 @@ func SummarizeCheck(w io.Writer, indent string, check *lib.Check) {
 	}
 }
 
+// SummaryMaxEntries returns the number of sub-groups per group and submetrics per metric that the
+// summary shows, or 0 if it shows all of them, which it does unless summaryMaxEntries is set.
+func SummaryMaxEntries(opts lib.Options) int {
+	return int(opts.SummaryMaxEntries.Int64)
+}
+
+// groupCheckCounts returns the passes and fails of all checks in a group and its sub-groups.
+func groupCheckCounts(group *lib.Group) (passes, fails int64) {
+	for _, check := range group.Checks {
+		passes += check.Passes
+		fails += check.Fails
+	}
+	for _, grp := range group.Groups {
+		p, f := groupCheckCounts(grp)
+		passes += p
+		fails += f
+	}
+	return passes, fails
+}
+
 func SummarizeGroup(w io.Writer, indent string, group *lib.Group) {
+	summarizeGroup(w, indent, group, 0)
+}
+
+// summarizeGroup writes a group like SummarizeGroup, but if max is above 0, only the max sub-groups
+// of each group with the most check results are written, and the rest are folded into one line.
+func summarizeGroup(w io.Writer, indent string, group *lib.Group, max int) {
 	if group.Name != "" {
 		_, _ = fmt.Fprintf(w, "%s%s %s\n\n", indent, GroupPrefix, group.Name)
 		indent = indent + "  "
@@ -203,12 +273,39 @@ func SummarizeGroup(w io.Writer, indent string, group *lib.Group) {
 	for _, grp := range group.Groups {
 		groupNames = append(groupNames, grp.Name)
 	}
+	if max <= 0 || len(groupNames) <= max {
+		for _, name := range groupNames {
+			summarizeGroup(w, indent, group.Groups[name], max)
+		}
+		return
+	}
+
+	results := make(map[string]int64, len(groupNames))
 	for _, name := range groupNames {
-		SummarizeGroup(w, indent, group.Groups[name])
+		passes, fails := groupCheckCounts(group.Groups[name])
+		results[name] = passes + fails
+	}
+	sort.SliceStable(groupNames, func(i, j int) bool {
+		if results[groupNames[i]] != results[groupNames[j]] {
+			return results[groupNames[i]] > results[groupNames[j]]
+		}
+		return groupNames[i] < groupNames[j]
+	})
+	for _, name := range groupNames[:max] {
+		summarizeGroup(w, indent, group.Groups[name], max)
+	}
+
+	var passes, fails int64
+	for _, name := range groupNames[max:] {
+		p, f := groupCheckCounts(group.Groups[name])
+		passes += p
+		fails += f
 	}
+	_, _ = GrayColor.Fprintf(w, "%s%s %d other groups — %s %d / %s %d\n\n",
+		indent, GroupPrefix, len(groupNames)-max, SuccMark, passes, FailMark, fails)
 }
 
-func NonTrendMetricValueForSum(t time.Duration, timeUnit string, m *stats.Metric) (data string, extra []string) {
+func NonTrendMetricValueForSum(t time.Duration, timeUnit string, precision int, m *stats.Metric) (data string, extra []string) {
 	switch sink := m.Sink.(type) {
 	case *stats.CounterSink:
 		value := sink.Value
@@ -216,20 +313,20 @@ func NonTrendMetricValueForSum(t time.Duration, timeUnit string, m *stats.Metric
 		if t > 0 {
 			rate = value / (float64(t) / float64(time.Second))
 		}
-		return m.HumanizeValue(value, timeUnit), []string{m.HumanizeValue(rate, timeUnit) + "/s"}
+		return m.HumanizeValueWithPrecision(value, timeUnit, precision), []string{m.HumanizeValueWithPrecision(rate, timeUnit, precision) + "/s"}
 	case *stats.GaugeSink:
 		value := sink.Value
 		min := sink.Min
 		max := sink.Max
-		return m.HumanizeValue(value, timeUnit), []string{
-			"min=" + m.HumanizeValue(min, timeUnit),
-			"max=" + m.HumanizeValue(max, timeUnit),
+		return m.HumanizeValueWithPrecision(value, timeUnit, precision), []string{
+			"min=" + m.HumanizeValueWithPrecision(min, timeUnit, precision),
+			"max=" + m.HumanizeValueWithPrecision(max, timeUnit, precision),
 		}
 	case *stats.RateSink:
 		value := float64(sink.Trues) / float64(sink.Total)
 		passes := sink.Trues
 		fails := sink.Total - sink.Trues
-		return m.HumanizeValue(value, timeUnit), []string{
+		return m.HumanizeValueWithPrecision(value, timeUnit, precision), []string{
 			"✓ " + strconv.FormatInt(passes, 10),
 			"✗ " + strconv.FormatInt(fails, 10),
 		}
@@ -252,7 +349,75 @@ func IndentForMetric(m *stats.Metric) string {
 	return ""
 }
 
-func SummarizeMetrics(w io.Writer, indent string, t time.Duration, timeUnit string, metrics map[string]*stats.Metric) {
+// metricSampleCount returns the number of samples a metric's sink has seen, as far as the sink
+// keeps track of it. The value of counters is used as their count.
+func metricSampleCount(m *stats.Metric) float64 {
+	switch sink := m.Sink.(type) {
+	case *stats.TrendSink:
+		return float64(sink.Count)
+	case *stats.RateSink:
+		return float64(sink.Total)
+	case *stats.CounterSink:
+		return sink.Value
+	default:
+		return 0
+	}
+}
+
+// limitSubmetrics returns the metrics with at most max submetrics per parent metric, keeping the
+// ones with failed thresholds and then the ones with the most samples, and the number of left out
+// submetrics by parent name. A max of 0 or below keeps all submetrics.
+func limitSubmetrics(metrics map[string]*stats.Metric, max int) (map[string]*stats.Metric, map[string]int) {
+	if max <= 0 {
+		return metrics, nil
+	}
+
+	subs := make(map[string][]*stats.Metric)
+	for _, m := range metrics {
+		if m.Sub.Parent != "" {
+			subs[m.Sub.Parent] = append(subs[m.Sub.Parent], m)
+		}
+	}
+
+	limited := make(map[string]*stats.Metric, len(metrics))
+	for name, m := range metrics {
+		limited[name] = m
+	}
+	others := make(map[string]int)
+	for parent, ms := range subs {
+		if len(ms) <= max {
+			continue
+		}
+		sort.Slice(ms, func(i, j int) bool {
+			iFailed, jFailed := ms[i].Tainted.Bool, ms[j].Tainted.Bool
+			if iFailed != jFailed {
+				return iFailed
+			}
+			if ci, cj := metricSampleCount(ms[i]), metricSampleCount(ms[j]); ci != cj {
+				return ci > cj
+			}
+			return ms[i].Name < ms[j].Name
+		})
+		for _, m := range ms[max:] {
+			delete(limited, m.Name)
+		}
+		others[parent] = len(ms) - max
+	}
+	return limited, others
+}
+
+func SummarizeMetrics(
+	w io.Writer, indent string, t time.Duration, timeUnit string, precision int, metrics map[string]*stats.Metric,
+) {
+	summarizeMetrics(w, indent, t, timeUnit, precision, metrics, nil)
+}
+
+// summarizeMetrics writes the metrics like SummarizeMetrics, followed by a line with the number of
+// left out submetrics after the submetrics of each parent in others.
+func summarizeMetrics(
+	w io.Writer, indent string, t time.Duration, timeUnit string, precision int, metrics map[string]*stats.Metric,
+	others map[string]int,
+) {
 	names := []string{}
 	nameLenMax := 0
 
@@ -277,7 +442,7 @@ func SummarizeMetrics(w io.Writer, indent string, t time.Duration, timeUnit stri
 		if sink, ok := m.Sink.(*stats.TrendSink); ok {
 			cols := make([]string, len(TrendColumns))
 			for i, col := range TrendColumns {
-				value := m.HumanizeValue(col.Get(sink), timeUnit)
+				value := m.HumanizeValueWithPrecision(col.Get(sink), timeUnit, precision)
 				if l := StrWidth(value); l > trendColMaxLens[i] {
 					trendColMaxLens[i] = l
 				}
@@ -287,7 +452,7 @@ func SummarizeMetrics(w io.Writer, indent string, t time.Duration, timeUnit stri
 			continue
 		}
 
-		value, extra := NonTrendMetricValueForSum(t, timeUnit, m)
+		value, extra := NonTrendMetricValueForSum(t, timeUnit, precision, m)
 		values[name] = value
 		if l := StrWidth(value); l > valueMaxLen {
 			valueMaxLen = l
@@ -304,8 +469,18 @@ func SummarizeMetrics(w io.Writer, indent string, t time.Duration, timeUnit stri
 
 	sort.Strings(names)
 	tmpCols := make([]string, len(TrendColumns))
+	parent := ""
+	writeOthers := func() {
+		if n := others[parent]; n > 0 {
+			_, _ = GrayColor.Fprintf(w, "%s    { %d others }\n", indent, n)
+		}
+	}
 	for _, name := range names {
 		m := metrics[name]
+		if m.Sub.Parent != parent {
+			writeOthers()
+			parent = m.Sub.Parent
+		}
 
 		mark := " "
 		markColor := StdColor
@@ -348,12 +523,72 @@ func SummarizeMetrics(w io.Writer, indent string, t time.Duration, timeUnit stri
 		}
 		_, _ = fmt.Fprint(w, indent+fmtIndent+markColor.Sprint(mark)+" "+fmtName+" "+fmtData+"\n")
 	}
+	writeOthers()
+}
+
+// SummarizeLiveMetrics writes the metrics with the given names from a snapshot in the same format as
+// the end-of-test summary, for showing them while the test is still running. Metrics that haven't
+// been observed yet are skipped. It returns the number of written lines.
+func SummarizeLiveMetrics(
+	w io.Writer, indent string, t time.Duration, timeUnit string, precision int, names []string,
+	metrics map[string]*stats.Metric,
+) int {
+	selected := make(map[string]*stats.Metric, len(names))
+	for _, name := range names {
+		if m, ok := metrics[name]; ok {
+			selected[name] = m
+		}
+	}
+	SummarizeMetrics(w, indent, t, timeUnit, precision, selected)
+	return len(selected)
+}
+
+// SummaryPrecision returns the number of decimal places of the summary values, or -1 for the
+// default formatting.
+func SummaryPrecision(opts lib.Options) int {
+	if !opts.SummaryPrecision.Valid {
+		return -1
+	}
+	return int(opts.SummaryPrecision.Int64)
 }
 
 // Summarizes a dataset and returns whether the test run was considered a success.
 func Summarize(w io.Writer, indent string, data SummaryData) {
+	if data.ScriptHash != "" {
+		_, _ = fmt.Fprintf(w, "%sscript hash: %s\n\n", indent+"  ", data.ScriptHash)
+	}
+	SummarizeTestRuns(w, indent+"  ", data.TestRuns)
+	SummarizeOutputs(w, indent+"  ", data.Outputs)
+	maxEntries := SummaryMaxEntries(data.Opts)
 	if data.Root != nil {
-		SummarizeGroup(w, indent+"    ", data.Root)
+		summarizeGroup(w, indent+"    ", data.Root, maxEntries)
+	}
+	metrics, others := limitSubmetrics(data.Metrics, maxEntries)
+	summarizeMetrics(w, indent+"  ", data.Time, data.Opts.SummaryTimeUnit.String, SummaryPrecision(data.Opts), metrics, others)
+	SummarizeThresholdMessages(w, indent+"  ", data.Metrics)
+}
+
+// SummarizeThresholdMessages writes the messages of the failed thresholds that have one, so the
+// summary explains what their failures mean, sorted by metric name.
+func SummarizeThresholdMessages(w io.Writer, indent string, metrics map[string]*stats.Metric) {
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	first := true
+	for _, name := range names {
+		for _, th := range metrics[name].Thresholds.Thresholds {
+			if !th.LastFailed || th.Message == "" {
+				continue
+			}
+			if first {
+				_, _ = fmt.Fprintf(w, "\n")
+				first = false
+			}
+			_, _ = FailColor.Fprintf(w, "%s%s %s: %s\n", indent, FailMark, name, th.Source)
+			_, _ = fmt.Fprintf(w, "%s %s  %s\n", indent, DetailsPrefix, th.Message)
+		}
 	}
-	SummarizeMetrics(w, indent+"  ", data.Time, data.Opts.SummaryTimeUnit.String, data.Metrics)
 }
@@ -0,0 +1,153 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ui
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/pkg/errors"
+)
+
+// ExportedMetric is the machine-readable end-of-test summary of a metric.
+type ExportedMetric struct {
+	Type     stats.MetricType   `json:"type"`
+	Contains stats.ValueType    `json:"contains"`
+	Values   map[string]float64 `json:"values"`
+
+	// The raw values of trend metrics, only included if enabled with the summaryTrendValues
+	// option. If there were more values than the option allows, an evenly spaced subset of
+	// them is included and RawValuesTruncated is true.
+	RawValues          []float64 `json:"rawValues,omitempty"`
+	RawValuesTruncated bool      `json:"rawValuesTruncated,omitempty"`
+
+	// The data needed to merge the summaries of distributed tests, only included if enabled
+	// with the summaryMergeData option.
+	Merge *ExportedMergeData `json:"merge,omitempty"`
+
+	// Whether the thresholds of the metric passed, by their source.
+	Thresholds map[string]ExportedThreshold `json:"thresholds,omitempty"`
+}
+
+// ExportedMergeData is the data of a metric that isn't shown in the summary, but is needed to
+// merge it with the summaries of the other parts of a distributed test.
+type ExportedMergeData struct {
+	// The number of passes and fails of rate metrics.
+	Passes int64 `json:"passes,omitempty"`
+	Fails  int64 `json:"fails,omitempty"`
+
+	// The minimum and maximum values of gauge metrics.
+	Min float64 `json:"min,omitempty"`
+	Max float64 `json:"max,omitempty"`
+
+	// The distribution of the values of trend metrics, as a base64-encoded HDR histogram like
+	// the ones written with --summary-hdr.
+	Histogram string `json:"histogram,omitempty"`
+}
+
+// ExportedThreshold is the machine-readable end-of-test result of a threshold.
+type ExportedThreshold struct {
+	OK bool `json:"ok"`
+}
+
+// ExportedSummary is the machine-readable end-of-test summary, for custom post-processing.
+type ExportedSummary struct {
+	Metrics    map[string]ExportedMetric `json:"metrics"`
+	RootGroup  *lib.Group                `json:"rootGroup"`
+	TestRuns   []SummaryTestRun          `json:"testRuns,omitempty"`
+	Outputs    []SummaryOutput           `json:"outputs,omitempty"`
+	ScriptHash string                    `json:"scriptHash,omitempty"`
+}
+
+// ExportSummary builds the machine-readable end-of-test summary. The names of the trend
+// metrics whose raw values were truncated are returned, so the caller can warn about them.
+// If the summaryMergeData option is enabled, the metrics include the data needed to merge them.
+func ExportSummary(data SummaryData) (summary ExportedSummary, truncated []string, err error) {
+	maxValues := int(data.Opts.SummaryTrendValues.Int64)
+	mergeData := data.Opts.SummaryMergeData.Bool
+	summary = ExportedSummary{
+		Metrics:    make(map[string]ExportedMetric, len(data.Metrics)),
+		RootGroup:  data.Root,
+		TestRuns:   data.TestRuns,
+		Outputs:    data.Outputs,
+		ScriptHash: data.ScriptHash,
+	}
+	for name, m := range data.Metrics {
+		metric := ExportedMetric{Type: m.Type, Contains: m.Contains, Values: m.Sink.Format(data.Time)}
+		switch sink := m.Sink.(type) {
+		case *stats.RateSink:
+			if mergeData {
+				metric.Merge = &ExportedMergeData{Passes: sink.Trues, Fails: sink.Total - sink.Trues}
+			}
+		case *stats.GaugeSink:
+			if mergeData {
+				metric.Merge = &ExportedMergeData{Min: sink.Min, Max: sink.Max}
+			}
+		case *stats.TrendSink:
+			if mergeData {
+				encoded, err := stats.NewTrendHistogram(sink).Encode()
+				if err != nil {
+					return summary, nil, errors.Wrapf(err, "couldn't encode the histogram of %s", name)
+				}
+				metric.Merge = &ExportedMergeData{Histogram: base64.StdEncoding.EncodeToString(encoded)}
+			}
+			if maxValues > 0 {
+				metric.RawValues, metric.RawValuesTruncated = sampleValues(sink.Values, maxValues)
+				if metric.RawValuesTruncated {
+					truncated = append(truncated, name)
+				}
+			}
+		}
+		if len(m.Thresholds.Thresholds) > 0 {
+			metric.Thresholds = make(map[string]ExportedThreshold, len(m.Thresholds.Thresholds))
+			for _, th := range m.Thresholds.Thresholds {
+				metric.Thresholds[th.Source] = ExportedThreshold{OK: !th.LastFailed}
+			}
+		}
+		summary.Metrics[name] = metric
+	}
+	return summary, truncated, nil
+}
+
+// WriteSummaryJSON writes the machine-readable end-of-test summary as JSON.
+func WriteSummaryJSON(w io.Writer, summary ExportedSummary) error {
+	data, err := json.MarshalIndent(summary, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// sampleValues returns a copy of the values, or an evenly spaced subset of max of them.
+func sampleValues(values []float64, max int) ([]float64, bool) {
+	if len(values) <= max {
+		return append([]float64{}, values...), false
+	}
+	result := make([]float64, max)
+	for i := range result {
+		result[i] = values[i*len(values)/max]
+	}
+	return result, true
+}
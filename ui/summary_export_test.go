@@ -0,0 +1,151 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2018 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestExportSummary(t *testing.T) {
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+
+	trend := stats.New("my_trend", stats.Trend, stats.Time)
+	for i := 0; i < 10; i++ {
+		trend.Sink.Add(stats.Sample{Metric: trend, Value: float64(i)})
+	}
+	counter := stats.New("my_counter", stats.Counter)
+	counter.Sink.Add(stats.Sample{Metric: counter, Value: 5})
+	data := SummaryData{
+		Root:    root,
+		Metrics: map[string]*stats.Metric{trend.Name: trend, counter.Name: counter},
+		Time:    time.Second,
+	}
+
+	t.Run("NoRawValues", func(t *testing.T) {
+		summary, truncated, err := ExportSummary(data)
+		require.NoError(t, err)
+		assert.Empty(t, truncated)
+		assert.Nil(t, summary.Metrics["my_trend"].RawValues)
+		assert.Equal(t, 4.5, summary.Metrics["my_trend"].Values["avg"])
+		assert.Equal(t, 5.0, summary.Metrics["my_counter"].Values["count"])
+		assert.Equal(t, root, summary.RootGroup)
+	})
+	t.Run("RawValues", func(t *testing.T) {
+		data := data
+		data.Opts.SummaryTrendValues = null.IntFrom(20)
+		summary, truncated, err := ExportSummary(data)
+		require.NoError(t, err)
+		assert.Empty(t, truncated)
+		assert.Equal(t, []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, summary.Metrics["my_trend"].RawValues)
+		assert.False(t, summary.Metrics["my_trend"].RawValuesTruncated)
+		assert.Nil(t, summary.Metrics["my_counter"].RawValues)
+	})
+	t.Run("Truncated", func(t *testing.T) {
+		data := data
+		data.Opts.SummaryTrendValues = null.IntFrom(5)
+		summary, truncated, err := ExportSummary(data)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"my_trend"}, truncated)
+		assert.Equal(t, []float64{0, 2, 4, 6, 8}, summary.Metrics["my_trend"].RawValues)
+		assert.True(t, summary.Metrics["my_trend"].RawValuesTruncated)
+	})
+	t.Run("JSON", func(t *testing.T) {
+		data := data
+		data.Opts.SummaryTrendValues = null.IntFrom(5)
+		summary, _, err := ExportSummary(data)
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, WriteSummaryJSON(&buf, summary))
+
+		var decoded struct {
+			Metrics map[string]struct {
+				Type               string             `json:"type"`
+				Contains           string             `json:"contains"`
+				Values             map[string]float64 `json:"values"`
+				RawValues          []float64          `json:"rawValues"`
+				RawValuesTruncated bool               `json:"rawValuesTruncated"`
+			} `json:"metrics"`
+		}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+		assert.Equal(t, "trend", decoded.Metrics["my_trend"].Type)
+		assert.Equal(t, "time", decoded.Metrics["my_trend"].Contains)
+		assert.Len(t, decoded.Metrics["my_trend"].RawValues, 5)
+		assert.True(t, decoded.Metrics["my_trend"].RawValuesTruncated)
+		assert.Equal(t, "counter", decoded.Metrics["my_counter"].Type)
+	})
+	t.Run("MergeData", func(t *testing.T) {
+		rate := stats.New("my_rate", stats.Rate)
+		for _, v := range []float64{1, 0, 1} {
+			rate.Sink.Add(stats.Sample{Metric: rate, Value: v})
+		}
+		rate.Thresholds = stats.Thresholds{Thresholds: []*stats.Threshold{
+			{Source: "rate>0.9", LastFailed: true},
+			{Source: "rate>0.5"},
+		}}
+		gauge := stats.New("my_gauge", stats.Gauge)
+		for _, v := range []float64{3, 1, 2} {
+			gauge.Sink.Add(stats.Sample{Metric: gauge, Value: v})
+		}
+		data := data
+		data.Metrics = map[string]*stats.Metric{trend.Name: trend, rate.Name: rate, gauge.Name: gauge}
+
+		summary, _, err := ExportSummary(data)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]float64{"rate": 2.0 / 3}, summary.Metrics["my_rate"].Values)
+		assert.Equal(t, map[string]ExportedThreshold{"rate>0.9": {OK: false}, "rate>0.5": {OK: true}},
+			summary.Metrics["my_rate"].Thresholds)
+		assert.Nil(t, summary.Metrics["my_trend"].Thresholds)
+		for _, metric := range summary.Metrics {
+			assert.Nil(t, metric.Merge)
+		}
+
+		data.Opts.SummaryMergeData = null.BoolFrom(true)
+		summary, _, err = ExportSummary(data)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]float64{"rate": 2.0 / 3}, summary.Metrics["my_rate"].Values)
+		assert.Equal(t, &ExportedMergeData{Passes: 2, Fails: 1}, summary.Metrics["my_rate"].Merge)
+		assert.Equal(t, map[string]float64{"value": 2}, summary.Metrics["my_gauge"].Values)
+		assert.Equal(t, &ExportedMergeData{Min: 1, Max: 3}, summary.Metrics["my_gauge"].Merge)
+		require.NotNil(t, summary.Metrics["my_trend"].Merge)
+		assert.NotEmpty(t, summary.Metrics["my_trend"].Merge.Histogram)
+	})
+	t.Run("ScriptHash", func(t *testing.T) {
+		summary, _, err := ExportSummary(data)
+		require.NoError(t, err)
+		assert.Empty(t, summary.ScriptHash)
+
+		data := data
+		data.ScriptHash = "abc123"
+		summary, _, err = ExportSummary(data)
+		require.NoError(t, err)
+		assert.Equal(t, "abc123", summary.ScriptHash)
+	})
+}
@@ -0,0 +1,72 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ui
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/loadimpact/k6/stats"
+)
+
+// hdrTagReplacer makes the names of the metrics valid tags of the histogram log, which can't
+// have commas or whitespace in them.
+var hdrTagReplacer = strings.NewReplacer(",", "_", " ", "_", "\t", "_", "\n", "_")
+
+// WriteSummaryHDR writes the distributions of the trend metrics of the end-of-test summary as an
+// HdrHistogram log (format version 1.3), with a histogram for every metric, tagged with its name.
+// The values are recorded in thousandths of the unit of the metrics, i.e. in microseconds for
+// time metrics. The histograms are built from the values kept by the sinks, so they are only
+// approximations if the trend values are sampled, e.g. with --trend-reservoir-size.
+func WriteSummaryHDR(w io.Writer, data SummaryData) error {
+	names := make([]string, 0, len(data.Metrics))
+	for name, m := range data.Metrics {
+		if _, ok := m.Sink.(*stats.TrendSink); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	if _, err := fmt.Fprintf(w, "#[Histogram log format version 1.3]\n"+
+		"#[Values are recorded in thousandths of the unit of the metrics]\n"+
+		"\"StartTimestamp\",\"Interval_Length\",\"Interval_Max\",\"Interval_Compressed_Histogram\"\n",
+	); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		h := stats.NewTrendHistogram(data.Metrics[name].Sink.(*stats.TrendSink))
+		encoded, err := h.Encode()
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "Tag=%s,0.000,%.3f,%.3f,%s\n",
+			hdrTagReplacer.Replace(name), data.Time.Seconds(), float64(h.MaxValue())/stats.HDRValueScale,
+			base64.StdEncoding.EncodeToString(encoded),
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
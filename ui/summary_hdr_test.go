@@ -0,0 +1,119 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ui
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeHDR decodes a histogram in the compressed V2 encoding of HdrHistogram.
+func decodeHDR(t *testing.T, encoded string) (header []int64, counts []int64) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	require.NoError(t, err)
+	r := bytes.NewReader(data)
+
+	var cookie, length int32
+	require.NoError(t, binary.Read(r, binary.BigEndian, &cookie))
+	require.NoError(t, binary.Read(r, binary.BigEndian, &length))
+	assert.Equal(t, int32(0x1c849314), cookie)
+	assert.Equal(t, int(length), r.Len())
+
+	zr, err := zlib.NewReader(r)
+	require.NoError(t, err)
+	raw, err := ioutil.ReadAll(zr)
+	require.NoError(t, err)
+	r = bytes.NewReader(raw)
+
+	var fields struct {
+		Cookie, PayloadLength, NormalizingIndexOffset, SignificantDigits int32
+		Lowest, Highest                                                  int64
+		Ratio                                                            float64
+	}
+	require.NoError(t, binary.Read(r, binary.BigEndian, &fields))
+	assert.Equal(t, int32(0x1c849313), fields.Cookie)
+	assert.Equal(t, int(fields.PayloadLength), r.Len())
+	assert.Equal(t, 1.0, fields.Ratio)
+	header = []int64{int64(fields.NormalizingIndexOffset), int64(fields.SignificantDigits), fields.Lowest, fields.Highest}
+
+	for r.Len() > 0 {
+		var u uint64
+		for i := uint(0); ; i += 7 {
+			b, err := r.ReadByte()
+			require.NoError(t, err)
+			u |= uint64(b&0x7f) << i
+			if b < 0x80 {
+				break
+			}
+		}
+		v := int64(u>>1) ^ -int64(u&1)
+		if v < 0 {
+			counts = append(counts, make([]int64, -v)...)
+		} else {
+			counts = append(counts, v)
+		}
+	}
+	return header, counts
+}
+
+func TestWriteSummaryHDR(t *testing.T) {
+	duration := stats.New("http_req_duration{status:200,method:GET}", stats.Trend, stats.Time)
+	for _, v := range []float64{0.5, 0.5, 1, 2.048, 250, 250} {
+		duration.Sink.Add(stats.Sample{Value: v})
+	}
+	data := SummaryData{
+		Metrics: map[string]*stats.Metric{
+			duration.Name: duration,
+			"vus":         stats.New("vus", stats.Gauge),
+		},
+		Time: 10 * time.Second,
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSummaryHDR(&buf, data))
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, "#[Histogram log format version 1.3]", lines[0])
+
+	fields := strings.Split(lines[3], ",")
+	require.Len(t, fields, 5)
+	assert.Equal(t, []string{"Tag=http_req_duration{status:200_method:GET}", "0.000", "10.000", "250.000"}, fields[:4])
+
+	header, counts := decodeHDR(t, fields[4])
+	assert.Equal(t, []int64{0, 3, 1, 250000}, header)
+
+	// The indexes of the counts of 500, 1000, 2048 and 250000 in a histogram of up to 250000.
+	require.Len(t, counts, 9122)
+	expected := map[int]int64{500: 2, 1000: 1, 2048: 1, 9121: 2}
+	for i, count := range counts {
+		assert.Equal(t, expected[i], count, i)
+	}
+}
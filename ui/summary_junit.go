@@ -0,0 +1,112 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ui
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// ansiEscapeRE matches the color escape sequences of the text summary.
+var ansiEscapeRE = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// The JUnit XML format, as understood by most CI systems.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+
+	// The text summary of the metrics, without colors.
+	SystemOut string `xml:"system-out,omitempty"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+}
+
+// WriteSummaryJUnit writes the thresholds of the end-of-test summary as JUnit XML, with a test
+// case for every threshold, so that CI systems can show which of them passed or failed. The
+// summary of the metrics is included as the output of the test suite.
+func WriteSummaryJUnit(w io.Writer, data SummaryData) error {
+	names := make([]string, 0, len(data.Metrics))
+	for name := range data.Metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	suite := junitTestSuite{
+		Name:  "k6 thresholds",
+		Time:  strconv.FormatFloat(data.Time.Seconds(), 'f', 3, 64),
+		Cases: []junitTestCase{},
+	}
+	for _, name := range names {
+		for _, th := range data.Metrics[name].Thresholds.Thresholds {
+			tc := junitTestCase{Name: th.Source, ClassName: name}
+			if th.LastFailed {
+				message := fmt.Sprintf("%s failed", th.Source)
+				if v := th.Value(); v.Valid {
+					message = fmt.Sprintf("%s failed with a value of %g", th.Source, v.Float64)
+				}
+				tc.Failure = &junitFailure{Message: message, Type: "threshold"}
+				suite.Failures++
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+	}
+	suite.Tests = len(suite.Cases)
+
+	var metrics bytes.Buffer
+	SummarizeMetrics(&metrics, "", data.Time, data.Opts.SummaryTimeUnit.String, SummaryPrecision(data.Opts), data.Metrics)
+	suite.SystemOut = ansiEscapeRE.ReplaceAllString(metrics.String(), "")
+
+	suites := junitTestSuites{Tests: suite.Tests, Failures: suite.Failures, Suites: []junitTestSuite{suite}}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,87 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ui
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteSummaryJUnit(t *testing.T) {
+	// The colors of the text summary must be stripped from the XML.
+	defer func(noColor bool) { color.NoColor = noColor }(color.NoColor)
+	color.NoColor = false
+
+	duration := stats.New("http_req_duration", stats.Trend, stats.Time)
+	duration.Sink.Add(stats.Sample{Value: 612.5})
+	ths, err := stats.NewThresholds([]string{"avg<500", "max<1000"})
+	require.NoError(t, err)
+	_, err = ths.Run(duration.Sink, 10*time.Second)
+	require.NoError(t, err)
+	duration.Thresholds = ths
+
+	checks := stats.New("checks", stats.Rate)
+	checks.Sink.Add(stats.Sample{Value: 1})
+	ths, err = stats.NewThresholds([]string{"rate>0.9"})
+	require.NoError(t, err)
+	_, err = ths.Run(checks.Sink, 10*time.Second)
+	require.NoError(t, err)
+	checks.Thresholds = ths
+
+	data := SummaryData{
+		Metrics: map[string]*stats.Metric{
+			"http_req_duration": duration,
+			"checks":            checks,
+			"vus":               stats.New("vus", stats.Gauge),
+		},
+		Time: 10 * time.Second,
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSummaryJUnit(&buf, data))
+	output := buf.String()
+	assert.True(t, strings.HasPrefix(output, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="3" failures="1">
+  <testsuite name="k6 thresholds" tests="3" failures="1" time="10.000">
+    <testcase name="rate&gt;0.9" classname="checks"></testcase>
+    <testcase name="avg&lt;500" classname="http_req_duration">
+      <failure message="avg&lt;500 failed with a value of 612.5" type="threshold"></failure>
+    </testcase>
+    <testcase name="max&lt;1000" classname="http_req_duration"></testcase>
+    <system-out>`), output)
+	assert.True(t, strings.HasSuffix(output, "</system-out>\n  </testsuite>\n</testsuites>\n"), output)
+	assert.Contains(t, output, "http_req_duration...: avg=612.5ms")
+	assert.Contains(t, output, "vus.................: 0")
+	assert.NotContains(t, output, "\x1b")
+
+	var suites junitTestSuites
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &suites))
+	require.Len(t, suites.Suites, 1)
+	assert.Contains(t, suites.Suites[0].SystemOut, "checks..............: 100.00%")
+}
@@ -0,0 +1,268 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ui
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/lib/metrics"
+	"github.com/loadimpact/k6/stats"
+)
+
+// MergeSummaries combines the machine-readable summaries of the parts of a distributed test,
+// which ran at the same time, into the summary of the whole test. The summaries have to include
+// the merge data, see the summaryMergeData option. The counts and rates of counters are summed,
+// and so are the values of the gauges that every part adds to, like vus, while the other gauges
+// keep the largest value. The minimum and maximum of the gauges are merged, rates are
+// recalculated from their summed passes and fails, and the values of trends from their merged
+// histograms, to the precision of the histograms. A threshold fails if it failed in any of the
+// summaries, and the passes and fails of the checks are summed. The trends of summaries without
+// histograms only keep their minimum and maximum, and the raw values of trends are dropped.
+// Those losses are returned as warnings.
+func MergeSummaries(summaries []ExportedSummary) (merged ExportedSummary, warnings []string, err error) {
+	merged = ExportedSummary{Metrics: map[string]ExportedMetric{}}
+	if len(summaries) == 0 {
+		return merged, nil, nil
+	}
+
+	parts := map[string][]ExportedMetric{}
+	for _, summary := range summaries {
+		for name, metric := range summary.Metrics {
+			if len(parts[name]) > 0 && parts[name][0].Type != metric.Type {
+				return merged, nil, fmt.Errorf("the metric %s is a %s in one summary and a %s in another",
+					name, parts[name][0].Type, metric.Type)
+			}
+			parts[name] = append(parts[name], metric)
+		}
+	}
+
+	names := make([]string, 0, len(parts))
+	for name := range parts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		metric, warning, err := mergeMetrics(name, parts[name])
+		if err != nil {
+			return merged, nil, fmt.Errorf("couldn't merge the metric %s: %s", name, err)
+		}
+		if warning != "" {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", name, warning))
+		}
+		merged.Metrics[name] = metric
+	}
+
+	merged.ScriptHash = summaries[0].ScriptHash
+	for _, summary := range summaries {
+		if summary.RootGroup != nil {
+			if merged.RootGroup == nil {
+				merged.RootGroup = &lib.Group{Name: summary.RootGroup.Name, Path: summary.RootGroup.Path,
+					ID: summary.RootGroup.ID}
+			}
+			mergeGroups(merged.RootGroup, summary.RootGroup)
+		}
+		merged.TestRuns = append(merged.TestRuns, summary.TestRuns...)
+		merged.Outputs = append(merged.Outputs, summary.Outputs...)
+		if summary.ScriptHash != merged.ScriptHash {
+			merged.ScriptHash = ""
+		}
+	}
+	if merged.ScriptHash == "" && summaries[0].ScriptHash != "" {
+		warnings = append(warnings, "the summaries are of different scripts")
+	}
+	return merged, warnings, nil
+}
+
+// additiveGauges are the gauges whose values are summed, since every part of a distributed test
+// adds to them.
+var additiveGauges = map[string]bool{metrics.VUs.Name: true, metrics.VUsMax.Name: true} //nolint:gochecknoglobals
+
+// mergeMetrics merges the summaries of a metric, which are all of the same type.
+func mergeMetrics(name string, parts []ExportedMetric) (merged ExportedMetric, warning string, err error) {
+	merged = ExportedMetric{Type: parts[0].Type, Contains: parts[0].Contains, Values: map[string]float64{}}
+	for _, part := range parts {
+		for source, th := range part.Thresholds {
+			if merged.Thresholds == nil {
+				merged.Thresholds = map[string]ExportedThreshold{}
+			}
+			if prev, ok := merged.Thresholds[source]; ok && !prev.OK {
+				continue
+			}
+			merged.Thresholds[source] = th
+		}
+	}
+
+	switch merged.Type {
+	case stats.Counter:
+		for _, part := range parts {
+			for key, v := range part.Values {
+				merged.Values[key] += v
+			}
+		}
+	case stats.Gauge:
+		mergeGauges(&merged, parts, additiveGauges[name])
+	case stats.Rate:
+		merged.Merge = &ExportedMergeData{}
+		for _, part := range parts {
+			if part.Merge == nil {
+				return merged, "", fmt.Errorf("a summary has no passes and fails for it, it has to be " +
+					"exported with --summary-merge-data")
+			}
+			merged.Merge.Passes += part.Merge.Passes
+			merged.Merge.Fails += part.Merge.Fails
+		}
+		merged.Values["rate"] = 0
+		if total := merged.Merge.Passes + merged.Merge.Fails; total > 0 {
+			merged.Values["rate"] = float64(merged.Merge.Passes) / float64(total)
+		}
+	case stats.Trend:
+		warning, err = mergeTrends(&merged, parts)
+	}
+	return merged, warning, err
+}
+
+// mergeGauges sums the values of the gauges if they're additive, or keeps the largest one, and
+// merges their minimum and maximum if all the summaries have them.
+func mergeGauges(merged *ExportedMetric, parts []ExportedMetric, additive bool) {
+	min, max, complete := math.Inf(1), math.Inf(-1), true
+	for i, part := range parts {
+		value := part.Values["value"]
+		if additive {
+			merged.Values["value"] += value
+		} else if i == 0 || value > merged.Values["value"] {
+			merged.Values["value"] = value
+		}
+		if part.Merge == nil {
+			complete = false
+			continue
+		}
+		min = math.Min(min, part.Merge.Min)
+		max = math.Max(max, part.Merge.Max)
+	}
+	if complete {
+		merged.Merge = &ExportedMergeData{Min: min, Max: max}
+	}
+}
+
+// mergeTrends merges the histograms of the summaries of a trend metric and recalculates its
+// values from them, except for the minimum and maximum, which are kept exact.
+func mergeTrends(merged *ExportedMetric, parts []ExportedMetric) (warning string, err error) {
+	min, max := math.Inf(1), math.Inf(-1)
+	var weightedSum float64
+	var h *stats.HDRHistogram
+	for _, part := range parts {
+		if part.Merge == nil || part.Merge.Histogram == "" {
+			h = nil
+			break
+		}
+		data, err := base64.StdEncoding.DecodeString(part.Merge.Histogram)
+		if err != nil {
+			return "", err
+		}
+		ph, err := stats.DecodeHDRHistogram(data)
+		if err != nil {
+			return "", err
+		}
+		weightedSum += part.Values["avg"] * float64(ph.TotalCount())
+		if h == nil {
+			h = stats.NewHDRHistogram(ph.HighestTrackableValue())
+		}
+		if ph.HighestTrackableValue() > h.HighestTrackableValue() {
+			bigger := stats.NewHDRHistogram(ph.HighestTrackableValue())
+			bigger.Merge(h)
+			h = bigger
+		}
+		h.Merge(ph)
+	}
+	for _, part := range parts {
+		min = math.Min(min, part.Values["min"])
+		max = math.Max(max, part.Values["max"])
+	}
+	merged.Values["min"] = min
+	merged.Values["max"] = max
+
+	if h == nil {
+		return "a summary has no histogram for it, so only its min and max were merged", nil
+	}
+
+	count := h.TotalCount()
+	for key := range parts[0].Values {
+		switch {
+		case key == "avg":
+			merged.Values[key] = 0
+			if count > 0 {
+				merged.Values[key] = weightedSum / float64(count)
+			}
+		case key == "med":
+			merged.Values[key] = float64(h.ValueAtPercentile(50)) / stats.HDRValueScale
+		case key == "count":
+			merged.Values[key] = float64(count)
+		case strings.HasPrefix(key, "p(") && strings.HasSuffix(key, ")"):
+			pct, err := strconv.ParseFloat(key[2:len(key)-1], 64)
+			if err != nil {
+				continue
+			}
+			merged.Values[key] = float64(h.ValueAtPercentile(pct)) / stats.HDRValueScale
+		}
+	}
+
+	encoded, err := h.Encode()
+	if err != nil {
+		return "", err
+	}
+	merged.Merge = &ExportedMergeData{Histogram: base64.StdEncoding.EncodeToString(encoded)}
+	return "", nil
+}
+
+// mergeGroups adds the passes and fails of the checks of a group, and of its subgroups, to the
+// ones of the group with the same path.
+func mergeGroups(dst, src *lib.Group) {
+	for name, check := range src.Checks {
+		if dst.Checks == nil {
+			dst.Checks = map[string]*lib.Check{}
+		}
+		if existing, ok := dst.Checks[name]; ok {
+			existing.Passes += check.Passes
+			existing.Fails += check.Fails
+			continue
+		}
+		c := *check
+		c.Group = dst
+		dst.Checks[name] = &c
+	}
+	for name, group := range src.Groups {
+		if dst.Groups == nil {
+			dst.Groups = map[string]*lib.Group{}
+		}
+		existing, ok := dst.Groups[name]
+		if !ok {
+			existing = &lib.Group{Name: group.Name, Path: group.Path, ID: group.ID, Parent: dst}
+			dst.Groups[name] = existing
+		}
+		mergeGroups(existing, group)
+	}
+}
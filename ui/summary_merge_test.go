@@ -0,0 +1,174 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/lib"
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// exportedTestSummary returns the summary of a machine of a distributed test, with the trend
+// values from start to start+99, as it's read back from the JSON export.
+func exportedTestSummary(t *testing.T, start float64, thresholdFailed bool, checkPasses int64) ExportedSummary {
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+	group, err := root.Group("my group")
+	require.NoError(t, err)
+	check, err := group.Check("my check")
+	require.NoError(t, err)
+	check.Passes, check.Fails = checkPasses, 1
+
+	trend := stats.New("my_trend", stats.Trend, stats.Time)
+	for i := 0; i < 100; i++ {
+		trend.Sink.Add(stats.Sample{Value: start + float64(i)})
+	}
+	trend.Thresholds = stats.Thresholds{Thresholds: []*stats.Threshold{
+		{Source: "p(95)<500", LastFailed: thresholdFailed},
+	}}
+	counter := stats.New("my_counter", stats.Counter)
+	counter.Sink.Add(stats.Sample{Value: 10})
+	rate := stats.New("my_rate", stats.Rate)
+	for i := 0; i < 4; i++ {
+		rate.Sink.Add(stats.Sample{Value: start + float64(i) - 1})
+	}
+	vus := stats.New("vus", stats.Gauge)
+	vus.Sink.Add(stats.Sample{Value: 5})
+	gauge := stats.New("my_gauge", stats.Gauge)
+	for _, v := range []float64{start, start + 10, start + 5} {
+		gauge.Sink.Add(stats.Sample{Value: v})
+	}
+
+	data := SummaryData{
+		Root: root,
+		Metrics: map[string]*stats.Metric{
+			trend.Name: trend, counter.Name: counter, rate.Name: rate, vus.Name: vus, gauge.Name: gauge,
+		},
+		Time:       10 * time.Second,
+		TestRuns:   []SummaryTestRun{{Output: "cloud", ID: "run"}},
+		ScriptHash: "abc123",
+	}
+	data.Opts.SummaryMergeData = null.BoolFrom(true)
+	summary, _, err := ExportSummary(data)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSummaryJSON(&buf, summary))
+	var decoded ExportedSummary
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	return decoded
+}
+
+func TestMergeSummaries(t *testing.T) {
+	summaries := []ExportedSummary{
+		exportedTestSummary(t, 1, false, 3),
+		exportedTestSummary(t, 101, true, 4),
+	}
+	merged, warnings, err := MergeSummaries(summaries)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+
+	assert.Equal(t, map[string]float64{"count": 20, "rate": 2}, merged.Metrics["my_counter"].Values)
+	assert.Equal(t, map[string]float64{"value": 10}, merged.Metrics["vus"].Values)
+	assert.Equal(t, &ExportedMergeData{Min: 5, Max: 5}, merged.Metrics["vus"].Merge)
+	// Only the values of the gauges every machine adds to are summed
+	assert.Equal(t, map[string]float64{"value": 106}, merged.Metrics["my_gauge"].Values)
+	assert.Equal(t, &ExportedMergeData{Min: 1, Max: 111}, merged.Metrics["my_gauge"].Merge)
+	// 0 doesn't pass in the first summary, every value passes in the second one
+	assert.Equal(t, map[string]float64{"rate": 0.875}, merged.Metrics["my_rate"].Values)
+	assert.Equal(t, &ExportedMergeData{Passes: 7, Fails: 1}, merged.Metrics["my_rate"].Merge)
+
+	trend := merged.Metrics["my_trend"]
+	assert.Equal(t, stats.Trend, trend.Type)
+	assert.Equal(t, stats.Time, trend.Contains)
+	assert.Equal(t, 1.0, trend.Values["min"])
+	assert.Equal(t, 200.0, trend.Values["max"])
+	assert.InDelta(t, 100.5, trend.Values["avg"], 1e-9)
+	assert.InEpsilon(t, 100, trend.Values["med"], 0.01)
+	assert.InEpsilon(t, 180, trend.Values["p(90)"], 0.01)
+	assert.InEpsilon(t, 190, trend.Values["p(95)"], 0.01)
+	require.NotNil(t, trend.Merge)
+	assert.NotEmpty(t, trend.Merge.Histogram)
+	assert.Equal(t, map[string]ExportedThreshold{"p(95)<500": {OK: false}}, trend.Thresholds)
+
+	check := merged.RootGroup.Groups["my group"].Checks["my check"]
+	assert.Equal(t, int64(7), check.Passes)
+	assert.Equal(t, int64(2), check.Fails)
+	assert.Len(t, merged.TestRuns, 2)
+	assert.Equal(t, "abc123", merged.ScriptHash)
+
+	t.Run("MergedAgain", func(t *testing.T) {
+		again, _, err := MergeSummaries([]ExportedSummary{merged, exportedTestSummary(t, 201, false, 1)})
+		require.NoError(t, err)
+		assert.Equal(t, 300.0, again.Metrics["my_trend"].Values["max"])
+		assert.InEpsilon(t, 150, again.Metrics["my_trend"].Values["med"], 0.01)
+	})
+	t.Run("NoHistogram", func(t *testing.T) {
+		summaries := []ExportedSummary{exportedTestSummary(t, 1, false, 1), exportedTestSummary(t, 101, false, 1)}
+		trend := summaries[1].Metrics["my_trend"]
+		trend.Merge = nil
+		summaries[1].Metrics["my_trend"] = trend
+		summaries[1].ScriptHash = "def456"
+
+		merged, warnings, err := MergeSummaries(summaries)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"my_trend: a summary has no histogram for it, so only its min and max were merged",
+			"the summaries are of different scripts",
+		}, warnings)
+		assert.Equal(t, map[string]float64{"min": 1, "max": 200}, merged.Metrics["my_trend"].Values)
+		assert.Empty(t, merged.ScriptHash)
+	})
+	t.Run("TypeMismatch", func(t *testing.T) {
+		summaries := []ExportedSummary{exportedTestSummary(t, 1, false, 1), exportedTestSummary(t, 101, false, 1)}
+		counter := summaries[1].Metrics["my_counter"]
+		counter.Type = stats.Gauge
+		summaries[1].Metrics["my_counter"] = counter
+
+		_, _, err := MergeSummaries(summaries)
+		assert.EqualError(t, err, `the metric my_counter is a "counter" in one summary and a "gauge" in another`)
+	})
+	t.Run("NoMergeData", func(t *testing.T) {
+		summaries := []ExportedSummary{exportedTestSummary(t, 1, false, 1), exportedTestSummary(t, 101, false, 1)}
+		for name, metric := range summaries[1].Metrics {
+			metric.Merge = nil
+			summaries[1].Metrics[name] = metric
+		}
+
+		_, _, err := MergeSummaries(summaries)
+		assert.EqualError(t, err, "couldn't merge the metric my_rate: a summary has no passes and fails for it, "+
+			"it has to be exported with --summary-merge-data")
+
+		delete(summaries[0].Metrics, "my_rate")
+		delete(summaries[1].Metrics, "my_rate")
+		merged, _, err := MergeSummaries(summaries)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]float64{"value": 106}, merged.Metrics["my_gauge"].Values)
+		assert.Nil(t, merged.Metrics["my_gauge"].Merge)
+	})
+}
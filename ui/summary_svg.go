@@ -0,0 +1,259 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package ui
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loadimpact/k6/stats"
+)
+
+// DefaultSummarySVGMetrics are the trend metrics charted in the SVG summary by default.
+var DefaultSummarySVGMetrics = []string{"http_req_duration", "iteration_duration"}
+
+// The interval in which the TrendSeriesRecorder aggregates the samples, and the maximum number of
+// points charted per metric, for which adjacent intervals are merged in long tests.
+const (
+	trendSeriesInterval  = time.Second
+	svgMaxPoints         = 300
+	svgWidth, svgHeight  = 800, 220
+	svgMarginX, svgTopY  = 70, 30
+	svgPlotW, svgPlotH   = svgWidth - 2*svgMarginX, svgHeight - svgTopY - 40
+	svgGridLines         = 4
+	svgAvgColor          = "#7d64ff"
+	svgMaxColor          = "#ff8b3d"
+	svgThresholdColor    = "#888888"
+	svgFontFamily        = "sans-serif"
+	svgFontSize          = 11
+	svgTitleFontSize     = 13
+	svgChartsGap         = 10
+	svgEmptyChartMessage = "no samples"
+)
+
+// TrendPoint is the aggregate of the samples of a trend metric in one interval.
+type TrendPoint struct {
+	Count    uint64
+	Sum, Max float64
+}
+
+func (p TrendPoint) add(other TrendPoint) TrendPoint {
+	p.Count += other.Count
+	p.Sum += other.Sum
+	if other.Count > 0 && (p.Count == other.Count || other.Max > p.Max) {
+		p.Max = other.Max
+	}
+	return p
+}
+
+// TrendSeries is the values of a trend metric over the test, aggregated in fixed intervals from
+// the start of the test, so they can be charted in the SVG summary.
+type TrendSeries struct {
+	Name     string
+	Interval time.Duration
+	Points   []TrendPoint
+}
+
+// TrendSeriesRecorder aggregates the samples of some trend metrics over time. It's fed with the
+// samples of the test, e.g. by a sample tap, since the metrics of the summary only have totals.
+type TrendSeriesRecorder struct {
+	mutex   sync.Mutex
+	start   time.Time
+	metrics map[string]*TrendSeries
+	names   []string
+	prefix  string
+	now     func() time.Time
+}
+
+// NewTrendSeriesRecorder returns a recorder for the metrics with the given names. The samples
+// are matched by the names of their metrics without the prefix, which the --metric-prefix adds
+// to the metrics of the samples, but not to the ones of the summary.
+func NewTrendSeriesRecorder(names []string, prefix string) *TrendSeriesRecorder {
+	r := &TrendSeriesRecorder{
+		metrics: make(map[string]*TrendSeries, len(names)),
+		names:   names,
+		prefix:  prefix,
+		now:     time.Now,
+	}
+	for _, name := range names {
+		r.metrics[name] = &TrendSeries{Name: name, Interval: trendSeriesInterval}
+	}
+	return r
+}
+
+// Collect adds the samples of the recorded metrics. The time of the first sample is the start.
+// Samples from the future are added to the current interval, so they can't grow the series.
+func (r *TrendSeriesRecorder) Collect(sampleContainers []stats.SampleContainer) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, sc := range sampleContainers {
+		for _, sample := range sc.GetSamples() {
+			if !strings.HasPrefix(sample.Metric.Name, r.prefix) {
+				continue
+			}
+			series, ok := r.metrics[sample.Metric.Name[len(r.prefix):]]
+			if !ok || sample.Metric.Type != stats.Trend {
+				continue
+			}
+			if r.start.IsZero() {
+				r.start = sample.Time
+			}
+			i := 0
+			if d := sample.Time.Sub(r.start); d > 0 {
+				i = int(d / series.Interval)
+			}
+			if current := int(r.now().Sub(r.start) / series.Interval); i > current && current >= 0 {
+				i = current
+			}
+			for len(series.Points) <= i {
+				series.Points = append(series.Points, TrendPoint{})
+			}
+			series.Points[i] = series.Points[i].add(TrendPoint{Count: 1, Sum: sample.Value, Max: sample.Value})
+		}
+	}
+}
+
+// Series returns the recorded series, in the order of the names the recorder was created with.
+func (r *TrendSeriesRecorder) Series() []TrendSeries {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	series := make([]TrendSeries, 0, len(r.names))
+	for _, name := range r.names {
+		s := *r.metrics[name]
+		s.Points = append([]TrendPoint(nil), s.Points...)
+		series = append(series, s)
+	}
+	return series
+}
+
+// downsample merges adjacent points of the series, so it has at most max points.
+func (s TrendSeries) downsample(max int) TrendSeries {
+	if len(s.Points) <= max {
+		return s
+	}
+	factor := (len(s.Points) + max - 1) / max
+	merged := make([]TrendPoint, 0, max)
+	for i := 0; i < len(s.Points); i += factor {
+		var p TrendPoint
+		for j := i; j < i+factor && j < len(s.Points); j++ {
+			p = p.add(s.Points[j])
+		}
+		merged = append(merged, p)
+	}
+	return TrendSeries{Name: s.Name, Interval: s.Interval * time.Duration(factor), Points: merged}
+}
+
+// WriteSummarySVG writes an SVG image with a chart of the average and the maximum of every trend
+// series over time, and the p(95) of the whole test from the summary as a dashed line.
+func WriteSummarySVG(w io.Writer, data SummaryData, series []TrendSeries) error {
+	height := len(series)*(svgHeight+svgChartsGap) + svgChartsGap
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" `+
+		`font-family="%s" font-size="%d">`+"\n", svgWidth, height, svgWidth, height, svgFontFamily, svgFontSize)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	for i, s := range series {
+		writeSVGChart(&b, data, s.downsample(svgMaxPoints), svgChartsGap+i*(svgHeight+svgChartsGap))
+	}
+	b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeSVGChart(b *strings.Builder, data SummaryData, s TrendSeries, top int) {
+	m := data.Metrics[s.Name]
+	if m == nil {
+		m = stats.New(s.Name, stats.Trend)
+	}
+	timeUnit := data.Opts.SummaryTimeUnit.String
+	fmt.Fprintf(b, `<g transform="translate(0,%d)">`+"\n", top)
+	fmt.Fprintf(b, `<text x="%d" y="%d" font-size="%d" font-weight="bold">%s</text>`+"\n",
+		svgMarginX, svgTopY-12, svgTitleFontSize, html.EscapeString(s.Name))
+	fmt.Fprintf(b, `<text x="%d" y="%d" text-anchor="end"><tspan fill="%s">avg</tspan> `+
+		`<tspan fill="%s">max</tspan> <tspan fill="%s">p(95)</tspan></text>`+"\n",
+		svgMarginX+svgPlotW, svgTopY-12, svgAvgColor, svgMaxColor, svgThresholdColor)
+
+	var p95 float64
+	if sink, ok := m.Sink.(*stats.TrendSink); ok && sink.Count > 0 {
+		p95 = sink.P(0.95)
+	}
+	maxY := p95
+	for _, p := range s.Points {
+		maxY = math.Max(maxY, p.Max)
+	}
+	if maxY <= 0 {
+		maxY = 1
+	}
+	y := func(v float64) float64 { return float64(svgTopY+svgPlotH) - v/maxY*svgPlotH }
+	x := func(i int) float64 {
+		if len(s.Points) < 2 {
+			return float64(svgMarginX)
+		}
+		return float64(svgMarginX) + float64(i)/float64(len(s.Points)-1)*svgPlotW
+	}
+
+	// The grid, with the values on the left and the times at the bottom.
+	for i := 0; i <= svgGridLines; i++ {
+		v := maxY * float64(i) / svgGridLines
+		fmt.Fprintf(b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#e5e5e5"/>`+"\n",
+			svgMarginX, y(v), svgMarginX+svgPlotW, y(v))
+		fmt.Fprintf(b, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`+"\n",
+			svgMarginX-6, y(v)+4, html.EscapeString(m.HumanizeValue(v, timeUnit)))
+	}
+	total := s.Interval * time.Duration(len(s.Points))
+	for i, anchor := range []string{"start", "middle", "end"} {
+		fmt.Fprintf(b, `<text x="%d" y="%d" text-anchor="%s">%s</text>`+"\n",
+			svgMarginX+i*svgPlotW/2, svgTopY+svgPlotH+16, anchor, total*time.Duration(i)/2)
+	}
+
+	if len(s.Points) == 0 {
+		fmt.Fprintf(b, `<text x="%d" y="%d" text-anchor="middle" fill="#888">%s</text>`+"\n",
+			svgMarginX+svgPlotW/2, svgTopY+svgPlotH/2, svgEmptyChartMessage)
+		b.WriteString("</g>\n")
+		return
+	}
+
+	if p95 > 0 {
+		fmt.Fprintf(b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="%s" stroke-dasharray="4,3"/>`+"\n",
+			svgMarginX, y(p95), svgMarginX+svgPlotW, y(p95), svgThresholdColor)
+	}
+	for _, line := range []struct {
+		color string
+		value func(TrendPoint) float64
+	}{
+		{svgMaxColor, func(p TrendPoint) float64 { return p.Max }},
+		{svgAvgColor, func(p TrendPoint) float64 { return p.Sum / float64(p.Count) }},
+	} {
+		points := make([]string, 0, len(s.Points))
+		for i, p := range s.Points {
+			if p.Count == 0 {
+				continue
+			}
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x(i), y(line.value(p))))
+		}
+		fmt.Fprintf(b, `<polyline fill="none" stroke="%s" stroke-width="1.5" points="%s"/>`+"\n",
+			line.color, strings.Join(points, " "))
+	}
+	b.WriteString("</g>\n")
+}
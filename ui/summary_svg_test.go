@@ -0,0 +1,109 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package ui
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loadimpact/k6/stats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrendSeriesRecorder(t *testing.T) {
+	duration := stats.New("http_req_duration", stats.Trend, stats.Time)
+	other := stats.New("other_duration", stats.Trend, stats.Time)
+	start := time.Unix(1000, 0)
+	sample := func(m *stats.Metric, d time.Duration, v float64) stats.Sample {
+		return stats.Sample{Metric: m, Time: start.Add(d), Value: v}
+	}
+
+	r := NewTrendSeriesRecorder([]string{"http_req_duration", "iteration_duration"}, "")
+	r.Collect([]stats.SampleContainer{
+		sample(duration, 0, 10),
+		sample(duration, 500*time.Millisecond, 30),
+		sample(other, time.Second, 1000),
+		stats.Samples{sample(duration, 2500*time.Millisecond, 5), sample(duration, -time.Second, 20)},
+	})
+
+	series := r.Series()
+	require.Len(t, series, 2)
+	assert.Equal(t, TrendSeries{
+		Name:     "http_req_duration",
+		Interval: time.Second,
+		Points:   []TrendPoint{{Count: 3, Sum: 60, Max: 30}, {}, {Count: 1, Sum: 5, Max: 5}},
+	}, series[0])
+	assert.Equal(t, "iteration_duration", series[1].Name)
+	assert.Empty(t, series[1].Points)
+
+	t.Run("Prefixed", func(t *testing.T) {
+		prefixed := stats.New("teamA_http_req_duration", stats.Trend, stats.Time)
+		r := NewTrendSeriesRecorder([]string{"http_req_duration"}, "teamA_")
+		r.Collect([]stats.SampleContainer{sample(prefixed, 0, 10), sample(duration, 0, 20)})
+		assert.Equal(t, []TrendPoint{{Count: 1, Sum: 10, Max: 10}}, r.Series()[0].Points)
+	})
+	t.Run("Future", func(t *testing.T) {
+		r := NewTrendSeriesRecorder([]string{"http_req_duration"}, "")
+		r.now = func() time.Time { return start.Add(1500 * time.Millisecond) }
+		r.Collect([]stats.SampleContainer{sample(duration, 0, 10), sample(duration, 24*time.Hour, 20)})
+		assert.Equal(t, []TrendPoint{{Count: 1, Sum: 10, Max: 10}, {Count: 1, Sum: 20, Max: 20}}, r.Series()[0].Points)
+	})
+}
+
+func TestTrendSeriesDownsample(t *testing.T) {
+	s := TrendSeries{Name: "m", Interval: time.Second}
+	for i := 0; i < 10; i++ {
+		s.Points = append(s.Points, TrendPoint{Count: 1, Sum: float64(i), Max: float64(i)})
+	}
+	assert.Equal(t, s, s.downsample(10))
+
+	d := s.downsample(4)
+	assert.Equal(t, 3*time.Second, d.Interval)
+	assert.Equal(t, []TrendPoint{
+		{Count: 3, Sum: 3, Max: 2}, {Count: 3, Sum: 12, Max: 5}, {Count: 3, Sum: 21, Max: 8}, {Count: 1, Sum: 9, Max: 9},
+	}, d.Points)
+}
+
+func TestWriteSummarySVG(t *testing.T) {
+	duration := stats.New("http_req_duration", stats.Trend, stats.Time)
+	for _, v := range []float64{100, 200, 300} {
+		duration.Sink.Add(stats.Sample{Value: v})
+	}
+	data := SummaryData{Metrics: map[string]*stats.Metric{duration.Name: duration}}
+	series := []TrendSeries{
+		{Name: "http_req_duration", Interval: time.Second, Points: []TrendPoint{{Count: 2, Sum: 300, Max: 200}, {}, {Count: 1, Sum: 300, Max: 300}}},
+		{Name: "iteration_duration", Interval: time.Second},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSummarySVG(&buf, data, series))
+	out := buf.String()
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), new(struct{})), out)
+	assert.Equal(t, 2, strings.Count(out, "<g "))
+	assert.Equal(t, 2, strings.Count(out, "<polyline "))
+	assert.Contains(t, out, `stroke-dasharray="4,3"`)
+	assert.Contains(t, out, ">300ms</text>")
+	assert.Contains(t, out, ">3s</text>")
+	assert.Contains(t, out, ">"+svgEmptyChartMessage+"</text>")
+}
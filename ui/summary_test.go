@@ -21,10 +21,16 @@
 package ui
 
 import (
+	"bytes"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/loadimpact/k6/lib"
 	"github.com/loadimpact/k6/stats"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	null "gopkg.in/guregu/null.v3"
 )
 
 var verifyTests = []struct {
@@ -158,3 +164,164 @@ func TestGeneratePercentileTrendColumn(t *testing.T) {
 		assert.Exactly(t, err, ErrPercentileStatInvalidValue)
 	})
 }
+
+func TestSummarizeTestRuns(t *testing.T) {
+	var buf bytes.Buffer
+	SummarizeTestRuns(&buf, "  ", nil)
+	assert.Empty(t, buf.String())
+
+	SummarizeTestRuns(&buf, "  ", []SummaryTestRun{
+		{Output: "cloud", ID: "1234", URL: "https://app.loadimpact.com/k6/runs/1234"},
+		{Output: "custom", ID: "abcd"},
+	})
+	assert.Equal(t, ""+
+		"  test run: 1234 (cloud)\n"+
+		"   results: https://app.loadimpact.com/k6/runs/1234\n"+
+		"  test run: abcd (custom)\n\n",
+		buf.String())
+}
+
+func TestSummarizePrecision(t *testing.T) {
+	duration := stats.New("http_req_duration", stats.Trend, stats.Time)
+	duration.Sink.Add(stats.Sample{Value: 123.456})
+	checks := stats.New("checks", stats.Rate)
+	checks.Sink.Add(stats.Sample{Value: 1})
+	checks.Sink.Add(stats.Sample{Value: 0})
+	checks.Sink.Add(stats.Sample{Value: 0})
+	data := SummaryData{
+		Opts:    lib.Options{SummaryPrecision: null.IntFrom(1)},
+		Metrics: map[string]*stats.Metric{"http_req_duration": duration, "checks": checks},
+		Time:    time.Second,
+	}
+
+	var buf bytes.Buffer
+	Summarize(&buf, "", data)
+	assert.Contains(t, buf.String(), "=123.5ms")
+	assert.Contains(t, buf.String(), "33.3%")
+
+	exported, _, err := ExportSummary(data)
+	require.NoError(t, err)
+	assert.Equal(t, 123.456, exported.Metrics["http_req_duration"].Values["avg"])
+}
+
+func TestSummarizeMaxEntries(t *testing.T) {
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+	for i, name := range []string{"a", "b", "c", "d"} {
+		grp, err := root.Group(name)
+		require.NoError(t, err)
+		check, err := grp.Check("ok")
+		require.NoError(t, err)
+		check.Passes = int64(i + 1)
+		check.Fails = 1
+	}
+
+	duration := stats.New("http_req_duration", stats.Trend, stats.Time)
+	duration.Sink.Add(stats.Sample{Value: 1})
+	metrics := map[string]*stats.Metric{"http_req_duration": duration}
+	for i, suffix := range []string{"url:/a", "url:/b", "url:/c"} {
+		_, sub := stats.NewSubmetric("http_req_duration{" + suffix + "}")
+		m := stats.New("http_req_duration{"+suffix+"}", stats.Trend, stats.Time)
+		m.Sub = *sub
+		for j := 0; j <= i; j++ {
+			m.Sink.Add(stats.Sample{Value: 1})
+		}
+		metrics[m.Name] = m
+	}
+	metrics["http_req_duration{url:/a}"].Tainted = null.BoolFrom(true)
+
+	data := SummaryData{Opts: lib.Options{SummaryMaxEntries: null.IntFrom(2)}, Root: root, Metrics: metrics}
+	var buf bytes.Buffer
+	Summarize(&buf, "", data)
+	out := buf.String()
+	assert.Contains(t, out, GroupPrefix+" d\n")
+	assert.Contains(t, out, GroupPrefix+" c\n")
+	assert.NotContains(t, out, GroupPrefix+" b\n")
+	assert.NotContains(t, out, GroupPrefix+" a\n")
+	assert.Contains(t, out, "2 other groups — "+SuccMark+" 3 / "+FailMark+" 2\n")
+	assert.Contains(t, out, "{ url:/a }")
+	assert.Contains(t, out, "{ url:/c }")
+	assert.NotContains(t, out, "{ url:/b }")
+	assert.Contains(t, out, "    { 1 others }\n")
+
+	buf.Reset()
+	data.Opts.SummaryMaxEntries = null.IntFrom(0)
+	Summarize(&buf, "", data)
+	assert.Contains(t, buf.String(), GroupPrefix+" a\n")
+	assert.Contains(t, buf.String(), "{ url:/b }")
+	assert.NotContains(t, buf.String(), "other")
+
+	// All of them are shown unless the option is set.
+	buf.Reset()
+	data.Opts.SummaryMaxEntries = null.Int{}
+	Summarize(&buf, "", data)
+	assert.Contains(t, buf.String(), GroupPrefix+" a\n")
+	assert.NotContains(t, buf.String(), "other")
+}
+
+func TestSummarizeOutputs(t *testing.T) {
+	var buf bytes.Buffer
+	SummarizeOutputs(&buf, "  ", nil)
+	assert.Empty(t, buf.String())
+
+	SummarizeOutputs(&buf, "  ", []SummaryOutput{
+		{Output: "cloud", Lines: []string{"uploaded 10 samples (1.2 kB) in 1 request"}},
+		{Output: "json", Lines: []string{"written to out.json (20 kB)"}},
+	})
+	assert.Equal(t, ""+
+		"  output: cloud\n"+
+		"   uploaded 10 samples (1.2 kB) in 1 request\n"+
+		"  output: json\n"+
+		"   written to out.json (20 kB)\n\n",
+		buf.String())
+}
+
+func TestSummarizeScriptHash(t *testing.T) {
+	data := SummaryData{Root: &lib.Group{}, Metrics: map[string]*stats.Metric{}}
+
+	var buf bytes.Buffer
+	Summarize(&buf, "", data)
+	assert.NotContains(t, buf.String(), "script hash")
+
+	buf.Reset()
+	data.ScriptHash = "abc123"
+	Summarize(&buf, "", data)
+	assert.True(t, strings.HasPrefix(buf.String(), "  script hash: abc123\n"))
+}
+
+func TestSummarizeThresholdMessages(t *testing.T) {
+	failed := stats.New("http_req_failed", stats.Rate)
+	thresholds, err := stats.NewThresholds([]string{"rate<0.01", "rate<0.5"})
+	require.NoError(t, err)
+	thresholds.Thresholds[0].Message = "error rate too high, check upstream service"
+	thresholds.Thresholds[0].LastFailed = true
+	thresholds.Thresholds[1].Message = "passed, so not shown"
+	failed.Thresholds = thresholds
+
+	var buf bytes.Buffer
+	SummarizeThresholdMessages(&buf, "  ", map[string]*stats.Metric{"vus": stats.New("vus", stats.Gauge)})
+	assert.Empty(t, buf.String())
+
+	SummarizeThresholdMessages(&buf, "  ", map[string]*stats.Metric{"http_req_failed": failed})
+	assert.Equal(t, 2, strings.Count(buf.String(), "\n")-1)
+	assert.Contains(t, buf.String(), "http_req_failed: rate<0.01")
+	assert.Contains(t, buf.String(), "  ↳  error rate too high, check upstream service\n")
+	assert.NotContains(t, buf.String(), "not shown")
+}
+
+func TestSummarizeLiveMetrics(t *testing.T) {
+	reqs := stats.New("http_reqs", stats.Counter)
+	reqs.Sink.Add(stats.Sample{Value: 10})
+	vus := stats.New("vus", stats.Gauge)
+	vus.Sink.Add(stats.Sample{Value: 5})
+	metrics := map[string]*stats.Metric{"http_reqs": reqs, "vus": vus, "data_sent": stats.New("data_sent", stats.Counter)}
+
+	var buf bytes.Buffer
+	lines := SummarizeLiveMetrics(&buf, "  ", 2*time.Second, "", -1, []string{"vus", "http_reqs", "iterations"}, metrics)
+	assert.Equal(t, 2, lines)
+	assert.Equal(t, lines, strings.Count(buf.String(), "\n"))
+	assert.Contains(t, buf.String(), "http_reqs")
+	assert.Contains(t, buf.String(), "5/s")
+	assert.Contains(t, buf.String(), "vus")
+	assert.NotContains(t, buf.String(), "data_sent")
+}